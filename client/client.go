@@ -2,15 +2,20 @@ package client
 
 import (
 	"context"
-	"github.com/ethereum/go-ethereum/accounts/abi"
+	"fmt"
+	"github.com/toolglobal/api/config"
+	"github.com/toolglobal/api/database"
 	"github.com/toolglobal/api/datamanager"
+	"github.com/toolglobal/api/libs/bus"
 	"github.com/toolglobal/api/libs/log"
 	"go.uber.org/zap"
-	"strings"
 	"time"
 )
 
-const abijson = `[{"anonymous":false,"inputs":[{"indexed":true,"name":"from","type":"address"},{"indexed":true,"name":"to","type":"address"},{"indexed":false,"name":"value","type":"uint256"}],"name":"Transfer","type":"event"},{"anonymous":false,"inputs":[{"indexed":true,"name":"dst","type":"address"},{"indexed":false,"name":"wad","type":"uint256"}],"name":"Deposit","type":"event"},{"anonymous":false,"inputs":[{"indexed":true,"name":"src","type":"address"},{"indexed":false,"name":"wad","type":"uint256"}],"name":"Withdrawal","type":"event"}]`
+const (
+	// IndexerVersion 解析器版本，解析结果发生变化时递增，记录在sync_state中
+	IndexerVersion = 7
+)
 
 type Client struct {
 	currentHeight int64
@@ -19,34 +24,43 @@ type Client struct {
 	dataMgr       *datamanager.DataManager
 	version       int
 	tokenMgr      *TokenMgr
-	abi           abi.ABI
+	events        *EventRegistry // 合约事件解析
+	txs           *TxRegistry    // 交易解析
+	workers       int            // 并发拉取区块的协程数
+	batchSize     int            // 每轮预取的区块数
+	tipDistance   int            // 距离最新高度小于该值时逐块提交
+	newBlock      chan struct{}
+	subscribed    int32    // 新区块订阅是否正常
+	bus           *bus.Bus // 入库后发布区块
 }
 
-func NewClient(ctx context.Context, tgsBaseURL, chainId string, version int, rpcRemote string, mgr *datamanager.DataManager, startHeight int64) (*Client, error) {
+// NewClient fetch为拉取区块的节点，见NewFetch、NewMultiFetcher
+func NewClient(ctx context.Context, tgsBaseURL, chainId string, version int, fetch Fetcher, mgr *datamanager.DataManager, startHeight int64, syncCfg config.Sync) (*Client, error) {
+	newTxRegistry, ok := txRegistries[version]
+	if !ok {
+		return nil, fmt.Errorf("unsupported version %d", version)
+	}
 	cli := &Client{
-		ctx:      ctx,
-		fetch:    Fetcher(NewFetch(rpcRemote)),
-		dataMgr:  mgr,
-		version:  version,
-		tokenMgr: NewTokenMgr(tgsBaseURL, chainId),
+		ctx:         ctx,
+		fetch:       fetch,
+		dataMgr:     mgr,
+		version:     version,
+		tokenMgr:    NewTokenMgr(tgsBaseURL, chainId),
+		workers:     syncCfg.Workers,
+		batchSize:   syncCfg.BatchSize,
+		tipDistance: syncCfg.TipDistance,
+		events:      DefaultEventRegistry(),
+		txs:         newTxRegistry(),
+		newBlock:    make(chan struct{}, 1),
 	}
-
-	{
-		abi, err := abi.JSON(strings.NewReader(abijson))
-		if err != nil {
-			panic(err)
-		}
-		cli.abi = abi
+	if err := cli.txs.Activate(syncCfg.TxTags); err != nil {
+		return nil, err
 	}
 
 	cli.tokenMgr.Start()
 
 	// 获取库里最新的height
-	var (
-		height int64
-		err    error
-	)
-	height, err = cli.GetCurrentHeightV3()
+	height, err := cli.resumeHeight()
 	if err != nil {
 		return nil, err
 	}
@@ -63,6 +77,10 @@ func NewClient(ctx context.Context, tgsBaseURL, chainId string, version int, rpc
 
 // Start 保存获取到的数据，手动开启
 func (cli *Client) Start() {
+	if sub, ok := cli.fetch.(Subscriber); ok {
+		go cli.subscribe(sub)
+	}
+
 	for {
 		select {
 		case <-cli.ctx.Done():
@@ -75,23 +93,14 @@ func (cli *Client) Start() {
 				continue
 			}
 
-			if cli.currentHeight > lastBlockHeight {
-				time.Sleep(time.Second)
-				continue
-			}
+			observeHeights(cli.currentHeight-1, lastBlockHeight)
 
-			data, err := cli.GetV3BlockData(cli.currentHeight)
-			if err != nil {
-				log.Logger.Error("GetV3BlockData", zap.Error(err), zap.Int64("height", cli.currentHeight))
-				continue
-			}
-			if err := cli.SaveV3Data(data); err != nil {
-				log.Logger.Error("SaveV3Data", zap.Error(err))
+			if cli.currentHeight > lastBlockHeight {
+				cli.waitNewBlock()
 				continue
 			}
 
-			log.Logger.Info("fetch ok", zap.Int64("height", cli.currentHeight), zap.Int("version", cli.version))
-			cli.currentHeight++
+			cli.sync(lastBlockHeight)
 		}
 	}
 }
@@ -100,10 +109,27 @@ func (cli *Client) LastBlockHeight() (height int64, err error) {
 	return cli.fetch.LastBlockHeight()
 }
 
+// resumeHeight 优先从sync_state恢复同步进度，没有记录时（旧版本的库）根据v3_ledgers推断
+func (cli *Client) resumeHeight() (int64, error) {
+	state, err := cli.dataMgr.QuerySyncState(database.SyncStateV3)
+	if err != nil {
+		return 0, err
+	}
+	if state == nil {
+		return cli.GetCurrentHeightV3()
+	}
+
+	if state.IndexerVersion != IndexerVersion {
+		log.Logger.Warn("indexed by another indexer version, consider reindex",
+			zap.Int("indexed", state.IndexerVersion), zap.Int("current", IndexerVersion), zap.Int64("height", state.Height))
+	}
+	return state.Height + 1, nil
+}
+
 func (cli *Client) GetCurrentHeightV3() (int64, error) {
 	var height int64
 
-	result, err := cli.dataMgr.QueryV3AllLedger(0, 0, 0, 1, "DESC")
+	result, err := cli.dataMgr.QueryV3AllLedger(0, 0, database.MakePaging("id", 0, 1), "DESC")
 	if err != nil {
 		return height, err
 	}
@@ -117,51 +143,130 @@ func (cli *Client) GetCurrentHeightV3() (int64, error) {
 	return height, nil
 }
 
-func (cli Client) SaveV3Data(data *V3BlockData) error {
-	err := cli.dataMgr.QTxBegin()
+// isForked 检查区块的父hash是否与库中上一高度的blockHash一致
+func (cli *Client) isForked(data *V3BlockData) (bool, error) {
+	prev, err := cli.dataMgr.QueryV3Ledger(data.ledger.Height - 1)
 	if err != nil {
-		return err
+		return false, err
 	}
-	defer func() {
-		if err != nil {
-			if err := cli.dataMgr.QTxRollback(); err != nil {
-				log.Logger.Error("client insert rollback", zap.Error(err))
-			}
-		}
-	}()
-	// 区块
-	_, err = cli.dataMgr.AddV3Ledger(data.ledger)
+	// 库中没有上一高度（首个区块或从startHeight开始），无法校验
+	if prev == nil {
+		return false, nil
+	}
+	return prev.BlockHash != data.parentHash, nil
+}
+
+// reorg 从height开始向前查找与节点一致的共同祖先，回滚其后的数据并从祖先的下一高度重新索引
+func (cli *Client) reorg(height int64) error {
+	ancestor, err := cli.findCommonAncestor(height)
 	if err != nil {
 		return err
 	}
-	txStmt, err := cli.dataMgr.PrepareV3Transaction()
-	if err != nil {
+
+	log.Logger.Warn("chain fork detected, rollback", zap.Int64("height", height+1), zap.Int64("ancestor", ancestor))
+	if err := cli.dataMgr.RollbackV3(ancestor); err != nil {
 		return err
 	}
-	defer txStmt.Close()
-	paymentStmt, err := cli.dataMgr.PrepareV3Payment()
+	cli.currentHeight = ancestor + 1
+	cli.bus.Publish(&database.RollbackEvent{Height: ancestor})
+	return nil
+}
+
+func (cli *Client) findCommonAncestor(height int64) (int64, error) {
+	for ; height > 0; height-- {
+		ledger, err := cli.dataMgr.QueryV3Ledger(height)
+		if err != nil {
+			return 0, err
+		}
+		if ledger == nil {
+			return height, nil
+		}
+
+		block, err := cli.fetch.FetchBlockInfo(height)
+		if err != nil {
+			return 0, err
+		}
+		if block.Block.Hash().String() == ledger.BlockHash {
+			return height, nil
+		}
+	}
+	return 0, nil
+}
+
+func (cli *Client) SaveV3Data(data *V3BlockData) error {
+	return cli.SaveV3Batch([]*V3BlockData{data})
+}
+
+// SaveV3Batch 在一个数据库事务中保存多个区块，要么全部写入，要么全部不写入
+func (cli *Client) SaveV3Batch(datas []*V3BlockData) (err error) {
+	batch, err := cli.dataMgr.BeginV3Batch()
 	if err != nil {
 		return err
 	}
-	defer paymentStmt.Close()
-	// 交易
-	for _, tx := range data.txs {
-		err = cli.dataMgr.AddV3TransactionStmt(txStmt, &tx)
+	defer func() {
 		if err != nil {
+			if err := batch.Rollback(); err != nil {
+				log.Logger.Error("client insert rollback", zap.Error(err))
+			}
+		}
+	}()
+
+	for _, data := range datas {
+		// 区块
+		if err = batch.AddLedger(data.ledger); err != nil {
 			return err
 		}
+		// 交易
+		for i := range data.txs {
+			if err = batch.AddTransaction(&data.txs[i]); err != nil {
+				return err
+			}
+		}
+		for i := range data.payments {
+			if err = batch.AddPayment(&data.payments[i]); err != nil {
+				return err
+			}
+		}
+		for i := range data.nftTransfers {
+			if err = batch.AddNFTTransfer(&data.nftTransfers[i]); err != nil {
+				return err
+			}
+		}
+		for i := range data.approvals {
+			if err = batch.AddApproval(&data.approvals[i]); err != nil {
+				return err
+			}
+		}
+		for i := range data.contracts {
+			if err = batch.AddContract(&data.contracts[i]); err != nil {
+				return err
+			}
+		}
+		for i := range data.logs {
+			if err = batch.AddLog(&data.logs[i]); err != nil {
+				return err
+			}
+		}
+		for i := range data.rawTxs {
+			if err = batch.AddRawTx(&data.rawTxs[i]); err != nil {
+				return err
+			}
+		}
 	}
 
-	for _, payment := range data.payments {
-		err = cli.dataMgr.AddV3PaymentStmt(paymentStmt, &payment)
-		if err != nil {
+	if len(datas) > 0 {
+		last := datas[len(datas)-1].ledger
+		if err = batch.SetSyncState(database.SyncStateV3, last.Height, last.BlockHash, IndexerVersion); err != nil {
 			return err
 		}
 	}
 
-	if err := cli.dataMgr.QTxCommit(); err != nil {
+	if err = batch.Commit(); err != nil {
 		return err
 	}
 
+	for _, data := range datas {
+		cli.bus.Publish(data.blockEvent())
+	}
 	return nil
 }
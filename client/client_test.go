@@ -2,37 +2,293 @@ package client
 
 import (
 	"context"
-	"github.com/toolglobal/api/config"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	abcitypes "github.com/tendermint/tendermint/abci/types"
+	tmtypes "github.com/tendermint/tendermint/types"
+	"github.com/toolglobal/api/config"
 	"github.com/toolglobal/api/database"
-	"github.com/toolglobal/api/database/basesql"
-	"github.com/toolglobal/api/datamanager"
-	"github.com/toolglobal/api/libs/log"
+	"github.com/toolglobal/api/datamanager/datamanagertest"
+	"github.com/toolglobal/api/libs/bus"
 )
 
-var dataMgr *datamanager.DataManager
+// testRPC 需要真实节点的测试通过环境变量MONDO_RPC指定节点，例如 http://127.0.0.1:26657
+func testRPC(t *testing.T) string {
+	rpc := os.Getenv("MONDO_RPC")
+	if rpc == "" {
+		t.Skip("MONDO_RPC not set")
+	}
+	return rpc
+}
+
+// memFetcher 内存中的链，用于测试
+type memFetcher struct {
+	mu     sync.Mutex
+	blocks map[int64]*Block
+	last   int64
+	notify chan int64 // 不为空时支持订阅新区块
+}
+
+func newMemFetcher() *memFetcher {
+	return &memFetcher{blocks: make(map[int64]*Block)}
+}
+
+// extend 在height之后生成n个空区块，seed用于区分不同分叉
+func (f *memFetcher) extend(height int64, n int, seed string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var lastID tmtypes.BlockID
+	if prev, ok := f.blocks[height]; ok {
+		lastID = prev.BlockID
+	}
+	for i := 0; i < n; i++ {
+		h := height + int64(i) + 1
+		block := tmtypes.MakeBlock(h, nil, &tmtypes.Commit{}, nil)
+		block.ChainID = "test"
+		block.Time = time.Unix(1600000000+h, 0)
+		block.LastBlockID = lastID
+		block.ValidatorsHash = []byte(fmt.Sprintf("%s-%d", seed, h))
+		lastID = tmtypes.BlockID{Hash: block.Hash()}
+		f.blocks[h] = &Block{BlockID: lastID, Block: block}
+	}
+	f.last = height + int64(n)
+}
+
+func (f *memFetcher) LastBlockHeight() (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.last, nil
+}
+
+func (f *memFetcher) FetchBlockInfo(height int64) (*Block, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	block, ok := f.blocks[height]
+	if !ok || height > f.last {
+		return nil, fmt.Errorf("block %d not found", height)
+	}
+	return block, nil
+}
+
+func (f *memFetcher) FetchBlockResultInfo(height int64) ([]*abcitypes.ResponseDeliverTx, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.blocks[height]; !ok || height > f.last {
+		return nil, fmt.Errorf("block %d not found", height)
+	}
+	return nil, nil
+}
 
-func init() {
-	dataM, err := datamanager.NewDataManager("mondo_query.db", func(dbname string) database.Database {
-		dbi := &basesql.Basesql{}
-		err := dbi.Init(dbname, "", log.Logger)
+func (f *memFetcher) SubscribeNewBlock(ctx context.Context) (<-chan int64, error) {
+	if f.notify == nil {
+		return nil, errors.New("subscribe not supported")
+	}
+	heights := make(chan int64)
+	go func() {
+		defer close(heights)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case h := <-f.notify:
+				heights <- h
+			}
+		}
+	}()
+	return heights, nil
+}
+
+func newTestClient(t *testing.T, fetch Fetcher) (*Client, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cli := &Client{
+		ctx:           ctx,
+		fetch:         fetch,
+		dataMgr:       datamanagertest.New(t),
+		version:       3,
+		events:        DefaultEventRegistry(),
+		txs:           DefaultTxRegistry(),
+		tokenMgr:      NewTokenMgr("", ""),
+		currentHeight: 1,
+		workers:       4,
+		batchSize:     3,
+		newBlock:      make(chan struct{}, 1),
+	}
+	return cli, cancel
+}
+
+// syncTo 同步到target高度，包括分叉回滚
+func syncTo(t *testing.T, cli *Client, target int64) {
+	for i := 0; cli.currentHeight <= target; i++ {
+		if i > 1000 {
+			t.Fatal("sync does not converge")
+		}
+		cli.sync(target)
+	}
+}
+
+func TestClient_Reorg(t *testing.T) {
+	fetch := newMemFetcher()
+	fetch.extend(0, 10, "a")
+	cli, cancel := newTestClient(t, fetch)
+	defer cancel()
+
+	syncTo(t, cli, 10)
+
+	// 节点从另一快照恢复：高度6之后是另一条链
+	fetch.extend(6, 6, "b")
+	syncTo(t, cli, 12)
+
+	for h := int64(1); h <= 12; h++ {
+		ledger, err := cli.dataMgr.QueryV3Ledger(h)
 		if err != nil {
-			panic(err)
+			t.Fatal(err)
 		}
-		return dbi
-	})
+		if ledger == nil {
+			t.Fatalf("ledger %d missing", h)
+		}
+		if ledger.BlockHash != fetch.blocks[h].Block.Hash().String() {
+			t.Fatalf("ledger %d hash %s, want %s", h, ledger.BlockHash, fetch.blocks[h].Block.Hash())
+		}
+	}
+
+	ledgers, err := cli.dataMgr.QueryV3AllLedger(0, 0, database.MakePaging("id", 0, 200), "DESC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ledgers) != 12 {
+		t.Fatalf("got %d ledgers, want 12", len(ledgers))
+	}
+
+	state, err := cli.dataMgr.QuerySyncState(database.SyncStateV3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state == nil || state.Height != 12 || state.BlockHash != fetch.blocks[12].Block.Hash().String() {
+		t.Fatalf("sync state %+v", state)
+	}
+	if height, err := cli.resumeHeight(); err != nil || height != 13 {
+		t.Fatalf("resume height %d, %v", height, err)
+	}
+
+	// 回滚后同步进度回退到共同祖先
+	if err := cli.dataMgr.RollbackV3(8); err != nil {
+		t.Fatal(err)
+	}
+	state, err = cli.dataMgr.QuerySyncState(database.SyncStateV3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Height != 8 || state.BlockHash != fetch.blocks[8].Block.Hash().String() {
+		t.Fatalf("sync state after rollback %+v", state)
+	}
+}
+
+func TestClient_SharedDatabase(t *testing.T) {
+	fetch := newMemFetcher()
+	fetch.extend(0, 5, "a")
+	cli, cancel := newTestClient(t, fetch)
+	defer cancel()
+	syncTo(t, cli, 5)
+
+	// 第二个同步实例误配置到同一个库：写入已索引的高度失败，从sync_state重新定位
+	other, cancelOther := newTestClient(t, fetch)
+	defer cancelOther()
+	other.dataMgr = cli.dataMgr
+	other.sync(5)
+	if other.currentHeight != 6 {
+		t.Fatalf("current height %d, want 6", other.currentHeight)
+	}
 
+	ledgers, err := cli.dataMgr.QueryV3AllLedger(0, 0, database.MakePaging("id", 0, 200), "DESC")
 	if err != nil {
-		panic(err)
+		t.Fatal(err)
+	}
+	if len(ledgers) != 5 {
+		t.Fatalf("got %d ledgers, want 5", len(ledgers))
+	}
+}
+
+func TestClient_PublishEvents(t *testing.T) {
+	fetch := newMemFetcher()
+	fetch.extend(0, 3, "a")
+	cli, cancel := newTestClient(t, fetch)
+	defer cancel()
+	b := bus.New()
+	cli.SetBus(b)
+	sub := b.Subscribe(100)
+
+	syncTo(t, cli, 3)
+	// 高度2之后分叉
+	fetch.extend(2, 3, "b")
+	syncTo(t, cli, 5)
+	sub.Unsubscribe()
+
+	var got []string
+	for msg := range sub.C() {
+		switch ev := msg.(type) {
+		case *database.BlockEvent:
+			got = append(got, fmt.Sprintf("block %d", ev.Ledger.Height))
+		case *database.RollbackEvent:
+			got = append(got, fmt.Sprintf("rollback %d", ev.Height))
+		}
+	}
+	want := "[block 1 block 2 block 3 rollback 2 block 3 block 4 block 5]"
+	if fmt.Sprint(got) != want {
+		t.Fatalf("events %v, want %s", got, want)
+	}
+}
+
+func TestClient_SubscribeNewBlock(t *testing.T) {
+	fetch := newMemFetcher()
+	fetch.extend(0, 3, "a")
+	fetch.notify = make(chan int64)
+	cli, cancel := newTestClient(t, fetch)
+
+	done := make(chan struct{})
+	go func() {
+		cli.Start()
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	waitHeight := func(height int64, timeout time.Duration) {
+		deadline := time.Now().Add(timeout)
+		for time.Now().Before(deadline) {
+			state, err := cli.dataMgr.QuerySyncState(database.SyncStateV3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if state != nil && state.Height >= height {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("height %d not indexed in %s", height, timeout)
 	}
 
-	dataMgr = dataM
+	waitHeight(3, 5*time.Second)
+	for atomic.LoadInt32(&cli.subscribed) == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// 订阅正常时轮询间隔为subscribedPollInterval，新区块应由通知触发索引
+	fetch.extend(3, 1, "a")
+	fetch.notify <- 4
+	waitHeight(4, subscribedPollInterval/2)
 }
 
 func TestFetch_FetchBlockInfo(t *testing.T) {
 	height := int64(1)
-	fetch := NewFetch("http://192.168.8.101:26657")
+	fetch := NewFetch(testRPC(t))
 	block, err := fetch.FetchBlockInfo(height)
 	if err != nil {
 		t.Fatal(err)
@@ -44,12 +300,119 @@ func TestFetch_FetchBlockInfo(t *testing.T) {
 }
 
 func TestClient(t *testing.T) {
-	client, err := NewClient(context.Background(), config.Tokens{}, 3, "http://192.168.8.123:26657", dataMgr, 0)
+	dataMgr := datamanagertest.New(t)
+	client, err := NewClient(context.Background(), "https://services.wolot.io", "8723", 3, NewFetch(testRPC(t)), dataMgr, 0, config.Sync{})
 	if err != nil {
 		t.Fatal(err)
 		return
 	}
-	defer dataMgr.Close()
 
 	client.Start()
 }
+
+func TestNewClient_UnsupportedVersion(t *testing.T) {
+	// 配置中的versions只能是已支持的解析协议版本
+	if _, err := NewClient(context.Background(), "", "", 2, newMemFetcher(), datamanagertest.New(t), 0, config.Sync{}); err == nil {
+		t.Fatal("want error for version 2")
+	}
+}
+
+func TestClient_FetchRange(t *testing.T) {
+	fetch := newMemFetcher()
+	fetch.extend(0, 20, "a")
+	cli, cancel := newTestClient(t, fetch)
+	defer cancel()
+
+	datas, err := cli.fetchRange(1, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, data := range datas {
+		if data.ledger.Height != int64(i+1) {
+			t.Fatalf("datas[%d] height %d", i, data.ledger.Height)
+		}
+	}
+
+	// 高度15之后节点尚未产出，只返回连续的前缀
+	fetch.last = 14
+	datas, err = cli.fetchRange(10, 20)
+	if err == nil {
+		t.Fatal("want error")
+	}
+	if len(datas) != 5 || datas[4].ledger.Height != 14 {
+		t.Fatalf("got %d blocks", len(datas))
+	}
+}
+
+func TestClient_SyncBackoff(t *testing.T) {
+	fetch := newMemFetcher()
+	fetch.extend(0, 5, "a")
+	fetch.last = 0
+	cli, cancel := newTestClient(t, fetch)
+	defer cancel()
+
+	// 节点不可用时等待后再返回，不反复请求节点
+	start := time.Now()
+	cli.sync(5)
+	if time.Since(start) < time.Second {
+		t.Fatal("sync returned without backoff")
+	}
+	if cli.currentHeight != 1 {
+		t.Fatalf("current height %d", cli.currentHeight)
+	}
+}
+
+func TestClient_Reindex(t *testing.T) {
+	fetch := newMemFetcher()
+	fetch.extend(0, 10, "a")
+	cli, cancel := newTestClient(t, fetch)
+	defer cancel()
+	syncTo(t, cli, 10)
+
+	var diffs []*ReindexDiff
+	report := func(diff *ReindexDiff) { diffs = append(diffs, diff) }
+
+	if err := cli.Reindex(1, 10, true, report); err != nil {
+		t.Fatal(err)
+	}
+	for _, diff := range diffs {
+		if !diff.Empty() {
+			t.Fatalf("unexpected diff %+v", diff)
+		}
+	}
+
+	if err := cli.Reindex(5, 11, true, nil); err == nil {
+		t.Fatal("reindex above indexed height should fail")
+	}
+
+	// 节点上3-5的区块变化
+	old := fetch.blocks[8]
+	fetch.extend(2, 3, "b")
+	fetch.last = 10
+	diffs = nil
+	if err := cli.Reindex(3, 5, true, report); err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 3 || !diffs[0].LedgerChanged {
+		t.Fatalf("dry-run diffs %+v", diffs)
+	}
+	if ledger, _ := cli.dataMgr.QueryV3Ledger(4); ledger.BlockHash == fetch.blocks[4].Block.Hash().String() {
+		t.Fatal("dry-run must not write")
+	}
+
+	if err := cli.Reindex(3, 5, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	for h := int64(3); h <= 5; h++ {
+		ledger, err := cli.dataMgr.QueryV3Ledger(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ledger.BlockHash != fetch.blocks[h].Block.Hash().String() {
+			t.Fatalf("ledger %d not reindexed", h)
+		}
+	}
+	if ledger, _ := cli.dataMgr.QueryV3Ledger(8); ledger.BlockHash != old.Block.Hash().String() {
+		t.Fatal("heights outside the range must not change")
+	}
+}
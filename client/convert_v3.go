@@ -4,10 +4,11 @@ import (
 	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
-	"errors"
-	"github.com/ethereum/go-ethereum/accounts/abi"
+	"fmt"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/shopspring/decimal"
 	abcitypes "github.com/tendermint/tendermint/abci/types"
 	tmtypes "github.com/tendermint/tendermint/types"
@@ -20,9 +21,15 @@ import (
 )
 
 type V3BlockData struct {
-	ledger   *database.V3Ledger
-	txs      []database.V3Transaction
-	payments []database.V3Payment
+	parentHash   string // 上一区块hash，用于检测分叉
+	ledger       *database.V3Ledger
+	txs          []database.V3Transaction
+	payments     []database.V3Payment
+	nftTransfers []database.V3NFTTransfer
+	approvals    []database.V3Approval
+	contracts    []database.V3Contract
+	logs         []database.V3Log
+	rawTxs       []database.V3RawTx // 无法解析的交易
 }
 
 func (cli *Client) GetV3BlockData(height int64) (*V3BlockData, error) {
@@ -33,6 +40,8 @@ func (cli *Client) GetV3BlockData(height int64) (*V3BlockData, error) {
 		return nil, err
 	}
 
+	data.parentHash = blockResult.Block.LastBlockID.Hash.String()
+
 	data.ledger = &database.V3Ledger{
 		Height:     height,
 		BlockHash:  blockResult.Block.Hash().String(),
@@ -52,65 +61,97 @@ func (cli *Client) GetV3BlockData(height int64) (*V3BlockData, error) {
 	}
 
 	for txIdx, bs := range blockResult.Block.Txs {
-		if len(bs) <= 2 {
-			log.Logger.Warn("error tx", zap.ByteString("tx", bs))
-			continue
-		}
+		cli.addTx(&data, txIdx, bs, &TxContext{Block: blockResult.Block, Result: deliverResult[txIdx], Ledger: data.ledger})
+	}
+	data.setGasPrice()
 
-		tag, itx, err := types.DecodeTx(bs)
-		if err != nil {
-			return nil, err
+	return &data, nil
+}
+
+// addTx 解析区块中的一笔交易，无法解析的交易记录到v3_raw_txs后继续同步，解析器升级后用retry-raw-txs重新解析
+func (cli *Client) addTx(data *V3BlockData, txIdx int, bs tmtypes.Tx, c *TxContext) {
+	converted, err := cli.txs.Convert(bs, c)
+	if err != nil {
+		log.Logger.Warn("tx not decoded", zap.Int64("height", c.Block.Height), zap.Int("txIdx", txIdx), zap.Error(err))
+		data.addRawTx(data.ledger, txIdx, bs, err.Error())
+		return
+	}
+	if converted.CreatesContract {
+		data.addContract(converted.Tx, converted.Payments)
+	}
+	converted.Tx.TxIdx = txIdx
+	data.txs = append(data.txs, *converted.Tx)
+	data.payments = append(data.payments, converted.Payments...)
+
+	tx := &data.txs[len(data.txs)-1]
+	// 合约日志，以及从中解析的代币转账、NFT转账、授权
+	if tx.Codei == 0 && tx.Events != "" {
+		var logs []*ethtypes.Log
+		if err := json.Unmarshal([]byte(tx.Events), &logs); err != nil {
+			log.Logger.Error("Unmarshal events", zap.Error(err), zap.Any("event", tx.Events))
+			return
 		}
+		data.logs = append(data.logs, txLogs(tx, logs)...)
 
-		switch tag {
-		case types.TxTagAppEvm:
-			{
-				if tx, ok := itx.(*types.TxEvm); ok {
-					transaction, payments := cli.DecodeTxAppEvm(tx, blockResult.Block, deliverResult[txIdx], data.ledger)
-					data.txs = append(data.txs, *transaction)
-					data.payments = append(data.payments, payments...)
-				}
-			}
-		case types.TxTagEthereumTx:
-			{
-				if tx, ok := itx.(*ethtypes.Transaction); ok {
-					transaction, payments := cli.DecodeTxAppEthereum(tx, blockResult.Block, deliverResult[txIdx], data.ledger)
-					data.txs = append(data.txs, *transaction)
-					data.payments = append(data.payments, payments...)
-				}
-			}
-		case types.TxTagAppBatch:
-			{
-				if tx, ok := itx.(*types.TxBatch); ok {
-					transaction, payments := cli.DecodeTxAppBatch(tx, blockResult.Block, deliverResult[txIdx], data.ledger)
-					data.txs = append(data.txs, *transaction)
-					data.payments = append(data.payments, payments...)
-				}
-			}
-		case types.TxTagAppEvmMultisig:
-			{
-				if tx, ok := itx.(*types.MultisigEvmTx); ok {
-					transaction, payments := cli.DecodeTxMultisigEvm(tx, blockResult.Block, deliverResult[txIdx], data.ledger)
-					data.txs = append(data.txs, *transaction)
-					data.payments = append(data.payments, payments...)
-				}
-			}
-		default:
-			log.Logger.Warn("unknown txTag", zap.Int("txTag", txTagToTypei(bs[:2])))
-			continue
+		events, err := cli.resolveTxEvents(tx, logs)
+		if err != nil {
+			log.Logger.Warn("resolveTxEvents", zap.String("hash", tx.Hash), zap.Error(err))
 		}
+		data.payments = append(data.payments, events.Payments...)
+		data.nftTransfers = append(data.nftTransfers, events.NFTTransfers...)
+		data.approvals = append(data.approvals, events.Approvals...)
 	}
+}
 
-	// 计算平均gasPrice
+// setGasPrice 计算平均gasPrice
+func (data *V3BlockData) setGasPrice() {
 	if data.ledger.TxCount > 0 {
 		data.ledger.GasPrice = decimal.NewFromBigInt(data.ledger.TotalPrice, 0).Div(decimal.New(data.ledger.TxCount, 0)).Round(2).String()
 		//data.ledger.GasPrice = new(big.Int).Div(data.ledger.TotalPrice, big.NewInt(data.ledger.TxCount)).String()
 	}
+}
 
-	return &data, nil
+// addRawTx 记录无法解析的交易，hash为交易字节的hash
+func (data *V3BlockData) addRawTx(ledger *database.V3Ledger, txIdx int, bs tmtypes.Tx, reason string) {
+	tag := bs
+	if len(tag) > 2 {
+		tag = tag[:2]
+	}
+	data.rawTxs = append(data.rawTxs, database.V3RawTx{
+		Height:    ledger.Height,
+		TxIdx:     txIdx,
+		Hash:      hexutil.Encode(bs.Hash()),
+		Tag:       hexutil.Encode(tag),
+		Raw:       hexutil.Encode(bs),
+		Error:     reason,
+		CreatedAt: ledger.CreatedAt,
+	})
 }
 
-func (cli *Client) DecodeTxAppEvm(tx *types.TxEvm, block *tmtypes.Block, deliverResult *abcitypes.ResponseDeliverTx,
+// addContract 创建合约的交易执行成功时记录合约地址，地址由发起者地址和nonce计算，与节点创建合约的规则一致。
+// 交易附带的原生币转入新合约，payment的接收方由全零地址改为合约地址
+func (data *V3BlockData) addContract(tx *database.V3Transaction, payments []database.V3Payment) {
+	if tx == nil || tx.Codei != 0 {
+		return
+	}
+	address := crypto.CreateAddress(common.HexToAddress(tx.Sender), uint64(tx.Nonce)).Hex()
+	zero := common.Address{}.Hex()
+	for i := range payments {
+		if payments[i].Contract == zero && payments[i].Receiver == zero {
+			payments[i].Receiver = address
+		}
+	}
+	data.contracts = append(data.contracts, database.V3Contract{
+		Address:   address,
+		Creator:   tx.Sender,
+		Hash:      tx.Hash,
+		Height:    tx.Height,
+		Types:     tx.Types,
+		CreatedAt: tx.CreatedAt,
+	})
+}
+
+func convertTxEvm(tx *types.TxEvm, block *tmtypes.Block, deliverResult *abcitypes.ResponseDeliverTx,
 	ledger *database.V3Ledger) (*database.V3Transaction, []database.V3Payment) {
 	trans := &database.V3Transaction{
 		Hash:      tx.Hash().Hex(),
@@ -156,16 +197,11 @@ func (cli *Client) DecodeTxAppEvm(tx *types.TxEvm, block *tmtypes.Block, deliver
 			CreatedAt: block.Time,
 		})
 	}
-	subPays, err := cli.resolveTxEvents(trans)
-	if err != nil {
-		return trans, payments
-	}
-	payments = append(payments, subPays...)
 	return trans, payments
 }
 
-func (cli *Client) DecodeTxAppEthereum(tx *ethtypes.Transaction, block *tmtypes.Block, deliverResult *abcitypes.ResponseDeliverTx,
-	ledger *database.V3Ledger) (*database.V3Transaction, []database.V3Payment) {
+func convertTxEthereum(tx *ethtypes.Transaction, block *tmtypes.Block, deliverResult *abcitypes.ResponseDeliverTx,
+	ledger *database.V3Ledger) (*database.V3Transaction, []database.V3Payment, error) {
 	to := tx.To()
 	if to == nil {
 		to = &common.Address{}
@@ -174,10 +210,14 @@ func (cli *Client) DecodeTxAppEthereum(tx *ethtypes.Transaction, block *tmtypes.
 	signer := ethtypes.NewEIP155Signer(tx.ChainId())
 	sender, err := signer.Sender(tx)
 	if err != nil {
-		log.Logger.Error("DecodeTxAppEthereum", zap.Error(err))
-		return nil, nil
+		return nil, nil, fmt.Errorf("invalid sender: %v", err)
 	}
 
+	// 签名数据原样保存，value、gasPrice换算为ether后有精度损失，/rpc从签名数据还原交易
+	raw, err := tx.MarshalBinary()
+	if err != nil {
+		return nil, nil, err
+	}
 	value := utils.ToEther(tx.Value())
 	gasPrice := utils.ToEther(tx.GasPrice())
 
@@ -199,13 +239,14 @@ func (cli *Client) DecodeTxAppEthereum(tx *ethtypes.Transaction, block *tmtypes.
 		Codei:     deliverResult.Code,
 		Codes:     deliverResult.Log,
 		CreatedAt: block.Time,
+		RawTx:     hexutil.Encode(raw),
 	}
 	ledger.GasLimit += int64(tx.Gas())
 	ledger.GasUsed += deliverResult.GasUsed
 	ledger.TotalPrice = new(big.Int).Add(ledger.TotalPrice, gasPrice)
 
 	if deliverResult.Code != 0 {
-		return trans, nil
+		return trans, nil, nil
 	}
 
 	var (
@@ -225,15 +266,10 @@ func (cli *Client) DecodeTxAppEthereum(tx *ethtypes.Transaction, block *tmtypes.
 			CreatedAt: block.Time,
 		})
 	}
-	subPays, err := cli.resolveTxEvents(trans)
-	if err != nil {
-		return trans, payments
-	}
-	payments = append(payments, subPays...)
-	return trans, payments
+	return trans, payments, nil
 }
 
-func (cli *Client) DecodeTxMultisigEvm(tx *types.MultisigEvmTx, block *tmtypes.Block, deliverResult *abcitypes.ResponseDeliverTx,
+func convertTxMultisigEvm(tx *types.MultisigEvmTx, block *tmtypes.Block, deliverResult *abcitypes.ResponseDeliverTx,
 	ledger *database.V3Ledger) (*database.V3Transaction, []database.V3Payment) {
 	trans := &database.V3Transaction{
 		Hash:      tx.Hash().Hex(),
@@ -279,15 +315,10 @@ func (cli *Client) DecodeTxMultisigEvm(tx *types.MultisigEvmTx, block *tmtypes.B
 			CreatedAt: block.Time,
 		})
 	}
-	subPays, err := cli.resolveTxEvents(trans)
-	if err != nil {
-		return trans, payments
-	}
-	payments = append(payments, subPays...)
 	return trans, payments
 }
 
-func (cli *Client) DecodeTxAppBatch(tx *types.TxBatch, block *tmtypes.Block, deliverResult *abcitypes.ResponseDeliverTx,
+func convertTxBatch(tx *types.TxBatch, block *tmtypes.Block, deliverResult *abcitypes.ResponseDeliverTx,
 	ledger *database.V3Ledger) (*database.V3Transaction, []database.V3Payment) {
 	trans := &database.V3Transaction{
 		Hash:      tx.Hash().Hex(),
@@ -339,72 +370,43 @@ func txTagToTypei(txTag []byte) int {
 	return int(typei)
 }
 
-func (cli *Client) resolveTxEvents(tx *database.V3Transaction) ([]database.V3Payment, error) {
-	var (
-		logs     []*ethtypes.Log
-		payments []database.V3Payment
-	)
-	if err := json.Unmarshal([]byte(tx.Events), &logs); err != nil {
-		log.Logger.Error("Unmarshal events", zap.Error(err), zap.Any("event", tx.Events))
-		return payments, err
-	}
-
-	for _, v := range logs {
-		evname, indexed, unindexed, err := unpackEventV2(cli.abi, v)
-		if err != nil {
-			continue // 如果用此abi无法解析事件，则跳过继续解析后续的事件
+// txLogs 交易日志逐条写入v3_logs，topic不足4个时为空
+func txLogs(tx *database.V3Transaction, logs []*ethtypes.Log) []database.V3Log {
+	result := make([]database.V3Log, 0, len(logs))
+	for _, l := range logs {
+		if l == nil {
+			continue
 		}
-
-		var symbol string
-
-		if token, ok := cli.tokenMgr.Token(v.Address.Hex()); ok {
-			symbol = token.Symbol
+		var topics [4]string
+		for i := 0; i < len(l.Topics) && i < len(topics); i++ {
+			topics[i] = l.Topics[i].Hex()
 		}
-
-		payment := database.V3Payment{
+		result = append(result, database.V3Log{
 			Hash:      tx.Hash,
 			Height:    tx.Height,
-			EvName:    evname,
-			Idx:       v.Index, // EVM的取事件的索引id
-			Symbol:    symbol,
-			Contract:  v.Address.Hex(),
+			TxIdx:     tx.TxIdx,
+			LogIdx:    l.Index,
+			Address:   l.Address.Hex(),
+			Topic0:    topics[0],
+			Topic1:    topics[1],
+			Topic2:    topics[2],
+			Topic3:    topics[3],
+			Data:      hexutil.Encode(l.Data),
 			CreatedAt: tx.CreatedAt,
-		}
-
-		if evname == "Transfer" {
-			payment.Sender = hashToAddress(indexed[0]).Hex()
-			payment.Receiver = hashToAddress(indexed[1]).Hex()
-			value, _ := unindexed[0].(*big.Int)
-			payment.Value = value.String()
-		} else if evname == "Deposit" {
-			payment.Sender = tx.Sender
-			payment.Receiver = hashToAddress(indexed[0]).Hex()
-			value, _ := unindexed[0].(*big.Int)
-			payment.Value = value.String()
-		} else if evname == "Withdrawal" {
-			payment.Sender = hashToAddress(indexed[0]).Hex()
-			payment.Receiver = tx.Sender
-			value, _ := unindexed[0].(*big.Int)
-			payment.Value = value.String()
-		}
-		payments = append(payments, payment)
+		})
 	}
-	return payments, nil
+	return result
 }
 
-func unpackEventV2(abi abi.ABI, log *ethtypes.Log) (string, []common.Hash, []interface{}, error) {
-	if len(log.Topics) == 0 {
-		return "", nil, nil, errors.New("No Topic")
-	}
-	event, err := abi.EventByID(log.Topics[0])
-	if err != nil {
-		return "", nil, nil, err
+// resolveTxEvents 解析交易日志，无法识别的日志跳过；解析出错的日志跳过并返回错误，其余结果仍然有效
+func (cli *Client) resolveTxEvents(tx *database.V3Transaction, logs []*ethtypes.Log) (*EventData, error) {
+	events, err := cli.events.Decode(tx, logs)
+	for i := range events.Payments {
+		if token, ok := cli.tokenMgr.Token(events.Payments[i].Contract); ok {
+			events.Payments[i].Symbol = token.Symbol
+		}
 	}
-
-	// agrs.Unpack只会解析未index的
-	args := event.Inputs
-	retval, err := args.UnpackValues(log.Data)
-	return event.Name, log.Topics[1:], retval, err
+	return events, err
 }
 
 func hashToAddress(hx common.Hash) common.Address {
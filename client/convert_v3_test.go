@@ -0,0 +1,274 @@
+package client
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	ethcmn "github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	abcitypes "github.com/tendermint/tendermint/abci/types"
+	tmtypes "github.com/tendermint/tendermint/types"
+	"github.com/toolglobal/api/database"
+	"github.com/toolglobal/api/mondo/types"
+)
+
+// txFetcher 带交易和执行结果的区块
+type txFetcher struct {
+	*memFetcher
+	results map[int64][]*abcitypes.ResponseDeliverTx
+}
+
+func (f *txFetcher) FetchBlockResultInfo(height int64) ([]*abcitypes.ResponseDeliverTx, error) {
+	if _, err := f.memFetcher.FetchBlockResultInfo(height); err != nil {
+		return nil, err
+	}
+	return f.results[height], nil
+}
+
+// resultFetcher 只能读取执行结果
+type resultFetcher struct {
+	*txFetcher
+}
+
+func (resultFetcher) FetchBlockInfo(height int64) (*Block, error) {
+	return nil, fmt.Errorf("block %d not available", height)
+}
+
+func TestClient_GetV3BlockDataContracts(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender := crypto.PubkeyToAddress(key.PublicKey)
+
+	// TxEvm创建合约，附带5个OLO
+	evmTx := types.NewTxEvm()
+	evmTx.GasLimit = 100000
+	evmTx.GasPrice = big.NewInt(1)
+	evmTx.Nonce = 3
+	evmTx.Sender.SetBytes(sender.Bytes())
+	evmTx.Body.Value = big.NewInt(5)
+	evmTx.Body.Load = []byte{0x60, 0x80}
+
+	// 以太坊兼容交易创建合约
+	signer := ethtypes.NewEIP155Signer(big.NewInt(1))
+	ethTx, err := ethtypes.SignTx(ethtypes.NewContractCreation(4, new(big.Int), 100000, big.NewInt(1), []byte{0x60, 0x80}), signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ethBytes, err := rlp.EncodeToBytes(ethTx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 多签账户创建合约
+	multisig := ethcmn.HexToAddress("0x00000000000000000000000000000000000A11cE")
+	msTx := types.NewMultisigEvmTx(1, []types.PublicKey{evmTx.Sender})
+	msTx.GasLimit = 100000
+	msTx.GasPrice = big.NewInt(1)
+	msTx.From = multisig
+	msTx.Nonce = 7
+	msTx.Load = []byte{0x60, 0x80}
+
+	// 执行失败的创建不产生合约
+	failedTx := types.NewTxEvm()
+	failedTx.GasLimit = 100000
+	failedTx.GasPrice = big.NewInt(1)
+	failedTx.Nonce = 5
+	failedTx.Sender.SetBytes(sender.Bytes())
+	failedTx.Body.Value = new(big.Int)
+
+	txs := tmtypes.Txs{
+		append(types.TxTagAppEvm[:], evmTx.ToBytes()...),
+		append(types.TxTagEthereumTx[:], ethBytes...),
+		append(types.TxTagAppEvmMultisig[:], msTx.ToBytes()...),
+		append(types.TxTagAppEvm[:], failedTx.ToBytes()...),
+	}
+	block := tmtypes.MakeBlock(1, txs, &tmtypes.Commit{}, nil)
+	block.ChainID = "test"
+	block.Time = time.Unix(1600000001, 0)
+	fetch := &txFetcher{
+		memFetcher: newMemFetcher(),
+		results: map[int64][]*abcitypes.ResponseDeliverTx{
+			1: {{GasUsed: 100}, {GasUsed: 100}, {GasUsed: 100}, {Code: 1, GasUsed: 100}},
+		},
+	}
+	fetch.blocks[1] = &Block{BlockID: tmtypes.BlockID{Hash: block.Hash()}, Block: block}
+	fetch.last = 1
+
+	cli, cancel := newTestClient(t, fetch)
+	defer cancel()
+	data, err := cli.GetV3BlockData(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		address, creator, hash, types string
+	}{
+		{crypto.CreateAddress(sender, 3).Hex(), sender.Hex(), evmTx.Hash().Hex(), "TxTagAppEvm"},
+		{crypto.CreateAddress(sender, 4).Hex(), sender.Hex(), ethTx.Hash().Hex(), "TxTagEthereumTx"},
+		{crypto.CreateAddress(multisig, 7).Hex(), multisig.Hex(), msTx.Hash().Hex(), "TxTagAppEvmMultisig"},
+	}
+	if len(data.contracts) != len(want) {
+		t.Fatalf("contracts %+v", data.contracts)
+	}
+	for i, w := range want {
+		c := data.contracts[i]
+		if c.Address != w.address || c.Creator != w.creator || c.Hash != w.hash || c.Types != w.types || c.Height != 1 {
+			t.Fatalf("contract %d got %+v, want %+v", i, c, w)
+		}
+	}
+	// 附带的OLO转入新合约
+	if len(data.payments) != 1 || data.payments[0].Receiver != want[0].address || data.payments[0].Value != "5" {
+		t.Fatalf("payments %+v", data.payments)
+	}
+	// 交易接收方仍为全零地址
+	if data.txs[0].Receiver != (ethcmn.Address{}).Hex() {
+		t.Fatalf("receiver %s", data.txs[0].Receiver)
+	}
+	// 以太坊交易保存签名数据，其他交易为空
+	if data.txs[1].RawTx != "0x"+ethcmn.Bytes2Hex(ethBytes) || data.txs[0].RawTx != "" {
+		t.Fatalf("raw tx %q %q", data.txs[1].RawTx, data.txs[0].RawTx)
+	}
+
+	if err := cli.SaveV3Batch([]*V3BlockData{data}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := cli.dataMgr.QueryV3Contract(want[1].address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || got.Hash != want[1].hash || !got.CreatedAt.Equal(block.Time) {
+		t.Fatalf("saved contract %+v", got)
+	}
+
+	if err := cli.dataMgr.RollbackV3(0); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := cli.dataMgr.QueryV3Contract(want[1].address); err != nil || got != nil {
+		t.Fatalf("contract after rollback %+v %v", got, err)
+	}
+}
+
+func TestTxLogs(t *testing.T) {
+	tx := &database.V3Transaction{Hash: "0x01", Height: 3, TxIdx: 2, CreatedAt: time.Unix(1600000003, 0)}
+	contract := ethcmn.HexToAddress("0x1000000000000000000000000000000000000001")
+	logs := []*ethtypes.Log{
+		{Address: contract, Topics: []ethcmn.Hash{ethcmn.HexToHash("0xddf2"), ethcmn.HexToHash("0xa11c"), ethcmn.HexToHash("0x0b0b")}, Data: []byte{1, 2}, Index: 5},
+		nil,
+		{Address: contract, Index: 6},
+	}
+
+	result := txLogs(tx, logs)
+	if len(result) != 2 {
+		t.Fatalf("logs %+v", result)
+	}
+	l := result[0]
+	if l.Hash != "0x01" || l.Height != 3 || l.TxIdx != 2 || l.LogIdx != 5 || l.Address != contract.Hex() ||
+		l.Topic0 != ethcmn.HexToHash("0xddf2").Hex() || l.Topic2 != ethcmn.HexToHash("0x0b0b").Hex() || l.Topic3 != "" ||
+		l.Data != "0x0102" || !l.CreatedAt.Equal(tx.CreatedAt) {
+		t.Fatalf("log %+v", l)
+	}
+	// 匿名事件没有topic
+	if result[1].Topic0 != "" || result[1].Data != "0x" {
+		t.Fatalf("anonymous log %+v", result[1])
+	}
+}
+
+func TestClient_GetV3BlockDataRawTxs(t *testing.T) {
+	evmTx := types.NewTxEvm()
+	evmTx.GasLimit = 100000
+	evmTx.GasPrice = big.NewInt(1)
+	evmTx.Nonce = 1
+	evmTx.Sender.SetBytes(ethcmn.HexToAddress("0x00000000000000000000000000000000000A11cE").Bytes())
+	evmTx.Body.To.SetBytes(ethcmn.HexToAddress("0x0000000000000000000000000000000000000B0b").Bytes())
+	evmTx.Body.Value = new(big.Int)
+
+	txs := tmtypes.Txs{
+		append(types.TxTagAppEvm[:], evmTx.ToBytes()...),
+		{0xff, 0xfe, 0x01, 0x02},               // 未知类型
+		append(types.TxTagEthereumTx[:], 0xc3), // RLP格式错误
+		{0x01},                                 // 长度不足
+	}
+	block := tmtypes.MakeBlock(1, txs, &tmtypes.Commit{}, nil)
+	block.ChainID = "test"
+	block.Time = time.Unix(1600000001, 0)
+	fetch := &txFetcher{
+		memFetcher: newMemFetcher(),
+		results: map[int64][]*abcitypes.ResponseDeliverTx{
+			1: {{GasUsed: 100}, {}, {}, {}},
+		},
+	}
+	fetch.blocks[1] = &Block{BlockID: tmtypes.BlockID{Hash: block.Hash()}, Block: block}
+	fetch.last = 1
+
+	cli, cancel := newTestClient(t, fetch)
+	defer cancel()
+	data, err := cli.GetV3BlockData(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data.txs) != 1 || data.txs[0].Hash != evmTx.Hash().Hex() {
+		t.Fatalf("txs %+v", data.txs)
+	}
+	want := []struct {
+		idx int
+		tag string
+	}{{1, "0xfffe"}, {2, "0x0105"}, {3, "0x01"}}
+	if len(data.rawTxs) != len(want) {
+		t.Fatalf("raw txs %+v", data.rawTxs)
+	}
+	for i, w := range want {
+		raw := data.rawTxs[i]
+		if raw.TxIdx != w.idx || raw.Tag != w.tag || raw.Hash != "0x"+ethcmn.Bytes2Hex(txs[w.idx].Hash()) ||
+			raw.Raw != "0x"+ethcmn.Bytes2Hex(txs[w.idx]) || raw.Error == "" || !raw.CreatedAt.Equal(block.Time) {
+			t.Fatalf("raw tx %d %+v", i, raw)
+		}
+	}
+
+	// 旧版本解析器无法解析第一笔交易，ledger中没有它的gas
+	old := *data
+	oldLedger := *data.ledger
+	oldLedger.GasLimit, oldLedger.GasUsed, oldLedger.GasPrice = 0, 0, "0"
+	old.ledger = &oldLedger
+	old.txs, old.rawTxs = nil, nil
+	old.addRawTx(data.ledger, 0, txs[0], "unknown tx tag")
+	old.rawTxs = append(old.rawTxs, data.rawTxs...)
+	if err := cli.SaveV3Batch([]*V3BlockData{&old}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重新解析只从节点读取执行结果，不读取区块
+	cli.fetch = resultFetcher{fetch}
+	var diffs []*ReindexDiff
+	if err := cli.RetryRawTxs(false, func(diff *ReindexDiff) { diffs = append(diffs, diff) }); err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 1 || diffs[0].RawTxsRemoved != 1 || diffs[0].RawTxsAdded != 0 || diffs[0].TxsAdded != 1 || !diffs[0].LedgerChanged {
+		t.Fatalf("diffs %+v", diffs)
+	}
+	ledger, err := cli.dataMgr.QueryV3Ledger(1)
+	if err != nil || ledger.GasLimit != data.ledger.GasLimit || ledger.GasUsed != data.ledger.GasUsed || ledger.GasPrice != data.ledger.GasPrice ||
+		ledger.BlockHash != data.ledger.BlockHash {
+		t.Fatalf("ledger after retry %+v %v", ledger, err)
+	}
+	rawTxs, err := cli.dataMgr.QueryV3RawTxs(&database.V3RawTxFilter{FromHeight: 1, ToHeight: 1}, nil, "ASC")
+	if err != nil || len(rawTxs) != len(want) || rawTxs[0].TxIdx != 1 {
+		t.Fatalf("raw txs after retry %+v %v", rawTxs, err)
+	}
+	if got, err := cli.dataMgr.QueryV3TxsByHashes([]string{evmTx.Hash().Hex()}); err != nil || len(got) != 1 || got[0].TxIdx != 0 {
+		t.Fatalf("tx after retry %+v %v", got, err)
+	}
+
+	if err := cli.dataMgr.RollbackV3(0); err != nil {
+		t.Fatal(err)
+	}
+	if rawTxs, err := cli.dataMgr.QueryV3RawTxs(&database.V3RawTxFilter{}, nil, "ASC"); err != nil || len(rawTxs) != 0 {
+		t.Fatalf("raw txs after rollback %+v %v", rawTxs, err)
+	}
+}
@@ -0,0 +1,428 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/toolglobal/api/database"
+)
+
+// 代币标准
+const (
+	StandardERC20   = "ERC20"
+	StandardERC721  = "ERC721"
+	StandardERC1155 = "ERC1155"
+)
+
+// EventData 交易日志解析出的数据
+type EventData struct {
+	Payments     []database.V3Payment
+	NFTTransfers []database.V3NFTTransfer
+	Approvals    []database.V3Approval
+}
+
+func (d *EventData) merge(o *EventData) {
+	if o == nil {
+		return
+	}
+	d.Payments = append(d.Payments, o.Payments...)
+	d.NFTTransfers = append(d.NFTTransfers, o.NFTTransfers...)
+	d.Approvals = append(d.Approvals, o.Approvals...)
+}
+
+// EventDecoder 解析一种合约事件日志
+type EventDecoder interface {
+	// Topic 事件签名hash，即日志的topics[0]
+	Topic() common.Hash
+	// Decode 解析日志并写入out。日志格式不是该解码器处理的（例如indexed参数数量不同）时返回false，
+	// 由同一Topic的下一个解码器继续尝试
+	Decode(tx *database.V3Transaction, log *ethtypes.Log, out *EventData) (bool, error)
+}
+
+// EventRegistry 按事件签名分发日志到已注册的解码器，同一签名按注册顺序尝试
+type EventRegistry struct {
+	decoders map[common.Hash][]EventDecoder
+}
+
+func NewEventRegistry(decoders ...EventDecoder) *EventRegistry {
+	r := &EventRegistry{decoders: make(map[common.Hash][]EventDecoder)}
+	for _, d := range decoders {
+		r.Register(d)
+	}
+	return r
+}
+
+// DefaultEventRegistry 支持ERC20、ERC721、ERC1155的转账、授权事件，以及WETH式的Deposit、Withdrawal
+func DefaultEventRegistry() *EventRegistry {
+	return NewEventRegistry(
+		erc20TransferDecoder{},
+		erc721TransferDecoder{},
+		depositDecoder{},
+		withdrawalDecoder{},
+		erc20ApprovalDecoder{},
+		erc721ApprovalDecoder{},
+		approvalForAllDecoder{},
+		transferSingleDecoder{},
+		transferBatchDecoder{},
+	)
+}
+
+func (r *EventRegistry) Register(d EventDecoder) {
+	r.decoders[d.Topic()] = append(r.decoders[d.Topic()], d)
+}
+
+// Decode 依次解析交易的日志，无法识别的日志跳过，返回第一个解析出错的日志的错误
+func (r *EventRegistry) Decode(tx *database.V3Transaction, logs []*ethtypes.Log) (*EventData, error) {
+	var (
+		out      EventData
+		firstErr error
+	)
+	for _, log := range logs {
+		if log == nil || len(log.Topics) == 0 {
+			continue
+		}
+		for _, d := range r.decoders[log.Topics[0]] {
+			ok, err := d.Decode(tx, log, &out)
+			if err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("decode log %d of %s: %v", log.Index, tx.Hash, err)
+			}
+			if ok || err != nil {
+				break
+			}
+		}
+	}
+	return &out, firstErr
+}
+
+// eventABI 解析单个事件的abi定义
+type eventABI struct {
+	event abi.Event
+}
+
+func mustEventABI(signature string) eventABI {
+	parsed, err := abi.JSON(strings.NewReader("[" + signature + "]"))
+	if err != nil {
+		panic(err)
+	}
+	for _, event := range parsed.Events {
+		return eventABI{event: event}
+	}
+	panic("no event in abi: " + signature)
+}
+
+func (e eventABI) topic() common.Hash {
+	return e.event.ID
+}
+
+// match indexed参数数量一致时才由该事件定义解析
+func (e eventABI) match(log *ethtypes.Log) bool {
+	indexed := 0
+	for _, input := range e.event.Inputs {
+		if input.Indexed {
+			indexed++
+		}
+	}
+	return len(log.Topics) == indexed+1
+}
+
+// unpack 解析未indexed的参数
+func (e eventABI) unpack(log *ethtypes.Log) ([]interface{}, error) {
+	return e.event.Inputs.NonIndexed().UnpackValues(log.Data)
+}
+
+var (
+	transferEvent      = mustEventABI(`{"anonymous":false,"inputs":[{"indexed":true,"name":"from","type":"address"},{"indexed":true,"name":"to","type":"address"},{"indexed":false,"name":"value","type":"uint256"}],"name":"Transfer","type":"event"}`)
+	nftTransferEvent   = mustEventABI(`{"anonymous":false,"inputs":[{"indexed":true,"name":"from","type":"address"},{"indexed":true,"name":"to","type":"address"},{"indexed":true,"name":"tokenId","type":"uint256"}],"name":"Transfer","type":"event"}`)
+	depositEvent       = mustEventABI(`{"anonymous":false,"inputs":[{"indexed":true,"name":"dst","type":"address"},{"indexed":false,"name":"wad","type":"uint256"}],"name":"Deposit","type":"event"}`)
+	withdrawalEvent    = mustEventABI(`{"anonymous":false,"inputs":[{"indexed":true,"name":"src","type":"address"},{"indexed":false,"name":"wad","type":"uint256"}],"name":"Withdrawal","type":"event"}`)
+	approvalEvent      = mustEventABI(`{"anonymous":false,"inputs":[{"indexed":true,"name":"owner","type":"address"},{"indexed":true,"name":"spender","type":"address"},{"indexed":false,"name":"value","type":"uint256"}],"name":"Approval","type":"event"}`)
+	nftApprovalEvent   = mustEventABI(`{"anonymous":false,"inputs":[{"indexed":true,"name":"owner","type":"address"},{"indexed":true,"name":"approved","type":"address"},{"indexed":true,"name":"tokenId","type":"uint256"}],"name":"Approval","type":"event"}`)
+	approvalForAll     = mustEventABI(`{"anonymous":false,"inputs":[{"indexed":true,"name":"owner","type":"address"},{"indexed":true,"name":"operator","type":"address"},{"indexed":false,"name":"approved","type":"bool"}],"name":"ApprovalForAll","type":"event"}`)
+	transferSingle     = mustEventABI(`{"anonymous":false,"inputs":[{"indexed":true,"name":"operator","type":"address"},{"indexed":true,"name":"from","type":"address"},{"indexed":true,"name":"to","type":"address"},{"indexed":false,"name":"id","type":"uint256"},{"indexed":false,"name":"value","type":"uint256"}],"name":"TransferSingle","type":"event"}`)
+	transferBatchEvent = mustEventABI(`{"anonymous":false,"inputs":[{"indexed":true,"name":"operator","type":"address"},{"indexed":true,"name":"from","type":"address"},{"indexed":true,"name":"to","type":"address"},{"indexed":false,"name":"ids","type":"uint256[]"},{"indexed":false,"name":"values","type":"uint256[]"}],"name":"TransferBatch","type":"event"}`)
+)
+
+func newPayment(tx *database.V3Transaction, log *ethtypes.Log, evName string) database.V3Payment {
+	return database.V3Payment{
+		Hash:      tx.Hash,
+		Height:    tx.Height,
+		EvName:    evName,
+		Idx:       log.Index, // EVM的取事件的索引id
+		Contract:  log.Address.Hex(),
+		CreatedAt: tx.CreatedAt,
+	}
+}
+
+func newNFTTransfer(tx *database.V3Transaction, log *ethtypes.Log, standard string) database.V3NFTTransfer {
+	return database.V3NFTTransfer{
+		Hash:      tx.Hash,
+		Height:    tx.Height,
+		Idx:       log.Index,
+		Standard:  standard,
+		Contract:  log.Address.Hex(),
+		CreatedAt: tx.CreatedAt,
+	}
+}
+
+func newApproval(tx *database.V3Transaction, log *ethtypes.Log, evName, standard string) database.V3Approval {
+	return database.V3Approval{
+		Hash:      tx.Hash,
+		Height:    tx.Height,
+		Idx:       log.Index,
+		EvName:    evName,
+		Standard:  standard,
+		Contract:  log.Address.Hex(),
+		Approved:  true,
+		CreatedAt: tx.CreatedAt,
+	}
+}
+
+func topicToBig(hx common.Hash) *big.Int {
+	return new(big.Int).SetBytes(hx.Bytes())
+}
+
+func unpackBig(values []interface{}, i int) (*big.Int, error) {
+	if i >= len(values) {
+		return nil, errors.New("missing event argument")
+	}
+	value, ok := values[i].(*big.Int)
+	if !ok {
+		return nil, fmt.Errorf("argument %d is %T, want *big.Int", i, values[i])
+	}
+	return value, nil
+}
+
+// erc20TransferDecoder Transfer(address indexed from, address indexed to, uint256 value)
+type erc20TransferDecoder struct{}
+
+func (erc20TransferDecoder) Topic() common.Hash { return transferEvent.topic() }
+
+func (erc20TransferDecoder) Decode(tx *database.V3Transaction, log *ethtypes.Log, out *EventData) (bool, error) {
+	if !transferEvent.match(log) {
+		return false, nil
+	}
+	values, err := transferEvent.unpack(log)
+	if err != nil {
+		return false, err
+	}
+	value, err := unpackBig(values, 0)
+	if err != nil {
+		return false, err
+	}
+	payment := newPayment(tx, log, "Transfer")
+	payment.Sender = hashToAddress(log.Topics[1]).Hex()
+	payment.Receiver = hashToAddress(log.Topics[2]).Hex()
+	payment.Value = value.String()
+	out.Payments = append(out.Payments, payment)
+	return true, nil
+}
+
+// erc721TransferDecoder Transfer(address indexed from, address indexed to, uint256 indexed tokenId)
+type erc721TransferDecoder struct{}
+
+func (erc721TransferDecoder) Topic() common.Hash { return nftTransferEvent.topic() }
+
+func (erc721TransferDecoder) Decode(tx *database.V3Transaction, log *ethtypes.Log, out *EventData) (bool, error) {
+	if !nftTransferEvent.match(log) {
+		return false, nil
+	}
+	transfer := newNFTTransfer(tx, log, StandardERC721)
+	transfer.Sender = hashToAddress(log.Topics[1]).Hex()
+	transfer.Receiver = hashToAddress(log.Topics[2]).Hex()
+	transfer.TokenId = topicToBig(log.Topics[3]).String()
+	transfer.Value = "1"
+	out.NFTTransfers = append(out.NFTTransfers, transfer)
+	return true, nil
+}
+
+// depositDecoder Deposit(address indexed dst, uint256 wad)，原生币存入包装合约，视为由交易发起者转入dst
+type depositDecoder struct{}
+
+func (depositDecoder) Topic() common.Hash { return depositEvent.topic() }
+
+func (depositDecoder) Decode(tx *database.V3Transaction, log *ethtypes.Log, out *EventData) (bool, error) {
+	if !depositEvent.match(log) {
+		return false, nil
+	}
+	values, err := depositEvent.unpack(log)
+	if err != nil {
+		return false, err
+	}
+	value, err := unpackBig(values, 0)
+	if err != nil {
+		return false, err
+	}
+	payment := newPayment(tx, log, "Deposit")
+	payment.Sender = tx.Sender
+	payment.Receiver = hashToAddress(log.Topics[1]).Hex()
+	payment.Value = value.String()
+	out.Payments = append(out.Payments, payment)
+	return true, nil
+}
+
+// withdrawalDecoder Withdrawal(address indexed src, uint256 wad)，从包装合约取回原生币，视为由src转给交易发起者
+type withdrawalDecoder struct{}
+
+func (withdrawalDecoder) Topic() common.Hash { return withdrawalEvent.topic() }
+
+func (withdrawalDecoder) Decode(tx *database.V3Transaction, log *ethtypes.Log, out *EventData) (bool, error) {
+	if !withdrawalEvent.match(log) {
+		return false, nil
+	}
+	values, err := withdrawalEvent.unpack(log)
+	if err != nil {
+		return false, err
+	}
+	value, err := unpackBig(values, 0)
+	if err != nil {
+		return false, err
+	}
+	payment := newPayment(tx, log, "Withdrawal")
+	payment.Sender = hashToAddress(log.Topics[1]).Hex()
+	payment.Receiver = tx.Sender
+	payment.Value = value.String()
+	out.Payments = append(out.Payments, payment)
+	return true, nil
+}
+
+// erc20ApprovalDecoder Approval(address indexed owner, address indexed spender, uint256 value)
+type erc20ApprovalDecoder struct{}
+
+func (erc20ApprovalDecoder) Topic() common.Hash { return approvalEvent.topic() }
+
+func (erc20ApprovalDecoder) Decode(tx *database.V3Transaction, log *ethtypes.Log, out *EventData) (bool, error) {
+	if !approvalEvent.match(log) {
+		return false, nil
+	}
+	values, err := approvalEvent.unpack(log)
+	if err != nil {
+		return false, err
+	}
+	value, err := unpackBig(values, 0)
+	if err != nil {
+		return false, err
+	}
+	approval := newApproval(tx, log, "Approval", StandardERC20)
+	approval.Owner = hashToAddress(log.Topics[1]).Hex()
+	approval.Spender = hashToAddress(log.Topics[2]).Hex()
+	approval.Value = value.String()
+	out.Approvals = append(out.Approvals, approval)
+	return true, nil
+}
+
+// erc721ApprovalDecoder Approval(address indexed owner, address indexed approved, uint256 indexed tokenId)
+type erc721ApprovalDecoder struct{}
+
+func (erc721ApprovalDecoder) Topic() common.Hash { return nftApprovalEvent.topic() }
+
+func (erc721ApprovalDecoder) Decode(tx *database.V3Transaction, log *ethtypes.Log, out *EventData) (bool, error) {
+	if !nftApprovalEvent.match(log) {
+		return false, nil
+	}
+	approval := newApproval(tx, log, "Approval", StandardERC721)
+	approval.Owner = hashToAddress(log.Topics[1]).Hex()
+	approval.Spender = hashToAddress(log.Topics[2]).Hex()
+	approval.TokenId = topicToBig(log.Topics[3]).String()
+	out.Approvals = append(out.Approvals, approval)
+	return true, nil
+}
+
+// approvalForAllDecoder ApprovalForAll(address indexed owner, address indexed operator, bool approved)，
+// ERC721与ERC1155定义相同，无法区分
+type approvalForAllDecoder struct{}
+
+func (approvalForAllDecoder) Topic() common.Hash { return approvalForAll.topic() }
+
+func (approvalForAllDecoder) Decode(tx *database.V3Transaction, log *ethtypes.Log, out *EventData) (bool, error) {
+	if !approvalForAll.match(log) {
+		return false, nil
+	}
+	values, err := approvalForAll.unpack(log)
+	if err != nil {
+		return false, err
+	}
+	approved, ok := values[0].(bool)
+	if !ok {
+		return false, fmt.Errorf("approved is %T, want bool", values[0])
+	}
+	approval := newApproval(tx, log, "ApprovalForAll", "")
+	approval.Owner = hashToAddress(log.Topics[1]).Hex()
+	approval.Spender = hashToAddress(log.Topics[2]).Hex()
+	approval.Approved = approved
+	out.Approvals = append(out.Approvals, approval)
+	return true, nil
+}
+
+// transferSingleDecoder TransferSingle(address indexed operator, address indexed from, address indexed to, uint256 id, uint256 value)
+type transferSingleDecoder struct{}
+
+func (transferSingleDecoder) Topic() common.Hash { return transferSingle.topic() }
+
+func (transferSingleDecoder) Decode(tx *database.V3Transaction, log *ethtypes.Log, out *EventData) (bool, error) {
+	if !transferSingle.match(log) {
+		return false, nil
+	}
+	values, err := transferSingle.unpack(log)
+	if err != nil {
+		return false, err
+	}
+	id, err := unpackBig(values, 0)
+	if err != nil {
+		return false, err
+	}
+	value, err := unpackBig(values, 1)
+	if err != nil {
+		return false, err
+	}
+	transfer := newNFTTransfer(tx, log, StandardERC1155)
+	transfer.Operator = hashToAddress(log.Topics[1]).Hex()
+	transfer.Sender = hashToAddress(log.Topics[2]).Hex()
+	transfer.Receiver = hashToAddress(log.Topics[3]).Hex()
+	transfer.TokenId = id.String()
+	transfer.Value = value.String()
+	out.NFTTransfers = append(out.NFTTransfers, transfer)
+	return true, nil
+}
+
+// transferBatchDecoder TransferBatch(address indexed operator, address indexed from, address indexed to, uint256[] ids, uint256[] values)，
+// 每个id记录一条，BatchIdx为其在数组中的序号
+type transferBatchDecoder struct{}
+
+func (transferBatchDecoder) Topic() common.Hash { return transferBatchEvent.topic() }
+
+func (transferBatchDecoder) Decode(tx *database.V3Transaction, log *ethtypes.Log, out *EventData) (bool, error) {
+	if !transferBatchEvent.match(log) {
+		return false, nil
+	}
+	values, err := transferBatchEvent.unpack(log)
+	if err != nil {
+		return false, err
+	}
+	ids, ok := values[0].([]*big.Int)
+	if !ok {
+		return false, fmt.Errorf("ids is %T, want []*big.Int", values[0])
+	}
+	amounts, ok := values[1].([]*big.Int)
+	if !ok {
+		return false, fmt.Errorf("values is %T, want []*big.Int", values[1])
+	}
+	if len(ids) != len(amounts) {
+		return false, fmt.Errorf("%d ids but %d values", len(ids), len(amounts))
+	}
+	for i := range ids {
+		transfer := newNFTTransfer(tx, log, StandardERC1155)
+		transfer.BatchIdx = uint(i)
+		transfer.Operator = hashToAddress(log.Topics[1]).Hex()
+		transfer.Sender = hashToAddress(log.Topics[2]).Hex()
+		transfer.Receiver = hashToAddress(log.Topics[3]).Hex()
+		transfer.TokenId = ids[i].String()
+		transfer.Value = amounts[i].String()
+		out.NFTTransfers = append(out.NFTTransfers, transfer)
+	}
+	return true, nil
+}
@@ -0,0 +1,242 @@
+package client
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/toolglobal/api/database"
+)
+
+const (
+	testAlice = "0x00000000000000000000000000000000000A11cE"
+	testBob   = "0x0000000000000000000000000000000000000B0b"
+	testCarol = "0x00000000000000000000000000000000000ca201"
+	testToken = "0x1000000000000000000000000000000000000001"
+	testNFT   = "0x2000000000000000000000000000000000000002"
+	testMulti = "0x3000000000000000000000000000000000000003"
+	testWETH  = "0x4000000000000000000000000000000000000004"
+)
+
+// loadTestLogs testdata/events.json为构造的日志，每种事件一条，最后一条为无法识别的事件。
+// 链上真实的日志见events_mainnet.json
+func loadTestLogs(t *testing.T) []*ethtypes.Log {
+	bz, err := ioutil.ReadFile("testdata/events.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var logs []*ethtypes.Log
+	if err := json.Unmarshal(bz, &logs); err != nil {
+		t.Fatal(err)
+	}
+	return logs
+}
+
+// mainnetTx events_mainnet.json中的一笔以太坊主网交易。
+// 日志取自go-ethereum测试数据中回放主网交易得到的callTracer结果，按调用树中产生的顺序排列
+type mainnetTx struct {
+	Source          string         `json:"source"`
+	BlockNumber     int64          `json:"blockNumber"`
+	TransactionHash common.Hash    `json:"transactionHash"`
+	From            common.Address `json:"from"`
+	Logs            []struct {
+		Address common.Address `json:"address"`
+		Topics  []common.Hash  `json:"topics"`
+		Data    hexutil.Bytes  `json:"data"`
+	} `json:"logs"`
+}
+
+func loadMainnetTxs(t *testing.T) []mainnetTx {
+	bz, err := ioutil.ReadFile("testdata/events_mainnet.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var txs []mainnetTx
+	if err := json.Unmarshal(bz, &txs); err != nil {
+		t.Fatal(err)
+	}
+	return txs
+}
+
+func testEventTx() *database.V3Transaction {
+	return &database.V3Transaction{
+		Hash:      "0x01",
+		Height:    2,
+		Sender:    common.HexToAddress(testAlice).Hex(),
+		CreatedAt: time.Unix(1600000000, 0),
+	}
+}
+
+func hexAddr(s string) string {
+	return common.HexToAddress(s).Hex()
+}
+
+func TestEventRegistry_Decode(t *testing.T) {
+	logs := loadTestLogs(t)
+	tx := testEventTx()
+
+	events, err := DefaultEventRegistry().Decode(tx, logs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantPayments := []database.V3Payment{
+		{EvName: "Transfer", Idx: 0, Contract: hexAddr(testToken), Sender: hexAddr(testAlice), Receiver: hexAddr(testBob), Value: "1000"},
+		{EvName: "Deposit", Idx: 2, Contract: hexAddr(testWETH), Sender: tx.Sender, Receiver: hexAddr(testAlice), Value: "5"},
+		{EvName: "Withdrawal", Idx: 3, Contract: hexAddr(testWETH), Sender: hexAddr(testAlice), Receiver: tx.Sender, Value: "3"},
+	}
+	if len(events.Payments) != len(wantPayments) {
+		t.Fatalf("payments %+v", events.Payments)
+	}
+	for i, want := range wantPayments {
+		want.Hash, want.Height, want.CreatedAt = tx.Hash, tx.Height, tx.CreatedAt
+		if events.Payments[i] != want {
+			t.Errorf("payment %d got %+v, want %+v", i, events.Payments[i], want)
+		}
+	}
+
+	wantNFTs := []database.V3NFTTransfer{
+		{Idx: 1, Standard: StandardERC721, Contract: hexAddr(testNFT), Sender: hexAddr(testAlice), Receiver: hexAddr(testBob), TokenId: "42", Value: "1"},
+		{Idx: 7, Standard: StandardERC1155, Contract: hexAddr(testMulti), Operator: hexAddr(testCarol), Sender: hexAddr(testAlice), Receiver: hexAddr(testBob), TokenId: "7", Value: "10"},
+		{Idx: 8, BatchIdx: 0, Standard: StandardERC1155, Contract: hexAddr(testMulti), Operator: hexAddr(testCarol), Sender: common.Address{}.Hex(), Receiver: hexAddr(testBob), TokenId: "1", Value: "100"},
+		{Idx: 8, BatchIdx: 1, Standard: StandardERC1155, Contract: hexAddr(testMulti), Operator: hexAddr(testCarol), Sender: common.Address{}.Hex(), Receiver: hexAddr(testBob), TokenId: "2", Value: "200"},
+	}
+	if len(events.NFTTransfers) != len(wantNFTs) {
+		t.Fatalf("nft transfers %+v", events.NFTTransfers)
+	}
+	for i, want := range wantNFTs {
+		want.Hash, want.Height, want.CreatedAt = tx.Hash, tx.Height, tx.CreatedAt
+		if events.NFTTransfers[i] != want {
+			t.Errorf("nft transfer %d got %+v, want %+v", i, events.NFTTransfers[i], want)
+		}
+	}
+
+	wantApprovals := []database.V3Approval{
+		{Idx: 4, EvName: "Approval", Standard: StandardERC20, Contract: hexAddr(testToken), Owner: hexAddr(testAlice), Spender: hexAddr(testCarol), Value: "777", Approved: true},
+		{Idx: 5, EvName: "Approval", Standard: StandardERC721, Contract: hexAddr(testNFT), Owner: hexAddr(testBob), Spender: hexAddr(testCarol), TokenId: "42", Approved: true},
+		{Idx: 6, EvName: "ApprovalForAll", Contract: hexAddr(testNFT), Owner: hexAddr(testBob), Spender: hexAddr(testCarol), Approved: false},
+	}
+	if len(events.Approvals) != len(wantApprovals) {
+		t.Fatalf("approvals %+v", events.Approvals)
+	}
+	for i, want := range wantApprovals {
+		want.Hash, want.Height, want.CreatedAt = tx.Hash, tx.Height, tx.CreatedAt
+		if events.Approvals[i] != want {
+			t.Errorf("approval %d got %+v, want %+v", i, events.Approvals[i], want)
+		}
+	}
+}
+
+func TestEventRegistry_DecodeMainnet(t *testing.T) {
+	const (
+		token1 = "0xf4eced2f682ce333f96f2d8966c613ded8fc95dd"
+		token2 = "0x92f1dbea03ce08225e31e95cc926ddbe0198e6f2"
+		token3 = "0xf4cbd7e037b80c2e67b80512d482685f15b1fb28" // 代理合约，Transfer由DELEGATECALL的实现合约产生
+		user1  = "0xd1220a0cf47c7b9be7a2e6ba89f429762e7b9adb"
+		user2  = "0xdbf03b407c01e7cd3cbea99509d93f8dddc8c6fb"
+		user3  = "0x3de712784baf97260455ae25fb74f574ec9c1add"
+		user4  = "0x6ca7f214ab2ddbb9a8e1a1e2c8550e3164e9dba5"
+		user5  = "0x5aae5c59d642e5fd45b427df6ed478b49d55fefd"
+		user6  = "0x950ca4a06c78934a148b7a3ff3ea8fc366f77a06"
+	)
+	cases := []struct {
+		payments  []database.V3Payment
+		approvals []database.V3Approval
+	}{
+		{
+			payments: []database.V3Payment{
+				{EvName: "Transfer", Idx: 0, Contract: hexAddr(token1), Sender: hexAddr(user1), Receiver: hexAddr(user2), Value: "10000000"},
+			},
+		},
+		{
+			payments: []database.V3Payment{
+				{EvName: "Transfer", Idx: 0, Contract: hexAddr(token2), Sender: hexAddr(user3), Receiver: hexAddr(user4), Value: "9282657316418596268"},
+				{EvName: "Transfer", Idx: 2, Contract: hexAddr(token2), Sender: hexAddr(user4), Receiver: hexAddr(user5), Value: "9282657316418596268"},
+				{EvName: "Transfer", Idx: 3, Contract: hexAddr(token2), Sender: hexAddr(user5), Receiver: hexAddr(user6), Value: "18565314632837192"},
+				{EvName: "Transfer", Idx: 4, Contract: hexAddr(token3), Sender: hexAddr(user4), Receiver: hexAddr(user3), Value: "16000000000000000000"},
+			},
+			approvals: []database.V3Approval{
+				{Idx: 1, EvName: "Approval", Standard: StandardERC20, Contract: hexAddr(token2), Owner: hexAddr(user4), Spender: hexAddr(user5), Value: "9282657316418596268", Approved: true},
+			},
+		},
+	}
+
+	txs := loadMainnetTxs(t)
+	if len(txs) != len(cases) {
+		t.Fatalf("got %d mainnet txs", len(txs))
+	}
+	for i, c := range cases {
+		mtx := txs[i]
+		tx := &database.V3Transaction{Hash: mtx.TransactionHash.Hex(), Height: mtx.BlockNumber, Sender: mtx.From.Hex(), CreatedAt: time.Unix(1600000000, 0)}
+		var logs []*ethtypes.Log
+		for idx, l := range mtx.Logs {
+			logs = append(logs, &ethtypes.Log{Address: l.Address, Topics: l.Topics, Data: l.Data, BlockNumber: uint64(mtx.BlockNumber), TxHash: mtx.TransactionHash, Index: uint(idx)})
+		}
+
+		events, err := DefaultEventRegistry().Decode(tx, logs)
+		if err != nil {
+			t.Fatalf("%s: %v", mtx.Source, err)
+		}
+		if len(events.Payments) != len(c.payments) || len(events.Approvals) != len(c.approvals) || len(events.NFTTransfers) != 0 {
+			t.Fatalf("%s: payments %+v approvals %+v nft transfers %+v", mtx.Source, events.Payments, events.Approvals, events.NFTTransfers)
+		}
+		for j, want := range c.payments {
+			want.Hash, want.Height, want.CreatedAt = tx.Hash, tx.Height, tx.CreatedAt
+			if events.Payments[j] != want {
+				t.Errorf("%s: payment %d got %+v, want %+v", mtx.Source, j, events.Payments[j], want)
+			}
+		}
+		for j, want := range c.approvals {
+			want.Hash, want.Height, want.CreatedAt = tx.Hash, tx.Height, tx.CreatedAt
+			if events.Approvals[j] != want {
+				t.Errorf("%s: approval %d got %+v, want %+v", mtx.Source, j, events.Approvals[j], want)
+			}
+		}
+	}
+}
+
+func TestEventRegistry_DecodeError(t *testing.T) {
+	logs := loadTestLogs(t)
+	// 数据长度不对的Transfer，跳过并返回错误，不影响后续日志
+	bad := *logs[0]
+	bad.Data = bad.Data[:16]
+	logs = []*ethtypes.Log{&bad, logs[2]}
+
+	events, err := DefaultEventRegistry().Decode(testEventTx(), logs)
+	if err == nil {
+		t.Fatal("want error")
+	}
+	if len(events.Payments) != 1 || events.Payments[0].EvName != "Deposit" {
+		t.Fatalf("payments %+v", events.Payments)
+	}
+}
+
+type countDecoder struct {
+	topic common.Hash
+	n     int
+}
+
+func (d *countDecoder) Topic() common.Hash { return d.topic }
+
+func (d *countDecoder) Decode(tx *database.V3Transaction, log *ethtypes.Log, out *EventData) (bool, error) {
+	d.n++
+	return true, nil
+}
+
+func TestEventRegistry_Register(t *testing.T) {
+	logs := loadTestLogs(t)
+	unknown := &countDecoder{topic: logs[len(logs)-1].Topics[0]}
+	registry := DefaultEventRegistry()
+	registry.Register(unknown)
+
+	if _, err := registry.Decode(testEventTx(), logs); err != nil {
+		t.Fatal(err)
+	}
+	if unknown.n != 1 {
+		t.Fatalf("custom decoder called %d times", unknown.n)
+	}
+}
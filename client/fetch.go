@@ -2,6 +2,7 @@ package client
 
 import (
 	"context"
+	"fmt"
 	abcitypes "github.com/tendermint/tendermint/abci/types"
 	"github.com/tendermint/tendermint/rpc/client/http"
 	tmtypes "github.com/tendermint/tendermint/types"
@@ -14,8 +15,15 @@ type Fetcher interface {
 	FetchBlockResultInfo(height int64) ([]*abcitypes.ResponseDeliverTx, error)
 }
 
+// Subscriber 支持订阅新区块通知的Fetcher
+type Subscriber interface {
+	// SubscribeNewBlock 订阅新区块，返回的channel在ctx结束或连接失败时关闭
+	SubscribeNewBlock(ctx context.Context) (<-chan int64, error)
+}
+
 // DefaultFetcher Fetcher impl
 type DefaultFetcher struct {
+	remote        string
 	abciRpcClient *http.HTTP
 }
 
@@ -25,19 +33,40 @@ type Block struct {
 }
 
 func NewFetch(rpcRemote string) *DefaultFetcher {
-	cli, err := http.New(rpcRemote, "/websocket")
+	f, err := newDefaultFetcher(rpcRemote)
 	if err != nil {
 		panic(err)
 	}
+	return f
+}
+
+func newDefaultFetcher(rpcRemote string) (*DefaultFetcher, error) {
+	cli, err := http.New(rpcRemote, "/websocket")
+	if err != nil {
+		return nil, err
+	}
 	f := DefaultFetcher{
+		remote:        rpcRemote,
 		abciRpcClient: cli,
 	}
-	return &f
+	return &f, nil
 }
 
 // FetchBlockInfo 获取区块信息
 func (f *DefaultFetcher) FetchBlockInfo(height int64) (*Block, error) {
-	resp, err := f.abciRpcClient.Block(context.Background(), &height)
+	return f.fetchBlockInfo(context.Background(), height)
+}
+
+func (f *DefaultFetcher) FetchBlockResultInfo(height int64) ([]*abcitypes.ResponseDeliverTx, error) {
+	return f.fetchBlockResultInfo(context.Background(), height)
+}
+
+func (f *DefaultFetcher) LastBlockHeight() (int64, error) {
+	return f.lastBlockHeight(context.Background())
+}
+
+func (f *DefaultFetcher) fetchBlockInfo(ctx context.Context, height int64) (*Block, error) {
+	resp, err := f.abciRpcClient.Block(ctx, &height)
 	if err != nil {
 		return nil, err
 	}
@@ -50,18 +79,72 @@ func (f *DefaultFetcher) FetchBlockInfo(height int64) (*Block, error) {
 	return &block, nil
 }
 
-func (f *DefaultFetcher) FetchBlockResultInfo(height int64) ([]*abcitypes.ResponseDeliverTx, error) {
-	resp, err := f.abciRpcClient.BlockResults(context.Background(), &height)
+func (f *DefaultFetcher) fetchBlockResultInfo(ctx context.Context, height int64) ([]*abcitypes.ResponseDeliverTx, error) {
+	resp, err := f.abciRpcClient.BlockResults(ctx, &height)
 	if err != nil {
 		return nil, err
 	}
 	return resp.TxsResults, nil
 }
 
-func (f *DefaultFetcher) LastBlockHeight() (int64, error) {
-	result, err := f.abciRpcClient.ABCIInfo(context.Background())
+func (f *DefaultFetcher) lastBlockHeight(ctx context.Context) (int64, error) {
+	result, err := f.abciRpcClient.ABCIInfo(ctx)
 	if err != nil {
 		return 0, err
 	}
 	return result.Response.LastBlockHeight, nil
 }
+
+// blockHash 只获取区块头中的hash，用于比较节点是否在同一条链上
+func (f *DefaultFetcher) blockHash(ctx context.Context, height int64) (string, error) {
+	result, err := f.abciRpcClient.BlockchainInfo(ctx, height, height)
+	if err != nil {
+		return "", err
+	}
+	if len(result.BlockMetas) == 0 {
+		return "", fmt.Errorf("block %d not found", height)
+	}
+	return result.BlockMetas[0].BlockID.Hash.String(), nil
+}
+
+// SubscribeNewBlock 通过节点的/websocket订阅新区块。
+// 每次订阅使用独立的连接，调用方取消ctx即可断开，断开后重新调用建立新连接。
+func (f *DefaultFetcher) SubscribeNewBlock(ctx context.Context) (<-chan int64, error) {
+	cli, err := http.New(f.remote, "/websocket")
+	if err != nil {
+		return nil, err
+	}
+	if err := cli.Start(); err != nil {
+		return nil, err
+	}
+
+	// NewBlockHeader与NewBlock同时发布，但不携带区块交易
+	query := tmtypes.EventQueryNewBlockHeader.String()
+	events, err := cli.Subscribe(ctx, "api", query, 16)
+	if err != nil {
+		cli.Stop()
+		return nil, err
+	}
+
+	heights := make(chan int64, 1)
+	go func() {
+		defer close(heights)
+		defer cli.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev := <-events:
+				data, ok := ev.Data.(tmtypes.EventDataNewBlockHeader)
+				if !ok {
+					continue
+				}
+				select {
+				case heights <- data.Header.Height:
+				default: // 同步协程尚未处理上一个通知，丢弃即可
+				}
+			}
+		}
+	}()
+	return heights, nil
+}
@@ -0,0 +1,59 @@
+package client
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	metricIndexedHeight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "api",
+		Subsystem: "sync",
+		Name:      "indexed_height",
+		Help:      "Height of the last block saved to the database.",
+	})
+	metricNodeHeight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "api",
+		Subsystem: "sync",
+		Name:      "node_height",
+		Help:      "Last block height reported by the node.",
+	})
+	metricLag = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "api",
+		Subsystem: "sync",
+		Name:      "lag_blocks",
+		Help:      "Number of blocks the index is behind the node.",
+	})
+	metricRPCUp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "api",
+		Subsystem: "sync",
+		Name:      "rpc_up",
+		Help:      "Whether the rpc endpoint is used for fetching blocks (1) or excluded as down or diverged (0).",
+	}, []string{"remote"})
+	metricFetchDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "api",
+		Subsystem: "sync",
+		Name:      "fetch_duration_seconds",
+		Help:      "Time spent fetching and decoding one block.",
+		Buckets:   prometheus.DefBuckets,
+	})
+)
+
+func init() {
+	prometheus.MustRegister(metricIndexedHeight, metricNodeHeight, metricLag, metricRPCUp, metricFetchDuration)
+}
+
+func observeHeights(indexed, node int64) {
+	metricIndexedHeight.Set(float64(indexed))
+	metricNodeHeight.Set(float64(node))
+	if lag := node - indexed; lag > 0 {
+		metricLag.Set(float64(lag))
+	} else {
+		metricLag.Set(0)
+	}
+}
+
+func observeFetch(start time.Time) {
+	metricFetchDuration.Observe(time.Since(start).Seconds())
+}
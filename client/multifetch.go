@@ -0,0 +1,321 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	abcitypes "github.com/tendermint/tendermint/abci/types"
+	"github.com/toolglobal/api/config"
+	"github.com/toolglobal/api/libs/log"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultFetchTimeout = 10 * time.Second
+	defaultFetchRetries = 3
+	defaultFetchMaxLag  = 10
+	fetchRetryBase      = 200 * time.Millisecond
+)
+
+// MultiFetcher 从多个节点拉取区块。请求在可用节点之间轮流分配，出错或超时时换节点按退避间隔重试；
+// 每次查询最新高度时检查所有节点：无响应、落后超过maxLag、区块hash与多数节点不一致的节点不参与拉取，恢复后自动重新使用
+type MultiFetcher struct {
+	nodes     []*fetchNode
+	timeout   time.Duration // 单次请求超时
+	retries   int           // 失败后重试次数
+	retryBase time.Duration
+	maxLag    int64
+
+	mu       sync.Mutex
+	next     int   // 轮询位置
+	top      int64 // 可用节点的最高高度
+	verified int64 // 上次比较区块hash的高度
+}
+
+type fetchNode struct {
+	*DefaultFetcher
+	up       bool  // 最近一次请求是否成功
+	diverged bool  // 区块hash与多数节点不一致
+	height   int64 // 最近一次查询到的高度
+}
+
+// NewMultiFetcher remotes为节点RPC地址，例如http://127.0.0.1:26657，配置的顺序在hash比较票数相同时作为优先级
+func NewMultiFetcher(remotes []string, cfg config.Fetch) (*MultiFetcher, error) {
+	if len(remotes) == 0 {
+		return nil, errors.New("no rpc endpoint configured")
+	}
+
+	f := &MultiFetcher{
+		timeout:   cfg.Timeout.Duration,
+		retries:   cfg.Retries,
+		retryBase: fetchRetryBase,
+		maxLag:    cfg.MaxLag,
+	}
+	if f.timeout <= 0 {
+		f.timeout = defaultFetchTimeout
+	}
+	if f.retries <= 0 {
+		f.retries = defaultFetchRetries
+	}
+	if f.maxLag <= 0 {
+		f.maxLag = defaultFetchMaxLag
+	}
+
+	for _, remote := range remotes {
+		df, err := newDefaultFetcher(remote)
+		if err != nil {
+			return nil, fmt.Errorf("rpc %s: %v", remote, err)
+		}
+		n := &fetchNode{DefaultFetcher: df, up: true}
+		f.nodes = append(f.nodes, n)
+		metricRPCUp.WithLabelValues(remote).Set(1)
+	}
+	return f, nil
+}
+
+// LastBlockHeight 查询所有节点的高度，返回可用节点中的最高高度，分叉节点的高度不计入
+func (f *MultiFetcher) LastBlockHeight() (int64, error) {
+	heights := make([]int64, len(f.nodes))
+	errs := make([]error, len(f.nodes))
+	f.each(f.nodes, func(ctx context.Context, i int, n *fetchNode) {
+		heights[i], errs[i] = n.lastBlockHeight(ctx)
+	})
+
+	f.mu.Lock()
+	var top int64
+	for i, n := range f.nodes {
+		f.mark(n, errs[i])
+		if errs[i] == nil {
+			n.height = heights[i]
+			if !n.diverged && heights[i] > top {
+				top = heights[i]
+			}
+		}
+	}
+	f.top = top
+	f.mu.Unlock()
+
+	f.verify()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var (
+		height    int64
+		available bool
+	)
+	for _, n := range f.nodes {
+		if f.usable(n, 0) {
+			available = true
+			if n.height > height {
+				height = n.height
+			}
+		}
+	}
+	if !available {
+		return 0, fmt.Errorf("no rpc endpoint available: %v", firstError(errs))
+	}
+	return height, nil
+}
+
+// verify 取未落后节点的共同高度比较区块hash，与多数节点不一致的节点标记为分叉
+func (f *MultiFetcher) verify() {
+	f.mu.Lock()
+	var (
+		candidates []*fetchNode
+		common     int64
+	)
+	for _, n := range f.nodes {
+		if n.up && n.height >= f.top-f.maxLag {
+			candidates = append(candidates, n)
+			if common == 0 || n.height < common {
+				common = n.height
+			}
+		}
+	}
+	skip := len(candidates) < 2 || common <= f.verified
+	f.mu.Unlock()
+	if skip {
+		return
+	}
+
+	hashes := make([]string, len(candidates))
+	errs := make([]error, len(candidates))
+	f.each(candidates, func(ctx context.Context, i int, n *fetchNode) {
+		hashes[i], errs[i] = n.blockHash(ctx, common)
+	})
+
+	votes := make(map[string]int)
+	var majority string
+	for i, hash := range hashes {
+		if errs[i] != nil {
+			continue
+		}
+		votes[hash]++
+		// 票数相同时取配置在前的节点
+		if majority == "" || votes[hash] > votes[majority] {
+			majority = hash
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, n := range candidates {
+		if errs[i] != nil {
+			f.mark(n, errs[i])
+			continue
+		}
+		diverged := hashes[i] != majority
+		if diverged != n.diverged {
+			if diverged {
+				log.Logger.Error("rpc endpoint diverged", zap.String("remote", n.remote), zap.Int64("height", common),
+					zap.String("hash", hashes[i]), zap.String("majority", majority))
+			} else {
+				log.Logger.Info("rpc endpoint agrees again", zap.String("remote", n.remote), zap.Int64("height", common))
+			}
+			n.diverged = diverged
+			f.observe(n)
+		}
+	}
+	if majority != "" {
+		f.verified = common
+	}
+}
+
+func (f *MultiFetcher) FetchBlockInfo(height int64) (*Block, error) {
+	var block *Block
+	err := f.do(height, func(ctx context.Context, n *fetchNode) (err error) {
+		block, err = n.fetchBlockInfo(ctx, height)
+		return err
+	})
+	return block, err
+}
+
+func (f *MultiFetcher) FetchBlockResultInfo(height int64) ([]*abcitypes.ResponseDeliverTx, error) {
+	var results []*abcitypes.ResponseDeliverTx
+	err := f.do(height, func(ctx context.Context, n *fetchNode) (err error) {
+		results, err = n.fetchBlockResultInfo(ctx, height)
+		return err
+	})
+	return results, err
+}
+
+// SubscribeNewBlock 订阅一个可用节点，节点断开后由调用方重新订阅时换到下一个节点
+func (f *MultiFetcher) SubscribeNewBlock(ctx context.Context) (<-chan int64, error) {
+	return f.pick(0, nil).SubscribeNewBlock(ctx)
+}
+
+// do 在拥有该高度的可用节点上执行请求，失败时换节点重试
+func (f *MultiFetcher) do(height int64, call func(ctx context.Context, n *fetchNode) error) error {
+	var (
+		last *fetchNode
+		err  error
+	)
+	for attempt := 0; attempt <= f.retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(f.backoff(attempt))
+		}
+
+		n := f.pick(height, last)
+		ctx, cancel := context.WithTimeout(context.Background(), f.timeout)
+		err = call(ctx, n)
+		cancel()
+
+		f.mu.Lock()
+		f.mark(n, err)
+		f.mu.Unlock()
+		if err == nil {
+			return nil
+		}
+		log.Logger.Warn("fetch", zap.String("remote", n.remote), zap.Int64("height", height), zap.Int("attempt", attempt), zap.Error(err))
+		last = n
+	}
+	return err
+}
+
+// pick 轮流选择节点，优先拥有该高度的可用节点，其次尚未确认分叉的节点；尽量避开上次失败的节点
+func (f *MultiFetcher) pick(height int64, last *fetchNode) *fetchNode {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	tiers := []func(n *fetchNode) bool{
+		func(n *fetchNode) bool { return f.usable(n, height) },
+		func(n *fetchNode) bool { return !n.diverged },
+	}
+	for _, ok := range tiers {
+		for i := range f.nodes {
+			idx := (f.next + i) % len(f.nodes)
+			if n := f.nodes[idx]; n != last && ok(n) {
+				f.next = idx + 1
+				return n
+			}
+		}
+	}
+	if last != nil {
+		return last
+	}
+	return f.nodes[0]
+}
+
+// usable 节点可以提供height高度的区块，调用方持有锁
+func (f *MultiFetcher) usable(n *fetchNode, height int64) bool {
+	return n.up && !n.diverged && n.height >= height && n.height >= f.top-f.maxLag
+}
+
+// mark 记录请求结果，节点状态变化时打印日志，调用方持有锁
+func (f *MultiFetcher) mark(n *fetchNode, err error) {
+	up := err == nil
+	if up == n.up {
+		return
+	}
+	if up {
+		log.Logger.Info("rpc endpoint up", zap.String("remote", n.remote))
+	} else {
+		log.Logger.Warn("rpc endpoint down", zap.String("remote", n.remote), zap.Error(err))
+	}
+	n.up = up
+	f.observe(n)
+}
+
+func (f *MultiFetcher) observe(n *fetchNode) {
+	var v float64
+	if n.up && !n.diverged {
+		v = 1
+	}
+	metricRPCUp.WithLabelValues(n.remote).Set(v)
+}
+
+// each 并发请求nodes，每个请求单独超时
+func (f *MultiFetcher) each(nodes []*fetchNode, call func(ctx context.Context, i int, n *fetchNode)) {
+	var wg sync.WaitGroup
+	for i, n := range nodes {
+		wg.Add(1)
+		go func(i int, n *fetchNode) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), f.timeout)
+			defer cancel()
+			call(ctx, i, n)
+		}(i, n)
+	}
+	wg.Wait()
+}
+
+// backoff 第attempt次重试前的等待时间，从retryBase开始翻倍，不超过单次请求超时
+func (f *MultiFetcher) backoff(attempt int) time.Duration {
+	d := f.retryBase << uint(attempt-1)
+	if d > f.timeout || d <= 0 {
+		d = f.timeout
+	}
+	return d
+}
+
+func firstError(errs []error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,260 @@
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	abcitypes "github.com/tendermint/tendermint/abci/types"
+	tmjson "github.com/tendermint/tendermint/libs/json"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+	rpctypes "github.com/tendermint/tendermint/rpc/jsonrpc/types"
+	tmtypes "github.com/tendermint/tendermint/types"
+	"github.com/toolglobal/api/config"
+)
+
+// fakeRPC 模拟节点的tendermint RPC，只实现同步用到的abci_info、block、block_results、blockchain
+type fakeRPC struct {
+	*httptest.Server
+	mu     sync.Mutex
+	height int64
+	chain  string        // 区块hash的种子，不同的种子模拟分叉
+	down   bool          // 返回503
+	delay  time.Duration // 响应前等待，模拟超时
+	blocks int           // 收到的block请求数
+}
+
+func newFakeRPC(t *testing.T, height int64, chain string) *fakeRPC {
+	f := &fakeRPC{height: height, chain: chain}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeRPC) set(fn func(f *fakeRPC)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fn(f)
+}
+
+func (f *fakeRPC) blockCalls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.blocks
+}
+
+func (f *fakeRPC) blockID(height int64) tmtypes.BlockID {
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%s/%d", f.chain, height)))
+	return tmtypes.BlockID{Hash: hash[:]}
+}
+
+func (f *fakeRPC) serve(w http.ResponseWriter, r *http.Request) {
+	var req rpctypes.RPCRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var params struct {
+		Height    int64 `json:"height,string"`
+		MinHeight int64 `json:"minHeight,string"`
+	}
+	if len(req.Params) > 0 {
+		if err := tmjson.Unmarshal(req.Params, &params); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	f.mu.Lock()
+	down, delay, height := f.down, f.delay, f.height
+	if req.Method == "block" {
+		f.blocks++
+	}
+	f.mu.Unlock()
+	time.Sleep(delay)
+	if down {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	var result interface{}
+	switch req.Method {
+	case "abci_info":
+		result = &ctypes.ResultABCIInfo{Response: abcitypes.ResponseInfo{LastBlockHeight: height}}
+	case "block", "block_results", "blockchain":
+		h := params.Height
+		if req.Method == "blockchain" {
+			h = params.MinHeight
+		}
+		if h > height {
+			json.NewEncoder(w).Encode(rpctypes.RPCInternalError(req.ID, fmt.Errorf("height %d must be less than or equal to the current blockchain height %d", h, height)))
+			return
+		}
+		switch req.Method {
+		case "block":
+			result = &ctypes.ResultBlock{BlockID: f.blockID(h), Block: &tmtypes.Block{Header: tmtypes.Header{ChainID: "test", Height: h}}}
+		case "block_results":
+			result = &ctypes.ResultBlockResults{Height: h, TxsResults: []*abcitypes.ResponseDeliverTx{{Info: f.chain}}}
+		default:
+			result = &ctypes.ResultBlockchainInfo{LastHeight: height, BlockMetas: []*tmtypes.BlockMeta{{BlockID: f.blockID(h)}}}
+		}
+	default:
+		json.NewEncoder(w).Encode(rpctypes.RPCMethodNotFoundError(req.ID))
+		return
+	}
+	json.NewEncoder(w).Encode(rpctypes.NewRPCSuccessResponse(req.ID, result))
+}
+
+func newTestMultiFetcher(t *testing.T, cfg config.Fetch, nodes ...*fakeRPC) *MultiFetcher {
+	remotes := make([]string, len(nodes))
+	for i, n := range nodes {
+		remotes[i] = n.URL
+	}
+	f, err := NewMultiFetcher(remotes, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.retryBase = time.Millisecond
+	return f
+}
+
+func lastBlockHeight(t *testing.T, f *MultiFetcher, want int64) {
+	height, err := f.LastBlockHeight()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if height != want {
+		t.Fatalf("LastBlockHeight = %d, want %d", height, want)
+	}
+}
+
+func fetchBlocks(t *testing.T, f *MultiFetcher, from, to int64) {
+	for h := from; h <= to; h++ {
+		block, err := f.FetchBlockInfo(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if block.Block.Height != h {
+			t.Fatalf("block %d: got height %d", h, block.Block.Height)
+		}
+	}
+}
+
+func TestMultiFetcher_Failover(t *testing.T) {
+	a := newFakeRPC(t, 10, "main")
+	b := newFakeRPC(t, 10, "main")
+	f := newTestMultiFetcher(t, config.Fetch{}, a, b)
+
+	lastBlockHeight(t, f, 10)
+	fetchBlocks(t, f, 1, 10)
+	// 请求在节点之间轮流分配
+	if a.blockCalls() != 5 || b.blockCalls() != 5 {
+		t.Fatalf("block calls a=%d b=%d", a.blockCalls(), b.blockCalls())
+	}
+	if results, err := f.FetchBlockResultInfo(3); err != nil || len(results) != 1 {
+		t.Fatalf("block results %v %v", results, err)
+	}
+
+	// a重启期间由b提供全部请求
+	a.set(func(f *fakeRPC) { f.down = true })
+	fetchBlocks(t, f, 1, 10)
+	b.set(func(f *fakeRPC) { f.height = 11 })
+	lastBlockHeight(t, f, 11)
+	before := a.blockCalls()
+	fetchBlocks(t, f, 1, 11)
+	if a.blockCalls() != before {
+		t.Fatalf("down node still used")
+	}
+
+	// 恢复后重新参与
+	a.set(func(f *fakeRPC) { f.down, f.height = false, 11 })
+	lastBlockHeight(t, f, 11)
+	fetchBlocks(t, f, 1, 4)
+	if a.blockCalls() != before+2 {
+		t.Fatalf("recovered node not used: %d", a.blockCalls()-before)
+	}
+
+	// 全部不可用
+	a.set(func(f *fakeRPC) { f.down = true })
+	b.set(func(f *fakeRPC) { f.down = true })
+	if _, err := f.LastBlockHeight(); err == nil {
+		t.Fatal("expected error")
+	}
+	if _, err := f.FetchBlockInfo(1); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestMultiFetcher_Timeout(t *testing.T) {
+	a := newFakeRPC(t, 10, "main")
+	b := newFakeRPC(t, 10, "main")
+	f := newTestMultiFetcher(t, config.Fetch{}, a, b)
+	f.timeout = 50 * time.Millisecond
+	lastBlockHeight(t, f, 10)
+
+	a.set(func(f *fakeRPC) { f.delay = time.Second })
+	start := time.Now()
+	fetchBlocks(t, f, 1, 4)
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("slow node not skipped: %v", elapsed)
+	}
+}
+
+func TestMultiFetcher_Lagging(t *testing.T) {
+	a := newFakeRPC(t, 10, "main")
+	b := newFakeRPC(t, 9, "main")
+	c := newFakeRPC(t, 5, "main")
+	f := newTestMultiFetcher(t, config.Fetch{MaxLag: 2}, a, b, c)
+
+	lastBlockHeight(t, f, 10)
+	// b没有高度10，c落后超过maxLag不参与
+	fetchBlocks(t, f, 10, 10)
+	fetchBlocks(t, f, 1, 9)
+	if c.blockCalls() != 0 || b.blockCalls() == 0 {
+		t.Fatalf("block calls a=%d b=%d c=%d", a.blockCalls(), b.blockCalls(), c.blockCalls())
+	}
+	if b.blockCalls()+a.blockCalls() != 10 {
+		t.Fatalf("retried %d", b.blockCalls()+a.blockCalls()-10)
+	}
+}
+
+func TestMultiFetcher_Diverged(t *testing.T) {
+	a := newFakeRPC(t, 10, "main")
+	b := newFakeRPC(t, 10, "fork")
+	c := newFakeRPC(t, 10, "main")
+	f := newTestMultiFetcher(t, config.Fetch{}, a, b, c)
+
+	lastBlockHeight(t, f, 10)
+	fetchBlocks(t, f, 1, 10)
+	if b.blockCalls() != 0 {
+		t.Fatalf("diverged node used %d times", b.blockCalls())
+	}
+	for h := int64(1); h <= 10; h++ {
+		block, err := f.FetchBlockInfo(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := a.blockID(h); !block.BlockID.Equals(want) {
+			t.Fatalf("block %d from fork", h)
+		}
+	}
+
+	// 分叉节点高度领先也不计入
+	b.set(func(f *fakeRPC) { f.height = 20 })
+	lastBlockHeight(t, f, 10)
+
+	// 重新同步到主链后恢复使用
+	b.set(func(f *fakeRPC) { f.chain, f.height = "main", 11 })
+	a.set(func(f *fakeRPC) { f.height = 11 })
+	c.set(func(f *fakeRPC) { f.height = 11 })
+	lastBlockHeight(t, f, 11)
+	fetchBlocks(t, f, 1, 6)
+	if b.blockCalls() != 2 {
+		t.Fatalf("recovered node used %d times", b.blockCalls())
+	}
+}
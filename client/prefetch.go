@@ -0,0 +1,164 @@
+package client
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/toolglobal/api/datamanager"
+	"github.com/toolglobal/api/libs/log"
+	"go.uber.org/zap"
+)
+
+// defaultTipDistance 未配置时，距离最新高度小于该值的区块逐块提交
+const defaultTipDistance = 3
+
+// sync 同步一轮：并发预取[currentHeight, lastBlockHeight]中最多batchSize个区块，再按高度顺序入库
+func (cli *Client) sync(lastBlockHeight int64) {
+	batchSize := int64(cli.batchSize)
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+	end := cli.currentHeight + batchSize - 1
+	if end > lastBlockHeight {
+		end = lastBlockHeight
+	}
+
+	datas, err := cli.fetchRange(cli.currentHeight, end)
+	if err != nil {
+		log.Logger.Error("GetV3BlockData", zap.Error(err), zap.Int64("height", cli.currentHeight+int64(len(datas))))
+		// 第一个高度就失败（节点不可用）时等待后重试，避免反复请求节点
+		if len(datas) == 0 {
+			time.Sleep(time.Second)
+			return
+		}
+	}
+
+	// 第一个区块与库中比对，其余区块与前一个预取的区块比对
+	for i, data := range datas {
+		if i > 0 {
+			if datas[i-1].ledger.BlockHash != data.parentHash {
+				// 预取期间节点发生了分叉，先保存之前的区块，下一轮再与库比对
+				datas = datas[:i]
+				break
+			}
+			continue
+		}
+
+		forked, err := cli.isForked(data)
+		if err != nil {
+			log.Logger.Error("isForked", zap.Error(err), zap.Int64("height", cli.currentHeight))
+			return
+		}
+		if forked {
+			if err := cli.reorg(cli.currentHeight - 1); err != nil {
+				log.Logger.Error("reorg", zap.Error(err), zap.Int64("height", cli.currentHeight))
+				time.Sleep(time.Second)
+			}
+			return
+		}
+	}
+
+	// 追块时距离最新高度较远的区块在一个事务中批量提交，接近最新高度时逐块提交
+	tipDistance := int64(cli.tipDistance)
+	if tipDistance <= 0 {
+		tipDistance = defaultTipDistance
+	}
+	n := 0
+	for n < len(datas) && datas[n].ledger.Height <= lastBlockHeight-tipDistance {
+		n++
+	}
+	if n > 1 {
+		if err := cli.SaveV3Batch(datas[:n]); err != nil {
+			log.Logger.Error("SaveV3Batch", zap.Error(err), zap.Int64("height", cli.currentHeight))
+			cli.skipIndexed(err)
+			return
+		}
+		log.Logger.Info("fetch ok", zap.Int64("from", cli.currentHeight), zap.Int64("to", datas[n-1].ledger.Height), zap.Int("version", cli.version))
+		cli.currentHeight += int64(n)
+		metricIndexedHeight.Set(float64(cli.currentHeight - 1))
+		datas = datas[n:]
+	}
+
+	for _, data := range datas {
+		if err := cli.SaveV3Data(data); err != nil {
+			log.Logger.Error("SaveV3Data", zap.Error(err))
+			cli.skipIndexed(err)
+			return
+		}
+
+		log.Logger.Info("fetch ok", zap.Int64("height", cli.currentHeight), zap.Int("version", cli.version))
+		metricIndexedHeight.Set(float64(cli.currentHeight))
+		cli.currentHeight++
+	}
+}
+
+// skipIndexed 入库时高度已存在，说明另一个同步实例在写同一个库，从sync_state重新定位而不是反复重试。
+// 同一个库只应有一个同步实例写入，v3_ledgers.height的唯一索引保证不会写入重复的区块
+func (cli *Client) skipIndexed(err error) {
+	if !errors.Is(err, datamanager.ErrHeightIndexed) {
+		return
+	}
+	height, err := cli.resumeHeight()
+	if err != nil {
+		log.Logger.Error("resumeHeight", zap.Error(err))
+		return
+	}
+	log.Logger.Error("height indexed by another sync instance", zap.Int64("height", cli.currentHeight), zap.Int64("resume", height))
+	cli.currentHeight = height
+}
+
+// fetchRange 用workers个协程并发拉取并解析[begin, end]的区块。
+// 返回从begin开始连续拉取成功的区块，遇到失败的高度时一并返回第一个错误，之后的区块丢弃留待下一轮。
+func (cli *Client) fetchRange(begin, end int64) ([]*V3BlockData, error) {
+	if end < begin {
+		return nil, nil
+	}
+
+	workers := cli.workers
+	if workers <= 0 {
+		workers = 1
+	}
+
+	var (
+		n       = int(end - begin + 1)
+		datas   = make([]*V3BlockData, n)
+		errs    = make([]error, n)
+		heights = make(chan int64, n)
+		wg      sync.WaitGroup
+	)
+	for h := begin; h <= end; h++ {
+		heights <- h
+	}
+	close(heights)
+
+	if workers > n {
+		workers = n
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for h := range heights {
+				idx := h - begin
+				select {
+				case <-cli.ctx.Done():
+					errs[idx] = cli.ctx.Err()
+					continue
+				default:
+				}
+				start := time.Now()
+				datas[idx], errs[idx] = cli.GetV3BlockData(h)
+				observeFetch(start)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i := range datas {
+		if errs[i] != nil {
+			return datas[:i], errs[i]
+		}
+	}
+	return datas, nil
+}
@@ -0,0 +1,170 @@
+package client
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	tmtypes "github.com/tendermint/tendermint/types"
+	"github.com/toolglobal/api/database"
+	"github.com/toolglobal/api/libs/log"
+	"go.uber.org/zap"
+)
+
+// RetryRawTxs 重新解析v3_raw_txs中记录的交易，用于解析器升级后补全之前无法解析的交易。
+// 交易从v3_raw_txs保存的原始数据解码，只从节点读取这些高度的执行结果，库中已有的交易原样保留。
+// 解析成功的交易写入transactions等表并从v3_raw_txs中删除，diff.RawTxsRemoved为这一高度解析成功的数量。
+// dryRun时只比较差异不写入
+func (cli *Client) RetryRawTxs(dryRun bool, report func(diff *ReindexDiff)) error {
+	heights, err := cli.rawTxHeights()
+	if err != nil {
+		return err
+	}
+	if len(heights) == 0 {
+		return nil
+	}
+
+	// 解析代币转账依赖最新的代币列表
+	if err := cli.tokenMgr.Sync(); err != nil {
+		log.Logger.Warn("retry raw txs sync tokens", zap.Error(err))
+	}
+
+	for _, height := range heights {
+		data, err := cli.retryHeight(height)
+		if err != nil {
+			return err
+		}
+		diff, err := cli.diffHeight(data)
+		if err != nil {
+			return err
+		}
+		if !dryRun {
+			if err := cli.replaceV3Batch([]*V3BlockData{data}, []*ReindexDiff{diff}); err != nil {
+				return err
+			}
+		}
+		if report != nil {
+			report(diff)
+		}
+	}
+	return nil
+}
+
+// retryHeight 库中某一高度的数据加上重新解析的交易，按交易在区块中的顺序排列。
+// ledger的gas统计加上新解析的交易，平均gasPrice按全部交易重新计算
+func (cli *Client) retryHeight(height int64) (*V3BlockData, error) {
+	stored, err := cli.dataMgr.QueryV3Ledger(height)
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil {
+		return nil, fmt.Errorf("height %d not indexed", height)
+	}
+	old, err := cli.loadHeight(height)
+	if err != nil {
+		return nil, err
+	}
+	results, err := cli.fetch.FetchBlockResultInfo(height)
+	if err != nil {
+		return nil, err
+	}
+
+	ledger := *stored
+	ledger.TotalPrice = new(big.Int)
+	data := &V3BlockData{ledger: &ledger}
+	// 解析交易只用到区块的高度和时间
+	block := &tmtypes.Block{Header: tmtypes.Header{Height: height, Time: stored.CreatedAt}}
+
+	txs := make(map[int]*database.V3Transaction, len(old.txs))
+	for i := range old.txs {
+		txs[old.txs[i].TxIdx] = &old.txs[i]
+	}
+	rawTxs := make(map[int]*database.V3RawTx, len(old.rawTxs))
+	for i := range old.rawTxs {
+		rawTxs[old.rawTxs[i].TxIdx] = &old.rawTxs[i]
+	}
+	for txIdx := 0; txIdx < int(ledger.TxCount); txIdx++ {
+		if raw, ok := rawTxs[txIdx]; ok {
+			bs, err := hexutil.Decode(raw.Raw)
+			if err != nil {
+				return nil, fmt.Errorf("raw tx %s: %v", raw.Hash, err)
+			}
+			if txIdx >= len(results) {
+				return nil, fmt.Errorf("height %d has %d tx results, want %d", height, len(results), ledger.TxCount)
+			}
+			cli.addTx(data, txIdx, bs, &TxContext{Block: block, Result: results[txIdx], Ledger: data.ledger})
+			continue
+		}
+		if tx, ok := txs[txIdx]; ok {
+			if err := data.addStoredTx(old, tx); err != nil {
+				return nil, err
+			}
+		}
+	}
+	data.setGasPrice()
+	return data, nil
+}
+
+// addStoredTx 库中已有的交易及其payments、nft_transfers、approvals、contracts、logs原样保留，gasPrice计入ledger
+func (data *V3BlockData) addStoredTx(old *V3BlockData, tx *database.V3Transaction) error {
+	gasPrice, ok := new(big.Int).SetString(tx.GasPrice, 10)
+	if !ok {
+		return fmt.Errorf("tx %s invalid gasPrice %q", tx.Hash, tx.GasPrice)
+	}
+	data.ledger.TotalPrice.Add(data.ledger.TotalPrice, gasPrice)
+
+	data.txs = append(data.txs, *tx)
+	for _, v := range old.payments {
+		if v.Hash == tx.Hash {
+			data.payments = append(data.payments, v)
+		}
+	}
+	for _, v := range old.nftTransfers {
+		if v.Hash == tx.Hash {
+			data.nftTransfers = append(data.nftTransfers, v)
+		}
+	}
+	for _, v := range old.approvals {
+		if v.Hash == tx.Hash {
+			data.approvals = append(data.approvals, v)
+		}
+	}
+	for _, v := range old.contracts {
+		if v.Hash == tx.Hash {
+			data.contracts = append(data.contracts, v)
+		}
+	}
+	for _, v := range old.logs {
+		if v.Hash == tx.Hash {
+			data.logs = append(data.logs, v)
+		}
+	}
+	return nil
+}
+
+// rawTxHeights v3_raw_txs中出现的高度，从小到大
+func (cli *Client) rawTxHeights() ([]int64, error) {
+	const limit = 200
+
+	seen := make(map[int64]bool)
+	var heights []int64
+	for after := uint64(0); ; {
+		result, err := cli.dataMgr.QueryV3RawTxs(&database.V3RawTxFilter{}, database.MakeKeysetPaging("id", after, limit), "ASC")
+		if err != nil {
+			return nil, err
+		}
+		for _, tx := range result {
+			if !seen[tx.Height] {
+				seen[tx.Height] = true
+				heights = append(heights, tx.Height)
+			}
+		}
+		if len(result) < limit {
+			break
+		}
+		after = result[len(result)-1].Id
+	}
+	sort.Slice(heights, func(i, j int) bool { return heights[i] < heights[j] })
+	return heights, nil
+}
@@ -0,0 +1,461 @@
+package client
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/toolglobal/api/database"
+	"github.com/toolglobal/api/libs/log"
+	"go.uber.org/zap"
+)
+
+// ReindexDiff 某一高度重建前后的差异
+type ReindexDiff struct {
+	Height           int64 `json:"height"`
+	LedgerMissing    bool  `json:"ledgerMissing"`    // 库中没有该高度
+	LedgerChanged    bool  `json:"ledgerChanged"`    // 区块hash或统计信息变化
+	TxsAdded         int   `json:"txsAdded"`         // 新增的交易
+	TxsRemoved       int   `json:"txsRemoved"`       // 删除的交易
+	TxsChanged       int   `json:"txsChanged"`       // 内容变化的交易
+	PaymentsAdded    int   `json:"paymentsAdded"`    // 新增的payment
+	PaymentsRemoved  int   `json:"paymentsRemoved"`  // 删除的payment
+	PaymentsChanged  int   `json:"paymentsChanged"`  // 内容变化的payment
+	NFTsAdded        int   `json:"nftsAdded"`        // 新增的NFT转账
+	NFTsRemoved      int   `json:"nftsRemoved"`      // 删除的NFT转账
+	NFTsChanged      int   `json:"nftsChanged"`      // 内容变化的NFT转账
+	ApprovalsAdded   int   `json:"approvalsAdded"`   // 新增的授权
+	ApprovalsRemoved int   `json:"approvalsRemoved"` // 删除的授权
+	ApprovalsChanged int   `json:"approvalsChanged"` // 内容变化的授权
+	ContractsAdded   int   `json:"contractsAdded"`   // 新增的合约
+	ContractsRemoved int   `json:"contractsRemoved"` // 删除的合约
+	ContractsChanged int   `json:"contractsChanged"` // 内容变化的合约
+	LogsAdded        int   `json:"logsAdded"`        // 新增的日志
+	LogsRemoved      int   `json:"logsRemoved"`      // 删除的日志
+	LogsChanged      int   `json:"logsChanged"`      // 内容变化的日志
+	RawTxsAdded      int   `json:"rawTxsAdded"`      // 新增的无法解析的交易
+	RawTxsRemoved    int   `json:"rawTxsRemoved"`    // 不再无法解析的交易
+}
+
+// Empty 重建前后没有差异
+func (d *ReindexDiff) Empty() bool {
+	return !d.LedgerMissing && !d.LedgerChanged &&
+		d.TxsAdded == 0 && d.TxsRemoved == 0 && d.TxsChanged == 0 &&
+		d.PaymentsAdded == 0 && d.PaymentsRemoved == 0 && d.PaymentsChanged == 0 &&
+		d.NFTsAdded == 0 && d.NFTsRemoved == 0 && d.NFTsChanged == 0 &&
+		d.ApprovalsAdded == 0 && d.ApprovalsRemoved == 0 && d.ApprovalsChanged == 0 &&
+		d.ContractsAdded == 0 && d.ContractsRemoved == 0 && d.ContractsChanged == 0 &&
+		d.LogsAdded == 0 && d.LogsRemoved == 0 && d.LogsChanged == 0 &&
+		d.RawTxsAdded == 0 && d.RawTxsRemoved == 0
+}
+
+// Reindex 从节点重新拉取[from, to]的区块，删除并重建这些高度的ledgers、transactions、payments、nft_transfers、approvals、contracts、logs、raw_txs。
+// dryRun时只比较差异不写入。每处理完一个高度调用一次report。
+func (cli *Client) Reindex(from, to int64, dryRun bool, report func(diff *ReindexDiff)) error {
+	if from <= 0 || to < from {
+		return fmt.Errorf("invalid height range %d-%d", from, to)
+	}
+	next, err := cli.resumeHeight()
+	if err != nil {
+		return err
+	}
+	if to >= next {
+		return fmt.Errorf("height %d not indexed yet, indexed height %d", to, next-1)
+	}
+
+	// 重建依赖最新的代币列表
+	if err := cli.tokenMgr.Sync(); err != nil {
+		log.Logger.Warn("reindex sync tokens", zap.Error(err))
+	}
+
+	batchSize := int64(cli.batchSize)
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+	for begin := from; begin <= to; begin += batchSize {
+		end := begin + batchSize - 1
+		if end > to {
+			end = to
+		}
+
+		datas, err := cli.fetchRange(begin, end)
+		if err != nil {
+			return err
+		}
+
+		diffs := make([]*ReindexDiff, len(datas))
+		for i, data := range datas {
+			if diffs[i], err = cli.diffHeight(data); err != nil {
+				return err
+			}
+		}
+
+		if !dryRun {
+			if err := cli.replaceV3Batch(datas, diffs); err != nil {
+				return err
+			}
+		}
+
+		if report != nil {
+			for _, diff := range diffs {
+				report(diff)
+			}
+		}
+	}
+	return nil
+}
+
+// replaceV3Batch 在一个数据库事务中用新解析的数据替换库中已有的高度
+func (cli *Client) replaceV3Batch(datas []*V3BlockData, diffs []*ReindexDiff) (err error) {
+	batch, err := cli.dataMgr.BeginV3Batch()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			if err := batch.Rollback(); err != nil {
+				log.Logger.Error("reindex rollback", zap.Error(err))
+			}
+		}
+	}()
+
+	for i, data := range datas {
+		if diffs[i].LedgerMissing {
+			err = batch.AddLedger(data.ledger)
+		} else {
+			err = batch.ReplaceLedger(data.ledger)
+		}
+		if err != nil {
+			return err
+		}
+		if err = batch.DeleteHeight(data.ledger.Height); err != nil {
+			return err
+		}
+		for j := range data.txs {
+			if err = batch.AddTransaction(&data.txs[j]); err != nil {
+				return err
+			}
+		}
+		for j := range data.payments {
+			if err = batch.AddPayment(&data.payments[j]); err != nil {
+				return err
+			}
+		}
+		for j := range data.nftTransfers {
+			if err = batch.AddNFTTransfer(&data.nftTransfers[j]); err != nil {
+				return err
+			}
+		}
+		for j := range data.approvals {
+			if err = batch.AddApproval(&data.approvals[j]); err != nil {
+				return err
+			}
+		}
+		for j := range data.contracts {
+			if err = batch.AddContract(&data.contracts[j]); err != nil {
+				return err
+			}
+		}
+		for j := range data.logs {
+			if err = batch.AddLog(&data.logs[j]); err != nil {
+				return err
+			}
+		}
+		for j := range data.rawTxs {
+			if err = batch.AddRawTx(&data.rawTxs[j]); err != nil {
+				return err
+			}
+		}
+	}
+
+	return batch.Commit()
+}
+
+// diffHeight 比较新解析的数据与库中已有数据的差异
+func (cli *Client) diffHeight(data *V3BlockData) (*ReindexDiff, error) {
+	height := data.ledger.Height
+	diff := &ReindexDiff{Height: height}
+
+	ledger, err := cli.dataMgr.QueryV3Ledger(height)
+	if err != nil {
+		return nil, err
+	}
+	if ledger == nil {
+		diff.LedgerMissing = true
+	} else {
+		diff.LedgerChanged = ledger.BlockHash != data.ledger.BlockHash ||
+			ledger.BlockSize != data.ledger.BlockSize ||
+			ledger.TxCount != data.ledger.TxCount ||
+			ledger.GasLimit != data.ledger.GasLimit ||
+			ledger.GasUsed != data.ledger.GasUsed ||
+			ledger.GasPrice != data.ledger.GasPrice
+	}
+
+	old, err := cli.loadHeight(height)
+	if err != nil {
+		return nil, err
+	}
+
+	txs := make(map[string]database.V3Transaction, len(old.txs))
+	for _, tx := range old.txs {
+		txs[tx.Hash] = normalizeTx(tx)
+	}
+	for _, tx := range data.txs {
+		old, ok := txs[tx.Hash]
+		if !ok {
+			diff.TxsAdded++
+			continue
+		}
+		if old != normalizeTx(tx) {
+			diff.TxsChanged++
+		}
+		delete(txs, tx.Hash)
+	}
+	diff.TxsRemoved = len(txs)
+
+	payments := make(map[string]database.V3Payment, len(old.payments))
+	for _, payment := range old.payments {
+		payments[paymentKey(&payment)] = normalizePayment(payment)
+	}
+	for _, payment := range data.payments {
+		key := paymentKey(&payment)
+		old, ok := payments[key]
+		if !ok {
+			diff.PaymentsAdded++
+			continue
+		}
+		if old != normalizePayment(payment) {
+			diff.PaymentsChanged++
+		}
+		delete(payments, key)
+	}
+	diff.PaymentsRemoved = len(payments)
+
+	nfts := make(map[string]database.V3NFTTransfer, len(old.nftTransfers))
+	for _, nft := range old.nftTransfers {
+		nfts[nftTransferKey(&nft)] = normalizeNFTTransfer(nft)
+	}
+	for _, nft := range data.nftTransfers {
+		key := nftTransferKey(&nft)
+		old, ok := nfts[key]
+		if !ok {
+			diff.NFTsAdded++
+			continue
+		}
+		if old != normalizeNFTTransfer(nft) {
+			diff.NFTsChanged++
+		}
+		delete(nfts, key)
+	}
+	diff.NFTsRemoved = len(nfts)
+
+	approvals := make(map[string]database.V3Approval, len(old.approvals))
+	for _, approval := range old.approvals {
+		approvals[approvalKey(&approval)] = normalizeApproval(approval)
+	}
+	for _, approval := range data.approvals {
+		key := approvalKey(&approval)
+		old, ok := approvals[key]
+		if !ok {
+			diff.ApprovalsAdded++
+			continue
+		}
+		if old != normalizeApproval(approval) {
+			diff.ApprovalsChanged++
+		}
+		delete(approvals, key)
+	}
+	diff.ApprovalsRemoved = len(approvals)
+
+	contracts := make(map[string]database.V3Contract, len(old.contracts))
+	for _, contract := range old.contracts {
+		contracts[contract.Address] = normalizeContract(contract)
+	}
+	for _, contract := range data.contracts {
+		old, ok := contracts[contract.Address]
+		if !ok {
+			diff.ContractsAdded++
+			continue
+		}
+		if old != normalizeContract(contract) {
+			diff.ContractsChanged++
+		}
+		delete(contracts, contract.Address)
+	}
+	diff.ContractsRemoved = len(contracts)
+
+	logs := make(map[string]database.V3Log, len(old.logs))
+	for _, l := range old.logs {
+		logs[logKey(&l)] = normalizeLog(l)
+	}
+	for _, l := range data.logs {
+		key := logKey(&l)
+		old, ok := logs[key]
+		if !ok {
+			diff.LogsAdded++
+			continue
+		}
+		if old != normalizeLog(l) {
+			diff.LogsChanged++
+		}
+		delete(logs, key)
+	}
+	diff.LogsRemoved = len(logs)
+
+	rawTxs := make(map[string]bool, len(old.rawTxs))
+	for _, tx := range old.rawTxs {
+		rawTxs[tx.Hash] = true
+	}
+	for _, tx := range data.rawTxs {
+		if !rawTxs[tx.Hash] {
+			diff.RawTxsAdded++
+		}
+		delete(rawTxs, tx.Hash)
+	}
+	diff.RawTxsRemoved = len(rawTxs)
+
+	return diff, nil
+}
+
+// loadHeight 读取库中某一高度的全部transactions、payments、nft_transfers、approvals、contracts、logs、raw_txs，不含ledger
+func (cli *Client) loadHeight(height int64) (*V3BlockData, error) {
+	const limit = 200
+
+	data := &V3BlockData{}
+	for after := uint64(0); ; {
+		result, err := cli.dataMgr.QueryV3BlockTxs(height, 0, 0, database.MakeKeysetPaging("id", after, limit), "ASC")
+		if err != nil {
+			return nil, err
+		}
+		data.txs = append(data.txs, result...)
+		if len(result) < limit {
+			break
+		}
+		after = result[len(result)-1].Id
+	}
+
+	for after := uint64(0); ; {
+		result, err := cli.dataMgr.QueryV3PaymentsByHeight(height, "", "", 0, 0, database.MakeKeysetPaging("id", after, limit), "ASC")
+		if err != nil {
+			return nil, err
+		}
+		data.payments = append(data.payments, result...)
+		if len(result) < limit {
+			break
+		}
+		after = result[len(result)-1].Id
+	}
+
+	for after := uint64(0); ; {
+		result, err := cli.dataMgr.QueryV3NFTTransfersByHeight(height, database.MakeKeysetPaging("id", after, limit), "ASC")
+		if err != nil {
+			return nil, err
+		}
+		data.nftTransfers = append(data.nftTransfers, result...)
+		if len(result) < limit {
+			break
+		}
+		after = result[len(result)-1].Id
+	}
+
+	for after := uint64(0); ; {
+		result, err := cli.dataMgr.QueryV3ApprovalsByHeight(height, database.MakeKeysetPaging("id", after, limit), "ASC")
+		if err != nil {
+			return nil, err
+		}
+		data.approvals = append(data.approvals, result...)
+		if len(result) < limit {
+			break
+		}
+		after = result[len(result)-1].Id
+	}
+
+	for after := uint64(0); ; {
+		result, err := cli.dataMgr.QueryV3ContractsByHeight(height, database.MakeKeysetPaging("id", after, limit), "ASC")
+		if err != nil {
+			return nil, err
+		}
+		data.contracts = append(data.contracts, result...)
+		if len(result) < limit {
+			break
+		}
+		after = result[len(result)-1].Id
+	}
+
+	for after := uint64(0); ; {
+		result, err := cli.dataMgr.QueryV3Logs(&database.V3LogFilter{FromHeight: height, ToHeight: height}, database.MakeKeysetPaging("id", after, limit), "ASC")
+		if err != nil {
+			return nil, err
+		}
+		data.logs = append(data.logs, result...)
+		if len(result) < limit {
+			break
+		}
+		after = result[len(result)-1].Id
+	}
+
+	for after := uint64(0); ; {
+		result, err := cli.dataMgr.QueryV3RawTxs(&database.V3RawTxFilter{FromHeight: height, ToHeight: height}, database.MakeKeysetPaging("id", after, limit), "ASC")
+		if err != nil {
+			return nil, err
+		}
+		data.rawTxs = append(data.rawTxs, result...)
+		if len(result) < limit {
+			break
+		}
+		after = result[len(result)-1].Id
+	}
+	return data, nil
+}
+
+func paymentKey(p *database.V3Payment) string {
+	return fmt.Sprintf("%s/%d/%s/%s", p.Hash, p.Idx, p.Contract, p.EvName)
+}
+
+func nftTransferKey(n *database.V3NFTTransfer) string {
+	return fmt.Sprintf("%s/%d/%d", n.Hash, n.Idx, n.BatchIdx)
+}
+
+func approvalKey(a *database.V3Approval) string {
+	return fmt.Sprintf("%s/%d", a.Hash, a.Idx)
+}
+
+func logKey(l *database.V3Log) string {
+	return fmt.Sprintf("%s/%d", l.Hash, l.LogIdx)
+}
+
+// normalizeTx 去掉自增id，时间只保留到秒，便于比较
+func normalizeTx(tx database.V3Transaction) database.V3Transaction {
+	tx.Id = 0
+	tx.CreatedAt = time.Unix(tx.CreatedAt.Unix(), 0)
+	return tx
+}
+
+func normalizePayment(p database.V3Payment) database.V3Payment {
+	p.Id = 0
+	p.CreatedAt = time.Unix(p.CreatedAt.Unix(), 0)
+	return p
+}
+
+func normalizeNFTTransfer(n database.V3NFTTransfer) database.V3NFTTransfer {
+	n.Id = 0
+	n.CreatedAt = time.Unix(n.CreatedAt.Unix(), 0)
+	return n
+}
+
+func normalizeApproval(a database.V3Approval) database.V3Approval {
+	a.Id = 0
+	a.CreatedAt = time.Unix(a.CreatedAt.Unix(), 0)
+	return a
+}
+
+func normalizeContract(c database.V3Contract) database.V3Contract {
+	c.Id = 0
+	c.CreatedAt = time.Unix(c.CreatedAt.Unix(), 0)
+	return c
+}
+
+func normalizeLog(l database.V3Log) database.V3Log {
+	l.Id = 0
+	l.CreatedAt = time.Unix(l.CreatedAt.Unix(), 0)
+	return l
+}
@@ -0,0 +1,19 @@
+package client
+
+import (
+	"github.com/toolglobal/api/database"
+	"github.com/toolglobal/api/libs/bus"
+)
+
+// SetBus 设置入库后发布区块的总线，不设置时不发布
+func (cli *Client) SetBus(b *bus.Bus) {
+	cli.bus = b
+}
+
+func (data *V3BlockData) blockEvent() *database.BlockEvent {
+	return &database.BlockEvent{
+		Ledger:       *data.ledger,
+		Transactions: data.txs,
+		Payments:     data.payments,
+	}
+}
@@ -0,0 +1,92 @@
+package client
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/toolglobal/api/libs/log"
+	"go.uber.org/zap"
+)
+
+const (
+	// pollInterval 未订阅新区块时轮询节点高度的间隔
+	pollInterval = time.Second
+	// subscribedPollInterval 订阅正常时仍然定期轮询，防止漏掉通知
+	subscribedPollInterval = 10 * time.Second
+	// subscriptionTimeout 超过该时间没有收到新区块通知，认为连接已断开，重新订阅
+	subscriptionTimeout = 30 * time.Second
+)
+
+// subscribe 维持新区块订阅，收到通知后唤醒同步协程；订阅断开时退回轮询并按退避间隔重连
+func (cli *Client) subscribe(sub Subscriber) {
+	backoff := time.Second
+	for {
+		ctx, cancel := context.WithCancel(cli.ctx)
+		heights, err := sub.SubscribeNewBlock(ctx)
+		if err != nil {
+			log.Logger.Warn("subscribe new block, fall back to polling", zap.Error(err))
+		} else {
+			log.Logger.Info("subscribed new block")
+			atomic.StoreInt32(&cli.subscribed, 1)
+			backoff = time.Second
+			cli.consume(heights, cancel)
+			atomic.StoreInt32(&cli.subscribed, 0)
+			log.Logger.Warn("new block subscription closed, fall back to polling")
+		}
+		cancel()
+
+		select {
+		case <-cli.ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff < time.Minute {
+			backoff *= 2
+		}
+	}
+}
+
+// consume 转发新区块通知，直到订阅关闭或长时间没有通知
+func (cli *Client) consume(heights <-chan int64, cancel context.CancelFunc) {
+	timer := time.NewTimer(subscriptionTimeout)
+	defer timer.Stop()
+	for {
+		select {
+		case _, ok := <-heights:
+			if !ok {
+				return
+			}
+			select {
+			case cli.newBlock <- struct{}{}:
+			default:
+			}
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(subscriptionTimeout)
+		case <-timer.C:
+			cancel()
+			// 等待订阅协程退出并关闭channel
+			for range heights {
+			}
+			return
+		}
+	}
+}
+
+// waitNewBlock 已追上最新高度时等待新区块通知，超时后继续轮询
+func (cli *Client) waitNewBlock() {
+	interval := pollInterval
+	if atomic.LoadInt32(&cli.subscribed) == 1 {
+		interval = subscribedPollInterval
+	}
+
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	select {
+	case <-cli.ctx.Done():
+	case <-cli.newBlock:
+	case <-timer.C:
+	}
+}
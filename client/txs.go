@@ -0,0 +1,239 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	abcitypes "github.com/tendermint/tendermint/abci/types"
+	tmtypes "github.com/tendermint/tendermint/types"
+	"github.com/toolglobal/api/config"
+	"github.com/toolglobal/api/database"
+	"github.com/toolglobal/api/mondo/types"
+)
+
+var (
+	errTxTooShort   = errors.New("tx too short")
+	errUnknownTxTag = errors.New("unknown tx tag")
+	errUnexpectedTx = errors.New("unexpected tx type")
+)
+
+// TxContext 交易所在的区块和执行结果
+type TxContext struct {
+	Block  *tmtypes.Block
+	Result *abcitypes.ResponseDeliverTx
+	Ledger *database.V3Ledger // 转换时累计区块的gas统计
+}
+
+// TxData 一笔交易转换出的数据
+type TxData struct {
+	Tx              *database.V3Transaction
+	Payments        []database.V3Payment
+	CreatesContract bool // 接收方为空的合约创建交易，执行成功时按发起者地址和nonce记录合约
+}
+
+// TxDecoder 解析一种交易类型
+type TxDecoder interface {
+	// Tag 交易类型，即交易的前两个字节
+	Tag() types.TxTag
+	// Decode 解析去掉类型后的交易字节
+	Decode(payload []byte) (interface{}, error)
+	// Convert 把Decode的结果转换为V3Transaction和原生币payment
+	Convert(tx interface{}, c *TxContext) (*TxData, error)
+}
+
+// HeightRange 高度范围[From, To]，To为0表示不限
+type HeightRange struct {
+	From int64
+	To   int64
+}
+
+func (r HeightRange) contains(height int64) bool {
+	return height >= r.From && (r.To == 0 || height <= r.To)
+}
+
+type txEntry struct {
+	decoder TxDecoder
+	heights []HeightRange // 为空时在所有高度生效
+	retired bool          // 已废弃的类型，heights为空时在所有高度都不生效
+}
+
+// TxRegistry 按交易类型分发交易到已注册的解码器，每种类型可以限定生效的高度范围
+type TxRegistry struct {
+	entries map[types.TxTag]*txEntry
+}
+
+func NewTxRegistry() *TxRegistry {
+	return &TxRegistry{entries: make(map[types.TxTag]*txEntry)}
+}
+
+// txRegistries 配置中versions可选的解析协议版本及其交易类型
+var txRegistries = map[int]func() *TxRegistry{
+	3: DefaultTxRegistry,
+}
+
+// DefaultTxRegistry 支持TxEvm、批量交易、以太坊兼容交易、多签交易，在所有高度生效；
+// 历史区块中的账户迁移v4废弃，需要在配置中指定生效的高度
+func DefaultTxRegistry() *TxRegistry {
+	r := NewTxRegistry()
+	r.Register(evmTxDecoder{})
+	r.Register(batchTxDecoder{})
+	r.Register(ethereumTxDecoder{})
+	r.Register(multisigTxDecoder{})
+
+	r.RegisterRetired(initTxDecoder{})
+	return r
+}
+
+// Register 注册交易类型，heights为空时在所有高度生效。同一类型重复注册时替换之前的解码器
+func (r *TxRegistry) Register(d TxDecoder, heights ...HeightRange) {
+	r.entries[d.Tag()] = &txEntry{decoder: d, heights: heights}
+}
+
+// RegisterRetired 注册已废弃的交易类型，只在heights内生效。heights为空时在所有高度都不生效，
+// 由Activate按配置设置废弃前的高度范围
+func (r *TxRegistry) RegisterRetired(d TxDecoder, heights ...HeightRange) {
+	r.entries[d.Tag()] = &txEntry{decoder: d, heights: heights, retired: true}
+}
+
+// Activate 按配置设置交易类型生效的高度范围，替换注册时的范围；配置了未注册的类型时返回错误
+func (r *TxRegistry) Activate(tags []config.TxTag) error {
+	heights := make(map[types.TxTag][]HeightRange)
+	for _, t := range tags {
+		bs, err := hexutil.Decode(t.Tag)
+		if err != nil || len(bs) != types.TxTagLength {
+			return fmt.Errorf("invalid tx tag %q", t.Tag)
+		}
+		var tag types.TxTag
+		copy(tag[:], bs)
+		if _, ok := r.entries[tag]; !ok {
+			return fmt.Errorf("tx tag %s not registered", t.Tag)
+		}
+		if t.From < 0 || t.To != 0 && t.To < t.From {
+			return fmt.Errorf("invalid height range %d-%d for tx tag %s", t.From, t.To, t.Tag)
+		}
+		heights[tag] = append(heights[tag], HeightRange{From: t.From, To: t.To})
+	}
+	for tag, ranges := range heights {
+		r.entries[tag].heights = ranges
+	}
+	return nil
+}
+
+// Lookup 返回在height生效的解码器
+func (r *TxRegistry) Lookup(tag types.TxTag, height int64) (TxDecoder, error) {
+	e, ok := r.entries[tag]
+	if !ok {
+		return nil, errUnknownTxTag
+	}
+	if len(e.heights) == 0 && !e.retired {
+		return e.decoder, nil
+	}
+	for _, h := range e.heights {
+		if h.contains(height) {
+			return e.decoder, nil
+		}
+	}
+	return nil, fmt.Errorf("tx tag %#x not active at height %d", tag[:], height)
+}
+
+// Convert 按交易的前两个字节选择解码器，解析并转换交易
+func (r *TxRegistry) Convert(raw []byte, c *TxContext) (*TxData, error) {
+	if len(raw) <= types.TxTagLength {
+		return nil, errTxTooShort
+	}
+	var tag types.TxTag
+	copy(tag[:], raw)
+
+	d, err := r.Lookup(tag, c.Block.Height)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := d.Decode(raw[types.TxTagLength:])
+	if err != nil {
+		return nil, err
+	}
+	return d.Convert(tx, c)
+}
+
+type evmTxDecoder struct{}
+
+func (evmTxDecoder) Tag() types.TxTag { return types.TxTagAppEvm }
+
+func (evmTxDecoder) Decode(payload []byte) (interface{}, error) {
+	var tx types.TxEvm
+	err := tx.FromBytes(payload)
+	return &tx, err
+}
+
+func (evmTxDecoder) Convert(itx interface{}, c *TxContext) (*TxData, error) {
+	tx, ok := itx.(*types.TxEvm)
+	if !ok {
+		return nil, fmt.Errorf("%w %T", errUnexpectedTx, itx)
+	}
+	trans, payments := convertTxEvm(tx, c.Block, c.Result, c.Ledger)
+	return &TxData{Tx: trans, Payments: payments, CreatesContract: tx.Body.To == (types.PublicKey{})}, nil
+}
+
+type batchTxDecoder struct{}
+
+func (batchTxDecoder) Tag() types.TxTag { return types.TxTagAppBatch }
+
+func (batchTxDecoder) Decode(payload []byte) (interface{}, error) {
+	var tx types.TxBatch
+	err := tx.FromBytes(payload)
+	return &tx, err
+}
+
+func (batchTxDecoder) Convert(itx interface{}, c *TxContext) (*TxData, error) {
+	tx, ok := itx.(*types.TxBatch)
+	if !ok {
+		return nil, fmt.Errorf("%w %T", errUnexpectedTx, itx)
+	}
+	trans, payments := convertTxBatch(tx, c.Block, c.Result, c.Ledger)
+	return &TxData{Tx: trans, Payments: payments}, nil
+}
+
+type ethereumTxDecoder struct{}
+
+func (ethereumTxDecoder) Tag() types.TxTag { return types.TxTagEthereumTx }
+
+func (ethereumTxDecoder) Decode(payload []byte) (interface{}, error) {
+	var tx ethtypes.Transaction
+	err := rlp.DecodeBytes(payload, &tx)
+	return &tx, err
+}
+
+func (ethereumTxDecoder) Convert(itx interface{}, c *TxContext) (*TxData, error) {
+	tx, ok := itx.(*ethtypes.Transaction)
+	if !ok {
+		return nil, fmt.Errorf("%w %T", errUnexpectedTx, itx)
+	}
+	trans, payments, err := convertTxEthereum(tx, c.Block, c.Result, c.Ledger)
+	if err != nil {
+		return nil, err
+	}
+	return &TxData{Tx: trans, Payments: payments, CreatesContract: tx.To() == nil}, nil
+}
+
+type multisigTxDecoder struct{}
+
+func (multisigTxDecoder) Tag() types.TxTag { return types.TxTagAppEvmMultisig }
+
+func (multisigTxDecoder) Decode(payload []byte) (interface{}, error) {
+	var tx types.MultisigEvmTx
+	err := tx.FromBytes(payload)
+	return &tx, err
+}
+
+func (multisigTxDecoder) Convert(itx interface{}, c *TxContext) (*TxData, error) {
+	tx, ok := itx.(*types.MultisigEvmTx)
+	if !ok {
+		return nil, fmt.Errorf("%w %T", errUnexpectedTx, itx)
+	}
+	trans, payments := convertTxMultisigEvm(tx, c.Block, c.Result, c.Ledger)
+	return &TxData{Tx: trans, Payments: payments, CreatesContract: tx.To == (common.Address{})}, nil
+}
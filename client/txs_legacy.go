@@ -0,0 +1,66 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/toolglobal/api/database"
+	"github.com/toolglobal/api/mondo/types"
+)
+
+// 已废弃的交易类型，用于从高度1完整重新同步。
+// 只解析结构有据可查的类型：账户迁移按types.TxTagAppInit的注释采用与TxBatch相同的结构；
+// 其他已废弃类型的结构已随节点代码删除，不注册解码器，这些交易记录在v3_raw_txs中。
+
+// initTxDecoder 账户迁移，采用TxBatch的结构。每个Op记一笔sender到接收方的payment，不收取手续费，
+// 执行失败的交易只记交易，不记payment
+type initTxDecoder struct{}
+
+func (initTxDecoder) Tag() types.TxTag { return types.TxTagAppInit }
+
+func (initTxDecoder) Decode(payload []byte) (interface{}, error) {
+	var tx types.TxBatch
+	err := tx.FromBytes(payload)
+	return &tx, err
+}
+
+func (initTxDecoder) Convert(itx interface{}, c *TxContext) (*TxData, error) {
+	tx, ok := itx.(*types.TxBatch)
+	if !ok {
+		return nil, fmt.Errorf("%w %T", errUnexpectedTx, itx)
+	}
+	trans := &database.V3Transaction{
+		Hash:      tx.Hash().Hex(),
+		Height:    c.Block.Height,
+		Typei:     txTagToTypei(types.TxTagAppInit[:]),
+		Types:     "TxTagAppInit",
+		Sender:    tx.Sender.ToAddress().Hex(),
+		Nonce:     int64(tx.Nonce),
+		GasUsed:   c.Result.GasUsed,
+		GasPrice:  "0",
+		Memo:      string(tx.Memo),
+		Codei:     c.Result.Code,
+		Codes:     c.Result.Log,
+		CreatedAt: c.Block.Time,
+	}
+	c.Ledger.GasUsed += c.Result.GasUsed
+	if trans.Codei != 0 {
+		return &TxData{Tx: trans}, nil
+	}
+
+	var payments []database.V3Payment
+	for idx, op := range tx.Ops {
+		payments = append(payments, database.V3Payment{
+			Hash:      trans.Hash,
+			Height:    trans.Height,
+			Idx:       uint(idx),
+			Sender:    trans.Sender,
+			Receiver:  op.To.ToAddress().Hex(),
+			Symbol:    "OLO",
+			Contract:  common.Address{}.Hex(),
+			Value:     op.Value.String(),
+			CreatedAt: trans.CreatedAt,
+		})
+	}
+	return &TxData{Tx: trans, Payments: payments}, nil
+}
@@ -0,0 +1,73 @@
+package client
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	abcitypes "github.com/tendermint/tendermint/abci/types"
+	tmtypes "github.com/tendermint/tendermint/types"
+	"github.com/toolglobal/api/config"
+	"github.com/toolglobal/api/database"
+	"github.com/toolglobal/api/mondo/types"
+)
+
+func TestTxRegistry_AppInit(t *testing.T) {
+	var sender, node types.PublicKey
+	sender[0], sender[32] = 2, 1
+	node.SetBytes(common.HexToAddress("0x0000000000000000000000000000000000000B0b").Bytes())
+	from, to := sender.ToAddress().Hex(), node.ToAddress().Hex()
+	batch := &types.TxBatch{GasPrice: new(big.Int), Sender: sender, Ops: []types.TxOp{{To: node, Value: big.NewInt(7)}, {To: node, Value: big.NewInt(8)}}}
+
+	// 已废弃的类型只在配置的高度范围内解析
+	r := DefaultTxRegistry()
+	if _, err := r.Lookup(types.TxTagAppInit, 1); err == nil {
+		t.Fatal("retired tag active without configured heights")
+	}
+	if err := r.Activate([]config.TxTag{{Tag: "0x0000", From: 1, To: 100}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Lookup(types.TxTagAppInit, 101); err == nil {
+		t.Fatal("retired tag active after its range")
+	}
+	block := &tmtypes.Block{Header: tmtypes.Header{Height: 1, Time: time.Unix(1500000001, 0)}}
+	for _, code := range []uint32{0, 1} {
+		ledger := &database.V3Ledger{TotalPrice: new(big.Int)}
+		data, err := r.Convert(append(types.TxTagAppInit[:], batch.ToBytes()...), &TxContext{Block: block, Result: &abcitypes.ResponseDeliverTx{Code: code, GasUsed: 10}, Ledger: ledger})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tx := data.Tx
+		if tx.Hash != batch.Hash().Hex() || tx.Types != "TxTagAppInit" || tx.Typei != 0 || tx.Sender != from || tx.GasPrice != "0" ||
+			tx.GasUsed != 10 || tx.Codei != code || ledger.GasUsed != 10 {
+			t.Fatalf("code %d: tx %+v", code, tx)
+		}
+		// 执行失败的交易不记payment
+		if code != 0 {
+			if len(data.Payments) != 0 {
+				t.Fatalf("code %d: payments %+v", code, data.Payments)
+			}
+			continue
+		}
+		if len(data.Payments) != 2 {
+			t.Fatalf("payments %+v", data.Payments)
+		}
+		for i, p := range data.Payments {
+			if p.Sender != from || p.Receiver != to || p.Value != batch.Ops[i].Value.String() || p.Hash != tx.Hash || p.Idx != uint(i) || p.Symbol != "OLO" {
+				t.Fatalf("payment %d %+v", i, p)
+			}
+		}
+	}
+
+	// 结构不一致的交易无法解析
+	if _, err := r.Convert(append(types.TxTagAppInit[:], 0xc3), &TxContext{Block: block, Result: &abcitypes.ResponseDeliverTx{}, Ledger: &database.V3Ledger{TotalPrice: new(big.Int)}}); err == nil {
+		t.Fatal("malformed app init decoded")
+	}
+	// 结构无据可查的已废弃类型不解析
+	for _, tag := range []types.TxTag{{0, 1}, {1, 1}, {1, 3}, {2, 1}, {2, 2}, {255, 0}, {255, 255}} {
+		if _, err := r.Lookup(tag, 1); err == nil {
+			t.Fatalf("tag %#x registered", tag[:])
+		}
+	}
+}
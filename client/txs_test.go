@@ -0,0 +1,156 @@
+package client
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	abcitypes "github.com/tendermint/tendermint/abci/types"
+	tmtypes "github.com/tendermint/tendermint/types"
+	"github.com/toolglobal/api/config"
+	"github.com/toolglobal/api/database"
+	"github.com/toolglobal/api/mondo/types"
+)
+
+var testTxTag = types.TxTag{9, 1}
+
+// testTxDecoder 交易内容为memo，空内容解析失败
+type testTxDecoder struct{}
+
+func (testTxDecoder) Tag() types.TxTag { return testTxTag }
+
+func (testTxDecoder) Decode(payload []byte) (interface{}, error) {
+	if payload[0] == 0 {
+		return nil, errors.New("empty memo")
+	}
+	return string(payload), nil
+}
+
+func (testTxDecoder) Convert(itx interface{}, c *TxContext) (*TxData, error) {
+	memo := itx.(string)
+	tx := &database.V3Transaction{
+		Hash:      hexutil.Encode([]byte(memo)),
+		Height:    c.Block.Height,
+		Types:     "TxTagTest",
+		Memo:      memo,
+		Codei:     c.Result.Code,
+		CreatedAt: c.Block.Time,
+	}
+	return &TxData{Tx: tx, Payments: []database.V3Payment{{Hash: tx.Hash, Height: tx.Height, Symbol: "OLO", Value: "1"}}}, nil
+}
+
+func TestTxRegistry_Lookup(t *testing.T) {
+	r := DefaultTxRegistry()
+	r.Register(testTxDecoder{}, HeightRange{From: 10, To: 19}, HeightRange{From: 30})
+
+	cases := []struct {
+		tag    types.TxTag
+		height int64
+		ok     bool
+	}{
+		{types.TxTagAppEvm, 1, true},
+		{types.TxTagEthereumTx, 1 << 40, true},
+		{types.TxTagAppInit, 1, false}, // 已废弃，未配置高度
+		{types.TxTag{0xff, 0xfe}, 1, false},
+		{testTxTag, 9, false},
+		{testTxTag, 10, true},
+		{testTxTag, 19, true},
+		{testTxTag, 20, false},
+		{testTxTag, 30, true},
+		{testTxTag, 1 << 40, true},
+	}
+	for _, c := range cases {
+		d, err := r.Lookup(c.tag, c.height)
+		if c.ok != (err == nil) {
+			t.Fatalf("lookup %#x at %d: %v", c.tag[:], c.height, err)
+		}
+		if c.ok && d.Tag() != c.tag {
+			t.Fatalf("lookup %#x at %d got %#x", c.tag[:], c.height, d.Tag())
+		}
+	}
+}
+
+func TestTxRegistry_Activate(t *testing.T) {
+	cases := []struct {
+		name     string
+		tags     []config.TxTag
+		ok       bool
+		active   []int64 // 以太坊兼容交易生效的高度
+		inactive []int64
+	}{
+		{name: "empty", ok: true, active: []int64{1, 100}},
+		{name: "from", tags: []config.TxTag{{Tag: "0x0105", From: 100}}, ok: true, active: []int64{100, 1 << 40}, inactive: []int64{1, 99}},
+		{name: "segments", tags: []config.TxTag{{Tag: "0x0105", To: 9}, {Tag: "0x0105", From: 20, To: 29}}, ok: true,
+			active: []int64{1, 9, 20, 29}, inactive: []int64{10, 19, 30}},
+		{name: "short tag", tags: []config.TxTag{{Tag: "0x01"}}},
+		{name: "not hex", tags: []config.TxTag{{Tag: "0105"}}},
+		{name: "not registered", tags: []config.TxTag{{Tag: "0x0909"}}},
+		{name: "reversed", tags: []config.TxTag{{Tag: "0x0105", From: 10, To: 5}}},
+		{name: "negative", tags: []config.TxTag{{Tag: "0x0105", From: -1}}},
+	}
+	for _, c := range cases {
+		r := DefaultTxRegistry()
+		err := r.Activate(c.tags)
+		if c.ok != (err == nil) {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		for _, h := range c.active {
+			if _, err := r.Lookup(types.TxTagEthereumTx, h); err != nil {
+				t.Fatalf("%s: %v", c.name, err)
+			}
+		}
+		for _, h := range c.inactive {
+			if _, err := r.Lookup(types.TxTagEthereumTx, h); err == nil {
+				t.Fatalf("%s: active at %d", c.name, h)
+			}
+		}
+		// 未配置的类型不受影响
+		if _, err := r.Lookup(types.TxTagAppEvm, 1); err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+	}
+}
+
+func TestClient_GetV3BlockDataTxRegistry(t *testing.T) {
+	txs := tmtypes.Txs{
+		append(testTxTag[:], "hello"...),
+		append(testTxTag[:], 0),
+	}
+	fetch := &txFetcher{
+		memFetcher: newMemFetcher(),
+		results:    make(map[int64][]*abcitypes.ResponseDeliverTx),
+	}
+	for h := int64(1); h <= 2; h++ {
+		block := tmtypes.MakeBlock(h, txs, &tmtypes.Commit{}, nil)
+		block.ChainID = "test"
+		block.Time = time.Unix(1600000000+h, 0)
+		fetch.blocks[h] = &Block{BlockID: tmtypes.BlockID{Hash: block.Hash()}, Block: block}
+		fetch.results[h] = []*abcitypes.ResponseDeliverTx{{}, {}}
+	}
+	fetch.last = 2
+
+	cli, cancel := newTestClient(t, fetch)
+	defer cancel()
+	cli.txs.Register(testTxDecoder{}, HeightRange{From: 2})
+
+	// 生效前按未知类型记录
+	data, err := cli.GetV3BlockData(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data.txs) != 0 || len(data.rawTxs) != 2 || data.rawTxs[0].Error != "tx tag 0x0901 not active at height 1" {
+		t.Fatalf("txs %+v raw %+v", data.txs, data.rawTxs)
+	}
+
+	data, err = cli.GetV3BlockData(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data.txs) != 1 || data.txs[0].Memo != "hello" || data.txs[0].TxIdx != 0 || len(data.payments) != 1 {
+		t.Fatalf("txs %+v payments %+v", data.txs, data.payments)
+	}
+	if len(data.rawTxs) != 1 || data.rawTxs[0].TxIdx != 1 || data.rawTxs[0].Error != "empty memo" {
+		t.Fatalf("raw %+v", data.rawTxs)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"os"
 
 	"github.com/toolglobal/api/client"
@@ -9,9 +10,11 @@ import (
 	"github.com/toolglobal/api/database"
 	"github.com/toolglobal/api/database/basesql"
 	"github.com/toolglobal/api/datamanager"
+	"github.com/toolglobal/api/libs/bus"
 	"github.com/toolglobal/api/libs/log"
 	"github.com/toolglobal/api/web/dbo"
 	"github.com/toolglobal/api/web/server"
+	"github.com/toolglobal/api/webhook"
 	"go.uber.org/zap"
 )
 
@@ -21,6 +24,8 @@ var (
 	GO_VERSION string
 )
 
+const dbName = "mondo_query_v3.db"
+
 func main() {
 	log.Logger.Info("init", zap.String("build", BUILD_TIME), zap.String("commit", GIT_HASH), zap.String("go", GO_VERSION))
 	cfg := config.New()
@@ -29,10 +34,17 @@ func main() {
 	}
 	log.Logger.Info("config", zap.Any("cfg", cfg))
 
-	dataM3, err := datamanager.NewDataManager("mondo_query_v3.db", func(dbname string) database.Database {
-		dbi := &basesql.Basesql{}
-		_ = os.Mkdir("data", 755)
-		err := dbi.Init(dbname, "data", log.Logger)
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := migrate(cfg, os.Args[2:]); err != nil {
+			log.Logger.Error("migrate", zap.Error(err))
+			fmt.Fprintln(os.Stderr, "migrate:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	dataM3, err := datamanager.NewDataManager(dbName, func(dbname string) database.Database {
+		dbi, err := openDatabase(cfg, dbname)
 		if err != nil {
 			panic(err)
 		}
@@ -43,16 +55,76 @@ func main() {
 	}
 	defer dataM3.Close()
 
+	if len(os.Args) > 1 && os.Args[1] == "reindex" {
+		if err := reindex(cfg, dataM3, os.Args[2:]); err != nil {
+			log.Logger.Error("reindex", zap.Error(err))
+			fmt.Fprintln(os.Stderr, "reindex:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "retry-raw-txs" {
+		if err := retryRawTxs(cfg, dataM3, os.Args[2:]); err != nil {
+			log.Logger.Error("retry-raw-txs", zap.Error(err))
+			fmt.Fprintln(os.Stderr, "retry-raw-txs:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// 每个配置的版本启动一个同步任务，不支持的版本启动失败
+	events := bus.New()
+	started := make(map[int]bool)
 	for _, version := range cfg.Versions {
-		if version == 3 {
-			syncCli, err := client.NewClient(context.Background(), cfg.TGSBaseURL, cfg.ChainId, version, "http://"+cfg.RPC, dataM3, cfg.StartHeight)
-			if err != nil {
-				panic(err)
-			}
-			go syncCli.Start()
+		if started[version] {
+			panic(fmt.Sprintf("duplicate version %d", version))
+		}
+		started[version] = true
+
+		fetch, err := client.NewMultiFetcher(cfg.RPCRemotes(), cfg.Fetch)
+		if err != nil {
+			panic(err)
 		}
+		syncCli, err := client.NewClient(context.Background(), cfg.TGSBaseURL, cfg.ChainId, version, fetch, dataM3, cfg.StartHeight, cfg.Sync)
+		if err != nil {
+			panic(err)
+		}
+		syncCli.SetBus(events)
+		go syncCli.Start()
 	}
 
-	server := server.NewServer(log.Logger, cfg, dbo.New(dataM3))
+	if cfg.Webhook.Enabled {
+		go webhook.New(context.Background(), dataM3, events, cfg.Webhook).Start()
+	}
+
+	server := server.NewServer(log.Logger, cfg, dbo.New(dataM3), events)
 	server.Start()
 }
+
+// syncVersion 确定reindex、retry-raw-txs使用的解析协议版本，与同步任务使用相同的解码器。
+// 未指定时只能配置了一个版本，指定的版本必须在配置的versions中
+func syncVersion(cfg *config.Config, version int) (int, error) {
+	if version == 0 {
+		if len(cfg.Versions) != 1 {
+			return 0, fmt.Errorf("--version required, configured versions %v", cfg.Versions)
+		}
+		return cfg.Versions[0], nil
+	}
+	for _, v := range cfg.Versions {
+		if v == version {
+			return version, nil
+		}
+	}
+	return 0, fmt.Errorf("version %d not in configured versions %v", version, cfg.Versions)
+}
+
+// openDatabase 按配置连接数据库，sqlite3存放在data目录
+func openDatabase(cfg *config.Config, dbname string) (*basesql.Basesql, error) {
+	dbi := &basesql.Basesql{DBType: cfg.Database.Type, DSN: cfg.Database.DSN}
+	_ = os.Mkdir("data", 755)
+	if err := dbi.Init(dbname, "data", log.Logger); err != nil {
+		return nil, err
+	}
+	return dbi, nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/toolglobal/api/config"
+)
+
+// migrate 执行数据库迁移：api migrate [--status]
+// 服务启动时也会自动执行迁移；多个实例共享mysql时应在升级前单独执行一次
+func migrate(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	status := fs.Bool("status", false, "only print applied and pending migrations")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	dbi, err := openDatabase(cfg, dbName)
+	if err != nil {
+		return err
+	}
+	defer dbi.Close()
+
+	qt, qi := dbi.GetInitSQLs()
+	if err := dbi.PrepareTables(qt, qi); err != nil {
+		return err
+	}
+
+	if !*status {
+		versions, err := dbi.Migrate()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("applied %d migrations\n", len(versions))
+	}
+
+	states, err := dbi.MigrationStatus()
+	if err != nil {
+		return err
+	}
+	for _, state := range states {
+		applied := "pending"
+		if state.AppliedAt != nil {
+			applied = state.AppliedAt.Local().Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("%4d  %-40s  %s\n", state.Version, state.Name, applied)
+	}
+	return nil
+}
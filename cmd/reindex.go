@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/toolglobal/api/client"
+	"github.com/toolglobal/api/config"
+	"github.com/toolglobal/api/datamanager"
+)
+
+// reindex 重建指定高度范围的数据：api reindex --from H1 --to H2 [--dry-run] [--version V]
+// 与正在运行的API服务共享同一个数据库，重建期间服务可继续提供查询
+func reindex(cfg *config.Config, dataM *datamanager.DataManager, args []string) error {
+	fs := flag.NewFlagSet("reindex", flag.ExitOnError)
+	from := fs.Int64("from", 0, "start height (inclusive)")
+	to := fs.Int64("to", 0, "end height (inclusive)")
+	dryRun := fs.Bool("dry-run", false, "only report differences, do not write")
+	verbose := fs.Bool("v", false, "print differences of every height")
+	version := fs.Int("version", 0, "sync version, required when more than one version is configured")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *from <= 0 || *to < *from {
+		fs.Usage()
+		return errors.New("invalid --from/--to")
+	}
+
+	v, err := syncVersion(cfg, *version)
+	if err != nil {
+		return err
+	}
+
+	fetch, err := client.NewMultiFetcher(cfg.RPCRemotes(), cfg.Fetch)
+	if err != nil {
+		return err
+	}
+	cli, err := client.NewClient(context.Background(), cfg.TGSBaseURL, cfg.ChainId, v, fetch, dataM, 0, cfg.Sync)
+	if err != nil {
+		return err
+	}
+
+	var (
+		total   = *to - *from + 1
+		done    int64
+		ledgers int
+		sum     client.ReindexDiff
+	)
+	err = cli.Reindex(*from, *to, *dryRun, func(diff *client.ReindexDiff) {
+		done++
+		if diff.LedgerMissing || diff.LedgerChanged {
+			ledgers++
+		}
+		sum.TxsAdded += diff.TxsAdded
+		sum.TxsRemoved += diff.TxsRemoved
+		sum.TxsChanged += diff.TxsChanged
+		sum.PaymentsAdded += diff.PaymentsAdded
+		sum.PaymentsRemoved += diff.PaymentsRemoved
+		sum.PaymentsChanged += diff.PaymentsChanged
+		sum.NFTsAdded += diff.NFTsAdded
+		sum.NFTsRemoved += diff.NFTsRemoved
+		sum.NFTsChanged += diff.NFTsChanged
+		sum.ApprovalsAdded += diff.ApprovalsAdded
+		sum.ApprovalsRemoved += diff.ApprovalsRemoved
+		sum.ApprovalsChanged += diff.ApprovalsChanged
+		sum.ContractsAdded += diff.ContractsAdded
+		sum.ContractsRemoved += diff.ContractsRemoved
+		sum.ContractsChanged += diff.ContractsChanged
+		sum.LogsAdded += diff.LogsAdded
+		sum.LogsRemoved += diff.LogsRemoved
+		sum.LogsChanged += diff.LogsChanged
+		sum.RawTxsAdded += diff.RawTxsAdded
+		sum.RawTxsRemoved += diff.RawTxsRemoved
+
+		if *verbose && !diff.Empty() {
+			fmt.Printf("height %d: ledger missing=%v changed=%v txs +%d -%d ~%d payments +%d -%d ~%d nfts +%d -%d ~%d approvals +%d -%d ~%d contracts +%d -%d ~%d logs +%d -%d ~%d raw txs +%d -%d\n",
+				diff.Height, diff.LedgerMissing, diff.LedgerChanged,
+				diff.TxsAdded, diff.TxsRemoved, diff.TxsChanged,
+				diff.PaymentsAdded, diff.PaymentsRemoved, diff.PaymentsChanged,
+				diff.NFTsAdded, diff.NFTsRemoved, diff.NFTsChanged,
+				diff.ApprovalsAdded, diff.ApprovalsRemoved, diff.ApprovalsChanged,
+				diff.ContractsAdded, diff.ContractsRemoved, diff.ContractsChanged,
+				diff.LogsAdded, diff.LogsRemoved, diff.LogsChanged,
+				diff.RawTxsAdded, diff.RawTxsRemoved)
+		}
+		if done%100 == 0 || done == total {
+			fmt.Printf("reindex %d/%d (%.1f%%) height %d\n", done, total, float64(done)*100/float64(total), diff.Height)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	mode := "applied"
+	if *dryRun {
+		mode = "dry-run"
+	}
+	fmt.Printf("%s: heights %d-%d, ledgers changed %d, txs +%d -%d ~%d, payments +%d -%d ~%d, nfts +%d -%d ~%d, approvals +%d -%d ~%d, contracts +%d -%d ~%d, logs +%d -%d ~%d, raw txs +%d -%d\n",
+		mode, *from, *to, ledgers,
+		sum.TxsAdded, sum.TxsRemoved, sum.TxsChanged,
+		sum.PaymentsAdded, sum.PaymentsRemoved, sum.PaymentsChanged,
+		sum.NFTsAdded, sum.NFTsRemoved, sum.NFTsChanged,
+		sum.ApprovalsAdded, sum.ApprovalsRemoved, sum.ApprovalsChanged,
+		sum.ContractsAdded, sum.ContractsRemoved, sum.ContractsChanged,
+		sum.LogsAdded, sum.LogsRemoved, sum.LogsChanged,
+		sum.RawTxsAdded, sum.RawTxsRemoved)
+	return nil
+}
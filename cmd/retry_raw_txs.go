@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/toolglobal/api/client"
+	"github.com/toolglobal/api/config"
+	"github.com/toolglobal/api/datamanager"
+)
+
+// retryRawTxs 解析器升级后重新解析v3_raw_txs中的交易：api retry-raw-txs [--dry-run] [--version V]
+// 从保存的原始数据重新解析，节点只提供执行结果，仍无法解析的交易保留在v3_raw_txs中
+func retryRawTxs(cfg *config.Config, dataM *datamanager.DataManager, args []string) error {
+	fs := flag.NewFlagSet("retry-raw-txs", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "only report how many txs can be decoded, do not write")
+	version := fs.Int("version", 0, "sync version, required when more than one version is configured")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	v, err := syncVersion(cfg, *version)
+	if err != nil {
+		return err
+	}
+
+	fetch, err := client.NewMultiFetcher(cfg.RPCRemotes(), cfg.Fetch)
+	if err != nil {
+		return err
+	}
+	cli, err := client.NewClient(context.Background(), cfg.TGSBaseURL, cfg.ChainId, v, fetch, dataM, 0, cfg.Sync)
+	if err != nil {
+		return err
+	}
+
+	var heights, decoded, added, txs int
+	err = cli.RetryRawTxs(*dryRun, func(diff *client.ReindexDiff) {
+		heights++
+		decoded += diff.RawTxsRemoved
+		added += diff.RawTxsAdded
+		txs += diff.TxsAdded
+		fmt.Printf("height %d: decoded %d, new undecodable %d, txs +%d\n", diff.Height, diff.RawTxsRemoved, diff.RawTxsAdded, diff.TxsAdded)
+	})
+	if err != nil {
+		return err
+	}
+
+	mode := "applied"
+	if *dryRun {
+		mode = "dry-run"
+	}
+	fmt.Printf("%s: heights %d, decoded %d, new undecodable %d, txs +%d\n", mode, heights, decoded, added, txs)
+	return nil
+}
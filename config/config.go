@@ -2,23 +2,33 @@ package config
 
 import (
 	"github.com/BurntSushi/toml"
+	"strings"
 	"time"
 )
 
 type Config struct {
-	Bind        string
-	RPC         string
-	Dev         bool
-	Metrics     bool
-	ChainId     string
-	Versions    []int
-	StartHeight int64
-	TGSBaseURL  string
-	Limiter     Limiter
+	Bind         string
+	RPC          string
+	RPCs         []string // 同步区块、/v2接口使用的节点列表，为空时使用rpc
+	Web3RPC      string   // 节点web3 JSON-RPC地址，/rpc的状态查询转发到该地址，为空时不支持状态查询
+	Dev          bool
+	Metrics      bool
+	ChainId      string
+	Versions     []int
+	StartHeight  int64
+	TGSBaseURL   string
+	LegacyPaging bool // 兼容旧客户端按页码翻页，cursor为数字时作为页码；配置文件中没有该项时默认开启
+	Limiter      Limiter
+	Sync         Sync
+	Fetch        Fetch
+	Node         Node
+	Database     Database
+	Webhook      Webhook
+	Deposit      Deposit
 }
 
 func New() *Config {
-	return &Config{}
+	return &Config{LegacyPaging: true}
 }
 
 func (p *Config) Init(cfgFile string) error {
@@ -26,11 +36,81 @@ func (p *Config) Init(cfgFile string) error {
 	return err
 }
 
+// RPCRemotes 节点RPC地址，rpcs为空时使用rpc
+func (p *Config) RPCRemotes() []string {
+	rpcs := p.RPCs
+	if len(rpcs) == 0 {
+		rpcs = []string{p.RPC}
+	}
+	remotes := make([]string, 0, len(rpcs))
+	for _, rpc := range rpcs {
+		if !strings.Contains(rpc, "://") {
+			rpc = "http://" + rpc
+		}
+		remotes = append(remotes, rpc)
+	}
+	return remotes
+}
+
 type Limiter struct {
 	Interval duration
 	Capacity int64
 }
 
+// Sync 区块同步参数
+type Sync struct {
+	Workers     int     // 并发拉取区块的协程数
+	BatchSize   int     // 每轮预取的区块数，同时也是追块时一个数据库事务提交的最大区块数
+	TipDistance int     // 距离最新高度小于该值时逐块提交，默认3
+	TxTags      []TxTag // 交易类型生效的高度范围，未配置的类型在所有高度解析，已废弃的类型在所有高度都不解析
+}
+
+// TxTag 交易类型在[From, To]高度范围内解析，同一类型可以配置多段，用于描述协议升级
+type TxTag struct {
+	Tag  string // 交易的前两个字节，例如0x0105
+	From int64  // 起始高度（含）
+	To   int64  // 结束高度（含），0表示不限
+}
+
+// Fetch 从节点拉取区块的参数
+type Fetch struct {
+	Timeout duration // 单次请求的超时时间，默认10s
+	Retries int      // 请求失败后换节点重试的次数，默认3
+	MaxLag  int64    // 落后最高节点超过该高度的节点不参与拉取，默认10
+}
+
+// Node /v2接口查询节点、广播交易的参数
+type Node struct {
+	QueryTimeout     duration // abci_query等查询的超时时间，默认5s
+	BroadcastTimeout duration // broadcast_tx_sync/async的超时时间，默认10s
+	CommitTimeout    duration // broadcast_tx_commit等待出块的超时时间，默认30s
+	Retries          int      // 查询失败后换节点重试的次数，默认1
+	Fanout           int      // 广播交易同时发送的节点数，默认3
+	FailureThreshold int      // 节点连续失败该次数后熔断，默认5
+	Cooldown         duration // 熔断时间，之后放行一个请求试探，默认30s
+}
+
+// Database 索引数据存储，多个API实例共享数据时使用mysql
+type Database struct {
+	Type string // sqlite3（默认）或mysql
+	DSN  string // mysql连接串，例如 user:password@tcp(127.0.0.1:3306)/mondo，sqlite3不使用
+}
+
+// Webhook 推送参数
+type Webhook struct {
+	Enabled     bool     // 是否在本实例上推送，多个API实例共享数据库时只在一个实例上开启
+	APIKey      string   // 管理接口的X-API-KEY，为空时不开放管理接口
+	Workers     int      // 并发推送数，默认4
+	Timeout     duration // 单次推送的超时时间，默认10s
+	MaxAttempts int      // 最多推送次数，仍失败时进入死信，默认8
+}
+
+// Deposit 充值确认
+type Deposit struct {
+	APIKey        string // /v3/deposits、/v3/deposit-addresses的X-API-KEY，为空时不开放
+	Confirmations int64  // 登记充值地址时未指定确认数的默认值，默认12
+}
+
 type duration struct {
 	time.Duration
 }
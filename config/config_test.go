@@ -2,6 +2,8 @@ package config
 
 import (
 	"fmt"
+	"io/ioutil"
+	"path/filepath"
 	"testing"
 )
 
@@ -12,3 +14,22 @@ func Test_parsefile(t *testing.T) {
 	}
 	fmt.Println(cfg)
 }
+
+func Test_legacyPagingDefault(t *testing.T) {
+	for body, want := range map[string]bool{
+		"bind = \":8889\"\n":     true,
+		"legacyPaging = false\n": false,
+	} {
+		file := filepath.Join(t.TempDir(), "config.toml")
+		if err := ioutil.WriteFile(file, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+		cfg := New()
+		if err := cfg.Init(file); err != nil {
+			t.Fatal(err)
+		}
+		if cfg.LegacyPaging != want {
+			t.Fatalf("%q: legacyPaging %v", body, cfg.LegacyPaging)
+		}
+	}
+}
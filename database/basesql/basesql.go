@@ -5,16 +5,23 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
 	"github.com/toolglobal/api/database"
 	"go.uber.org/zap"
 	"path"
+	"reflect"
+	"strings"
+	"time"
 )
 
 // Basesql sql-like database
 //	is not goroutine-safe
 type Basesql struct {
+	DBType string // database.DBTypeSQLite3 or database.DBTypeMySQL, default sqlite3
+	DSN    string // mysql data source name, not used by sqlite3
+
 	conn   *sqlx.DB
 	tx     *sqlx.Tx
 	logger *zap.Logger
@@ -23,8 +30,48 @@ type Basesql struct {
 // Init initialization
 //	init db connection
 // 	create tables if not exist
+//	sqlite3 opens dbpath/dbname, mysql connects to DSN and ignores dbname and dbpath
 func (bs *Basesql) Init(dbname string, dbpath string, logger *zap.Logger) error {
-	conn, err := sqlx.Connect(database.DBTypeSQLite3, path.Join(dbpath, dbname))
+	switch bs.dbType() {
+	case database.DBTypeSQLite3:
+		return bs.initSQLite3(dbname, dbpath, logger)
+	case database.DBTypeMySQL:
+		return bs.initMySQL(logger)
+	default:
+		return errors.New("unsupported database type :" + bs.DBType)
+	}
+}
+
+func (bs *Basesql) dbType() string {
+	if bs.DBType == "" {
+		return database.DBTypeSQLite3
+	}
+	return bs.DBType
+}
+
+func (bs *Basesql) initMySQL(logger *zap.Logger) error {
+	cfg, err := mysql.ParseDSN(bs.DSN)
+	if err != nil {
+		return err
+	}
+	// createdAt等时间列按UTC读写，与sqlite的unix时间戳保持一致
+	cfg.ParseTime = true
+	cfg.Loc = time.UTC
+
+	conn, err := sqlx.Connect(database.DBTypeMySQL, cfg.FormatDSN())
+	if err != nil {
+		return err
+	}
+	conn.SetConnMaxLifetime(3 * time.Minute)
+
+	bs.conn = conn
+	bs.logger = logger
+	return nil
+}
+
+func (bs *Basesql) initSQLite3(dbname string, dbpath string, logger *zap.Logger) error {
+	// busy_timeout：reindex等命令与服务进程同时写库时等待而不是立即返回database is locked
+	conn, err := sqlx.Connect(database.DBTypeSQLite3, path.Join(dbpath, dbname)+"?_busy_timeout=5000")
 	if err != nil {
 		return err
 	}
@@ -60,6 +107,23 @@ func (bs *Basesql) Close() {
 	}
 }
 
+// args convert values to the types stored by current dialect:
+// sqlite3 stores time.Time as unix timestamp, mysql stores it as DATETIME
+func (bs *Basesql) args(values []interface{}) []interface{} {
+	if bs.dbType() != database.DBTypeSQLite3 {
+		return values
+	}
+	args := make([]interface{}, len(values))
+	for i, v := range values {
+		if t, ok := v.(time.Time); ok {
+			args[i] = t.Unix()
+		} else {
+			args[i] = v
+		}
+	}
+	return args
+}
+
 // PrepareTables create tables if not exists
 func (bs *Basesql) PrepareTables(ctsqls, cisqls []string) error {
 	for _, ctsql := range ctsqls {
@@ -84,7 +148,7 @@ func (bs *Basesql) Excute(stmt *sql.Stmt, fields []database.Feild) (sql.Result,
 	for i, v := range fields {
 		values[i] = v.Value
 	}
-	return stmt.Exec(values...)
+	return stmt.Exec(bs.args(values)...)
 }
 
 func (bs *Basesql) Prepare(table string, fields []database.Feild) (*sql.Stmt, error) {
@@ -103,9 +167,9 @@ func (bs *Basesql) Prepare(table string, fields []database.Feild) (*sql.Stmt, er
 	sqlBuff.WriteString(fmt.Sprintf("?);"))
 
 	if bs.tx != nil {
-		return bs.tx.Prepare(sqlBuff.String())
+		return bs.tx.Prepare(bs.conn.Rebind(sqlBuff.String()))
 	} else {
-		return bs.conn.Prepare(sqlBuff.String())
+		return bs.conn.Prepare(bs.conn.Rebind(sqlBuff.String()))
 	}
 }
 
@@ -141,14 +205,30 @@ func (bs *Basesql) Insert(table string, fields []database.Feild) (sql.Result, er
 	//log.Println("Insert", sqlBuff.String(), values)
 
 	if bs.tx != nil {
-		res, err = bs.tx.Exec(sqlBuff.String(), values...)
+		res, err = bs.tx.Exec(bs.conn.Rebind(sqlBuff.String()), bs.args(values)...)
 	} else {
-		res, err = bs.conn.Exec(sqlBuff.String(), values...)
+		res, err = bs.conn.Exec(bs.conn.Rebind(sqlBuff.String()), bs.args(values)...)
+	}
+	if isDuplicate(err) {
+		err = fmt.Errorf("%w: %v", database.ErrDuplicate, err)
 	}
 
 	return res, err
 }
 
+// isDuplicate 是否违反唯一索引
+func isDuplicate(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique || sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey
+	}
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == 1062 // ER_DUP_ENTRY
+	}
+	return false
+}
+
 // Delete delete records
 func (bs *Basesql) Delete(table string, where []database.Where) (sql.Result, error) {
 	if table == "" {
@@ -160,21 +240,15 @@ func (bs *Basesql) Delete(table string, where []database.Where) (sql.Result, err
 
 	var sqlBuff bytes.Buffer
 	sqlBuff.WriteString(fmt.Sprintf("delete from %s where 1 = 1", table))
-	for i := 0; i < len(where); i++ {
-		sqlBuff.WriteString(fmt.Sprintf(" and %s %s ? ", where[i].Name, where[i].GetOp()))
-	}
+	values := writeWhere(&sqlBuff, where, nil)
 
 	// execute
-	values := make([]interface{}, len(where))
-	for i, v := range where {
-		values[i] = v.Value
-	}
 	var res sql.Result
 	var err error
 	if bs.tx != nil {
-		res, err = bs.tx.Exec(sqlBuff.String(), values...)
+		res, err = bs.tx.Exec(bs.conn.Rebind(sqlBuff.String()), bs.args(values)...)
 	} else {
-		res, err = bs.conn.Exec(sqlBuff.String(), values...)
+		res, err = bs.conn.Exec(bs.conn.Rebind(sqlBuff.String()), bs.args(values)...)
 	}
 
 	return res, err
@@ -198,28 +272,22 @@ func (bs *Basesql) Update(table string, toupdate []database.Feild, where []datab
 		sqlBuff.WriteString(fmt.Sprintf(", %s = ? ", toupdate[i].Name))
 	}
 
-	sqlBuff.WriteString(fmt.Sprintf(" where 1 = 1 "))
-	for i := 0; i < len(where); i++ {
-		sqlBuff.WriteString(fmt.Sprintf(" and %s %s ? ", where[i].Name, where[i].GetOp()))
-	}
-
-	// execute
-	values := make([]interface{}, len(toupdate)+len(where))
+	values := make([]interface{}, len(toupdate), len(toupdate)+len(where))
 	for i, v := range toupdate {
 		values[i] = v.Value
 	}
-	for i, v := range where {
-		values[len(toupdate)+i] = v.Value
-	}
+
+	sqlBuff.WriteString(fmt.Sprintf(" where 1 = 1 "))
+	values = writeWhere(&sqlBuff, where, values)
 
 	//log.Println("Update ", sqlBuff.String(), values)
 
 	var res sql.Result
 	var err error
 	if bs.tx != nil {
-		res, err = bs.tx.Exec(sqlBuff.String(), values...)
+		res, err = bs.tx.Exec(bs.conn.Rebind(sqlBuff.String()), bs.args(values)...)
 	} else {
-		res, err = bs.conn.Exec(sqlBuff.String(), values...)
+		res, err = bs.conn.Exec(bs.conn.Rebind(sqlBuff.String()), bs.args(values)...)
 	}
 
 	return res, err
@@ -245,14 +313,12 @@ func (bs *Basesql) SelectRowsUnion(table string, wheres [][]database.Where, orde
 
 		wheresLen--
 
-		for _, v := range where {
-			values = append(values, v.Value)
+		if w := paging.KeysetWhere(order); order != nil && w != nil {
+			where = append(where[:len(where):len(where)], *w)
 		}
 
 		sqlBuff.WriteString(fmt.Sprintf("select * from %s where 1 = 1", table))
-		for i := 0; i < len(where); i++ {
-			sqlBuff.WriteString(fmt.Sprintf(" and %s %s ? ", where[i].Name, where[i].GetOp()))
-		}
+		values = writeWhere(&sqlBuff, where, values)
 		if wheresLen > 0 {
 			sqlBuff.WriteString(" union ")
 		}
@@ -266,7 +332,7 @@ func (bs *Basesql) SelectRowsUnion(table string, wheres [][]database.Where, orde
 		}
 		sqlBuff.WriteString(order.Type)
 
-		sqlBuff.WriteString(fmt.Sprintf(" limit %d offset %d ", paging.Limit, paging.CursorValue))
+		sqlBuff.WriteString(fmt.Sprintf(" limit %d offset %d ", paging.Limit, paging.Offset()))
 	}
 
 	//log.Println("SelectRowsUnion ", sqlBuff.String(), values)
@@ -274,9 +340,9 @@ func (bs *Basesql) SelectRowsUnion(table string, wheres [][]database.Where, orde
 	// execute
 	var err error
 	if bs.tx != nil {
-		err = bs.tx.Select(result, sqlBuff.String(), values...)
+		err = bs.tx.Select(result, bs.conn.Rebind(sqlBuff.String()), bs.args(values)...)
 	} else {
-		err = bs.conn.Select(result, sqlBuff.String(), values...)
+		err = bs.conn.Select(result, bs.conn.Rebind(sqlBuff.String()), bs.args(values)...)
 	}
 
 	return err
@@ -294,22 +360,15 @@ func (bs *Basesql) SelectRows(table string, where []database.Where, order *datab
 		return errors.New("order type and fields is required")
 	}
 
-	values := make([]interface{}, len(where))
-	for i, v := range where {
-		values[i] = v.Value
-	}
-
 	var sqlBuff bytes.Buffer
 	sqlBuff.WriteString(fmt.Sprintf("select * from %s where 1 = 1", table))
-	for i := 0; i < len(where); i++ {
-		sqlBuff.WriteString(fmt.Sprintf(" and %s %s ? ", where[i].Name, where[i].GetOp()))
-	}
+	values := writeWhere(&sqlBuff, where, nil)
 	if order != nil {
-		// append where clause for paging
-		//		if paging != nil && paging.CursorValue != 0 {
-		//			sqlBuff.WriteString(fmt.Sprintf(" and %s %s ? ", paging.CursorName, order.GetOp()))
-		//			values = append(values, paging.CursorValue)
-		//		}
+		// append where clause for keyset paging
+		if w := paging.KeysetWhere(order); w != nil {
+			sqlBuff.WriteString(fmt.Sprintf(" and %s %s ? ", w.Name, w.GetOp()))
+			values = append(values, w.Value)
+		}
 
 		// append order by clause for ordering
 		sqlBuff.WriteString(fmt.Sprintf(" order by %s ", order.Feilds[0]))
@@ -322,7 +381,7 @@ func (bs *Basesql) SelectRows(table string, where []database.Where, order *datab
 		if paging != nil {
 			//sqlBuff.WriteString(" limit ? ")
 			//values = append(values, paging.Limit)
-			sqlBuff.WriteString(fmt.Sprintf(" limit %d offset %d ", paging.Limit, paging.CursorValue))
+			sqlBuff.WriteString(fmt.Sprintf(" limit %d offset %d ", paging.Limit, paging.Offset()))
 		}
 	}
 
@@ -331,9 +390,9 @@ func (bs *Basesql) SelectRows(table string, where []database.Where, order *datab
 	// execute
 	var err error
 	if bs.tx != nil {
-		err = bs.tx.Select(result, sqlBuff.String(), values...)
+		err = bs.tx.Select(result, bs.conn.Rebind(sqlBuff.String()), bs.args(values)...)
 	} else {
-		err = bs.conn.Select(result, sqlBuff.String(), values...)
+		err = bs.conn.Select(result, bs.conn.Rebind(sqlBuff.String()), bs.args(values)...)
 	}
 
 	return err
@@ -351,16 +410,9 @@ func (bs *Basesql) SelectRowsOffset(table string, where []database.Where, order
 		return errors.New("order type and fields is required")
 	}
 
-	values := make([]interface{}, len(where))
-	for i, v := range where {
-		values[i] = v.Value
-	}
-
 	var sqlBuff bytes.Buffer
 	sqlBuff.WriteString(fmt.Sprintf("select * from %s where 1 = 1", table))
-	for i := 0; i < len(where); i++ {
-		sqlBuff.WriteString(fmt.Sprintf(" and %s %s ? ", where[i].Name, where[i].GetOp()))
-	}
+	values := writeWhere(&sqlBuff, where, nil)
 	if order != nil {
 		// append order by clause for ordering
 		sqlBuff.WriteString(fmt.Sprintf(" order by %s ", order.Feilds[0]))
@@ -378,14 +430,42 @@ func (bs *Basesql) SelectRowsOffset(table string, where []database.Where, order
 	// execute
 	var err error
 	if bs.tx != nil {
-		err = bs.tx.Select(result, sqlBuff.String(), values...)
+		err = bs.tx.Select(result, bs.conn.Rebind(sqlBuff.String()), bs.args(values)...)
 	} else {
-		err = bs.conn.Select(result, sqlBuff.String(), values...)
+		err = bs.conn.Select(result, bs.conn.Rebind(sqlBuff.String()), bs.args(values)...)
 	}
 
 	return err
 }
 
+// writeWhere append where clauses to sqlBuff and their values to values,
+// the slice value of in is expanded to one placeholder per element, an empty slice matches nothing
+func writeWhere(sqlBuff *bytes.Buffer, where []database.Where, values []interface{}) []interface{} {
+	for _, w := range where {
+		if !strings.EqualFold(w.GetOp(), database.OpIn) {
+			sqlBuff.WriteString(fmt.Sprintf(" and %s %s ? ", w.Name, w.GetOp()))
+			values = append(values, w.Value)
+			continue
+		}
+
+		in := reflect.ValueOf(w.Value)
+		if in.Kind() != reflect.Slice {
+			sqlBuff.WriteString(fmt.Sprintf(" and %s in (?) ", w.Name))
+			values = append(values, w.Value)
+			continue
+		}
+		if in.Len() == 0 {
+			sqlBuff.WriteString(" and 1 = 0 ")
+			continue
+		}
+		sqlBuff.WriteString(fmt.Sprintf(" and %s in (?%s) ", w.Name, strings.Repeat(", ?", in.Len()-1)))
+		for i := 0; i < in.Len(); i++ {
+			values = append(values, in.Index(i).Interface())
+		}
+	}
+	return values
+}
+
 // SelectRawSQL query useing raw sql
 func (bs *Basesql) SelectRawSQL(table string, sqlStr string, values []interface{}, result interface{}) error {
 	if table == "" {
@@ -396,9 +476,9 @@ func (bs *Basesql) SelectRawSQL(table string, sqlStr string, values []interface{
 	//
 	var err error
 	if bs.tx != nil {
-		err = bs.tx.Select(result, sqlStr, values...)
+		err = bs.tx.Select(result, bs.conn.Rebind(sqlStr), bs.args(values)...)
 	} else {
-		err = bs.conn.Select(result, sqlStr, values...)
+		err = bs.conn.Select(result, bs.conn.Rebind(sqlStr), bs.args(values)...)
 	}
 
 	return err
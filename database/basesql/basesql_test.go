@@ -1 +1,282 @@
 package basesql
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/toolglobal/api/database"
+	"github.com/toolglobal/api/libs/log"
+)
+
+// testDatabases 每个用例在sqlite3上运行；设置MYSQL_TEST_DSN时同时在mysql上运行，例如
+//
+//	docker run -d -p 3306:3306 -e MYSQL_ROOT_PASSWORD=test -e MYSQL_DATABASE=mondo_test mysql:8
+//	MYSQL_TEST_DSN='root:test@tcp(127.0.0.1:3306)/mondo_test' go test ./database/basesql
+func testDatabases(t *testing.T, fn func(t *testing.T, bs *Basesql)) {
+	t.Run(database.DBTypeSQLite3, func(t *testing.T) {
+		bs := &Basesql{}
+		if err := bs.Init("test.db", t.TempDir(), log.Logger); err != nil {
+			t.Fatal(err)
+		}
+		defer bs.Close()
+		prepareTestTables(t, bs)
+		fn(t, bs)
+	})
+
+	t.Run(database.DBTypeMySQL, func(t *testing.T) {
+		dsn := os.Getenv("MYSQL_TEST_DSN")
+		if dsn == "" {
+			t.Skip("MYSQL_TEST_DSN not set")
+		}
+		bs := &Basesql{DBType: database.DBTypeMySQL, DSN: dsn}
+		if err := bs.Init("", "", log.Logger); err != nil {
+			t.Fatal(err)
+		}
+		defer bs.Close()
+		for _, table := range []string{database.TableV3Ledgers, database.TableV3Transactions, database.TableV3Payments, database.TableSyncState} {
+			if _, err := bs.conn.Exec("DROP TABLE IF EXISTS " + table); err != nil {
+				t.Fatal(err)
+			}
+		}
+		prepareTestTables(t, bs)
+		fn(t, bs)
+	})
+}
+
+func prepareTestTables(t *testing.T, bs *Basesql) {
+	qt, qi := bs.GetInitSQLs()
+	if err := bs.PrepareTables(qt, qi); err != nil {
+		t.Fatal(err)
+	}
+	// 重复建表不应报错
+	if err := bs.PrepareTables(qt, qi); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func insertTestTx(t *testing.T, bs *Basesql, height int64, sender string, createdAt time.Time) {
+	fields := []database.Feild{
+		{Name: "hash", Value: fmt.Sprintf("0x%02x", height)},
+		{Name: "height", Value: height},
+		{Name: "typei", Value: 1},
+		{Name: "types", Value: "TxTagAppEvm"},
+		{Name: "sender", Value: sender},
+		{Name: "nonce", Value: height},
+		{Name: "receiver", Value: "0xB0b"},
+		{Name: "value", Value: "1.5"},
+		{Name: "gasLimit", Value: 21000},
+		{Name: "gasUsed", Value: 21000},
+		{Name: "gasPrice", Value: "0.0000001"},
+		{Name: "memo", Value: ""},
+		{Name: "payload", Value: ""},
+		{Name: "events", Value: ""},
+		{Name: "codei", Value: 0},
+		{Name: "codes", Value: ""},
+		{Name: "createdAt", Value: createdAt},
+	}
+	if _, err := bs.Insert(database.TableV3Transactions, fields); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func heightsOf(txs []database.V3Transaction) []int64 {
+	var heights []int64
+	for _, tx := range txs {
+		heights = append(heights, tx.Height)
+	}
+	return heights
+}
+
+func equalHeights(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestBasesql_InsertSelect(t *testing.T) {
+	testDatabases(t, func(t *testing.T, bs *Basesql) {
+		base := time.Unix(1600000000, 0)
+		for h := int64(1); h <= 5; h++ {
+			sender := "0xA11cE"
+			if h%2 == 0 {
+				sender = "0xCa01"
+			}
+			insertTestTx(t, bs, h, sender, base.Add(time.Duration(h)*time.Minute))
+		}
+
+		var result []database.V3Transaction
+		where := []database.Where{{Name: "hash", Value: "0x03"}}
+		if err := bs.SelectRows(database.TableV3Transactions, where, nil, nil, &result); err != nil {
+			t.Fatal(err)
+		}
+		if len(result) != 1 || result[0].Value != "1.5" || result[0].GasLimit != 21000 {
+			t.Fatalf("select %+v", result)
+		}
+		if !result[0].CreatedAt.Equal(base.Add(3 * time.Minute)) {
+			t.Fatalf("createdAt %s, want %s", result[0].CreatedAt, base.Add(3*time.Minute))
+		}
+
+		// 时间条件
+		order, _ := database.MakeOrder("ASC", "id")
+		where = []database.Where{
+			{Name: "1", Value: 1},
+			{Name: "createdAt", Value: base.Add(2 * time.Minute), Op: ">="},
+			{Name: "createdAt", Value: base.Add(4 * time.Minute), Op: "<"},
+		}
+		result = nil
+		if err := bs.SelectRows(database.TableV3Transactions, where, order, database.MakePaging("id", 0, 10), &result); err != nil {
+			t.Fatal(err)
+		}
+		if got := heightsOf(result); !equalHeights(got, []int64{2, 3}) {
+			t.Fatalf("createdAt range got %v", got)
+		}
+
+		// 游标分页
+		order, _ = database.MakeOrder("DESC", "id")
+		where = []database.Where{{Name: "1", Value: 1}}
+		result = nil
+		if err := bs.SelectRows(database.TableV3Transactions, where, order, database.MakeKeysetPaging("id", 4, 2), &result); err != nil {
+			t.Fatal(err)
+		}
+		if got := heightsOf(result); !equalHeights(got, []int64{3, 2}) {
+			t.Fatalf("keyset got %v", got)
+		}
+
+		// in
+		where = []database.Where{{Name: "height", Value: []int64{1, 4, 9}, Op: database.OpIn}}
+		result = nil
+		if err := bs.SelectRows(database.TableV3Transactions, where, order, database.MakePaging("id", 0, 10), &result); err != nil {
+			t.Fatal(err)
+		}
+		if got := heightsOf(result); !equalHeights(got, []int64{4, 1}) {
+			t.Fatalf("in got %v", got)
+		}
+		where = []database.Where{{Name: "height", Value: []int64{}, Op: database.OpIn}}
+		result = nil
+		if err := bs.SelectRows(database.TableV3Transactions, where, order, database.MakePaging("id", 0, 10), &result); err != nil {
+			t.Fatal(err)
+		}
+		if len(result) != 0 {
+			t.Fatalf("empty in got %v", heightsOf(result))
+		}
+
+		// union
+		wheres := [][]database.Where{
+			{{Name: "sender", Value: "0xA11cE"}},
+			{{Name: "height", Value: 2}},
+		}
+		result = nil
+		if err := bs.SelectRowsUnion(database.TableV3Transactions, wheres, order, database.MakePaging("id", 0, 10), &result); err != nil {
+			t.Fatal(err)
+		}
+		if got := heightsOf(result); !equalHeights(got, []int64{5, 3, 2, 1}) {
+			t.Fatalf("union got %v", got)
+		}
+
+		// raw sql
+		result = nil
+		sqlStr := "select * from v3_transactions where sender = ? and createdAt > ? order by id asc"
+		if err := bs.SelectRawSQL(database.TableV3Transactions, sqlStr, []interface{}{"0xA11cE", base.Add(time.Minute)}, &result); err != nil {
+			t.Fatal(err)
+		}
+		if got := heightsOf(result); !equalHeights(got, []int64{3, 5}) {
+			t.Fatalf("raw sql got %v", got)
+		}
+	})
+}
+
+func TestBasesql_UpdateDelete(t *testing.T) {
+	testDatabases(t, func(t *testing.T, bs *Basesql) {
+		now := time.Unix(1600000000, 0)
+		for h := int64(1); h <= 3; h++ {
+			insertTestTx(t, bs, h, "0xA11cE", now)
+		}
+
+		toupdate := []database.Feild{{Name: "codei", Value: 7}, {Name: "createdAt", Value: now.Add(time.Hour)}}
+		where := []database.Where{{Name: "height", Value: 2}}
+		if _, err := bs.Update(database.TableV3Transactions, toupdate, where); err != nil {
+			t.Fatal(err)
+		}
+		var result []database.V3Transaction
+		if err := bs.SelectRows(database.TableV3Transactions, where, nil, nil, &result); err != nil {
+			t.Fatal(err)
+		}
+		if len(result) != 1 || result[0].Codei != 7 || !result[0].CreatedAt.Equal(now.Add(time.Hour)) {
+			t.Fatalf("update %+v", result)
+		}
+
+		if _, err := bs.Delete(database.TableV3Transactions, []database.Where{{Name: "height", Value: 1, Op: ">"}}); err != nil {
+			t.Fatal(err)
+		}
+		result = nil
+		if err := bs.SelectRows(database.TableV3Transactions, []database.Where{{Name: "1", Value: 1}}, nil, nil, &result); err != nil {
+			t.Fatal(err)
+		}
+		if got := heightsOf(result); !equalHeights(got, []int64{1}) {
+			t.Fatalf("delete got %v", got)
+		}
+	})
+}
+
+func TestBasesql_Transaction(t *testing.T) {
+	testDatabases(t, func(t *testing.T, bs *Basesql) {
+		now := time.Unix(1600000000, 0)
+		fields := []database.Feild{
+			{Name: "hash"}, {Name: "height"}, {Name: "evName"}, {Name: "idx"}, {Name: "sender"},
+			{Name: "receiver"}, {Name: "symbol"}, {Name: "contract"}, {Name: "value"}, {Name: "createdAt"},
+		}
+		insertPayments := func(height int64) {
+			stmt, err := bs.Prepare(database.TableV3Payments, fields)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer stmt.Close()
+			for i := 0; i < 3; i++ {
+				values := []interface{}{"0x01", height, "", i, "0xA11cE", "0xB0b", "OLO", "", "1", now}
+				for j := range fields {
+					fields[j].Value = values[j]
+				}
+				if _, err := bs.Excute(stmt, fields); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		count := func() int {
+			var result []database.V3Payment
+			if err := bs.SelectRows(database.TableV3Payments, []database.Where{{Name: "1", Value: 1}}, nil, nil, &result); err != nil {
+				t.Fatal(err)
+			}
+			return len(result)
+		}
+
+		if err := bs.Begin(); err != nil {
+			t.Fatal(err)
+		}
+		insertPayments(1)
+		if err := bs.Rollback(); err != nil {
+			t.Fatal(err)
+		}
+		if n := count(); n != 0 {
+			t.Fatalf("rollback left %d payments", n)
+		}
+
+		if err := bs.Begin(); err != nil {
+			t.Fatal(err)
+		}
+		insertPayments(2)
+		if err := bs.Commit(); err != nil {
+			t.Fatal(err)
+		}
+		if n := count(); n != 3 {
+			t.Fatalf("commit got %d payments", n)
+		}
+	})
+}
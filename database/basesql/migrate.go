@@ -0,0 +1,142 @@
+package basesql
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/toolglobal/api/database"
+	"go.uber.org/zap"
+)
+
+// Migration 一次表结构变更。建表语句保持初始版本不变，之后的变更都以迁移的方式追加，
+// 已发布的迁移不能修改，Version必须递增
+type Migration struct {
+	Version int
+	Name    string
+	Up      map[string][]string // 各方言的变更语句，key为database.DBTypeSQLite3、database.DBTypeMySQL
+}
+
+// MigrationState 迁移的执行情况，未执行时AppliedAt为nil
+type MigrationState struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+type schemaMigration struct {
+	Version   int       `db:"version"`
+	Name      string    `db:"name"`
+	AppliedAt time.Time `db:"appliedAt"`
+}
+
+const (
+	createSchemaMigrationsSQL = `CREATE TABLE IF NOT EXISTS schema_migrations
+	(
+		version    INTEGER  PRIMARY KEY,
+		name       TEXT     NOT NULL,
+		appliedAt  DATETIME NOT NULL
+	);`
+	createSchemaMigrationsMySQL = `CREATE TABLE IF NOT EXISTS schema_migrations
+	(
+		version    INT          NOT NULL,
+		name       VARCHAR(128) NOT NULL,
+		appliedAt  DATETIME     NOT NULL,
+		PRIMARY KEY (version)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`
+)
+
+// Migrations 全部迁移，按版本排序
+func Migrations() []Migration {
+	return migrations
+}
+
+// MigrationStatus 查询每个迁移的执行情况
+func (bs *Basesql) MigrationStatus() ([]MigrationState, error) {
+	if err := bs.prepareSchemaMigrations(); err != nil {
+		return nil, err
+	}
+
+	var applied []schemaMigration
+	if err := bs.conn.Select(&applied, "select * from schema_migrations order by version"); err != nil {
+		return nil, err
+	}
+	appliedAt := make(map[int]time.Time, len(applied))
+	for _, v := range applied {
+		appliedAt[v.Version] = v.AppliedAt
+	}
+
+	states := make([]MigrationState, len(migrations))
+	for i, m := range migrations {
+		states[i] = MigrationState{Version: m.Version, Name: m.Name}
+		if t, ok := appliedAt[m.Version]; ok {
+			states[i].AppliedAt = &t
+		}
+	}
+	return states, nil
+}
+
+// Migrate 按版本顺序执行尚未执行的迁移，返回本次执行的版本。
+// 每个迁移与其执行记录在同一个事务中提交；mysql的DDL会隐式提交，多实例部署时应先单独执行api migrate
+func (bs *Basesql) Migrate() ([]int, error) {
+	return bs.migrateTo(0)
+}
+
+// migrateTo 执行到target版本为止，target为0时执行全部
+func (bs *Basesql) migrateTo(target int) ([]int, error) {
+	states, err := bs.MigrationStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []int
+	for i, state := range states {
+		if target > 0 && state.Version > target {
+			break
+		}
+		if state.AppliedAt != nil {
+			continue
+		}
+		if err := bs.applyMigration(&migrations[i]); err != nil {
+			return versions, fmt.Errorf("migration %d %s: %v", state.Version, state.Name, err)
+		}
+		versions = append(versions, state.Version)
+	}
+	return versions, nil
+}
+
+func (bs *Basesql) prepareSchemaMigrations() error {
+	sqlStr := createSchemaMigrationsSQL
+	if bs.dbType() == database.DBTypeMySQL {
+		sqlStr = createSchemaMigrationsMySQL
+	}
+	_, err := bs.conn.Exec(sqlStr)
+	return err
+}
+
+func (bs *Basesql) applyMigration(m *Migration) error {
+	sqls, ok := m.Up[bs.dbType()]
+	if !ok {
+		return fmt.Errorf("no sql for %s", bs.dbType())
+	}
+
+	tx, err := bs.conn.Beginx()
+	if err != nil {
+		return err
+	}
+	for _, sqlStr := range sqls {
+		if _, err := tx.Exec(sqlStr); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	insert := bs.conn.Rebind("insert into schema_migrations (version,name,appliedAt) values (?,?,?)")
+	if _, err := tx.Exec(insert, bs.args([]interface{}{m.Version, m.Name, time.Now()})...); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	bs.logger.Info("migration applied", zap.Int("version", m.Version), zap.String("name", m.Name))
+	return nil
+}
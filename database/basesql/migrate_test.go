@@ -0,0 +1,352 @@
+package basesql
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/toolglobal/api/database"
+	"github.com/toolglobal/api/libs/log"
+)
+
+// loadFixture 用testdata中迁移之前的库初始化一个sqlite数据库
+func loadFixture(t *testing.T) *Basesql {
+	bs := &Basesql{}
+	if err := bs.Init("fixture.db", t.TempDir(), log.Logger); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(bs.Close)
+
+	fixture, err := ioutil.ReadFile("testdata/fixture_v0.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bs.conn.Exec(string(fixture)); err != nil {
+		t.Fatal(err)
+	}
+	return bs
+}
+
+// migrationChecks 每个迁移执行后的校验，新增迁移时必须同时增加校验
+var migrationChecks = map[int]func(t *testing.T, bs *Basesql){
+	1: func(t *testing.T, bs *Basesql) {
+		var txs []database.V3Transaction
+		if err := bs.SelectRows(database.TableV3Transactions, []database.Where{{Name: "height", Value: 2}}, nil, nil, &txs); err != nil {
+			t.Fatal(err)
+		}
+		if len(txs) != 2 || txs[0].TxIdx != 0 || txs[1].TxIdx != 0 {
+			t.Fatalf("existing transactions %+v", txs)
+		}
+
+		fields := []database.Feild{
+			{Name: "hash", Value: "0x03"}, {Name: "height", Value: 3}, {Name: "typei", Value: 1}, {Name: "types", Value: "TxTagAppEvm"},
+			{Name: "sender", Value: "0xA11cE"}, {Name: "nonce", Value: 2}, {Name: "receiver", Value: "0xB0b"}, {Name: "value", Value: "1"},
+			{Name: "gasLimit", Value: 21000}, {Name: "gasUsed", Value: 21000}, {Name: "gasPrice", Value: "1"}, {Name: "memo", Value: ""},
+			{Name: "payload", Value: ""}, {Name: "events", Value: ""}, {Name: "codei", Value: 0}, {Name: "codes", Value: ""},
+			{Name: "createdAt", Value: 1600000003}, {Name: "txIdx", Value: 4},
+		}
+		if _, err := bs.Insert(database.TableV3Transactions, fields); err != nil {
+			t.Fatal(err)
+		}
+		txs = nil
+		if err := bs.SelectRows(database.TableV3Transactions, []database.Where{{Name: "hash", Value: "0x03"}}, nil, nil, &txs); err != nil {
+			t.Fatal(err)
+		}
+		if len(txs) != 1 || txs[0].TxIdx != 4 {
+			t.Fatalf("new transaction %+v", txs)
+		}
+	},
+	2: func(t *testing.T, bs *Basesql) {
+		fields := []database.Feild{
+			{Name: "hash", Value: "0x03"}, {Name: "height", Value: 3}, {Name: "idx", Value: 1}, {Name: "batchIdx", Value: 2},
+			{Name: "standard", Value: "ERC1155"}, {Name: "contract", Value: "0xC0"}, {Name: "operator", Value: "0xA11cE"},
+			{Name: "sender", Value: "0xA11cE"}, {Name: "receiver", Value: "0xB0b"}, {Name: "tokenId", Value: "7"},
+			{Name: "value", Value: "10"}, {Name: "createdAt", Value: 1600000003},
+		}
+		if _, err := bs.Insert(database.TableV3NFTTransfers, fields); err != nil {
+			t.Fatal(err)
+		}
+		var nfts []database.V3NFTTransfer
+		if err := bs.SelectRows(database.TableV3NFTTransfers, []database.Where{{Name: "receiver", Value: "0xB0b"}}, nil, nil, &nfts); err != nil {
+			t.Fatal(err)
+		}
+		if len(nfts) != 1 || nfts[0].BatchIdx != 2 || nfts[0].TokenId != "7" {
+			t.Fatalf("nft transfers %+v", nfts)
+		}
+
+		fields = []database.Feild{
+			{Name: "hash", Value: "0x03"}, {Name: "height", Value: 3}, {Name: "idx", Value: 2}, {Name: "evName", Value: "ApprovalForAll"},
+			{Name: "standard", Value: ""}, {Name: "contract", Value: "0xC0"}, {Name: "owner", Value: "0xA11cE"},
+			{Name: "spender", Value: "0xB0b"}, {Name: "tokenId", Value: ""}, {Name: "value", Value: ""},
+			{Name: "approved", Value: true}, {Name: "createdAt", Value: 1600000003},
+		}
+		if _, err := bs.Insert(database.TableV3Approvals, fields); err != nil {
+			t.Fatal(err)
+		}
+		var approvals []database.V3Approval
+		if err := bs.SelectRows(database.TableV3Approvals, []database.Where{{Name: "owner", Value: "0xA11cE"}}, nil, nil, &approvals); err != nil {
+			t.Fatal(err)
+		}
+		if len(approvals) != 1 || !approvals[0].Approved || approvals[0].EvName != "ApprovalForAll" {
+			t.Fatalf("approvals %+v", approvals)
+		}
+	},
+	3: func(t *testing.T, bs *Basesql) {
+		fields := []database.Feild{
+			{Name: "address", Value: "0xA11cE"}, {Name: "contract", Value: "0xC0"}, {Name: "balance", Value: "123456789012345678901234567890"},
+			{Name: "height", Value: 3}, {Name: "updatedAt", Value: 1600000003},
+		}
+		if _, err := bs.Insert(database.TableV3Balances, fields); err != nil {
+			t.Fatal(err)
+		}
+		// 同一账户、合约只能有一条余额
+		if _, err := bs.Insert(database.TableV3Balances, fields); err == nil {
+			t.Fatal("duplicate balance inserted")
+		}
+		var balances []database.V3Balance
+		if err := bs.SelectRows(database.TableV3Balances, []database.Where{{Name: "address", Value: "0xA11cE"}}, nil, nil, &balances); err != nil {
+			t.Fatal(err)
+		}
+		if len(balances) != 1 || balances[0].Balance != "123456789012345678901234567890" || balances[0].Height != 3 {
+			t.Fatalf("balances %+v", balances)
+		}
+	},
+	4: func(t *testing.T, bs *Basesql) {
+		for h := 1; h <= 2; h++ {
+			fields := []database.Feild{
+				{Name: "address", Value: "0xA11cE"}, {Name: "height", Value: h}, {Name: "delta", Value: "-5"},
+				{Name: "balance", Value: fmt.Sprint(-5 * h)}, {Name: "createdAt", Value: 1600000000 + h},
+			}
+			if _, err := bs.Insert(database.TableV3BalanceHistory, fields); err != nil {
+				t.Fatal(err)
+			}
+		}
+		order, _ := database.MakeOrder("DESC", "height")
+		where := []database.Where{{Name: "address", Value: "0xA11cE"}, {Name: "height", Value: 1, Op: "<="}}
+		var history []database.V3BalanceHistory
+		if err := bs.SelectRows(database.TableV3BalanceHistory, where, order, database.MakePaging("id", 0, 1), &history); err != nil {
+			t.Fatal(err)
+		}
+		if len(history) != 1 || history[0].Height != 1 || history[0].Balance != "-5" {
+			t.Fatalf("balance history %+v", history)
+		}
+	},
+	5: func(t *testing.T, bs *Basesql) {
+		fields := []database.Feild{
+			{Name: "address", Value: "0xC0"}, {Name: "creator", Value: "0xA11cE"}, {Name: "hash", Value: "0x03"},
+			{Name: "height", Value: 3}, {Name: "types", Value: "TxTagAppEvm"}, {Name: "createdAt", Value: 1600000003},
+		}
+		if _, err := bs.Insert(database.TableV3Contracts, fields); err != nil {
+			t.Fatal(err)
+		}
+		// 合约地址唯一
+		if _, err := bs.Insert(database.TableV3Contracts, fields); err == nil {
+			t.Fatal("duplicate contract inserted")
+		}
+		var contracts []database.V3Contract
+		if err := bs.SelectRows(database.TableV3Contracts, []database.Where{{Name: "creator", Value: "0xA11cE"}}, nil, nil, &contracts); err != nil {
+			t.Fatal(err)
+		}
+		if len(contracts) != 1 || contracts[0].Address != "0xC0" || contracts[0].Height != 3 {
+			t.Fatalf("contracts %+v", contracts)
+		}
+	},
+	6: func(t *testing.T, bs *Basesql) {
+		for i, topic := range []string{"0xddf2", "0x8c5b", "0xddf2"} {
+			fields := []database.Feild{
+				{Name: "hash", Value: "0x03"}, {Name: "height", Value: 3}, {Name: "txIdx", Value: 1}, {Name: "logIdx", Value: i},
+				{Name: "address", Value: "0xC0"}, {Name: "topic0", Value: topic}, {Name: "topic1", Value: "0xA11cE"},
+				{Name: "topic2", Value: ""}, {Name: "topic3", Value: ""}, {Name: "data", Value: "0x"}, {Name: "createdAt", Value: 1600000003},
+			}
+			if _, err := bs.Insert(database.TableV3Logs, fields); err != nil {
+				t.Fatal(err)
+			}
+		}
+		where := []database.Where{{Name: "address", Value: "0xC0"}, {Name: "topic0", Value: []string{"0xddf2"}, Op: database.OpIn}}
+		var logs []database.V3Log
+		if err := bs.SelectRows(database.TableV3Logs, where, nil, nil, &logs); err != nil {
+			t.Fatal(err)
+		}
+		if len(logs) != 2 || logs[1].LogIdx != 2 || logs[1].Topic1 != "0xA11cE" {
+			t.Fatalf("logs %+v", logs)
+		}
+	},
+	7: func(t *testing.T, bs *Basesql) {
+		fields := []database.Feild{
+			{Name: "url", Value: "http://127.0.0.1/hook"}, {Name: "secret", Value: "s"}, {Name: "address", Value: "0xA11cE"},
+			{Name: "contract", Value: ""}, {Name: "event", Value: ""}, {Name: "createdAt", Value: 1600000003},
+		}
+		if _, err := bs.Insert(database.TableV3Webhooks, fields); err != nil {
+			t.Fatal(err)
+		}
+		for i, status := range []string{database.WebhookPending, database.WebhookDead} {
+			fields := []database.Feild{
+				{Name: "webhookId", Value: 1}, {Name: "kind", Value: database.WebhookKindPayment}, {Name: "hash", Value: "0x03"},
+				{Name: "height", Value: 3}, {Name: "payload", Value: "{}"}, {Name: "status", Value: status}, {Name: "attempts", Value: i},
+				{Name: "responseCode", Value: 0}, {Name: "lastError", Value: ""}, {Name: "nextAttemptAt", Value: 1600000003 + i},
+				{Name: "createdAt", Value: 1600000003}, {Name: "updatedAt", Value: 1600000003},
+			}
+			if _, err := bs.Insert(database.TableV3WebhookDeliveries, fields); err != nil {
+				t.Fatal(err)
+			}
+		}
+		where := []database.Where{{Name: "webhookId", Value: 1}, {Name: "status", Value: database.WebhookDead}}
+		var deliveries []database.V3WebhookDelivery
+		if err := bs.SelectRows(database.TableV3WebhookDeliveries, where, nil, nil, &deliveries); err != nil {
+			t.Fatal(err)
+		}
+		if len(deliveries) != 1 || deliveries[0].Attempts != 1 || deliveries[0].NextAttemptAt.Unix() != 1600000004 {
+			t.Fatalf("deliveries %+v", deliveries)
+		}
+	},
+	8: func(t *testing.T, bs *Basesql) {
+		fields := []database.Feild{
+			{Name: "address", Value: "0xA11cE"}, {Name: "confirmations", Value: 12}, {Name: "label", Value: "user 1"}, {Name: "createdAt", Value: 1600000003},
+		}
+		if _, err := bs.Insert(database.TableV3DepositAddresses, fields); err != nil {
+			t.Fatal(err)
+		}
+		// 同一地址只能登记一次
+		if _, err := bs.Insert(database.TableV3DepositAddresses, fields); err == nil {
+			t.Fatal("duplicate deposit address inserted")
+		}
+		for i, invalidated := range []bool{false, true} {
+			fields := []database.Feild{
+				{Name: "address", Value: "0xA11cE"}, {Name: "hash", Value: "0x03"}, {Name: "height", Value: 3}, {Name: "idx", Value: i},
+				{Name: "sender", Value: "0xB0b"}, {Name: "symbol", Value: "OLO"}, {Name: "contract", Value: "0x00"}, {Name: "value", Value: "1"},
+				{Name: "confirmHeight", Value: 14}, {Name: "invalidated", Value: invalidated},
+				{Name: "createdAt", Value: 1600000003}, {Name: "updatedAt", Value: 1600000003},
+			}
+			if _, err := bs.Insert(database.TableV3Deposits, fields); err != nil {
+				t.Fatal(err)
+			}
+		}
+		where := []database.Where{{Name: "address", Value: "0xA11cE"}, {Name: "invalidated", Value: false}, {Name: "confirmHeight", Value: 14, Op: "<="}}
+		var deposits []database.V3Deposit
+		if err := bs.SelectRows(database.TableV3Deposits, where, nil, nil, &deposits); err != nil {
+			t.Fatal(err)
+		}
+		if len(deposits) != 1 || deposits[0].Idx != 0 || deposits[0].Invalidated {
+			t.Fatalf("deposits %+v", deposits)
+		}
+	},
+	9: func(t *testing.T, bs *Basesql) {
+		for i, tag := range []string{"0x0a0b", "0x0c0d"} {
+			fields := []database.Feild{
+				{Name: "height", Value: 3}, {Name: "txIdx", Value: i}, {Name: "hash", Value: "0x03"}, {Name: "tag", Value: tag},
+				{Name: "raw", Value: tag + "00"}, {Name: "error", Value: "unknown tx tag"}, {Name: "createdAt", Value: 1600000003},
+			}
+			if _, err := bs.Insert(database.TableV3RawTxs, fields); err != nil {
+				t.Fatal(err)
+			}
+		}
+		where := []database.Where{{Name: "height", Value: 3}, {Name: "tag", Value: "0x0c0d"}}
+		var rawTxs []database.V3RawTx
+		if err := bs.SelectRows(database.TableV3RawTxs, where, nil, nil, &rawTxs); err != nil {
+			t.Fatal(err)
+		}
+		if len(rawTxs) != 1 || rawTxs[0].TxIdx != 1 || rawTxs[0].Raw != "0x0c0d00" {
+			t.Fatalf("raw txs %+v", rawTxs)
+		}
+	},
+	10: func(t *testing.T, bs *Basesql) {
+		fields := []database.Feild{
+			{Name: "height", Value: 3}, {Name: "blockHash", Value: "AAA3"}, {Name: "blockSize", Value: 1}, {Name: "validator", Value: "V"},
+			{Name: "txCount", Value: 0}, {Name: "gasLimit", Value: 0}, {Name: "gasUsed", Value: 0}, {Name: "gasPrice", Value: "0"},
+			{Name: "createdAt", Value: 1600000003},
+		}
+		if _, err := bs.Insert(database.TableV3Ledgers, fields); err != nil {
+			t.Fatal(err)
+		}
+		// 同一高度只能有一个ledger
+		if _, err := bs.Insert(database.TableV3Ledgers, fields); !errors.Is(err, database.ErrDuplicate) {
+			t.Fatalf("duplicate ledger: %v", err)
+		}
+		if _, err := bs.Delete(database.TableV3Ledgers, []database.Where{{Name: "height", Value: 3}}); err != nil {
+			t.Fatal(err)
+		}
+	},
+	11: func(t *testing.T, bs *Basesql) {
+		var txs []database.V3Transaction
+		if err := bs.SelectRows(database.TableV3Transactions, []database.Where{{Name: "height", Value: 2}}, nil, nil, &txs); err != nil {
+			t.Fatal(err)
+		}
+		// 已有交易没有签名数据
+		if len(txs) != 2 || txs[0].RawTx != "" || txs[1].RawTx != "" {
+			t.Fatalf("txs %+v", txs)
+		}
+	},
+}
+
+func TestMigrate_Fixture(t *testing.T) {
+	bs := loadFixture(t)
+
+	for _, m := range Migrations() {
+		check, ok := migrationChecks[m.Version]
+		if !ok {
+			t.Fatalf("migration %d has no check", m.Version)
+		}
+		if _, ok := m.Up[database.DBTypeMySQL]; !ok {
+			t.Fatalf("migration %d has no mysql sql", m.Version)
+		}
+
+		versions, err := bs.migrateTo(m.Version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(versions) != 1 || versions[0] != m.Version {
+			t.Fatalf("migrate to %d applied %v", m.Version, versions)
+		}
+		check(t, bs)
+	}
+
+	// 已有数据不受影响
+	var ledgers []database.V3Ledger
+	if err := bs.SelectRows(database.TableV3Ledgers, []database.Where{{Name: "1", Value: 1}}, nil, nil, &ledgers); err != nil {
+		t.Fatal(err)
+	}
+	if len(ledgers) != 2 || ledgers[1].BlockHash != "AAA2" || ledgers[1].CreatedAt.Unix() != 1600000002 {
+		t.Fatalf("ledgers %+v", ledgers)
+	}
+
+	states, err := bs.MigrationStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, state := range states {
+		if state.AppliedAt == nil {
+			t.Fatalf("migration %d not applied", state.Version)
+		}
+	}
+}
+
+func TestMigrate_Startup(t *testing.T) {
+	bs := loadFixture(t)
+
+	// 启动流程：建表（已存在）后执行全部迁移，再次启动时不重复执行
+	for i := 0; i < 2; i++ {
+		qt, qi := bs.GetInitSQLs()
+		if err := bs.PrepareTables(qt, qi); err != nil {
+			t.Fatal(err)
+		}
+		versions, err := bs.Migrate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 && len(versions) != len(Migrations()) {
+			t.Fatalf("first start applied %v", versions)
+		}
+		if i == 1 && len(versions) != 0 {
+			t.Fatalf("second start applied %v", versions)
+		}
+	}
+}
+
+func TestMigrate_Versions(t *testing.T) {
+	for i, m := range Migrations() {
+		if m.Version != i+1 {
+			t.Fatalf("migration %d %s out of order", m.Version, m.Name)
+		}
+	}
+}
@@ -0,0 +1,469 @@
+package basesql
+
+import "github.com/toolglobal/api/database"
+
+// migrations 按版本追加，已发布的迁移不能修改
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "v3_transactions add txIdx",
+		Up: map[string][]string{
+			database.DBTypeSQLite3: {
+				"ALTER TABLE v3_transactions ADD COLUMN txIdx INTEGER NOT NULL DEFAULT 0",
+				"CREATE INDEX IF NOT EXISTS idx_tx_height_txIdx ON v3_transactions (height, txIdx)",
+			},
+			database.DBTypeMySQL: {
+				"ALTER TABLE v3_transactions ADD COLUMN txIdx INT NOT NULL DEFAULT 0, ADD KEY idx_tx_height_txIdx (height, txIdx)",
+			},
+		},
+	},
+	{
+		Version: 2,
+		Name:    "create v3_nft_transfers, v3_approvals",
+		Up: map[string][]string{
+			database.DBTypeSQLite3: {
+				`CREATE TABLE v3_nft_transfers
+				(
+					id        INTEGER  PRIMARY KEY AUTOINCREMENT,
+					hash      TEXT     NOT NULL,
+					height    INTEGER  NOT NULL,
+					idx       INTEGER  NOT NULL,
+					batchIdx  INTEGER  NOT NULL,
+					standard  TEXT     NOT NULL,
+					contract  TEXT     NOT NULL,
+					operator  TEXT     NOT NULL,
+					sender    TEXT     NOT NULL,
+					receiver  TEXT     NOT NULL,
+					tokenId   TEXT     NOT NULL,
+					value     TEXT     NOT NULL,
+					createdAt DATETIME NOT NULL
+				)`,
+				"CREATE INDEX idx_nft_hash ON v3_nft_transfers (hash)",
+				"CREATE INDEX idx_nft_height ON v3_nft_transfers (height)",
+				"CREATE INDEX idx_nft_sender ON v3_nft_transfers (sender)",
+				"CREATE INDEX idx_nft_receiver ON v3_nft_transfers (receiver)",
+				"CREATE INDEX idx_nft_contract_tokenId ON v3_nft_transfers (contract, tokenId)",
+				`CREATE TABLE v3_approvals
+				(
+					id        INTEGER  PRIMARY KEY AUTOINCREMENT,
+					hash      TEXT     NOT NULL,
+					height    INTEGER  NOT NULL,
+					idx       INTEGER  NOT NULL,
+					evName    TEXT     NOT NULL,
+					standard  TEXT     NOT NULL,
+					contract  TEXT     NOT NULL,
+					owner     TEXT     NOT NULL,
+					spender   TEXT     NOT NULL,
+					tokenId   TEXT     NOT NULL,
+					value     TEXT     NOT NULL,
+					approved  INTEGER  NOT NULL,
+					createdAt DATETIME NOT NULL
+				)`,
+				"CREATE INDEX idx_ap_hash ON v3_approvals (hash)",
+				"CREATE INDEX idx_ap_height ON v3_approvals (height)",
+				"CREATE INDEX idx_ap_owner ON v3_approvals (owner)",
+				"CREATE INDEX idx_ap_spender ON v3_approvals (spender)",
+			},
+			database.DBTypeMySQL: {
+				`CREATE TABLE v3_nft_transfers
+				(
+					id        BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+					hash      VARCHAR(80)     NOT NULL,
+					height    BIGINT          NOT NULL,
+					idx       INT UNSIGNED    NOT NULL,
+					batchIdx  INT UNSIGNED    NOT NULL,
+					standard  VARCHAR(16)     NOT NULL,
+					contract  VARCHAR(64)     NOT NULL,
+					operator  VARCHAR(64)     NOT NULL,
+					sender    VARCHAR(64)     NOT NULL,
+					receiver  VARCHAR(64)     NOT NULL,
+					tokenId   VARCHAR(80)     NOT NULL,
+					value     VARCHAR(100)    NOT NULL,
+					createdAt DATETIME        NOT NULL,
+					PRIMARY KEY (id),
+					KEY idx_nft_hash (hash),
+					KEY idx_nft_height (height),
+					KEY idx_nft_sender (sender),
+					KEY idx_nft_receiver (receiver),
+					KEY idx_nft_contract_tokenId (contract, tokenId)
+				) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+				`CREATE TABLE v3_approvals
+				(
+					id        BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+					hash      VARCHAR(80)     NOT NULL,
+					height    BIGINT          NOT NULL,
+					idx       INT UNSIGNED    NOT NULL,
+					evName    VARCHAR(64)     NOT NULL,
+					standard  VARCHAR(16)     NOT NULL,
+					contract  VARCHAR(64)     NOT NULL,
+					owner     VARCHAR(64)     NOT NULL,
+					spender   VARCHAR(64)     NOT NULL,
+					tokenId   VARCHAR(80)     NOT NULL,
+					value     VARCHAR(100)    NOT NULL,
+					approved  TINYINT(1)      NOT NULL,
+					createdAt DATETIME        NOT NULL,
+					PRIMARY KEY (id),
+					KEY idx_ap_hash (hash),
+					KEY idx_ap_height (height),
+					KEY idx_ap_owner (owner),
+					KEY idx_ap_spender (spender)
+				) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+			},
+		},
+	},
+	{
+		Version: 3,
+		Name:    "create v3_balances",
+		Up: map[string][]string{
+			database.DBTypeSQLite3: {
+				`CREATE TABLE v3_balances
+				(
+					id        INTEGER  PRIMARY KEY AUTOINCREMENT,
+					address   TEXT     NOT NULL,
+					contract  TEXT     NOT NULL,
+					balance   TEXT     NOT NULL,
+					height    INTEGER  NOT NULL,
+					updatedAt DATETIME NOT NULL
+				)`,
+				"CREATE UNIQUE INDEX idx_bal_address_contract ON v3_balances (address, contract)",
+				"CREATE INDEX idx_bal_contract ON v3_balances (contract)",
+			},
+			database.DBTypeMySQL: {
+				`CREATE TABLE v3_balances
+				(
+					id        BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+					address   VARCHAR(64)     NOT NULL,
+					contract  VARCHAR(64)     NOT NULL,
+					balance   VARCHAR(100)    NOT NULL,
+					height    BIGINT          NOT NULL,
+					updatedAt DATETIME        NOT NULL,
+					PRIMARY KEY (id),
+					UNIQUE KEY idx_bal_address_contract (address, contract),
+					KEY idx_bal_contract (contract)
+				) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+			},
+		},
+	},
+	{
+		Version: 4,
+		Name:    "create v3_balance_history",
+		Up: map[string][]string{
+			database.DBTypeSQLite3: {
+				`CREATE TABLE v3_balance_history
+				(
+					id        INTEGER  PRIMARY KEY AUTOINCREMENT,
+					address   TEXT     NOT NULL,
+					height    INTEGER  NOT NULL,
+					delta     TEXT     NOT NULL,
+					balance   TEXT     NOT NULL,
+					createdAt DATETIME NOT NULL
+				)`,
+				"CREATE UNIQUE INDEX idx_bh_address_height ON v3_balance_history (address, height)",
+				"CREATE INDEX idx_bh_height ON v3_balance_history (height)",
+			},
+			database.DBTypeMySQL: {
+				`CREATE TABLE v3_balance_history
+				(
+					id        BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+					address   VARCHAR(64)     NOT NULL,
+					height    BIGINT          NOT NULL,
+					delta     VARCHAR(100)    NOT NULL,
+					balance   VARCHAR(100)    NOT NULL,
+					createdAt DATETIME        NOT NULL,
+					PRIMARY KEY (id),
+					UNIQUE KEY idx_bh_address_height (address, height),
+					KEY idx_bh_height (height)
+				) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+			},
+		},
+	},
+	{
+		Version: 5,
+		Name:    "create v3_contracts",
+		Up: map[string][]string{
+			database.DBTypeSQLite3: {
+				`CREATE TABLE v3_contracts
+				(
+					id        INTEGER  PRIMARY KEY AUTOINCREMENT,
+					address   TEXT     NOT NULL,
+					creator   TEXT     NOT NULL,
+					hash      TEXT     NOT NULL,
+					height    INTEGER  NOT NULL,
+					types     TEXT     NOT NULL,
+					createdAt DATETIME NOT NULL
+				)`,
+				"CREATE UNIQUE INDEX idx_ct_address ON v3_contracts (address)",
+				"CREATE INDEX idx_ct_creator ON v3_contracts (creator)",
+				"CREATE INDEX idx_ct_height ON v3_contracts (height)",
+			},
+			database.DBTypeMySQL: {
+				`CREATE TABLE v3_contracts
+				(
+					id        BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+					address   VARCHAR(64)     NOT NULL,
+					creator   VARCHAR(64)     NOT NULL,
+					hash      VARCHAR(80)     NOT NULL,
+					height    BIGINT          NOT NULL,
+					types     VARCHAR(32)     NOT NULL,
+					createdAt DATETIME        NOT NULL,
+					PRIMARY KEY (id),
+					UNIQUE KEY idx_ct_address (address),
+					KEY idx_ct_creator (creator),
+					KEY idx_ct_height (height)
+				) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+			},
+		},
+	},
+	{
+		Version: 6,
+		Name:    "create v3_logs",
+		Up: map[string][]string{
+			database.DBTypeSQLite3: {
+				`CREATE TABLE v3_logs
+				(
+					id        INTEGER  PRIMARY KEY AUTOINCREMENT,
+					hash      TEXT     NOT NULL,
+					height    INTEGER  NOT NULL,
+					txIdx     INTEGER  NOT NULL,
+					logIdx    INTEGER  NOT NULL,
+					address   TEXT     NOT NULL,
+					topic0    TEXT     NOT NULL,
+					topic1    TEXT     NOT NULL,
+					topic2    TEXT     NOT NULL,
+					topic3    TEXT     NOT NULL,
+					data      TEXT     NOT NULL,
+					createdAt DATETIME NOT NULL
+				)`,
+				"CREATE INDEX idx_log_hash ON v3_logs (hash)",
+				"CREATE INDEX idx_log_height ON v3_logs (height)",
+				"CREATE INDEX idx_log_address ON v3_logs (address)",
+				"CREATE INDEX idx_log_topic0 ON v3_logs (topic0)",
+				"CREATE INDEX idx_log_topic1 ON v3_logs (topic1)",
+				"CREATE INDEX idx_log_topic2 ON v3_logs (topic2)",
+			},
+			database.DBTypeMySQL: {
+				`CREATE TABLE v3_logs
+				(
+					id        BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+					hash      VARCHAR(80)     NOT NULL,
+					height    BIGINT          NOT NULL,
+					txIdx     INT             NOT NULL,
+					logIdx    INT UNSIGNED    NOT NULL,
+					address   VARCHAR(64)     NOT NULL,
+					topic0    VARCHAR(80)     NOT NULL,
+					topic1    VARCHAR(80)     NOT NULL,
+					topic2    VARCHAR(80)     NOT NULL,
+					topic3    VARCHAR(80)     NOT NULL,
+					data      MEDIUMTEXT      NOT NULL,
+					createdAt DATETIME        NOT NULL,
+					PRIMARY KEY (id),
+					KEY idx_log_hash (hash),
+					KEY idx_log_height (height),
+					KEY idx_log_address (address),
+					KEY idx_log_topic0 (topic0),
+					KEY idx_log_topic1 (topic1),
+					KEY idx_log_topic2 (topic2)
+				) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+			},
+		},
+	},
+	{
+		Version: 7,
+		Name:    "create v3_webhooks, v3_webhook_deliveries",
+		Up: map[string][]string{
+			database.DBTypeSQLite3: {
+				`CREATE TABLE v3_webhooks
+				(
+					id        INTEGER  PRIMARY KEY AUTOINCREMENT,
+					url       TEXT     NOT NULL,
+					secret    TEXT     NOT NULL,
+					address   TEXT     NOT NULL,
+					contract  TEXT     NOT NULL,
+					event     TEXT     NOT NULL,
+					createdAt DATETIME NOT NULL
+				)`,
+				`CREATE TABLE v3_webhook_deliveries
+				(
+					id            INTEGER  PRIMARY KEY AUTOINCREMENT,
+					webhookId     INTEGER  NOT NULL,
+					kind          TEXT     NOT NULL,
+					hash          TEXT     NOT NULL,
+					height        INTEGER  NOT NULL,
+					payload       TEXT     NOT NULL,
+					status        TEXT     NOT NULL,
+					attempts      INTEGER  NOT NULL,
+					responseCode  INTEGER  NOT NULL,
+					lastError     TEXT     NOT NULL,
+					nextAttemptAt DATETIME NOT NULL,
+					createdAt     DATETIME NOT NULL,
+					updatedAt     DATETIME NOT NULL
+				)`,
+				"CREATE INDEX idx_whd_webhookId ON v3_webhook_deliveries (webhookId)",
+				"CREATE INDEX idx_whd_status_next ON v3_webhook_deliveries (status, nextAttemptAt)",
+				"CREATE INDEX idx_whd_height ON v3_webhook_deliveries (height)",
+			},
+			database.DBTypeMySQL: {
+				`CREATE TABLE v3_webhooks
+				(
+					id        BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+					url       VARCHAR(512)    NOT NULL,
+					secret    VARCHAR(128)    NOT NULL,
+					address   VARCHAR(64)     NOT NULL,
+					contract  VARCHAR(64)     NOT NULL,
+					event     VARCHAR(32)     NOT NULL,
+					createdAt DATETIME        NOT NULL,
+					PRIMARY KEY (id)
+				) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+				`CREATE TABLE v3_webhook_deliveries
+				(
+					id            BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+					webhookId     BIGINT UNSIGNED NOT NULL,
+					kind          VARCHAR(16)     NOT NULL,
+					hash          VARCHAR(80)     NOT NULL,
+					height        BIGINT          NOT NULL,
+					payload       MEDIUMTEXT      NOT NULL,
+					status        VARCHAR(16)     NOT NULL,
+					attempts      INT             NOT NULL,
+					responseCode  INT             NOT NULL,
+					lastError     VARCHAR(512)    NOT NULL,
+					nextAttemptAt DATETIME        NOT NULL,
+					createdAt     DATETIME        NOT NULL,
+					updatedAt     DATETIME        NOT NULL,
+					PRIMARY KEY (id),
+					KEY idx_whd_webhookId (webhookId),
+					KEY idx_whd_status_next (status, nextAttemptAt),
+					KEY idx_whd_height (height)
+				) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+			},
+		},
+	},
+	{
+		Version: 8,
+		Name:    "create v3_deposit_addresses, v3_deposits",
+		Up: map[string][]string{
+			database.DBTypeSQLite3: {
+				`CREATE TABLE v3_deposit_addresses
+				(
+					id            INTEGER  PRIMARY KEY AUTOINCREMENT,
+					address       TEXT     NOT NULL,
+					confirmations INTEGER  NOT NULL,
+					label         TEXT     NOT NULL,
+					createdAt     DATETIME NOT NULL
+				)`,
+				"CREATE UNIQUE INDEX idx_da_address ON v3_deposit_addresses (address)",
+				`CREATE TABLE v3_deposits
+				(
+					id            INTEGER  PRIMARY KEY AUTOINCREMENT,
+					address       TEXT     NOT NULL,
+					hash          TEXT     NOT NULL,
+					height        INTEGER  NOT NULL,
+					idx           INTEGER  NOT NULL,
+					sender        TEXT     NOT NULL,
+					symbol        TEXT     NOT NULL,
+					contract      TEXT     NOT NULL,
+					value         TEXT     NOT NULL,
+					confirmHeight INTEGER  NOT NULL,
+					invalidated   INTEGER  NOT NULL,
+					createdAt     DATETIME NOT NULL,
+					updatedAt     DATETIME NOT NULL
+				)`,
+				"CREATE INDEX idx_dep_address ON v3_deposits (address)",
+				"CREATE INDEX idx_dep_height ON v3_deposits (height)",
+				"CREATE INDEX idx_dep_confirmHeight ON v3_deposits (confirmHeight)",
+			},
+			database.DBTypeMySQL: {
+				`CREATE TABLE v3_deposit_addresses
+				(
+					id            BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+					address       VARCHAR(64)     NOT NULL,
+					confirmations BIGINT          NOT NULL,
+					label         VARCHAR(128)    NOT NULL,
+					createdAt     DATETIME        NOT NULL,
+					PRIMARY KEY (id),
+					UNIQUE KEY idx_da_address (address)
+				) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+				`CREATE TABLE v3_deposits
+				(
+					id            BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+					address       VARCHAR(64)     NOT NULL,
+					hash          VARCHAR(80)     NOT NULL,
+					height        BIGINT          NOT NULL,
+					idx           INT UNSIGNED    NOT NULL,
+					sender        VARCHAR(64)     NOT NULL,
+					symbol        VARCHAR(32)     NOT NULL,
+					contract      VARCHAR(64)     NOT NULL,
+					value         VARCHAR(80)     NOT NULL,
+					confirmHeight BIGINT          NOT NULL,
+					invalidated   TINYINT(1)      NOT NULL,
+					createdAt     DATETIME        NOT NULL,
+					updatedAt     DATETIME        NOT NULL,
+					PRIMARY KEY (id),
+					KEY idx_dep_address (address),
+					KEY idx_dep_height (height),
+					KEY idx_dep_confirmHeight (confirmHeight)
+				) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+			},
+		},
+	},
+	{
+		Version: 9,
+		Name:    "create v3_raw_txs",
+		Up: map[string][]string{
+			database.DBTypeSQLite3: {
+				`CREATE TABLE v3_raw_txs
+				(
+					id        INTEGER  PRIMARY KEY AUTOINCREMENT,
+					height    INTEGER  NOT NULL,
+					txIdx     INTEGER  NOT NULL,
+					hash      TEXT     NOT NULL,
+					tag       TEXT     NOT NULL,
+					raw       TEXT     NOT NULL,
+					error     TEXT     NOT NULL,
+					createdAt DATETIME NOT NULL
+				)`,
+				"CREATE INDEX idx_raw_height ON v3_raw_txs (height)",
+				"CREATE INDEX idx_raw_tag ON v3_raw_txs (tag)",
+			},
+			database.DBTypeMySQL: {
+				`CREATE TABLE v3_raw_txs
+				(
+					id        BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+					height    BIGINT          NOT NULL,
+					txIdx     INT             NOT NULL,
+					hash      VARCHAR(80)     NOT NULL,
+					tag       VARCHAR(8)      NOT NULL,
+					raw       MEDIUMTEXT      NOT NULL,
+					error     TEXT            NOT NULL,
+					createdAt DATETIME        NOT NULL,
+					PRIMARY KEY (id),
+					KEY idx_raw_height (height),
+					KEY idx_raw_tag (tag)
+				) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+			},
+		},
+	},
+	{
+		Version: 10,
+		Name:    "v3_ledgers unique height",
+		Up: map[string][]string{
+			database.DBTypeSQLite3: {
+				"CREATE UNIQUE INDEX idx_ledger_height ON v3_ledgers (height)",
+			},
+			database.DBTypeMySQL: {
+				"ALTER TABLE v3_ledgers ADD UNIQUE KEY idx_ledger_height (height)",
+			},
+		},
+	},
+	{
+		Version: 11,
+		Name:    "v3_transactions add rawTx",
+		Up: map[string][]string{
+			database.DBTypeSQLite3: {
+				"ALTER TABLE v3_transactions ADD COLUMN rawTx TEXT NOT NULL DEFAULT ''",
+			},
+			database.DBTypeMySQL: {
+				"ALTER TABLE v3_transactions ADD COLUMN rawTx MEDIUMTEXT NOT NULL",
+			},
+		},
+	},
+}
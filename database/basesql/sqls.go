@@ -1,17 +1,26 @@
 package basesql
 
+import "github.com/toolglobal/api/database"
+
 // GetInitSQLs get database initialize sqls
 //	opt sqls to create operation tables
 //	opi sqls to create operation table-indexs
 //	qt  sqls to create query tables
 //	qi  sqls to create query table-indexs
+//	mysql declares indexes in create-table sqls, qi is empty
 func (bs *Basesql) GetInitSQLs() (qt, qi []string) {
+	if bs.dbType() == database.DBTypeMySQL {
+		return mysqlInitSQLs, nil
+	}
+
 	qt = []string{
 		createV3LedgerSQL,
 		createV3TransactionSQL,
 		createV3PaymentSQL,
+		createSyncStateSQL,
 	}
-	qi = append(createV3QIndex)
+	qi = append(qi, createV3QIndex...)
+	qi = append(qi, createSyncStateIndex...)
 
 	return
 }
@@ -0,0 +1,94 @@
+package basesql
+
+// mysql不支持CREATE INDEX IF NOT EXISTS，索引在建表语句中声明；
+// 需要建索引的列使用VARCHAR，时间列使用DATETIME，连接时统一按UTC解析
+var (
+	mysqlInitSQLs = []string{
+		createV3LedgerMySQL,
+		createV3TransactionMySQL,
+		createV3PaymentMySQL,
+		createSyncStateMySQL,
+	}
+)
+
+const (
+	createV3LedgerMySQL = `CREATE TABLE IF NOT EXISTS v3_ledgers
+	(
+		id         BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+		height     BIGINT          NOT NULL,
+		blockHash  VARCHAR(80)     NOT NULL,
+		blockSize  BIGINT          NOT NULL,
+		validator  VARCHAR(80)     NOT NULL,
+		txCount    BIGINT          NOT NULL,
+		gasLimit   BIGINT          NOT NULL,
+		gasUsed    BIGINT          NOT NULL,
+		gasPrice   VARCHAR(80)     NOT NULL,
+		createdAt  DATETIME        NOT NULL,
+		PRIMARY KEY (id),
+		KEY idx_height (height),
+		KEY idx_blockHash (blockHash),
+		KEY idx_createdAt (createdAt)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`
+	createV3TransactionMySQL = `CREATE TABLE IF NOT EXISTS v3_transactions
+	(
+		id        BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+		hash      VARCHAR(80)     NOT NULL,
+		height    BIGINT          NOT NULL,
+		typei     INT             NOT NULL,
+		types     VARCHAR(64)     NOT NULL,
+		sender    VARCHAR(64)     NOT NULL,
+		nonce     BIGINT          NOT NULL,
+		receiver  VARCHAR(64)     NOT NULL,
+		value     VARCHAR(100)    NOT NULL,
+		gasLimit  BIGINT          NOT NULL,
+		gasUsed   BIGINT          NOT NULL,
+		gasPrice  VARCHAR(80)     NOT NULL,
+		memo      TEXT,
+		payload   MEDIUMTEXT,
+		events    MEDIUMTEXT,
+		codei     INT UNSIGNED    NOT NULL,
+		codes     TEXT,
+		createdAt DATETIME        NOT NULL,
+		PRIMARY KEY (id),
+		KEY idx_hash (hash),
+		KEY idx_tx_height (height),
+		KEY idx_typei (typei),
+		KEY idx_sender (sender),
+		KEY idx_receiver (receiver),
+		KEY idx_tx_createdAt (createdAt)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`
+	createV3PaymentMySQL = `CREATE TABLE IF NOT EXISTS v3_payments
+	(
+		id        BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+		hash      VARCHAR(80)     NOT NULL,
+		height    BIGINT          NOT NULL,
+		evName    VARCHAR(64)     NOT NULL,
+		idx       INT UNSIGNED    NOT NULL,
+		sender    VARCHAR(64)     NOT NULL,
+		receiver  VARCHAR(64)     NOT NULL,
+		symbol    VARCHAR(64)     NOT NULL,
+		contract  VARCHAR(64)     NOT NULL,
+		value     VARCHAR(100)    NOT NULL,
+		createdAt DATETIME        NOT NULL,
+		PRIMARY KEY (id),
+		KEY idx_pm_hash (hash),
+		KEY idx_pm_height (height),
+		KEY idx_pm_sender (sender),
+		KEY idx_pm_receiver (receiver),
+		KEY idx_symbol (symbol),
+		KEY idx_contract (contract),
+		KEY idx_pm_createdAt (createdAt)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`
+	createSyncStateMySQL = `CREATE TABLE IF NOT EXISTS sync_state
+	(
+		id             BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+		name           VARCHAR(64)     NOT NULL,
+		height         BIGINT          NOT NULL,
+		blockHash      VARCHAR(80)     NOT NULL,
+		indexerVersion INT             NOT NULL,
+		createdAt      DATETIME        NOT NULL,
+		updatedAt      DATETIME        NOT NULL,
+		PRIMARY KEY (id),
+		UNIQUE KEY idx_sync_name (name)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`
+)
@@ -0,0 +1,20 @@
+package basesql
+
+var (
+	createSyncStateIndex = []string{
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_sync_name ON sync_state (name)",
+	}
+)
+
+const (
+	createSyncStateSQL = `CREATE TABLE IF NOT EXISTS sync_state
+	( 
+		id             INTEGER  PRIMARY KEY AUTOINCREMENT,
+		name           TEXT     NOT NULL,
+		height         INTEGER  NOT NULL,
+		blockHash      TEXT     NOT NULL,
+		indexerVersion INTEGER  NOT NULL,
+		createdAt      DATETIME NOT NULL,
+		updatedAt      DATETIME NOT NULL 
+	);`
+)
@@ -1,6 +1,12 @@
 package basesql
 
+import "github.com/toolglobal/api/database"
+
 func (bs *Basesql) GetV3InitSQLs() (qt, qi []string) {
+	if bs.dbType() == database.DBTypeMySQL {
+		return []string{createV3LedgerMySQL, createV3TransactionMySQL, createV3PaymentMySQL}, nil
+	}
+
 	qt = []string{
 		createV3LedgerSQL,
 		createV3TransactionSQL,
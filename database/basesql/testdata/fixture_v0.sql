@@ -0,0 +1,72 @@
+-- 迁移框架之前的库：初始建表语句及少量数据，不要随代码修改
+CREATE TABLE v3_ledgers
+(
+	id         INTEGER  PRIMARY KEY AUTOINCREMENT,
+	height     INTEGER  NOT NULL,
+	blockHash  TEXT     NOT NULL,
+	blockSize  INTEGER  NOT NULL,
+	validator  TEXT     NOT NULL,
+	txCount    INTEGER  NOT NULL,
+	gasLimit   INTEGER  NOT NULL,
+	gasUsed    INTEGER  NOT NULL,
+	gasPrice   TEXT     NOT NULL,
+	createdAt  DATETIME NOT NULL
+);
+CREATE TABLE v3_transactions
+(
+	id        INTEGER  PRIMARY KEY AUTOINCREMENT,
+	hash      TEXT     NOT NULL,
+	height    INTEGER  NOT NULL,
+	typei     INTEGER  NOT NULL,
+	types     TEXT     NOT NULL,
+	sender    TEXT     NOT NULL,
+	nonce     INTEGER  NOT NULL,
+	receiver  TEXT     NOT NULL,
+	value     TEXT     NOT NULL,
+	gasLimit  NUMERIC  NOT NULL,
+	gasUsed   INTEGER  NOT NULL,
+	gasPrice  TEXT     NOT NULL,
+	memo      TEXT,
+	payload   TEXT,
+	events    TEXT,
+	codei     INTEGER  NOT NULL,
+	codes     TEXT,
+	createdAt DATETIME NOT NULL
+);
+CREATE TABLE v3_payments
+(
+	id        INTEGER  PRIMARY KEY AUTOINCREMENT,
+	hash      TEXT     NOT NULL,
+	height    INTEGER  NOT NULL,
+	evName    TEXT     NOT NULL,
+	idx       INTEGER  NOT NULL,
+	sender    TEXT     NOT NULL,
+	receiver  TEXT     NOT NULL,
+	symbol    TEXT     NOT NULL,
+	contract  TEXT     NOT NULL,
+	value     TEXT     NOT NULL,
+	createdAt DATETIME NOT NULL
+);
+CREATE TABLE sync_state
+(
+	id             INTEGER  PRIMARY KEY AUTOINCREMENT,
+	name           TEXT     NOT NULL,
+	height         INTEGER  NOT NULL,
+	blockHash      TEXT     NOT NULL,
+	indexerVersion INTEGER  NOT NULL,
+	createdAt      DATETIME NOT NULL,
+	updatedAt      DATETIME NOT NULL
+);
+CREATE INDEX idx_tx_height ON v3_transactions (height);
+CREATE UNIQUE INDEX idx_sync_name ON sync_state (name);
+
+INSERT INTO v3_ledgers (height,blockHash,blockSize,validator,txCount,gasLimit,gasUsed,gasPrice,createdAt) VALUES
+	(1,'AAA1',512,'V1',0,0,0,'1',1600000001),
+	(2,'AAA2',1024,'V1',2,42000,42000,'100',1600000002);
+INSERT INTO v3_transactions (hash,height,typei,types,sender,nonce,receiver,value,gasLimit,gasUsed,gasPrice,memo,payload,events,codei,codes,createdAt) VALUES
+	('0x01',2,1,'TxTagAppEvm','0xA11cE',1,'0xB0b','1.5',21000,21000,'0.0000001','','','',0,'',1600000002),
+	('0x02',2,5,'TxTagEthereumTx','0xB0b',7,'0xA11cE','2',21000,21000,'0.0000001','','','',0,'',1600000002);
+INSERT INTO v3_payments (hash,height,evName,idx,sender,receiver,symbol,contract,value,createdAt) VALUES
+	('0x01',2,'',0,'0xA11cE','0xB0b','OLO','','1.5',1600000002);
+INSERT INTO sync_state (name,height,blockHash,indexerVersion,createdAt,updatedAt) VALUES
+	('v3',2,'AAA2',1,1600000002,1600000002);
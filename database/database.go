@@ -2,19 +2,39 @@ package database
 
 import (
 	"database/sql"
+	"encoding/base64"
 	"errors"
 	"go.uber.org/zap"
+	"strconv"
+	"strings"
 )
 
 const (
-	TableV3Ledgers      = "v3_ledgers"
-	TableV3Transactions = "v3_transactions"
-	TableV3Payments     = "v3_payments"
+	TableV3Ledgers        = "v3_ledgers"
+	TableV3Transactions   = "v3_transactions"
+	TableV3Payments       = "v3_payments"
+	TableV3NFTTransfers   = "v3_nft_transfers"
+	TableV3Approvals      = "v3_approvals"
+	TableV3Balances       = "v3_balances"
+	TableV3BalanceHistory = "v3_balance_history"
+	TableV3Contracts      = "v3_contracts"
+	TableV3Logs           = "v3_logs"
+	TableV3RawTxs         = "v3_raw_txs"
+	TableSyncState        = "sync_state"
+
+	TableV3Webhooks          = "v3_webhooks"
+	TableV3WebhookDeliveries = "v3_webhook_deliveries"
+
+	TableV3DepositAddresses = "v3_deposit_addresses"
+	TableV3Deposits         = "v3_deposits"
 )
 
+// ErrDuplicate 插入的数据违反唯一索引，Insert返回的错误可以用errors.Is判断
+var ErrDuplicate = errors.New("duplicate key")
+
 const (
 	DBTypeSQLite3 = "sqlite3"
-	DBTypeMySQL   = "mysql" // use it or not, who knows
+	DBTypeMySQL   = "mysql"
 )
 
 // Feild database field
@@ -23,11 +43,14 @@ type Feild struct {
 	Value interface{}
 }
 
+// OpIn where operator matching any element of a slice value
+const OpIn = "in"
+
 // Where query field
 type Where struct {
 	Name  string
 	Value interface{}
-	Op    string // can be =、>、<、<> and any operator supported by sql-database
+	Op    string // can be =、>、<、<>、in and any operator supported by sql-database, value of in is a slice
 }
 
 // GetOp get operator of current where clause, default =
@@ -53,10 +76,36 @@ func (o *Order) GetOp() string {
 	return ">="
 }
 
+// KeysetOp operator of keyset paging, rows after the cursor are excluded from the cursor itself
+func (o *Order) KeysetOp() string {
+	if o != nil && strings.ToLower(o.Type) == "desc" {
+		return "<"
+	}
+
+	return ">"
+}
+
 type Paging struct {
 	CursorName  string // cursor column
-	CursorValue uint64 // cursor column
+	CursorValue uint64 // offset of page-number paging, or last seen cursor column value of keyset paging
 	Limit       uint64 // limit
+	Keyset      bool   // keyset paging: where cursor column after CursorValue, no offset
+}
+
+// Offset offset used in sql, always 0 for keyset paging
+func (p *Paging) Offset() uint64 {
+	if p.Keyset {
+		return 0
+	}
+	return p.CursorValue
+}
+
+// KeysetWhere where clause of keyset paging, nil for the first page or page-number paging
+func (p *Paging) KeysetWhere(order *Order) *Where {
+	if p == nil || !p.Keyset || p.CursorValue == 0 {
+		return nil
+	}
+	return &Where{Name: p.CursorName, Value: p.CursorValue, Op: order.KeysetOp()}
 }
 
 // Database interface for delos app database-operation
@@ -65,6 +114,7 @@ type Database interface {
 	Close()
 	GetInitSQLs() (qt, qi []string)
 	PrepareTables(ctsqls, cisqls []string) error
+	Migrate() ([]int, error)
 
 	Insert(table string, fields []Feild) (sql.Result, error)
 	Delete(table string, where []Where) (sql.Result, error)
@@ -115,3 +165,31 @@ func MakePaging(colName string, colValue uint64, limit uint64) *Paging {
 		Limit:       limit,
 	}
 }
+
+// MakeKeysetPaging make a keyset paging object, after is the cursor column value of the last row of previous page
+func MakeKeysetPaging(colName string, after uint64, limit uint64) *Paging {
+	paging := MakePaging(colName, 0, limit)
+	paging.CursorValue = after
+	paging.Keyset = true
+	return paging
+}
+
+const cursorPrefix = "v1:"
+
+// EncodeCursor make an opaque cursor from the cursor column value of the last row
+func EncodeCursor(value uint64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + strconv.FormatUint(value, 10)))
+}
+
+// DecodeCursor parse an opaque cursor made by EncodeCursor
+func DecodeCursor(cursor string) (uint64, error) {
+	bz, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(bz), cursorPrefix) {
+		return 0, errors.New("invalid cursor :" + cursor)
+	}
+	value, err := strconv.ParseUint(strings.TrimPrefix(string(bz), cursorPrefix), 10, 64)
+	if err != nil {
+		return 0, errors.New("invalid cursor :" + cursor)
+	}
+	return value, nil
+}
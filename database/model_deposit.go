@@ -0,0 +1,47 @@
+package database
+
+import "time"
+
+// 充值状态，由已同步高度计算
+const (
+	DepositPending     = "pending"     // 确认数不足
+	DepositConfirmed   = "confirmed"   // 确认数已满足，可以入账
+	DepositInvalidated = "invalidated" // 所在区块被回滚，已入账的需要冲正
+)
+
+// V3DepositAddress 登记的充值地址，登记之后入库的区块中转入该地址的转账记为充值
+type V3DepositAddress struct {
+	Id            uint64    `db:"id" json:"id"`                       // 数据库自增id
+	Address       string    `db:"address" json:"address"`             // 充值地址
+	Confirmations int64     `db:"confirmations" json:"confirmations"` // 需要的确认数，包含转账所在区块
+	Label         string    `db:"label" json:"label"`                 // 备注，例如交易所的用户id
+	CreatedAt     time.Time `db:"createdAt" json:"createdAt"`         // 登记时间
+}
+
+// V3Deposit 转入登记地址的转账，不含WETH的Withdrawal
+type V3Deposit struct {
+	Id            uint64    `db:"id" json:"id"`                       // 数据库自增id
+	Address       string    `db:"address" json:"address"`             // 充值地址
+	Hash          string    `db:"hash" json:"hash"`                   // 交易hash
+	Height        int64     `db:"height" json:"height"`               // 区块高度
+	Idx           uint      `db:"idx" json:"idx"`                     // 转账在交易中的索引，与v3_payments一致
+	Sender        string    `db:"sender" json:"sender"`               // 转出方地址
+	Symbol        string    `db:"symbol" json:"symbol"`               // 币种，原生币为“OLO”
+	Contract      string    `db:"contract" json:"contract"`           // 合约地址，原生币为全零地址
+	Value         string    `db:"value" json:"value"`                 // 金额，十进制整数，未除以decimals
+	ConfirmHeight int64     `db:"confirmHeight" json:"confirmHeight"` // 同步到该高度时确认数满足
+	Invalidated   bool      `db:"invalidated" json:"-"`               // 所在区块已被回滚
+	CreatedAt     time.Time `db:"createdAt" json:"createdAt"`         // 区块时间
+	UpdatedAt     time.Time `db:"updatedAt" json:"updatedAt"`         // 最后更新时间，回滚时更新
+
+	Confirmations int64  `db:"-" json:"confirmations"` // 当前确认数，已回滚时为0
+	Status        string `db:"-" json:"status"`        // pending、confirmed、invalidated
+}
+
+// V3DepositFilter 充值查询条件，为空的条件不限
+type V3DepositFilter struct {
+	Address  string // 充值地址
+	Contract string // 合约地址
+	Symbol   string // 币种
+	Status   string // pending、confirmed、invalidated
+}
@@ -0,0 +1,13 @@
+package database
+
+// BlockEvent 区块入库后发布到总线
+type BlockEvent struct {
+	Ledger       V3Ledger        `json:"ledger"`
+	Transactions []V3Transaction `json:"transactions"`
+	Payments     []V3Payment     `json:"payments"`
+}
+
+// RollbackEvent 分叉回滚后发布，高于Height的区块已失效
+type RollbackEvent struct {
+	Height int64 `json:"height"`
+}
@@ -0,0 +1,17 @@
+package database
+
+import "time"
+
+// SyncStateV3 sync_state中v3同步任务的名称
+const SyncStateV3 = "v3"
+
+// SyncState 区块同步进度，每个同步任务一行
+type SyncState struct {
+	Id             uint64    `db:"id" json:"id"`                         // 数据库自增id
+	Name           string    `db:"name" json:"name"`                     // 同步任务名称，如"v3"
+	Height         int64     `db:"height" json:"height"`                 // 最后索引的区块高度
+	BlockHash      string    `db:"blockHash" json:"blockHash"`           // 最后索引的区块hash
+	IndexerVersion int       `db:"indexerVersion" json:"indexerVersion"` // 索引该高度时的解析器版本
+	CreatedAt      time.Time `db:"createdAt" json:"createdAt"`           // 首次索引时间
+	UpdatedAt      time.Time `db:"updatedAt" json:"updatedAt"`           // 最后更新时间
+}
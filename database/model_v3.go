@@ -38,6 +38,8 @@ type V3Transaction struct {
 	Codei     uint32    `db:"codei" json:"codei"`         // 失败代码
 	Codes     string    `db:"codes" json:"codes"`         // 失败原因
 	CreatedAt time.Time `db:"createdAt" json:"createdAt"` // 区块时间
+	TxIdx     int       `db:"txIdx" json:"txIdx"`         // 交易在区块中的序号
+	RawTx     string    `db:"rawTx" json:"-"`             // 以太坊交易的签名数据（hex），其他交易为空
 }
 
 type V3Payment struct {
@@ -53,3 +55,111 @@ type V3Payment struct {
 	Value     string    `db:"value" json:"value"`         // 交易金额
 	CreatedAt time.Time `db:"createdAt" json:"createdAt"` // 区块时间
 }
+
+type V3NFTTransfer struct {
+	Id        uint64    `db:"id" json:"id"`               // 数据库自增id
+	Hash      string    `db:"hash" json:"hash"`           // 交易hash
+	Height    int64     `db:"height" json:"height"`       // 区块高度
+	Idx       uint      `db:"idx" json:"idx"`             // 事件在区块中的索引
+	BatchIdx  uint      `db:"batchIdx" json:"batchIdx"`   // TransferBatch中的序号，其余为0
+	Standard  string    `db:"standard" json:"standard"`   // 代币标准：ERC721、ERC1155
+	Contract  string    `db:"contract" json:"contract"`   // 合约地址
+	Operator  string    `db:"operator" json:"operator"`   // ERC1155的操作者，ERC721为空
+	Sender    string    `db:"sender" json:"sender"`       // 转出方地址，铸造时为全零地址
+	Receiver  string    `db:"receiver" json:"receiver"`   // 转入方地址，销毁时为全零地址
+	TokenId   string    `db:"tokenId" json:"tokenId"`     // token id，十进制
+	Value     string    `db:"value" json:"value"`         // 数量，ERC721固定为1
+	CreatedAt time.Time `db:"createdAt" json:"createdAt"` // 区块时间
+}
+
+type V3Approval struct {
+	Id        uint64    `db:"id" json:"id"`               // 数据库自增id
+	Hash      string    `db:"hash" json:"hash"`           // 交易hash
+	Height    int64     `db:"height" json:"height"`       // 区块高度
+	Idx       uint      `db:"idx" json:"idx"`             // 事件在区块中的索引
+	EvName    string    `db:"evName" json:"evName"`       // 事件名称：Approval、ApprovalForAll
+	Standard  string    `db:"standard" json:"standard"`   // 代币标准：ERC20、ERC721，ApprovalForAll无法区分ERC721与ERC1155，为空
+	Contract  string    `db:"contract" json:"contract"`   // 合约地址
+	Owner     string    `db:"owner" json:"owner"`         // 授权方地址
+	Spender   string    `db:"spender" json:"spender"`     // 被授权地址
+	TokenId   string    `db:"tokenId" json:"tokenId"`     // ERC721授权的token id，其余为空
+	Value     string    `db:"value" json:"value"`         // ERC20授权额度，其余为空
+	Approved  bool      `db:"approved" json:"approved"`   // ApprovalForAll授权或取消，其余为true
+	CreatedAt time.Time `db:"createdAt" json:"createdAt"` // 区块时间
+}
+
+// V3Balance 代币余额，由token payment累加得到，未覆盖的历史（从startHeight开始同步）会导致余额不准确
+type V3Balance struct {
+	Id        uint64    `db:"id" json:"id"`               // 数据库自增id
+	Address   string    `db:"address" json:"address"`     // 账户地址
+	Contract  string    `db:"contract" json:"contract"`   // 代币合约地址
+	Balance   string    `db:"balance" json:"balance"`     // 余额，十进制整数，未除以decimals
+	Height    int64     `db:"height" json:"height"`       // 最后一次变化的区块高度
+	UpdatedAt time.Time `db:"updatedAt" json:"updatedAt"` // 最后一次变化的区块时间
+}
+
+// V3BalanceHistory 原生币OLO余额变化，每个账户每个高度一条，由原生币payment和手续费（gasUsed*gasPrice）计算。
+// 不含创世分配和合约内部转账，余额是相对于索引起点的累计值
+type V3BalanceHistory struct {
+	Id        uint64    `db:"id" json:"id"`               // 数据库自增id
+	Address   string    `db:"address" json:"address"`     // 账户地址
+	Height    int64     `db:"height" json:"height"`       // 区块高度
+	Delta     string    `db:"delta" json:"delta"`         // 该高度的变化量
+	Balance   string    `db:"balance" json:"balance"`     // 该高度之后的余额
+	CreatedAt time.Time `db:"createdAt" json:"createdAt"` // 区块时间
+}
+
+// V3Contract 交易创建的合约，地址由创建者地址和nonce计算（crypto.CreateAddress），不含合约内部创建的合约
+type V3Contract struct {
+	Id        uint64    `db:"id" json:"id"`               // 数据库自增id
+	Address   string    `db:"address" json:"address"`     // 合约地址
+	Creator   string    `db:"creator" json:"creator"`     // 创建者地址，多签交易为多签账户地址
+	Hash      string    `db:"hash" json:"hash"`           // 创建合约的交易hash
+	Height    int64     `db:"height" json:"height"`       // 区块高度
+	Types     string    `db:"types" json:"types"`         // 创建合约的交易类型
+	CreatedAt time.Time `db:"createdAt" json:"createdAt"` // 区块时间
+}
+
+// V3Log 交易日志，字段与eth_getLogs一致，topic不足4个时为空
+type V3Log struct {
+	Id        uint64    `db:"id" json:"id"`               // 数据库自增id
+	Hash      string    `db:"hash" json:"hash"`           // 交易hash
+	Height    int64     `db:"height" json:"height"`       // 区块高度
+	TxIdx     int       `db:"txIdx" json:"txIdx"`         // 交易在区块中的序号
+	LogIdx    uint      `db:"logIdx" json:"logIdx"`       // 日志在区块中的索引
+	Address   string    `db:"address" json:"address"`     // 产生日志的合约地址
+	Topic0    string    `db:"topic0" json:"topic0"`       // 事件签名
+	Topic1    string    `db:"topic1" json:"topic1"`       // indexed参数
+	Topic2    string    `db:"topic2" json:"topic2"`       // indexed参数
+	Topic3    string    `db:"topic3" json:"topic3"`       // indexed参数
+	Data      string    `db:"data" json:"data"`           // 非indexed参数，0x开头的十六进制
+	CreatedAt time.Time `db:"createdAt" json:"createdAt"` // 区块时间
+}
+
+// V3LogFilter 日志查询条件，与eth_getLogs一致：Addresses之间为或；Topics按位置匹配，同一位置的多个值为或，空表示任意
+type V3LogFilter struct {
+	Hash       string     // 交易hash，为空时不限
+	FromHeight int64      // 起始高度（含），0表示不限
+	ToHeight   int64      // 结束高度（含），0表示不限
+	Addresses  []string   // 合约地址
+	Topics     [][]string // topic0-3
+}
+
+// V3RawTx 无法解析的交易（格式错误或未知的交易类型），原样保存，解析器升级后可以重新解析
+type V3RawTx struct {
+	Id        uint64    `db:"id" json:"id"`               // 数据库自增id
+	Height    int64     `db:"height" json:"height"`       // 区块高度
+	TxIdx     int       `db:"txIdx" json:"txIdx"`         // 交易在区块中的序号
+	Hash      string    `db:"hash" json:"hash"`           // 交易字节的hash，与节点tx_search一致
+	Tag       string    `db:"tag" json:"tag"`             // 交易类型，前两个字节的十六进制
+	Raw       string    `db:"raw" json:"raw"`             // 完整交易，0x开头的十六进制
+	Error     string    `db:"error" json:"error"`         // 解析失败的原因
+	CreatedAt time.Time `db:"createdAt" json:"createdAt"` // 区块时间
+}
+
+// V3RawTxFilter 无法解析的交易的查询条件
+type V3RawTxFilter struct {
+	FromHeight int64  // 起始高度（含），0表示不限
+	ToHeight   int64  // 结束高度（含），0表示不限
+	Tag        string // 交易类型，为空时不限
+}
@@ -0,0 +1,44 @@
+package database
+
+import "time"
+
+// 推送的数据类型
+const (
+	WebhookKindTransaction = "transaction"
+	WebhookKindPayment     = "payment"
+)
+
+// 推送状态
+const (
+	WebhookPending   = "pending"   // 等待推送或重试
+	WebhookDelivered = "delivered" // 对方返回2xx
+	WebhookDead      = "dead"      // 超过最大重试次数，进入死信
+)
+
+// V3Webhook 推送地址及过滤条件，条件均为空时推送全部交易和转账
+type V3Webhook struct {
+	Id        uint64    `db:"id" json:"id"`                   // 数据库自增id
+	URL       string    `db:"url" json:"url"`                 // 推送地址
+	Secret    string    `db:"secret" json:"secret,omitempty"` // HMAC-SHA256签名密钥，只在创建时返回
+	Address   string    `db:"address" json:"address"`         // 交易、转账的发起方或接收方，为空时不限
+	Contract  string    `db:"contract" json:"contract"`       // 转账的合约地址或交易的接收方，为空时不限
+	Event     string    `db:"event" json:"event"`             // 转账的事件名称，如Transfer；不为空时只推送转账
+	CreatedAt time.Time `db:"createdAt" json:"createdAt"`     // 创建时间
+}
+
+// V3WebhookDelivery 每个匹配的交易或转账一条，同时作为推送日志和死信记录
+type V3WebhookDelivery struct {
+	Id            uint64    `db:"id" json:"id"`                       // 数据库自增id，推送时作为X-Mondo-Delivery
+	WebhookId     uint64    `db:"webhookId" json:"webhookId"`         // 所属webhook
+	Kind          string    `db:"kind" json:"kind"`                   // transaction、payment
+	Hash          string    `db:"hash" json:"hash"`                   // 交易hash
+	Height        int64     `db:"height" json:"height"`               // 区块高度
+	Payload       string    `db:"payload" json:"payload"`             // 推送的JSON
+	Status        string    `db:"status" json:"status"`               // pending、delivered、dead
+	Attempts      int       `db:"attempts" json:"attempts"`           // 已推送次数
+	ResponseCode  int       `db:"responseCode" json:"responseCode"`   // 最后一次推送的HTTP状态码，请求失败时为0
+	LastError     string    `db:"lastError" json:"lastError"`         // 最后一次推送失败的原因
+	NextAttemptAt time.Time `db:"nextAttemptAt" json:"nextAttemptAt"` // 下次推送时间
+	CreatedAt     time.Time `db:"createdAt" json:"createdAt"`         // 创建时间
+	UpdatedAt     time.Time `db:"updatedAt" json:"updatedAt"`         // 最后更新时间
+}
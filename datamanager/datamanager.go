@@ -2,6 +2,7 @@ package datamanager
 
 import (
 	"sync"
+	"time"
 
 	"github.com/toolglobal/api/database"
 )
@@ -15,6 +16,10 @@ type DataManager struct {
 	rdb       database.Database
 	qNeedLock bool
 	qLock     sync.Mutex
+
+	// awdb webhook等与区块同步无关的写入使用独立连接，不会混入同步任务未提交的事务
+	awdb  database.Database
+	aLock sync.Mutex
 }
 
 // NewDataManager create data manager
@@ -26,10 +31,22 @@ func NewDataManager(dbname string, dbc DBCreator) (*DataManager, error) {
 	if err != nil {
 		return nil, err
 	}
+	if _, err := wdb.Migrate(); err != nil {
+		return nil, err
+	}
 	dm := &DataManager{
 		wdb:       wdb,
 		rdb:       dbc(dbname),
 		qNeedLock: true,
+		awdb:      dbc(dbname),
+	}
+	if err := dm.ensureV3Balances(); err != nil {
+		dm.Close()
+		return nil, err
+	}
+	if err := dm.ensureV3BalanceHistory(); err != nil {
+		dm.Close()
+		return nil, err
 	}
 
 	return dm, nil
@@ -49,6 +66,13 @@ func (m *DataManager) Close() {
 		m.rdb.Close()
 		m.rdb = nil
 	}
+
+	m.aLock.Lock()
+	defer m.aLock.Unlock()
+	if m.awdb != nil {
+		m.awdb.Close()
+		m.awdb = nil
+	}
 }
 
 // QTxBegin start database transaction of wdb
@@ -80,3 +104,8 @@ func (m *DataManager) QTxRollback() error {
 
 	return m.wdb.Rollback()
 }
+
+// unixTime 查询参数中的时间戳转为time.Time，由数据库实现按方言存储
+func unixTime(ts uint64) time.Time {
+	return time.Unix(int64(ts), 0)
+}
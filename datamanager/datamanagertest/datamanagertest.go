@@ -0,0 +1,84 @@
+// Package datamanagertest 测试用的临时数据库和区块数据
+package datamanagertest
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/toolglobal/api/database"
+	"github.com/toolglobal/api/database/basesql"
+	"github.com/toolglobal/api/datamanager"
+	"github.com/toolglobal/api/libs/log"
+)
+
+const (
+	Alice = "0x00000000000000000000000000000000000A11cE"
+	Bob   = "0x0000000000000000000000000000000000000B0b"
+	USDT  = "0x1000000000000000000000000000000000000001"
+)
+
+// New 临时目录中的sqlite数据库，测试结束时关闭
+func New(t testing.TB) *datamanager.DataManager {
+	dir := t.TempDir()
+	dataM, err := datamanager.NewDataManager("mondo_query.db", func(dbname string) database.Database {
+		dbi := &basesql.Basesql{}
+		if err := dbi.Init(dbname, dir, log.Logger); err != nil {
+			t.Fatal(err)
+		}
+		return dbi
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(dataM.Close)
+	return dataM
+}
+
+// Block 高度h的区块：Alice转给Bob 1 OLO，Bob转给Alice h USDT
+func Block(h int64) *database.BlockEvent {
+	now := time.Unix(1600000000+h, 0)
+	olo := fmt.Sprintf("0x%02d01", h)
+	token := fmt.Sprintf("0x%02d02", h)
+	return &database.BlockEvent{
+		Ledger: database.V3Ledger{Height: h, BlockHash: fmt.Sprintf("%064X", h), TxCount: 2, GasPrice: "1", CreatedAt: now},
+		Transactions: []database.V3Transaction{
+			{Hash: olo, Height: h, Types: "TxTagAppEvm", Sender: Alice, Receiver: Bob, Value: "1", GasPrice: "1", CreatedAt: now},
+			{Hash: token, Height: h, TxIdx: 1, Types: "TxTagEthereumTx", Sender: Bob, Receiver: USDT, Value: "0", GasPrice: "1", CreatedAt: now},
+		},
+		Payments: []database.V3Payment{
+			{Hash: olo, Height: h, Sender: Alice, Receiver: Bob, Symbol: "OLO", Value: "1", CreatedAt: now},
+			{Hash: token, Height: h, EvName: "Transfer", Sender: Bob, Receiver: Alice, Symbol: "USDT", Contract: USDT, Value: fmt.Sprint(h), CreatedAt: now},
+		},
+	}
+}
+
+// SaveBlocks 写入from到to高度的Block并更新同步进度，indexerVersion为记录在sync_state中的解析器版本
+func SaveBlocks(t testing.TB, dataM *datamanager.DataManager, from, to int64, indexerVersion int) {
+	batch, err := dataM.BeginV3Batch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for h := from; h <= to; h++ {
+		ev := Block(h)
+		if err := batch.AddLedger(&ev.Ledger); err != nil {
+			t.Fatal(err)
+		}
+		for i := range ev.Transactions {
+			if err := batch.AddTransaction(&ev.Transactions[i]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for i := range ev.Payments {
+			if err := batch.AddPayment(&ev.Payments[i]); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := batch.SetSyncState(database.SyncStateV3, to, fmt.Sprintf("%064X", to), indexerVersion); err != nil {
+		t.Fatal(err)
+	}
+	if err := batch.Commit(); err != nil {
+		t.Fatal(err)
+	}
+}
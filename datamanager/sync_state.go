@@ -0,0 +1,102 @@
+package datamanager
+
+import (
+	"time"
+
+	"github.com/toolglobal/api/database"
+)
+
+// QuerySyncState 查询同步进度，不存在时返回nil
+func (m *DataManager) QuerySyncState(name string) (*database.SyncState, error) {
+	if m.qNeedLock {
+		m.qLock.Lock()
+		defer m.qLock.Unlock()
+	}
+
+	where := []database.Where{
+		database.Where{Name: "name", Value: name},
+	}
+
+	var result []database.SyncState
+	err := m.rdb.SelectRows(database.TableSyncState, where, nil, nil, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result) == 0 {
+		return nil, nil
+	}
+
+	return &result[0], nil
+}
+
+// SaveSyncState 更新同步进度，不存在时插入；在wdb当前事务中执行，与区块数据一起提交
+func (m *DataManager) SaveSyncState(name string, height int64, blockHash string, indexerVersion int) error {
+	if m.qNeedLock {
+		m.qLock.Lock()
+		defer m.qLock.Unlock()
+	}
+
+	return saveSyncState(m.wdb, name, height, blockHash, indexerVersion)
+}
+
+func saveSyncState(db database.Database, name string, height int64, blockHash string, indexerVersion int) error {
+	where := []database.Where{
+		database.Where{Name: "name", Value: name},
+	}
+
+	var result []database.SyncState
+	if err := db.SelectRows(database.TableSyncState, where, nil, nil, &result); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if len(result) > 0 {
+		fields := []database.Feild{
+			database.Feild{Name: "height", Value: height},
+			database.Feild{Name: "blockHash", Value: blockHash},
+			database.Feild{Name: "indexerVersion", Value: indexerVersion},
+			database.Feild{Name: "updatedAt", Value: now},
+		}
+		_, err := db.Update(database.TableSyncState, fields, where)
+		return err
+	}
+
+	fields := []database.Feild{
+		database.Feild{Name: "name", Value: name},
+		database.Feild{Name: "height", Value: height},
+		database.Feild{Name: "blockHash", Value: blockHash},
+		database.Feild{Name: "indexerVersion", Value: indexerVersion},
+		database.Feild{Name: "createdAt", Value: now},
+		database.Feild{Name: "updatedAt", Value: now},
+	}
+	_, err := db.Insert(database.TableSyncState, fields)
+	return err
+}
+
+// rollbackSyncState 将超过height的同步进度回退到height，调用方持有锁并已开启事务
+func (m *DataManager) rollbackSyncState(height int64) error {
+	var ledgers []database.V3Ledger
+	where := []database.Where{
+		database.Where{Name: "height", Value: height},
+	}
+	if err := m.wdb.SelectRows(database.TableV3Ledgers, where, nil, nil, &ledgers); err != nil {
+		return err
+	}
+
+	var blockHash string
+	if len(ledgers) > 0 {
+		blockHash = ledgers[0].BlockHash
+	}
+
+	fields := []database.Feild{
+		database.Feild{Name: "height", Value: height},
+		database.Feild{Name: "blockHash", Value: blockHash},
+		database.Feild{Name: "updatedAt", Value: time.Now()},
+	}
+	where = []database.Where{
+		database.Where{Name: "height", Value: height, Op: ">"},
+	}
+	_, err := m.wdb.Update(database.TableSyncState, fields, where)
+	return err
+}
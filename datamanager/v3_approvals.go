@@ -0,0 +1,124 @@
+package datamanager
+
+import (
+	"database/sql"
+
+	"github.com/toolglobal/api/database"
+)
+
+func (m *DataManager) PrepareV3Approval() (*sql.Stmt, error) {
+	if m.qNeedLock {
+		m.qLock.Lock()
+		defer m.qLock.Unlock()
+	}
+
+	return m.wdb.Prepare(database.TableV3Approvals, v3ApprovalFields(&database.V3Approval{}))
+}
+
+func (m *DataManager) AddV3ApprovalStmt(stmt *sql.Stmt, data *database.V3Approval) error {
+	if m.qNeedLock {
+		m.qLock.Lock()
+		defer m.qLock.Unlock()
+	}
+
+	_, err := m.wdb.Excute(stmt, v3ApprovalFields(data))
+	return err
+}
+
+func (m *DataManager) AddV3Approval(data *database.V3Approval) (uint64, error) {
+	if m.qNeedLock {
+		m.qLock.Lock()
+		defer m.qLock.Unlock()
+	}
+
+	sqlRes, err := m.wdb.Insert(database.TableV3Approvals, v3ApprovalFields(data))
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := sqlRes.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return uint64(id), nil
+}
+
+func v3ApprovalFields(data *database.V3Approval) []database.Feild {
+	return []database.Feild{
+		database.Feild{Name: "hash", Value: data.Hash},
+		database.Feild{Name: "height", Value: data.Height},
+		database.Feild{Name: "idx", Value: data.Idx},
+		database.Feild{Name: "evName", Value: data.EvName},
+		database.Feild{Name: "standard", Value: data.Standard},
+		database.Feild{Name: "contract", Value: data.Contract},
+		database.Feild{Name: "owner", Value: data.Owner},
+		database.Feild{Name: "spender", Value: data.Spender},
+		database.Feild{Name: "tokenId", Value: data.TokenId},
+		database.Feild{Name: "value", Value: data.Value},
+		database.Feild{Name: "approved", Value: data.Approved},
+		database.Feild{Name: "createdAt", Value: data.CreatedAt},
+	}
+}
+
+// QueryV3Approvals 查询地址作为授权方或被授权方的授权记录
+func (m *DataManager) QueryV3Approvals(address, contract string, begin, end uint64, paging *database.Paging, order string) ([]database.V3Approval, error) {
+	if m.qNeedLock {
+		m.qLock.Lock()
+		defer m.qLock.Unlock()
+	}
+
+	where := []database.Where{
+		database.Where{Name: "1", Value: 1},
+	}
+	if begin != 0 {
+		where = append(where, database.Where{Name: "createdAt", Value: unixTime(begin), Op: ">="})
+	}
+	if end != 0 {
+		where = append(where, database.Where{Name: "createdAt", Value: unixTime(end), Op: "<"})
+	}
+	if contract != "" {
+		where = append(where, database.Where{Name: "contract", Value: contract})
+	}
+	wheres := [][]database.Where{
+		append(where[:len(where):len(where)], database.Where{Name: "owner", Value: address}),
+		append(where[:len(where):len(where)], database.Where{Name: "spender", Value: address}),
+	}
+
+	orderT, err := database.MakeOrder(order, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	var result []database.V3Approval
+	err = m.rdb.SelectRowsUnion(database.TableV3Approvals, wheres, orderT, paging, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (m *DataManager) QueryV3ApprovalsByHeight(height int64, paging *database.Paging, order string) ([]database.V3Approval, error) {
+	if m.qNeedLock {
+		m.qLock.Lock()
+		defer m.qLock.Unlock()
+	}
+
+	where := []database.Where{
+		database.Where{Name: "height", Value: height},
+	}
+
+	orderT, err := database.MakeOrder(order, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	var result []database.V3Approval
+	err = m.rdb.SelectRows(database.TableV3Approvals, where, orderT, paging, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,321 @@
+package datamanager
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/toolglobal/api/database"
+)
+
+type nativeDelta struct {
+	value     *big.Int
+	createdAt time.Time
+}
+
+// nativeDeltas 原生币余额变化，按高度、账户汇总
+type nativeDeltas map[int64]map[string]*nativeDelta
+
+func (d nativeDeltas) add(height int64, address string, value *big.Int, createdAt time.Time) {
+	if address == "" || address == zeroAddress {
+		return
+	}
+	byAddress, ok := d[height]
+	if !ok {
+		byAddress = make(map[string]*nativeDelta)
+		d[height] = byAddress
+	}
+	delta, ok := byAddress[address]
+	if !ok {
+		delta = &nativeDelta{value: new(big.Int), createdAt: createdAt}
+		byAddress[address] = delta
+	}
+	delta.value.Add(delta.value, value)
+}
+
+// addPayment 原生币payment（合约为全零地址）从sender转给receiver
+func (d nativeDeltas) addPayment(p *database.V3Payment) error {
+	if p.Contract != zeroAddress {
+		return nil
+	}
+	value, ok := new(big.Int).SetString(p.Value, 10)
+	if !ok {
+		return fmt.Errorf("payment %s/%d: invalid value %q", p.Hash, p.Idx, p.Value)
+	}
+	d.add(p.Height, p.Sender, new(big.Int).Neg(value), p.CreatedAt)
+	d.add(p.Height, p.Receiver, value, p.CreatedAt)
+	return nil
+}
+
+// addFee 手续费gasUsed*gasPrice由sender支付，执行失败的交易同样扣除
+func (d nativeDeltas) addFee(tx *database.V3Transaction) error {
+	if tx.GasUsed == 0 {
+		return nil
+	}
+	gasPrice, ok := new(big.Int).SetString(tx.GasPrice, 10)
+	if !ok {
+		return fmt.Errorf("transaction %s: invalid gasPrice %q", tx.Hash, tx.GasPrice)
+	}
+	fee := new(big.Int).Mul(big.NewInt(tx.GasUsed), gasPrice)
+	d.add(tx.Height, tx.Sender, fee.Neg(fee), tx.CreatedAt)
+	return nil
+}
+
+func sortedHeights(heights map[int64]bool) []int64 {
+	result := make([]int64, 0, len(heights))
+	for height := range heights {
+		result = append(result, height)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result
+}
+
+func sortedAddresses(addresses map[string]*big.Int) []string {
+	result := make([]string, 0, len(addresses))
+	for address := range addresses {
+		result = append(result, address)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// saveV3BalanceHistory 加锁写入原生币余额变化，在wdb当前事务中执行
+func (m *DataManager) saveV3BalanceHistory(deltas nativeDeltas, replaced map[int64]map[string]*big.Int) error {
+	if m.qNeedLock {
+		m.qLock.Lock()
+		defer m.qLock.Unlock()
+	}
+
+	return m.applyV3BalanceHistory(deltas, replaced)
+}
+
+// applyV3BalanceHistory 按高度从小到大写入余额变化，余额在上一条记录的基础上累加。
+// replaced为重建前各高度的变化量，重建后变化量不同时平移之后各高度的余额。调用方负责加锁
+func (m *DataManager) applyV3BalanceHistory(deltas nativeDeltas, replaced map[int64]map[string]*big.Int) error {
+	heights := make(map[int64]bool, len(deltas)+len(replaced))
+	for height := range deltas {
+		heights[height] = true
+	}
+	for height := range replaced {
+		heights[height] = true
+	}
+
+	for _, height := range sortedHeights(heights) {
+		values := make(map[string]*big.Int, len(deltas[height]))
+		for address, delta := range deltas[height] {
+			values[address] = delta.value
+		}
+		for _, address := range sortedAddresses(values) {
+			delta := deltas[height][address]
+			prev, err := m.lastV3BalanceHistory(m.wdb, address, height, "<")
+			if err != nil {
+				return err
+			}
+			balance := new(big.Int)
+			if prev != nil {
+				if _, ok := balance.SetString(prev.Balance, 10); !ok {
+					return fmt.Errorf("balance history %s/%d: invalid balance %q", address, prev.Height, prev.Balance)
+				}
+			}
+			fields := []database.Feild{
+				database.Feild{Name: "address", Value: address},
+				database.Feild{Name: "height", Value: height},
+				database.Feild{Name: "delta", Value: delta.value.String()},
+				database.Feild{Name: "balance", Value: balance.Add(balance, delta.value).String()},
+				database.Feild{Name: "createdAt", Value: delta.createdAt},
+			}
+			if _, err := m.wdb.Insert(database.TableV3BalanceHistory, fields); err != nil {
+				return err
+			}
+		}
+
+		old, ok := replaced[height]
+		if !ok {
+			continue
+		}
+		shifts := make(map[string]*big.Int, len(values)+len(old))
+		for address, value := range values {
+			shifts[address] = new(big.Int).Set(value)
+		}
+		for address, value := range old {
+			if _, ok := shifts[address]; !ok {
+				shifts[address] = new(big.Int)
+			}
+			shifts[address].Sub(shifts[address], value)
+		}
+		for _, address := range sortedAddresses(shifts) {
+			if shifts[address].Sign() == 0 {
+				continue
+			}
+			if err := m.shiftV3BalanceHistory(address, height, shifts[address]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// shiftV3BalanceHistory 高度大于height的余额加上shift
+func (m *DataManager) shiftV3BalanceHistory(address string, height int64, shift *big.Int) error {
+	where := []database.Where{
+		database.Where{Name: "address", Value: address},
+		database.Where{Name: "height", Value: height, Op: ">"},
+	}
+	var result []database.V3BalanceHistory
+	if err := m.wdb.SelectRows(database.TableV3BalanceHistory, where, nil, nil, &result); err != nil {
+		return err
+	}
+	for _, row := range result {
+		balance, ok := new(big.Int).SetString(row.Balance, 10)
+		if !ok {
+			return fmt.Errorf("balance history %s/%d: invalid balance %q", address, row.Height, row.Balance)
+		}
+		toupdate := []database.Feild{
+			database.Feild{Name: "balance", Value: balance.Add(balance, shift).String()},
+		}
+		if _, err := m.wdb.Update(database.TableV3BalanceHistory, toupdate, []database.Where{{Name: "id", Value: row.Id}}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lastV3BalanceHistory 账户在满足height条件的高度中最后一条余额记录，没有时返回nil
+func (m *DataManager) lastV3BalanceHistory(db database.Database, address string, height int64, op string) (*database.V3BalanceHistory, error) {
+	where := []database.Where{
+		database.Where{Name: "address", Value: address},
+		database.Where{Name: "height", Value: height, Op: op},
+	}
+	orderT, err := database.MakeOrder("DESC", "height")
+	if err != nil {
+		return nil, err
+	}
+	var result []database.V3BalanceHistory
+	if err := db.SelectRows(database.TableV3BalanceHistory, where, orderT, database.MakePaging("id", 0, 1), &result); err != nil {
+		return nil, err
+	}
+	if len(result) == 0 {
+		return nil, nil
+	}
+	return &result[0], nil
+}
+
+// queryV3BalanceHistoryAt 某一高度各账户的变化量，重建区块前记录，用于平移之后的余额
+func (m *DataManager) queryV3BalanceHistoryAt(height int64) (map[string]*big.Int, error) {
+	if m.qNeedLock {
+		m.qLock.Lock()
+		defer m.qLock.Unlock()
+	}
+
+	var result []database.V3BalanceHistory
+	if err := m.wdb.SelectRows(database.TableV3BalanceHistory, []database.Where{{Name: "height", Value: height}}, nil, nil, &result); err != nil {
+		return nil, err
+	}
+	deltas := make(map[string]*big.Int, len(result))
+	for _, row := range result {
+		delta, ok := new(big.Int).SetString(row.Delta, 10)
+		if !ok {
+			return nil, fmt.Errorf("balance history %s/%d: invalid delta %q", row.Address, row.Height, row.Delta)
+		}
+		deltas[row.Address] = delta
+	}
+	return deltas, nil
+}
+
+// RebuildV3BalanceHistory 按库中全部交易和原生币payment重新计算v3_balance_history
+func (m *DataManager) RebuildV3BalanceHistory() (err error) {
+	if m.qNeedLock {
+		m.qLock.Lock()
+		defer m.qLock.Unlock()
+	}
+
+	if err = m.wdb.Begin(); err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			m.wdb.Rollback()
+		}
+	}()
+
+	if _, err = m.wdb.Delete(database.TableV3BalanceHistory, []database.Where{{Name: "1", Value: 1}}); err != nil {
+		return err
+	}
+
+	orderT, _ := database.MakeOrder("DESC", "height")
+	var ledgers []database.V3Ledger
+	if err = m.wdb.SelectRows(database.TableV3Ledgers, []database.Where{{Name: "1", Value: 1}}, orderT, database.MakePaging("id", 0, 1), &ledgers); err != nil {
+		return err
+	}
+	if len(ledgers) == 0 {
+		return m.wdb.Commit()
+	}
+
+	// 每次读取一段高度的交易和payment
+	const step = 1000
+	for from := int64(1); from <= ledgers[0].Height; from += step {
+		heightRange := []database.Where{
+			database.Where{Name: "height", Value: from, Op: ">="},
+			database.Where{Name: "height", Value: from + step, Op: "<"},
+		}
+		deltas := make(nativeDeltas)
+
+		var txs []database.V3Transaction
+		if err = m.wdb.SelectRows(database.TableV3Transactions, heightRange, nil, nil, &txs); err != nil {
+			return err
+		}
+		for i := range txs {
+			if err = deltas.addFee(&txs[i]); err != nil {
+				return err
+			}
+		}
+
+		var payments []database.V3Payment
+		where := append(heightRange, database.Where{Name: "contract", Value: zeroAddress})
+		if err = m.wdb.SelectRows(database.TableV3Payments, where, nil, nil, &payments); err != nil {
+			return err
+		}
+		for i := range payments {
+			if err = deltas.addPayment(&payments[i]); err != nil {
+				return err
+			}
+		}
+
+		if err = m.applyV3BalanceHistory(deltas, nil); err != nil {
+			return err
+		}
+	}
+
+	return m.wdb.Commit()
+}
+
+// ensureV3BalanceHistory 升级前已同步的库没有余额历史，启动时补算一次
+func (m *DataManager) ensureV3BalanceHistory() error {
+	orderT, _ := database.MakeOrder("ASC", "id")
+	var history []database.V3BalanceHistory
+	if err := m.wdb.SelectRows(database.TableV3BalanceHistory, []database.Where{{Name: "1", Value: 1}}, orderT, database.MakePaging("id", 0, 1), &history); err != nil {
+		return err
+	}
+	if len(history) > 0 {
+		return nil
+	}
+	var txs []database.V3Transaction
+	if err := m.wdb.SelectRows(database.TableV3Transactions, []database.Where{{Name: "1", Value: 1}}, orderT, database.MakePaging("id", 0, 1), &txs); err != nil {
+		return err
+	}
+	if len(txs) == 0 {
+		return nil
+	}
+	return m.RebuildV3BalanceHistory()
+}
+
+// QueryV3NativeBalance 查询账户在height（含）时的原生币余额记录，没有记录时返回nil
+func (m *DataManager) QueryV3NativeBalance(address string, height int64) (*database.V3BalanceHistory, error) {
+	if m.qNeedLock {
+		m.qLock.Lock()
+		defer m.qLock.Unlock()
+	}
+
+	return m.lastV3BalanceHistory(m.rdb, address, height, "<=")
+}
@@ -0,0 +1,101 @@
+package datamanager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/toolglobal/api/database"
+)
+
+func TestDataManager_V3BalanceHistory(t *testing.T) {
+	m := newTestDataManager(t)
+
+	const (
+		alice = "0x00000000000000000000000000000000000A11cE"
+		bob   = "0x0000000000000000000000000000000000000B0b"
+		token = "0x1000000000000000000000000000000000000001"
+	)
+	now := time.Unix(1600000000, 0)
+	type block struct {
+		txs      []database.V3Transaction
+		payments []database.V3Payment
+	}
+	blocks := map[int64]block{
+		1: {
+			payments: []database.V3Payment{
+				{Hash: "0x01", Height: 1, Sender: zeroAddress, Receiver: alice, Contract: zeroAddress, Value: "1000"},
+			},
+		},
+		2: {
+			txs: []database.V3Transaction{
+				{Hash: "0x02", Height: 2, Sender: alice, Receiver: bob, GasUsed: 21, GasPrice: "1"},
+				// 执行失败的交易同样扣除手续费
+				{Hash: "0x03", Height: 2, Sender: bob, Receiver: alice, GasUsed: 100, GasPrice: "2", Codei: 1},
+			},
+			payments: []database.V3Payment{
+				{Hash: "0x02", Height: 2, Sender: alice, Receiver: bob, Contract: zeroAddress, Value: "500"},
+				// 代币转账不影响原生币余额
+				{Hash: "0x02", Height: 2, Idx: 1, EvName: "Transfer", Sender: alice, Receiver: bob, Contract: token, Value: "7"},
+			},
+		},
+		3: {
+			payments: []database.V3Payment{
+				{Hash: "0x04", Height: 3, Sender: bob, Receiver: alice, Contract: zeroAddress, Value: "100"},
+			},
+		},
+	}
+	check := func(step string, want map[string][]string) {
+		for _, address := range []string{alice, bob} {
+			for i, balance := range want[address] {
+				height := int64(i)
+				got, err := m.QueryV3NativeBalance(address, height)
+				if err != nil {
+					t.Fatal(err)
+				}
+				gotBalance := "0"
+				if got != nil {
+					gotBalance = got.Balance
+				}
+				if gotBalance != balance {
+					t.Fatalf("%s: %s at %d got %s, want %s", step, address, height, gotBalance, balance)
+				}
+			}
+		}
+	}
+
+	for h := int64(1); h <= 3; h++ {
+		saveTestBlock(t, m, h, false, now.Add(time.Duration(h)*time.Second), blocks[h].txs, blocks[h].payments)
+	}
+	synced := map[string][]string{
+		alice: {"0", "1000", "479", "579"},
+		bob:   {"0", "0", "300", "200"},
+	}
+	check("sync", synced)
+
+	// 重建高度2，内容不变
+	saveTestBlock(t, m, 2, true, now.Add(2*time.Second), blocks[2].txs, blocks[2].payments)
+	check("reindex", synced)
+
+	// 重建高度2，转账金额变化后平移高度3的余额
+	blocks[2].payments[0].Value = "600"
+	saveTestBlock(t, m, 2, true, now.Add(2*time.Second), blocks[2].txs, blocks[2].payments)
+	check("reindex changed", map[string][]string{
+		alice: {"0", "1000", "379", "479"},
+		bob:   {"0", "0", "400", "300"},
+	})
+
+	blocks[2].payments[0].Value = "500"
+	saveTestBlock(t, m, 2, true, now.Add(2*time.Second), blocks[2].txs, blocks[2].payments)
+	if err := m.RebuildV3BalanceHistory(); err != nil {
+		t.Fatal(err)
+	}
+	check("rebuild", synced)
+
+	if err := m.RollbackV3(1); err != nil {
+		t.Fatal(err)
+	}
+	check("rollback", map[string][]string{
+		alice: {"0", "1000", "1000", "1000"},
+		bob:   {"0", "0", "0", "0"},
+	})
+}
@@ -0,0 +1,347 @@
+package datamanager
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/toolglobal/api/database"
+)
+
+var zeroAddress = common.Address{}.Hex()
+
+type balanceKey struct {
+	address  string
+	contract string
+}
+
+type balanceDelta struct {
+	value     *big.Int
+	height    int64
+	updatedAt time.Time
+}
+
+// balanceDeltas 一批payment对代币余额的影响，按账户、合约汇总
+type balanceDeltas map[balanceKey]*balanceDelta
+
+func (d balanceDeltas) add(address, contract string, value *big.Int, height int64, updatedAt time.Time) {
+	if address == "" || address == zeroAddress {
+		return
+	}
+	key := balanceKey{address: address, contract: contract}
+	delta, ok := d[key]
+	if !ok {
+		delta = &balanceDelta{value: new(big.Int)}
+		d[key] = delta
+	}
+	delta.value.Add(delta.value, value)
+	if height >= delta.height {
+		delta.height = height
+		delta.updatedAt = updatedAt
+	}
+}
+
+// addPayment 累加一条payment对余额的影响，sign为-1时撤销。
+// 只统计代币合约的payment：Transfer从sender转给receiver，Deposit视为给receiver铸造，Withdrawal视为从sender销毁；
+// 原生币（合约为全零地址）不在此统计
+func (d balanceDeltas) addPayment(p *database.V3Payment, sign int64) error {
+	if p.Contract == "" || p.Contract == zeroAddress {
+		return nil
+	}
+	value, ok := new(big.Int).SetString(p.Value, 10)
+	if !ok {
+		return fmt.Errorf("payment %s/%d: invalid value %q", p.Hash, p.Idx, p.Value)
+	}
+	value.Mul(value, big.NewInt(sign))
+
+	switch p.EvName {
+	case "Transfer":
+		d.add(p.Sender, p.Contract, new(big.Int).Neg(value), p.Height, p.CreatedAt)
+		d.add(p.Receiver, p.Contract, value, p.Height, p.CreatedAt)
+	case "Deposit":
+		d.add(p.Receiver, p.Contract, value, p.Height, p.CreatedAt)
+	case "Withdrawal":
+		d.add(p.Sender, p.Contract, new(big.Int).Neg(value), p.Height, p.CreatedAt)
+	}
+	return nil
+}
+
+// keys 排序后的key，保证写入顺序稳定
+func (d balanceDeltas) keys() []balanceKey {
+	keys := make([]balanceKey, 0, len(d))
+	for key := range d {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].address != keys[j].address {
+			return keys[i].address < keys[j].address
+		}
+		return keys[i].contract < keys[j].contract
+	})
+	return keys
+}
+
+// saveV3Balances 加锁写入余额变化，在wdb当前事务中执行
+func (m *DataManager) saveV3Balances(deltas balanceDeltas) error {
+	if m.qNeedLock {
+		m.qLock.Lock()
+		defer m.qLock.Unlock()
+	}
+
+	return m.applyV3Balances(deltas)
+}
+
+// applyV3Balances 把余额变化写入v3_balances，在wdb当前事务中执行，调用方负责加锁
+func (m *DataManager) applyV3Balances(deltas balanceDeltas) error {
+	for _, key := range deltas.keys() {
+		delta := deltas[key]
+		old, err := m.selectV3Balance(m.wdb, key.address, key.contract)
+		if err != nil {
+			return err
+		}
+		if old == nil {
+			fields := []database.Feild{
+				database.Feild{Name: "address", Value: key.address},
+				database.Feild{Name: "contract", Value: key.contract},
+				database.Feild{Name: "balance", Value: delta.value.String()},
+				database.Feild{Name: "height", Value: delta.height},
+				database.Feild{Name: "updatedAt", Value: delta.updatedAt},
+			}
+			if _, err := m.wdb.Insert(database.TableV3Balances, fields); err != nil {
+				return err
+			}
+			continue
+		}
+
+		balance, ok := new(big.Int).SetString(old.Balance, 10)
+		if !ok {
+			return fmt.Errorf("balance %s/%s: invalid value %q", key.address, key.contract, old.Balance)
+		}
+		toupdate := []database.Feild{
+			database.Feild{Name: "balance", Value: balance.Add(balance, delta.value).String()},
+		}
+		if delta.height >= old.Height {
+			toupdate = append(toupdate,
+				database.Feild{Name: "height", Value: delta.height},
+				database.Feild{Name: "updatedAt", Value: delta.updatedAt},
+			)
+		}
+		where := []database.Where{
+			database.Where{Name: "id", Value: old.Id},
+		}
+		if _, err := m.wdb.Update(database.TableV3Balances, toupdate, where); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// revertV3Balances 撤销已删除的payment对余额的影响，在payment删除之后、wdb当前事务中执行，调用方负责加锁。
+// 最后变化高度按剩余的payment重新计算，没有剩余payment的余额记录删除
+func (m *DataManager) revertV3Balances(payments []database.V3Payment) error {
+	deltas := make(balanceDeltas)
+	for i := range payments {
+		if err := deltas.addPayment(&payments[i], -1); err != nil {
+			return err
+		}
+	}
+	if len(deltas) == 0 {
+		return nil
+	}
+	if err := m.applyV3Balances(deltas); err != nil {
+		return err
+	}
+
+	for _, key := range deltas.keys() {
+		last, err := m.lastV3BalancePayment(key.address, key.contract)
+		if err != nil {
+			return err
+		}
+		where := []database.Where{
+			database.Where{Name: "address", Value: key.address},
+			database.Where{Name: "contract", Value: key.contract},
+		}
+		if last == nil {
+			if _, err := m.wdb.Delete(database.TableV3Balances, where); err != nil {
+				return err
+			}
+			continue
+		}
+		toupdate := []database.Feild{
+			database.Feild{Name: "height", Value: last.Height},
+			database.Feild{Name: "updatedAt", Value: last.CreatedAt},
+		}
+		if _, err := m.wdb.Update(database.TableV3Balances, toupdate, where); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lastV3BalancePayment 影响某账户某代币余额的最后一条payment
+func (m *DataManager) lastV3BalancePayment(address, contract string) (*database.V3Payment, error) {
+	wheres := [][]database.Where{
+		{
+			database.Where{Name: "contract", Value: contract},
+			database.Where{Name: "sender", Value: address},
+			database.Where{Name: "evName", Value: "Deposit", Op: "<>"},
+		},
+		{
+			database.Where{Name: "contract", Value: contract},
+			database.Where{Name: "receiver", Value: address},
+			database.Where{Name: "evName", Value: "Withdrawal", Op: "<>"},
+		},
+	}
+	orderT, err := database.MakeOrder("DESC", "height")
+	if err != nil {
+		return nil, err
+	}
+
+	var result []database.V3Payment
+	if err := m.wdb.SelectRowsUnion(database.TableV3Payments, wheres, orderT, database.MakePaging("id", 0, 1), &result); err != nil {
+		return nil, err
+	}
+	if len(result) == 0 {
+		return nil, nil
+	}
+	return &result[0], nil
+}
+
+// tokenPayments 按高度条件查询代币payment，用于删除前记录需要撤销的余额，调用方负责加锁
+func (m *DataManager) tokenPayments(height database.Where) ([]database.V3Payment, error) {
+	where := []database.Where{
+		height,
+		database.Where{Name: "contract", Value: zeroAddress, Op: "<>"},
+	}
+	var result []database.V3Payment
+	if err := m.wdb.SelectRows(database.TableV3Payments, where, nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (m *DataManager) selectV3Balance(db database.Database, address, contract string) (*database.V3Balance, error) {
+	where := []database.Where{
+		database.Where{Name: "address", Value: address},
+		database.Where{Name: "contract", Value: contract},
+	}
+	var result []database.V3Balance
+	if err := db.SelectRows(database.TableV3Balances, where, nil, nil, &result); err != nil {
+		return nil, err
+	}
+	if len(result) == 0 {
+		return nil, nil
+	}
+	return &result[0], nil
+}
+
+// RebuildV3Balances 按库中全部代币payment重新计算v3_balances
+func (m *DataManager) RebuildV3Balances() (err error) {
+	if m.qNeedLock {
+		m.qLock.Lock()
+		defer m.qLock.Unlock()
+	}
+
+	if err = m.wdb.Begin(); err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			m.wdb.Rollback()
+		}
+	}()
+
+	if _, err = m.wdb.Delete(database.TableV3Balances, []database.Where{{Name: "1", Value: 1}}); err != nil {
+		return err
+	}
+
+	const limit = 1000
+	deltas := make(balanceDeltas)
+	where := []database.Where{
+		database.Where{Name: "contract", Value: zeroAddress, Op: "<>"},
+	}
+	orderT, _ := database.MakeOrder("ASC", "id")
+	for after := uint64(0); ; {
+		var result []database.V3Payment
+		if err = m.wdb.SelectRows(database.TableV3Payments, where, orderT, database.MakeKeysetPaging("id", after, limit), &result); err != nil {
+			return err
+		}
+		for i := range result {
+			if err = deltas.addPayment(&result[i], 1); err != nil {
+				return err
+			}
+		}
+		if len(result) < limit {
+			break
+		}
+		after = result[len(result)-1].Id
+	}
+	if err = m.applyV3Balances(deltas); err != nil {
+		return err
+	}
+
+	return m.wdb.Commit()
+}
+
+// ensureV3Balances 升级前已同步的库没有余额数据，启动时补算一次
+func (m *DataManager) ensureV3Balances() error {
+	orderT, _ := database.MakeOrder("ASC", "id")
+	var balances []database.V3Balance
+	if err := m.wdb.SelectRows(database.TableV3Balances, []database.Where{{Name: "1", Value: 1}}, orderT, database.MakePaging("id", 0, 1), &balances); err != nil {
+		return err
+	}
+	if len(balances) > 0 {
+		return nil
+	}
+	var payments []database.V3Payment
+	where := []database.Where{
+		database.Where{Name: "contract", Value: zeroAddress, Op: "<>"},
+	}
+	if err := m.wdb.SelectRows(database.TableV3Payments, where, orderT, database.MakePaging("id", 0, 1), &payments); err != nil {
+		return err
+	}
+	if len(payments) == 0 {
+		return nil
+	}
+	return m.RebuildV3Balances()
+}
+
+// QueryV3Balance 查询账户某代币的余额，没有记录时返回nil
+func (m *DataManager) QueryV3Balance(address, contract string) (*database.V3Balance, error) {
+	if m.qNeedLock {
+		m.qLock.Lock()
+		defer m.qLock.Unlock()
+	}
+
+	return m.selectV3Balance(m.rdb, address, contract)
+}
+
+// QueryV3Balances 查询账户的代币余额，contract不为空时只查询该代币
+func (m *DataManager) QueryV3Balances(address, contract string, paging *database.Paging, order string) ([]database.V3Balance, error) {
+	if m.qNeedLock {
+		m.qLock.Lock()
+		defer m.qLock.Unlock()
+	}
+
+	where := []database.Where{
+		database.Where{Name: "address", Value: address},
+	}
+	if contract != "" {
+		where = append(where, database.Where{Name: "contract", Value: contract})
+	}
+
+	orderT, err := database.MakeOrder(order, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	var result []database.V3Balance
+	err = m.rdb.SelectRows(database.TableV3Balances, where, orderT, paging, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
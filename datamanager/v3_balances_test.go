@@ -0,0 +1,77 @@
+package datamanager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/toolglobal/api/database"
+)
+
+func TestDataManager_V3Balances(t *testing.T) {
+	m := newTestDataManager(t)
+
+	const (
+		alice = "0x00000000000000000000000000000000000A11cE"
+		bob   = "0x0000000000000000000000000000000000000B0b"
+		token = "0x1000000000000000000000000000000000000001"
+		weth  = "0x4000000000000000000000000000000000000004"
+	)
+	now := time.Unix(1600000000, 0)
+	blocks := map[int64][]database.V3Payment{
+		1: {
+			{Hash: "0x01", Height: 1, Idx: 0, EvName: "", Sender: alice, Receiver: bob, Contract: zeroAddress, Value: "9"},
+			{Hash: "0x01", Height: 1, Idx: 1, EvName: "Transfer", Sender: zeroAddress, Receiver: alice, Contract: token, Value: "100"},
+			{Hash: "0x02", Height: 1, Idx: 0, EvName: "Deposit", Sender: alice, Receiver: alice, Contract: weth, Value: "5"},
+		},
+		2: {
+			{Hash: "0x03", Height: 2, Idx: 0, EvName: "Transfer", Sender: alice, Receiver: bob, Contract: token, Value: "30"},
+			{Hash: "0x04", Height: 2, Idx: 0, EvName: "Withdrawal", Sender: alice, Receiver: alice, Contract: weth, Value: "2"},
+		},
+	}
+	check := func(step string, want map[string]database.V3Balance) {
+		for _, address := range []string{alice, bob, zeroAddress} {
+			for _, contract := range []string{token, weth, zeroAddress} {
+				got, err := m.QueryV3Balance(address, contract)
+				if err != nil {
+					t.Fatal(err)
+				}
+				w, ok := want[address+contract]
+				if !ok {
+					if got != nil {
+						t.Fatalf("%s: unexpected balance %+v", step, got)
+					}
+					continue
+				}
+				if got == nil || got.Balance != w.Balance || got.Height != w.Height {
+					t.Fatalf("%s: %s %s got %+v, want %s at %d", step, address, contract, got, w.Balance, w.Height)
+				}
+			}
+		}
+	}
+
+	saveTestBlock(t, m, 1, false, now.Add(1*time.Second), nil, blocks[1])
+	saveTestBlock(t, m, 2, false, now.Add(2*time.Second), nil, blocks[2])
+	afterTwo := map[string]database.V3Balance{
+		alice + token: {Balance: "70", Height: 2},
+		bob + token:   {Balance: "30", Height: 2},
+		alice + weth:  {Balance: "3", Height: 2},
+	}
+	check("sync", afterTwo)
+
+	// 重建高度2不改变余额
+	saveTestBlock(t, m, 2, true, now.Add(2*time.Second), nil, blocks[2])
+	check("reindex", afterTwo)
+
+	if err := m.RebuildV3Balances(); err != nil {
+		t.Fatal(err)
+	}
+	check("rebuild", afterTwo)
+
+	if err := m.RollbackV3(1); err != nil {
+		t.Fatal(err)
+	}
+	check("rollback", map[string]database.V3Balance{
+		alice + token: {Balance: "100", Height: 1},
+		alice + weth:  {Balance: "5", Height: 1},
+	})
+}
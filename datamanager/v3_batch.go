@@ -0,0 +1,205 @@
+package datamanager
+
+import (
+	"database/sql"
+	"math/big"
+
+	"github.com/toolglobal/api/database"
+)
+
+// V3Batch 在同一个数据库事务中写入一个或多个区块的ledgers、transactions、payments等数据。
+// 只有Commit成功后数据才可见，中途崩溃或Rollback不会留下部分写入的区块。
+type V3Batch struct {
+	m            *DataManager
+	txStmt       *sql.Stmt
+	paymentStmt  *sql.Stmt
+	nftStmt      *sql.Stmt
+	approvalStmt *sql.Stmt
+	contractStmt *sql.Stmt
+	logStmt      *sql.Stmt
+	depositStmt  *sql.Stmt
+	rawTxStmt    *sql.Stmt
+	watch        depositWatch                  // 登记的充值地址，转入的payment同时写入v3_deposits
+	balances     balanceDeltas                 // 本批payment对代币余额的影响，Commit时写入
+	native       nativeDeltas                  // 本批payment、手续费对原生币余额的影响，Commit时写入
+	replaced     map[int64]map[string]*big.Int // 重建的高度原有的原生币变化量
+}
+
+// BeginV3Batch 开启数据库事务并准备批量写入语句，调用方必须以Commit或Rollback结束
+func (m *DataManager) BeginV3Batch() (*V3Batch, error) {
+	if err := m.QTxBegin(); err != nil {
+		return nil, err
+	}
+
+	b := &V3Batch{
+		m:        m,
+		balances: make(balanceDeltas),
+		native:   make(nativeDeltas),
+		replaced: make(map[int64]map[string]*big.Int),
+	}
+	var err error
+	if b.txStmt, err = m.PrepareV3Transaction(); err != nil {
+		b.Rollback()
+		return nil, err
+	}
+	if b.paymentStmt, err = m.PrepareV3Payment(); err != nil {
+		b.Rollback()
+		return nil, err
+	}
+	if b.nftStmt, err = m.PrepareV3NFTTransfer(); err != nil {
+		b.Rollback()
+		return nil, err
+	}
+	if b.approvalStmt, err = m.PrepareV3Approval(); err != nil {
+		b.Rollback()
+		return nil, err
+	}
+	if b.contractStmt, err = m.PrepareV3Contract(); err != nil {
+		b.Rollback()
+		return nil, err
+	}
+	if b.logStmt, err = m.PrepareV3Log(); err != nil {
+		b.Rollback()
+		return nil, err
+	}
+	if b.depositStmt, err = m.PrepareV3Deposit(); err != nil {
+		b.Rollback()
+		return nil, err
+	}
+	if b.rawTxStmt, err = m.PrepareV3RawTx(); err != nil {
+		b.Rollback()
+		return nil, err
+	}
+	if b.watch, err = m.queryV3DepositWatch(); err != nil {
+		b.Rollback()
+		return nil, err
+	}
+	return b, nil
+}
+
+func (b *V3Batch) AddLedger(data *database.V3Ledger) error {
+	_, err := b.m.AddV3Ledger(data)
+	return err
+}
+
+func (b *V3Batch) AddTransaction(data *database.V3Transaction) error {
+	if err := b.native.addFee(data); err != nil {
+		return err
+	}
+	return b.m.AddV3TransactionStmt(b.txStmt, data)
+}
+
+func (b *V3Batch) AddPayment(data *database.V3Payment) error {
+	if err := b.balances.addPayment(data, 1); err != nil {
+		return err
+	}
+	if err := b.native.addPayment(data); err != nil {
+		return err
+	}
+	if deposit := b.watch.deposit(data); deposit != nil {
+		if err := b.m.AddV3DepositStmt(b.depositStmt, deposit); err != nil {
+			return err
+		}
+	}
+	return b.m.AddV3PaymentStmt(b.paymentStmt, data)
+}
+
+func (b *V3Batch) AddNFTTransfer(data *database.V3NFTTransfer) error {
+	return b.m.AddV3NFTTransferStmt(b.nftStmt, data)
+}
+
+func (b *V3Batch) AddApproval(data *database.V3Approval) error {
+	return b.m.AddV3ApprovalStmt(b.approvalStmt, data)
+}
+
+func (b *V3Batch) AddContract(data *database.V3Contract) error {
+	return b.m.AddV3ContractStmt(b.contractStmt, data)
+}
+
+func (b *V3Batch) AddLog(data *database.V3Log) error {
+	return b.m.AddV3LogStmt(b.logStmt, data)
+}
+
+// AddRawTx 记录无法解析的交易
+func (b *V3Batch) AddRawTx(data *database.V3RawTx) error {
+	return b.m.AddV3RawTxStmt(b.rawTxStmt, data)
+}
+
+// ReplaceLedger 重建区块时更新已有的ledger，保持原有的自增id
+func (b *V3Batch) ReplaceLedger(data *database.V3Ledger) error {
+	return b.m.UpdateV3Ledger(data)
+}
+
+// DeleteHeight 删除某一高度除ledger外的数据，用于重建区块
+func (b *V3Batch) DeleteHeight(height int64) error {
+	old, err := b.m.queryV3BalanceHistoryAt(height)
+	if err != nil {
+		return err
+	}
+	b.replaced[height] = old
+	return b.m.DeleteV3Height(height)
+}
+
+// SetSyncState 记录同步进度，与区块数据在同一事务中提交
+func (b *V3Batch) SetSyncState(name string, height int64, blockHash string, indexerVersion int) error {
+	return b.m.SaveSyncState(name, height, blockHash, indexerVersion)
+}
+
+// Commit 写入余额变化后提交批量写入，失败时回滚整个事务
+func (b *V3Batch) Commit() error {
+	b.close()
+	if err := b.m.saveV3Balances(b.balances); err != nil {
+		b.m.QTxRollback()
+		return err
+	}
+	if err := b.m.saveV3BalanceHistory(b.native, b.replaced); err != nil {
+		b.m.QTxRollback()
+		return err
+	}
+	if err := b.m.QTxCommit(); err != nil {
+		b.m.QTxRollback()
+		return err
+	}
+	return nil
+}
+
+// Rollback 放弃批量写入
+func (b *V3Batch) Rollback() error {
+	b.close()
+	return b.m.QTxRollback()
+}
+
+func (b *V3Batch) close() {
+	if b.txStmt != nil {
+		b.txStmt.Close()
+		b.txStmt = nil
+	}
+	if b.paymentStmt != nil {
+		b.paymentStmt.Close()
+		b.paymentStmt = nil
+	}
+	if b.nftStmt != nil {
+		b.nftStmt.Close()
+		b.nftStmt = nil
+	}
+	if b.approvalStmt != nil {
+		b.approvalStmt.Close()
+		b.approvalStmt = nil
+	}
+	if b.contractStmt != nil {
+		b.contractStmt.Close()
+		b.contractStmt = nil
+	}
+	if b.logStmt != nil {
+		b.logStmt.Close()
+		b.logStmt = nil
+	}
+	if b.depositStmt != nil {
+		b.depositStmt.Close()
+		b.depositStmt = nil
+	}
+	if b.rawTxStmt != nil {
+		b.rawTxStmt.Close()
+		b.rawTxStmt = nil
+	}
+}
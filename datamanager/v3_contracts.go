@@ -0,0 +1,119 @@
+package datamanager
+
+import (
+	"database/sql"
+
+	"github.com/toolglobal/api/database"
+)
+
+func (m *DataManager) PrepareV3Contract() (*sql.Stmt, error) {
+	if m.qNeedLock {
+		m.qLock.Lock()
+		defer m.qLock.Unlock()
+	}
+
+	return m.wdb.Prepare(database.TableV3Contracts, v3ContractFields(&database.V3Contract{}))
+}
+
+func (m *DataManager) AddV3ContractStmt(stmt *sql.Stmt, data *database.V3Contract) error {
+	if m.qNeedLock {
+		m.qLock.Lock()
+		defer m.qLock.Unlock()
+	}
+
+	_, err := m.wdb.Excute(stmt, v3ContractFields(data))
+	return err
+}
+
+func v3ContractFields(data *database.V3Contract) []database.Feild {
+	return []database.Feild{
+		database.Feild{Name: "address", Value: data.Address},
+		database.Feild{Name: "creator", Value: data.Creator},
+		database.Feild{Name: "hash", Value: data.Hash},
+		database.Feild{Name: "height", Value: data.Height},
+		database.Feild{Name: "types", Value: data.Types},
+		database.Feild{Name: "createdAt", Value: data.CreatedAt},
+	}
+}
+
+// QueryV3Contract 根据合约地址查询创建信息，不存在时返回nil
+func (m *DataManager) QueryV3Contract(address string) (*database.V3Contract, error) {
+	if m.qNeedLock {
+		m.qLock.Lock()
+		defer m.qLock.Unlock()
+	}
+
+	where := []database.Where{
+		database.Where{Name: "address", Value: address},
+	}
+
+	var result []database.V3Contract
+	err := m.rdb.SelectRows(database.TableV3Contracts, where, nil, nil, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result) == 0 {
+		return nil, nil
+	}
+
+	return &result[0], nil
+}
+
+// QueryV3Contracts 查询创建的合约，creator不为空时只查询该地址创建的合约
+func (m *DataManager) QueryV3Contracts(creator string, begin, end uint64, paging *database.Paging, order string) ([]database.V3Contract, error) {
+	if m.qNeedLock {
+		m.qLock.Lock()
+		defer m.qLock.Unlock()
+	}
+
+	where := []database.Where{
+		database.Where{Name: "1", Value: 1},
+	}
+	if creator != "" {
+		where = append(where, database.Where{Name: "creator", Value: creator})
+	}
+	if begin != 0 {
+		where = append(where, database.Where{Name: "createdAt", Value: unixTime(begin), Op: ">="})
+	}
+	if end != 0 {
+		where = append(where, database.Where{Name: "createdAt", Value: unixTime(end), Op: "<"})
+	}
+
+	orderT, err := database.MakeOrder(order, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	var result []database.V3Contract
+	err = m.rdb.SelectRows(database.TableV3Contracts, where, orderT, paging, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (m *DataManager) QueryV3ContractsByHeight(height int64, paging *database.Paging, order string) ([]database.V3Contract, error) {
+	if m.qNeedLock {
+		m.qLock.Lock()
+		defer m.qLock.Unlock()
+	}
+
+	where := []database.Where{
+		database.Where{Name: "height", Value: height},
+	}
+
+	orderT, err := database.MakeOrder(order, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	var result []database.V3Contract
+	err = m.rdb.SelectRows(database.TableV3Contracts, where, orderT, paging, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,276 @@
+package datamanager
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/toolglobal/api/database"
+)
+
+// SaveV3DepositAddress 登记充值地址，已登记时更新确认数和备注
+func (m *DataManager) SaveV3DepositAddress(data *database.V3DepositAddress) (err error) {
+	m.aLock.Lock()
+	defer m.aLock.Unlock()
+
+	if err = m.awdb.Begin(); err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			m.awdb.Rollback()
+		}
+	}()
+
+	where := []database.Where{
+		database.Where{Name: "address", Value: data.Address},
+	}
+	var result []database.V3DepositAddress
+	if err = m.awdb.SelectRows(database.TableV3DepositAddresses, where, nil, nil, &result); err != nil {
+		return err
+	}
+
+	if len(result) > 0 {
+		fields := []database.Feild{
+			database.Feild{Name: "confirmations", Value: data.Confirmations},
+			database.Feild{Name: "label", Value: data.Label},
+		}
+		_, err = m.awdb.Update(database.TableV3DepositAddresses, fields, where)
+	} else {
+		fields := []database.Feild{
+			database.Feild{Name: "address", Value: data.Address},
+			database.Feild{Name: "confirmations", Value: data.Confirmations},
+			database.Feild{Name: "label", Value: data.Label},
+			database.Feild{Name: "createdAt", Value: data.CreatedAt},
+		}
+		_, err = m.awdb.Insert(database.TableV3DepositAddresses, fields)
+	}
+	if err != nil {
+		return err
+	}
+
+	return m.awdb.Commit()
+}
+
+// DeleteV3DepositAddress 取消登记，已记录的充值保留，返回是否存在
+func (m *DataManager) DeleteV3DepositAddress(address string) (bool, error) {
+	m.aLock.Lock()
+	defer m.aLock.Unlock()
+
+	where := []database.Where{
+		database.Where{Name: "address", Value: address},
+	}
+	res, err := m.awdb.Delete(database.TableV3DepositAddresses, where)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// QueryV3DepositAddress 查询登记的充值地址，未登记时返回nil
+func (m *DataManager) QueryV3DepositAddress(address string) (*database.V3DepositAddress, error) {
+	if m.qNeedLock {
+		m.qLock.Lock()
+		defer m.qLock.Unlock()
+	}
+
+	where := []database.Where{
+		database.Where{Name: "address", Value: address},
+	}
+
+	var result []database.V3DepositAddress
+	err := m.rdb.SelectRows(database.TableV3DepositAddresses, where, nil, nil, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result) == 0 {
+		return nil, nil
+	}
+
+	return &result[0], nil
+}
+
+func (m *DataManager) QueryV3DepositAddresses(paging *database.Paging, order string) ([]database.V3DepositAddress, error) {
+	if m.qNeedLock {
+		m.qLock.Lock()
+		defer m.qLock.Unlock()
+	}
+
+	where := []database.Where{
+		database.Where{Name: "1", Value: 1},
+	}
+
+	orderT, err := database.MakeOrder(order, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	var result []database.V3DepositAddress
+	err = m.rdb.SelectRows(database.TableV3DepositAddresses, where, orderT, paging, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// depositWatch 登记的充值地址（小写）及其确认数
+type depositWatch map[string]int64
+
+// queryV3DepositWatch 在wdb当前事务中读取登记的充值地址
+func (m *DataManager) queryV3DepositWatch() (depositWatch, error) {
+	if m.qNeedLock {
+		m.qLock.Lock()
+		defer m.qLock.Unlock()
+	}
+
+	var result []database.V3DepositAddress
+	if err := m.wdb.SelectRows(database.TableV3DepositAddresses, []database.Where{{Name: "1", Value: 1}}, nil, nil, &result); err != nil {
+		return nil, err
+	}
+
+	watch := make(depositWatch, len(result))
+	for _, addr := range result {
+		watch[strings.ToLower(addr.Address)] = addr.Confirmations
+	}
+	return watch, nil
+}
+
+// deposit 转入登记地址的转账返回对应的充值，否则返回nil。WETH的Withdrawal是销毁，不算充值
+func (w depositWatch) deposit(p *database.V3Payment) *database.V3Deposit {
+	confirmations, ok := w[strings.ToLower(p.Receiver)]
+	if !ok || p.EvName == "Withdrawal" {
+		return nil
+	}
+	if confirmations < 1 {
+		confirmations = 1
+	}
+	return &database.V3Deposit{
+		Address:       p.Receiver,
+		Hash:          p.Hash,
+		Height:        p.Height,
+		Idx:           p.Idx,
+		Sender:        p.Sender,
+		Symbol:        p.Symbol,
+		Contract:      p.Contract,
+		Value:         p.Value,
+		ConfirmHeight: p.Height + confirmations - 1,
+		CreatedAt:     p.CreatedAt,
+		UpdatedAt:     p.CreatedAt,
+	}
+}
+
+func (m *DataManager) PrepareV3Deposit() (*sql.Stmt, error) {
+	if m.qNeedLock {
+		m.qLock.Lock()
+		defer m.qLock.Unlock()
+	}
+
+	return m.wdb.Prepare(database.TableV3Deposits, v3DepositFields(&database.V3Deposit{}))
+}
+
+func (m *DataManager) AddV3DepositStmt(stmt *sql.Stmt, data *database.V3Deposit) error {
+	if m.qNeedLock {
+		m.qLock.Lock()
+		defer m.qLock.Unlock()
+	}
+
+	_, err := m.wdb.Excute(stmt, v3DepositFields(data))
+	return err
+}
+
+func v3DepositFields(data *database.V3Deposit) []database.Feild {
+	return []database.Feild{
+		database.Feild{Name: "address", Value: data.Address},
+		database.Feild{Name: "hash", Value: data.Hash},
+		database.Feild{Name: "height", Value: data.Height},
+		database.Feild{Name: "idx", Value: data.Idx},
+		database.Feild{Name: "sender", Value: data.Sender},
+		database.Feild{Name: "symbol", Value: data.Symbol},
+		database.Feild{Name: "contract", Value: data.Contract},
+		database.Feild{Name: "value", Value: data.Value},
+		database.Feild{Name: "confirmHeight", Value: data.ConfirmHeight},
+		database.Feild{Name: "invalidated", Value: data.Invalidated},
+		database.Feild{Name: "createdAt", Value: data.CreatedAt},
+		database.Feild{Name: "updatedAt", Value: data.UpdatedAt},
+	}
+}
+
+// invalidateV3Deposits 把高度大于height的充值标记为已回滚，调用方持有锁并已开启事务
+func (m *DataManager) invalidateV3Deposits(height int64) error {
+	fields := []database.Feild{
+		database.Feild{Name: "invalidated", Value: true},
+		database.Feild{Name: "updatedAt", Value: time.Now()},
+	}
+	where := []database.Where{
+		database.Where{Name: "height", Value: height, Op: ">"},
+		database.Where{Name: "invalidated", Value: false},
+	}
+	_, err := m.wdb.Update(database.TableV3Deposits, fields, where)
+	return err
+}
+
+// QueryV3Deposits 查询充值，indexed为已同步高度，用于计算确认数和状态
+func (m *DataManager) QueryV3Deposits(filter *database.V3DepositFilter, indexed int64, paging *database.Paging, order string) ([]database.V3Deposit, error) {
+	if m.qNeedLock {
+		m.qLock.Lock()
+		defer m.qLock.Unlock()
+	}
+
+	where := []database.Where{
+		database.Where{Name: "1", Value: 1},
+	}
+	if filter.Address != "" {
+		where = append(where, database.Where{Name: "address", Value: filter.Address})
+	}
+	if filter.Contract != "" {
+		where = append(where, database.Where{Name: "contract", Value: filter.Contract})
+	}
+	if filter.Symbol != "" {
+		where = append(where, database.Where{Name: "symbol", Value: filter.Symbol})
+	}
+	switch filter.Status {
+	case database.DepositPending:
+		where = append(where,
+			database.Where{Name: "invalidated", Value: false},
+			database.Where{Name: "confirmHeight", Value: indexed, Op: ">"})
+	case database.DepositConfirmed:
+		where = append(where,
+			database.Where{Name: "invalidated", Value: false},
+			database.Where{Name: "confirmHeight", Value: indexed, Op: "<="})
+	case database.DepositInvalidated:
+		where = append(where, database.Where{Name: "invalidated", Value: true})
+	}
+
+	orderT, err := database.MakeOrder(order, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	var result []database.V3Deposit
+	err = m.rdb.SelectRows(database.TableV3Deposits, where, orderT, paging, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range result {
+		d := &result[i]
+		switch {
+		case d.Invalidated:
+			d.Status = database.DepositInvalidated
+		case d.ConfirmHeight <= indexed:
+			d.Status = database.DepositConfirmed
+		default:
+			d.Status = database.DepositPending
+		}
+		if !d.Invalidated && indexed >= d.Height {
+			d.Confirmations = indexed - d.Height + 1
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,89 @@
+package datamanager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/toolglobal/api/database"
+)
+
+func TestDataManager_V3Deposits(t *testing.T) {
+	m := newTestDataManager(t)
+
+	const (
+		alice = "0x00000000000000000000000000000000000A11cE"
+		bob   = "0x0000000000000000000000000000000000000B0b"
+		token = "0x1000000000000000000000000000000000000001"
+	)
+	now := time.Unix(1600000000, 0)
+	if err := m.SaveV3DepositAddress(&database.V3DepositAddress{Address: alice, Confirmations: 3, CreatedAt: now}); err != nil {
+		t.Fatal(err)
+	}
+	// 重复登记更新确认数
+	if err := m.SaveV3DepositAddress(&database.V3DepositAddress{Address: alice, Confirmations: 2, Label: "user 1", CreatedAt: now}); err != nil {
+		t.Fatal(err)
+	}
+	if addr, err := m.QueryV3DepositAddress(alice); err != nil || addr == nil || addr.Confirmations != 2 || addr.Label != "user 1" {
+		t.Fatalf("deposit address %+v %v", addr, err)
+	}
+
+	payments := map[int64][]database.V3Payment{
+		1: {
+			{Hash: "0x01", Height: 1, Sender: bob, Receiver: alice, Symbol: "OLO", Contract: zeroAddress, Value: "1000"},
+			// 转出不是充值
+			{Hash: "0x02", Height: 1, Sender: alice, Receiver: bob, Symbol: "OLO", Contract: zeroAddress, Value: "1"},
+		},
+		2: {
+			{Hash: "0x03", Height: 2, Idx: 1, EvName: "Transfer", Sender: bob, Receiver: alice, Symbol: "USDT", Contract: token, Value: "7"},
+			{Hash: "0x04", Height: 2, Idx: 2, EvName: "Withdrawal", Sender: alice, Receiver: alice, Symbol: "WOLO", Contract: token, Value: "5"},
+		},
+	}
+	query := func(filter database.V3DepositFilter, indexed int64) []database.V3Deposit {
+		result, err := m.QueryV3Deposits(&filter, indexed, nil, "ASC")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	saveTestBlock(t, m, 1, false, now, nil, payments[1])
+	saveTestBlock(t, m, 2, false, now, nil, payments[2])
+
+	deposits := query(database.V3DepositFilter{Address: alice}, 2)
+	if len(deposits) != 2 || deposits[0].Hash != "0x01" || deposits[0].Confirmations != 2 || deposits[0].Status != database.DepositConfirmed ||
+		deposits[1].Hash != "0x03" || deposits[1].Confirmations != 1 || deposits[1].Status != database.DepositPending || deposits[1].ConfirmHeight != 3 {
+		t.Fatalf("deposits %+v", deposits)
+	}
+	if pending := query(database.V3DepositFilter{Status: database.DepositPending}, 2); len(pending) != 1 || pending[0].Hash != "0x03" {
+		t.Fatalf("pending %+v", pending)
+	}
+	if confirmed := query(database.V3DepositFilter{Status: database.DepositConfirmed, Contract: token}, 3); len(confirmed) != 1 || confirmed[0].Value != "7" {
+		t.Fatalf("confirmed %+v", confirmed)
+	}
+
+	// 重建同一高度不产生重复的充值
+	saveTestBlock(t, m, 2, true, now, nil, payments[2])
+	if deposits := query(database.V3DepositFilter{}, 2); len(deposits) != 2 {
+		t.Fatalf("reindexed %+v", deposits)
+	}
+
+	// 回滚后高度2的充值失效，高度1的确认数随同步高度回退
+	if err := m.RollbackV3(1); err != nil {
+		t.Fatal(err)
+	}
+	deposits = query(database.V3DepositFilter{}, 1)
+	if len(deposits) != 2 || deposits[0].Status != database.DepositPending || deposits[0].Confirmations != 1 ||
+		deposits[1].Status != database.DepositInvalidated || deposits[1].Confirmations != 0 {
+		t.Fatalf("rolled back %+v", deposits)
+	}
+	if invalidated := query(database.V3DepositFilter{Status: database.DepositInvalidated}, 1); len(invalidated) != 1 || invalidated[0].Hash != "0x03" {
+		t.Fatalf("invalidated %+v", invalidated)
+	}
+
+	if ok, err := m.DeleteV3DepositAddress(alice); err != nil || !ok {
+		t.Fatalf("delete %v %v", ok, err)
+	}
+	if addr, err := m.QueryV3DepositAddress(alice); err != nil || addr != nil {
+		t.Fatalf("deleted address %+v %v", addr, err)
+	}
+}
@@ -1,9 +1,15 @@
 package datamanager
 
 import (
+	"errors"
+	"fmt"
+
 	"github.com/toolglobal/api/database"
 )
 
+// ErrHeightIndexed 该高度的ledger已存在，通常是另一个同步实例写入了同一个库
+var ErrHeightIndexed = errors.New("height already indexed")
+
 func (m *DataManager) AddV3Ledger(data *database.V3Ledger) (uint64, error) {
 	if m.qNeedLock {
 		m.qLock.Lock()
@@ -19,10 +25,13 @@ func (m *DataManager) AddV3Ledger(data *database.V3Ledger) (uint64, error) {
 		database.Feild{Name: "gasLimit", Value: data.GasLimit},
 		database.Feild{Name: "gasUsed", Value: data.GasUsed},
 		database.Feild{Name: "gasPrice", Value: data.GasPrice},
-		database.Feild{Name: "createdAt", Value: data.CreatedAt.Unix()},
+		database.Feild{Name: "createdAt", Value: data.CreatedAt},
 	}
 
 	sqlRes, err := m.wdb.Insert(database.TableV3Ledgers, fields)
+	if errors.Is(err, database.ErrDuplicate) {
+		return 0, fmt.Errorf("%w: %d", ErrHeightIndexed, data.Height)
+	}
 	if err != nil {
 		return 0, err
 	}
@@ -35,6 +44,31 @@ func (m *DataManager) AddV3Ledger(data *database.V3Ledger) (uint64, error) {
 	return uint64(id), nil
 }
 
+// UpdateV3Ledger 按高度更新ledger
+func (m *DataManager) UpdateV3Ledger(data *database.V3Ledger) error {
+	if m.qNeedLock {
+		m.qLock.Lock()
+		defer m.qLock.Unlock()
+	}
+
+	fields := []database.Feild{
+		database.Feild{Name: "blockHash", Value: data.BlockHash},
+		database.Feild{Name: "blockSize", Value: data.BlockSize},
+		database.Feild{Name: "validator", Value: data.Validator},
+		database.Feild{Name: "txCount", Value: data.TxCount},
+		database.Feild{Name: "gasLimit", Value: data.GasLimit},
+		database.Feild{Name: "gasUsed", Value: data.GasUsed},
+		database.Feild{Name: "gasPrice", Value: data.GasPrice},
+		database.Feild{Name: "createdAt", Value: data.CreatedAt},
+	}
+	where := []database.Where{
+		database.Where{Name: "height", Value: data.Height},
+	}
+
+	_, err := m.wdb.Update(database.TableV3Ledgers, fields, where)
+	return err
+}
+
 func (m *DataManager) QueryV3Ledger(height int64) (*database.V3Ledger, error) {
 	if m.qNeedLock {
 		m.qLock.Lock()
@@ -58,7 +92,56 @@ func (m *DataManager) QueryV3Ledger(height int64) (*database.V3Ledger, error) {
 	return &result[0], nil
 }
 
-func (m *DataManager) QueryV3AllLedger(begin, end uint64, cursor, limit uint64, order string) ([]database.V3Ledger, error) {
+// QueryV3LedgerByHash 根据区块hash查询，不存在时返回nil
+func (m *DataManager) QueryV3LedgerByHash(blockHash string) (*database.V3Ledger, error) {
+	if m.qNeedLock {
+		m.qLock.Lock()
+		defer m.qLock.Unlock()
+	}
+
+	where := []database.Where{
+		database.Where{Name: "blockHash", Value: blockHash},
+	}
+
+	var result []database.V3Ledger
+	err := m.rdb.SelectRows(database.TableV3Ledgers, where, nil, nil, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result) == 0 {
+		return nil, nil
+	}
+
+	return &result[0], nil
+}
+
+// QueryV3LedgersByHeights 批量查询多个高度的区块，按高度升序
+func (m *DataManager) QueryV3LedgersByHeights(heights []int64) ([]database.V3Ledger, error) {
+	if m.qNeedLock {
+		m.qLock.Lock()
+		defer m.qLock.Unlock()
+	}
+
+	where := []database.Where{
+		database.Where{Name: "height", Value: heights, Op: database.OpIn},
+	}
+
+	orderT, err := database.MakeOrder("ASC", "height")
+	if err != nil {
+		return nil, err
+	}
+
+	var result []database.V3Ledger
+	err = m.rdb.SelectRows(database.TableV3Ledgers, where, orderT, nil, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (m *DataManager) QueryV3AllLedger(begin, end uint64, paging *database.Paging, order string) ([]database.V3Ledger, error) {
 	if m.qNeedLock {
 		m.qLock.Lock()
 		defer m.qLock.Unlock()
@@ -68,10 +151,10 @@ func (m *DataManager) QueryV3AllLedger(begin, end uint64, cursor, limit uint64,
 		database.Where{Name: "1", Value: 1},
 	}
 	if begin != 0 {
-		where = append(where, database.Where{Name: "createdAt", Value: begin, Op: ">="})
+		where = append(where, database.Where{Name: "createdAt", Value: unixTime(begin), Op: ">="})
 	}
 	if end != 0 {
-		where = append(where, database.Where{Name: "createdAt", Value: end, Op: "<"})
+		where = append(where, database.Where{Name: "createdAt", Value: unixTime(end), Op: "<"})
 	}
 	// 区块轮询时适用
 	//if order == "ASC" || order == "asc" {
@@ -83,7 +166,6 @@ func (m *DataManager) QueryV3AllLedger(begin, end uint64, cursor, limit uint64,
 	if err != nil {
 		return nil, err
 	}
-	paging := database.MakePaging("id", cursor, limit)
 
 	var result []database.V3Ledger
 	err = m.rdb.SelectRows(database.TableV3Ledgers, where, orderT, paging, &result)
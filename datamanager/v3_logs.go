@@ -0,0 +1,89 @@
+package datamanager
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/toolglobal/api/database"
+)
+
+func (m *DataManager) PrepareV3Log() (*sql.Stmt, error) {
+	if m.qNeedLock {
+		m.qLock.Lock()
+		defer m.qLock.Unlock()
+	}
+
+	return m.wdb.Prepare(database.TableV3Logs, v3LogFields(&database.V3Log{}))
+}
+
+func (m *DataManager) AddV3LogStmt(stmt *sql.Stmt, data *database.V3Log) error {
+	if m.qNeedLock {
+		m.qLock.Lock()
+		defer m.qLock.Unlock()
+	}
+
+	_, err := m.wdb.Excute(stmt, v3LogFields(data))
+	return err
+}
+
+func v3LogFields(data *database.V3Log) []database.Feild {
+	return []database.Feild{
+		database.Feild{Name: "hash", Value: data.Hash},
+		database.Feild{Name: "height", Value: data.Height},
+		database.Feild{Name: "txIdx", Value: data.TxIdx},
+		database.Feild{Name: "logIdx", Value: data.LogIdx},
+		database.Feild{Name: "address", Value: data.Address},
+		database.Feild{Name: "topic0", Value: data.Topic0},
+		database.Feild{Name: "topic1", Value: data.Topic1},
+		database.Feild{Name: "topic2", Value: data.Topic2},
+		database.Feild{Name: "topic3", Value: data.Topic3},
+		database.Feild{Name: "data", Value: data.Data},
+		database.Feild{Name: "createdAt", Value: data.CreatedAt},
+	}
+}
+
+// QueryV3Logs 按filter查询日志
+func (m *DataManager) QueryV3Logs(filter *database.V3LogFilter, paging *database.Paging, order string) ([]database.V3Log, error) {
+	if m.qNeedLock {
+		m.qLock.Lock()
+		defer m.qLock.Unlock()
+	}
+
+	if len(filter.Topics) > 4 {
+		return nil, fmt.Errorf("too many topics: %d", len(filter.Topics))
+	}
+
+	where := []database.Where{
+		database.Where{Name: "1", Value: 1},
+	}
+	if filter.Hash != "" {
+		where = append(where, database.Where{Name: "hash", Value: filter.Hash})
+	}
+	if filter.FromHeight != 0 {
+		where = append(where, database.Where{Name: "height", Value: filter.FromHeight, Op: ">="})
+	}
+	if filter.ToHeight != 0 {
+		where = append(where, database.Where{Name: "height", Value: filter.ToHeight, Op: "<="})
+	}
+	if len(filter.Addresses) > 0 {
+		where = append(where, database.Where{Name: "address", Value: filter.Addresses, Op: database.OpIn})
+	}
+	for i, topics := range filter.Topics {
+		if len(topics) > 0 {
+			where = append(where, database.Where{Name: fmt.Sprintf("topic%d", i), Value: topics, Op: database.OpIn})
+		}
+	}
+
+	orderT, err := database.MakeOrder(order, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	var result []database.V3Log
+	err = m.rdb.SelectRows(database.TableV3Logs, where, orderT, paging, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,102 @@
+package datamanager
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/toolglobal/api/database"
+)
+
+func TestDataManager_QueryV3Logs(t *testing.T) {
+	m := newTestDataManager(t)
+
+	const (
+		token    = "0x1000000000000000000000000000000000000001"
+		nft      = "0x2000000000000000000000000000000000000002"
+		transfer = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+		approval = "0x8c5be1e5ebec7d5bd14f71427d1e84f3dd0314c0f7b2291e5b200ac8c7c3b925"
+		alice    = "0x000000000000000000000000000000000000000000000000000000000000a11c"
+		bob      = "0x0000000000000000000000000000000000000000000000000000000000000b0b"
+	)
+	now := time.Unix(1600000000, 0)
+	logs := []database.V3Log{
+		{Hash: "0x01", Height: 1, LogIdx: 0, Address: token, Topic0: transfer, Topic1: alice, Topic2: bob},
+		{Hash: "0x01", Height: 1, LogIdx: 1, Address: token, Topic0: approval, Topic1: alice, Topic2: bob},
+		{Hash: "0x02", Height: 2, LogIdx: 0, Address: nft, Topic0: transfer, Topic1: bob, Topic2: alice, Topic3: "0x01"},
+		{Hash: "0x03", Height: 3, LogIdx: 0, Address: token, Topic0: transfer, Topic1: bob, Topic2: alice},
+	}
+	for h := int64(1); h <= 3; h++ {
+		batch, err := m.BeginV3Batch()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := batch.AddLedger(&database.V3Ledger{Height: h, BlockHash: "AAA", CreatedAt: now}); err != nil {
+			t.Fatal(err)
+		}
+		for i := range logs {
+			if logs[i].Height != h {
+				continue
+			}
+			l := logs[i]
+			l.Data = "0x"
+			l.CreatedAt = now
+			if err := batch.AddLog(&l); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := batch.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		name   string
+		filter database.V3LogFilter
+		want   []string
+	}{
+		{"all", database.V3LogFilter{}, []string{"0x01/0", "0x01/1", "0x02/0", "0x03/0"}},
+		{"hash", database.V3LogFilter{Hash: "0x01"}, []string{"0x01/0", "0x01/1"}},
+		{"height range", database.V3LogFilter{FromHeight: 2, ToHeight: 2}, []string{"0x02/0"}},
+		{"address", database.V3LogFilter{Addresses: []string{nft}}, []string{"0x02/0"}},
+		{"addresses", database.V3LogFilter{Addresses: []string{token, nft}, FromHeight: 2}, []string{"0x02/0", "0x03/0"}},
+		{"topic0", database.V3LogFilter{Topics: [][]string{{approval}}}, []string{"0x01/1"}},
+		{"topic0 or", database.V3LogFilter{Topics: [][]string{{transfer, approval}}, ToHeight: 1}, []string{"0x01/0", "0x01/1"}},
+		{"topic2 any topic0", database.V3LogFilter{Topics: [][]string{nil, nil, {alice}}}, []string{"0x02/0", "0x03/0"}},
+		{"address and topics", database.V3LogFilter{Addresses: []string{token}, Topics: [][]string{{transfer}, {bob}}}, []string{"0x03/0"}},
+	}
+	order := "ASC"
+	for _, c := range cases {
+		result, err := m.QueryV3Logs(&c.filter, database.MakeKeysetPaging("id", 0, 10), order)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, l := range result {
+			got = append(got, fmt.Sprintf("%s/%d", l.Hash, l.LogIdx))
+		}
+		if len(got) != len(c.want) {
+			t.Fatalf("%s: got %v, want %v", c.name, got, c.want)
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Fatalf("%s: got %v, want %v", c.name, got, c.want)
+			}
+		}
+	}
+
+	if _, err := m.QueryV3Logs(&database.V3LogFilter{Topics: make([][]string, 5)}, database.MakeKeysetPaging("id", 0, 10), order); err == nil {
+		t.Fatal("want error for 5 topics")
+	}
+
+	if err := m.RollbackV3(1); err != nil {
+		t.Fatal(err)
+	}
+	result, err := m.QueryV3Logs(&database.V3LogFilter{}, database.MakeKeysetPaging("id", 0, 10), order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 2 || result[1].Height != 1 {
+		t.Fatalf("logs after rollback %+v", result)
+	}
+}
@@ -0,0 +1,131 @@
+package datamanager
+
+import (
+	"database/sql"
+
+	"github.com/toolglobal/api/database"
+)
+
+func (m *DataManager) PrepareV3NFTTransfer() (*sql.Stmt, error) {
+	if m.qNeedLock {
+		m.qLock.Lock()
+		defer m.qLock.Unlock()
+	}
+
+	return m.wdb.Prepare(database.TableV3NFTTransfers, v3NFTTransferFields(&database.V3NFTTransfer{}))
+}
+
+func (m *DataManager) AddV3NFTTransferStmt(stmt *sql.Stmt, data *database.V3NFTTransfer) error {
+	if m.qNeedLock {
+		m.qLock.Lock()
+		defer m.qLock.Unlock()
+	}
+
+	_, err := m.wdb.Excute(stmt, v3NFTTransferFields(data))
+	return err
+}
+
+func (m *DataManager) AddV3NFTTransfer(data *database.V3NFTTransfer) (uint64, error) {
+	if m.qNeedLock {
+		m.qLock.Lock()
+		defer m.qLock.Unlock()
+	}
+
+	sqlRes, err := m.wdb.Insert(database.TableV3NFTTransfers, v3NFTTransferFields(data))
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := sqlRes.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return uint64(id), nil
+}
+
+func v3NFTTransferFields(data *database.V3NFTTransfer) []database.Feild {
+	return []database.Feild{
+		database.Feild{Name: "hash", Value: data.Hash},
+		database.Feild{Name: "height", Value: data.Height},
+		database.Feild{Name: "idx", Value: data.Idx},
+		database.Feild{Name: "batchIdx", Value: data.BatchIdx},
+		database.Feild{Name: "standard", Value: data.Standard},
+		database.Feild{Name: "contract", Value: data.Contract},
+		database.Feild{Name: "operator", Value: data.Operator},
+		database.Feild{Name: "sender", Value: data.Sender},
+		database.Feild{Name: "receiver", Value: data.Receiver},
+		database.Feild{Name: "tokenId", Value: data.TokenId},
+		database.Feild{Name: "value", Value: data.Value},
+		database.Feild{Name: "createdAt", Value: data.CreatedAt},
+	}
+}
+
+// QueryV3NFTTransfers 查询NFT转账，address不为空时查询该地址转出或转入的记录，tokenId需要同时指定contract
+func (m *DataManager) QueryV3NFTTransfers(address, contract, tokenId string, begin, end uint64, paging *database.Paging, order string) ([]database.V3NFTTransfer, error) {
+	if m.qNeedLock {
+		m.qLock.Lock()
+		defer m.qLock.Unlock()
+	}
+
+	where := []database.Where{
+		database.Where{Name: "1", Value: 1},
+	}
+	if begin != 0 {
+		where = append(where, database.Where{Name: "createdAt", Value: unixTime(begin), Op: ">="})
+	}
+	if end != 0 {
+		where = append(where, database.Where{Name: "createdAt", Value: unixTime(end), Op: "<"})
+	}
+	if contract != "" {
+		where = append(where, database.Where{Name: "contract", Value: contract})
+		if tokenId != "" {
+			where = append(where, database.Where{Name: "tokenId", Value: tokenId})
+		}
+	}
+
+	orderT, err := database.MakeOrder(order, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	var result []database.V3NFTTransfer
+	if address == "" {
+		err = m.rdb.SelectRows(database.TableV3NFTTransfers, where, orderT, paging, &result)
+	} else {
+		wheres := [][]database.Where{
+			append(where[:len(where):len(where)], database.Where{Name: "sender", Value: address}),
+			append(where[:len(where):len(where)], database.Where{Name: "receiver", Value: address}),
+		}
+		err = m.rdb.SelectRowsUnion(database.TableV3NFTTransfers, wheres, orderT, paging, &result)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (m *DataManager) QueryV3NFTTransfersByHeight(height int64, paging *database.Paging, order string) ([]database.V3NFTTransfer, error) {
+	if m.qNeedLock {
+		m.qLock.Lock()
+		defer m.qLock.Unlock()
+	}
+
+	where := []database.Where{
+		database.Where{Name: "height", Value: height},
+	}
+
+	orderT, err := database.MakeOrder(order, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	var result []database.V3NFTTransfer
+	err = m.rdb.SelectRows(database.TableV3NFTTransfers, where, orderT, paging, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -41,7 +41,7 @@ func (m *DataManager) AddV3PaymentStmt(stmt *sql.Stmt, data *database.V3Payment)
 		database.Feild{Name: "symbol", Value: data.Symbol},
 		database.Feild{Name: "contract", Value: data.Contract},
 		database.Feild{Name: "value", Value: data.Value},
-		database.Feild{Name: "createdAt", Value: data.CreatedAt.Unix()},
+		database.Feild{Name: "createdAt", Value: data.CreatedAt},
 	}
 	_, err = m.wdb.Excute(stmt, fields)
 
@@ -64,7 +64,7 @@ func (m *DataManager) AddV3Payment(data *database.V3Payment) (uint64, error) {
 		database.Feild{Name: "symbol", Value: data.Symbol},
 		database.Feild{Name: "contract", Value: data.Contract},
 		database.Feild{Name: "value", Value: data.Value},
-		database.Feild{Name: "createdAt", Value: data.CreatedAt.Unix()},
+		database.Feild{Name: "createdAt", Value: data.CreatedAt},
 	}
 
 	sqlRes, err := m.wdb.Insert(database.TableV3Payments, fields)
@@ -80,7 +80,7 @@ func (m *DataManager) AddV3Payment(data *database.V3Payment) (uint64, error) {
 	return uint64(id), nil
 }
 
-func (m *DataManager) QueryV3PaymentsByAddress(address, symbol, contract string, begin, end uint64, cursor, limit uint64, order string) ([]database.V3Payment, error) {
+func (m *DataManager) QueryV3PaymentsByAddress(address, symbol, contract string, begin, end uint64, paging *database.Paging, order string) ([]database.V3Payment, error) {
 	if m.qNeedLock {
 		m.qLock.Lock()
 		defer m.qLock.Unlock()
@@ -93,10 +93,10 @@ func (m *DataManager) QueryV3PaymentsByAddress(address, symbol, contract string,
 	}
 	where1 = append(where1, database.Where{Name: "sender", Value: address})
 	if begin != 0 {
-		where1 = append(where1, database.Where{Name: "createdAt", Value: begin, Op: ">="})
+		where1 = append(where1, database.Where{Name: "createdAt", Value: unixTime(begin), Op: ">="})
 	}
 	if end != 0 {
-		where1 = append(where1, database.Where{Name: "createdAt", Value: end, Op: "<"})
+		where1 = append(where1, database.Where{Name: "createdAt", Value: unixTime(end), Op: "<"})
 	}
 	if symbol != "" {
 		where1 = append(where1, database.Where{Name: "symbol", Value: symbol})
@@ -111,10 +111,10 @@ func (m *DataManager) QueryV3PaymentsByAddress(address, symbol, contract string,
 		database.Where{Name: "1", Value: 1},
 	}
 	if begin != 0 {
-		where2 = append(where2, database.Where{Name: "createdAt", Value: begin, Op: ">="})
+		where2 = append(where2, database.Where{Name: "createdAt", Value: unixTime(begin), Op: ">="})
 	}
 	if end != 0 {
-		where2 = append(where2, database.Where{Name: "createdAt", Value: end, Op: "<"})
+		where2 = append(where2, database.Where{Name: "createdAt", Value: unixTime(end), Op: "<"})
 	}
 	if symbol != "" {
 		where2 = append(where2, database.Where{Name: "symbol", Value: symbol})
@@ -127,7 +127,6 @@ func (m *DataManager) QueryV3PaymentsByAddress(address, symbol, contract string,
 	if err != nil {
 		return nil, err
 	}
-	paging := database.MakePaging("id", cursor, limit)
 
 	var result []database.V3Payment
 	err = m.rdb.SelectRowsUnion(database.TableV3Payments, wheres, orderT, paging, &result)
@@ -138,7 +137,7 @@ func (m *DataManager) QueryV3PaymentsByAddress(address, symbol, contract string,
 	return result, nil
 }
 
-func (m *DataManager) QueryV3PaymentsByHash(hash, symbol, contract string, begin, end uint64, cursor, limit uint64, order string) ([]database.V3Payment, error) {
+func (m *DataManager) QueryV3PaymentsByHash(hash, symbol, contract string, begin, end uint64, paging *database.Paging, order string) ([]database.V3Payment, error) {
 	if m.qNeedLock {
 		m.qLock.Lock()
 		defer m.qLock.Unlock()
@@ -149,10 +148,10 @@ func (m *DataManager) QueryV3PaymentsByHash(hash, symbol, contract string, begin
 	}
 	where = append(where, database.Where{Name: "hash", Value: hash, Op: "="})
 	if begin != 0 {
-		where = append(where, database.Where{Name: "createdAt", Value: begin, Op: ">="})
+		where = append(where, database.Where{Name: "createdAt", Value: unixTime(begin), Op: ">="})
 	}
 	if end != 0 {
-		where = append(where, database.Where{Name: "createdAt", Value: end, Op: "<"})
+		where = append(where, database.Where{Name: "createdAt", Value: unixTime(end), Op: "<"})
 	}
 	if symbol != "" {
 		where = append(where, database.Where{Name: "symbol", Value: symbol})
@@ -169,7 +168,6 @@ func (m *DataManager) QueryV3PaymentsByHash(hash, symbol, contract string, begin
 	if err != nil {
 		return nil, err
 	}
-	paging := database.MakePaging("id", cursor, limit)
 
 	var result []database.V3Payment
 	err = m.rdb.SelectRows(database.TableV3Payments, where, orderT, paging, &result)
@@ -180,7 +178,57 @@ func (m *DataManager) QueryV3PaymentsByHash(hash, symbol, contract string, begin
 	return result, nil
 }
 
-func (m *DataManager) QueryV3PaymentsByHeight(height int64, symbol, contract string, begin, end uint64, cursor, limit uint64, order string) ([]database.V3Payment, error) {
+// QueryV3PaymentsByHeights 批量查询多个区块的全部转账，按id升序
+func (m *DataManager) QueryV3PaymentsByHeights(heights []int64) ([]database.V3Payment, error) {
+	if m.qNeedLock {
+		m.qLock.Lock()
+		defer m.qLock.Unlock()
+	}
+
+	where := []database.Where{
+		database.Where{Name: "height", Value: heights, Op: database.OpIn},
+	}
+
+	orderT, err := database.MakeOrder("ASC", "id")
+	if err != nil {
+		return nil, err
+	}
+
+	var result []database.V3Payment
+	err = m.rdb.SelectRows(database.TableV3Payments, where, orderT, nil, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// QueryV3PaymentsByHashes 批量查询多个交易的全部转账，按id升序
+func (m *DataManager) QueryV3PaymentsByHashes(hashes []string) ([]database.V3Payment, error) {
+	if m.qNeedLock {
+		m.qLock.Lock()
+		defer m.qLock.Unlock()
+	}
+
+	where := []database.Where{
+		database.Where{Name: "hash", Value: hashes, Op: database.OpIn},
+	}
+
+	orderT, err := database.MakeOrder("ASC", "id")
+	if err != nil {
+		return nil, err
+	}
+
+	var result []database.V3Payment
+	err = m.rdb.SelectRows(database.TableV3Payments, where, orderT, nil, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (m *DataManager) QueryV3PaymentsByHeight(height int64, symbol, contract string, begin, end uint64, paging *database.Paging, order string) ([]database.V3Payment, error) {
 	if m.qNeedLock {
 		m.qLock.Lock()
 		defer m.qLock.Unlock()
@@ -191,10 +239,10 @@ func (m *DataManager) QueryV3PaymentsByHeight(height int64, symbol, contract str
 	}
 	where = append(where, database.Where{Name: "height", Value: height, Op: "="})
 	if begin != 0 {
-		where = append(where, database.Where{Name: "createdAt", Value: begin, Op: ">="})
+		where = append(where, database.Where{Name: "createdAt", Value: unixTime(begin), Op: ">="})
 	}
 	if end != 0 {
-		where = append(where, database.Where{Name: "createdAt", Value: end, Op: "<"})
+		where = append(where, database.Where{Name: "createdAt", Value: unixTime(end), Op: "<"})
 	}
 	if symbol != "" {
 		where = append(where, database.Where{Name: "symbol", Value: symbol})
@@ -212,7 +260,6 @@ func (m *DataManager) QueryV3PaymentsByHeight(height int64, symbol, contract str
 	if err != nil {
 		return nil, err
 	}
-	paging := database.MakePaging("id", cursor, limit)
 
 	var result []database.V3Payment
 	err = m.rdb.SelectRows(database.TableV3Payments, where, orderT, paging, &result)
@@ -223,7 +270,7 @@ func (m *DataManager) QueryV3PaymentsByHeight(height int64, symbol, contract str
 	return result, nil
 }
 
-func (m *DataManager) QueryV3AllPayments(symbol, contract string, begin, end uint64, cursor, limit uint64, order string) ([]database.V3Payment, error) {
+func (m *DataManager) QueryV3AllPayments(symbol, contract string, begin, end uint64, paging *database.Paging, order string) ([]database.V3Payment, error) {
 	if m.qNeedLock {
 		m.qLock.Lock()
 		defer m.qLock.Unlock()
@@ -234,10 +281,10 @@ func (m *DataManager) QueryV3AllPayments(symbol, contract string, begin, end uin
 	}
 
 	if begin != 0 {
-		where = append(where, database.Where{Name: "createdAt", Value: begin, Op: ">="})
+		where = append(where, database.Where{Name: "createdAt", Value: unixTime(begin), Op: ">="})
 	}
 	if end != 0 {
-		where = append(where, database.Where{Name: "createdAt", Value: end, Op: "<"})
+		where = append(where, database.Where{Name: "createdAt", Value: unixTime(end), Op: "<"})
 	}
 
 	if symbol != "" {
@@ -256,7 +303,6 @@ func (m *DataManager) QueryV3AllPayments(symbol, contract string, begin, end uin
 	if err != nil {
 		return nil, err
 	}
-	paging := database.MakePaging("id", cursor, limit)
 
 	var result []database.V3Payment
 	err = m.rdb.SelectRows(database.TableV3Payments, where, orderT, paging, &result)
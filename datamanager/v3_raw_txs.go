@@ -0,0 +1,72 @@
+package datamanager
+
+import (
+	"database/sql"
+
+	"github.com/toolglobal/api/database"
+)
+
+func (m *DataManager) PrepareV3RawTx() (*sql.Stmt, error) {
+	if m.qNeedLock {
+		m.qLock.Lock()
+		defer m.qLock.Unlock()
+	}
+
+	return m.wdb.Prepare(database.TableV3RawTxs, v3RawTxFields(&database.V3RawTx{}))
+}
+
+func (m *DataManager) AddV3RawTxStmt(stmt *sql.Stmt, data *database.V3RawTx) error {
+	if m.qNeedLock {
+		m.qLock.Lock()
+		defer m.qLock.Unlock()
+	}
+
+	_, err := m.wdb.Excute(stmt, v3RawTxFields(data))
+	return err
+}
+
+func v3RawTxFields(data *database.V3RawTx) []database.Feild {
+	return []database.Feild{
+		database.Feild{Name: "height", Value: data.Height},
+		database.Feild{Name: "txIdx", Value: data.TxIdx},
+		database.Feild{Name: "hash", Value: data.Hash},
+		database.Feild{Name: "tag", Value: data.Tag},
+		database.Feild{Name: "raw", Value: data.Raw},
+		database.Feild{Name: "error", Value: data.Error},
+		database.Feild{Name: "createdAt", Value: data.CreatedAt},
+	}
+}
+
+// QueryV3RawTxs 按filter查询无法解析的交易
+func (m *DataManager) QueryV3RawTxs(filter *database.V3RawTxFilter, paging *database.Paging, order string) ([]database.V3RawTx, error) {
+	if m.qNeedLock {
+		m.qLock.Lock()
+		defer m.qLock.Unlock()
+	}
+
+	where := []database.Where{
+		database.Where{Name: "1", Value: 1},
+	}
+	if filter.FromHeight != 0 {
+		where = append(where, database.Where{Name: "height", Value: filter.FromHeight, Op: ">="})
+	}
+	if filter.ToHeight != 0 {
+		where = append(where, database.Where{Name: "height", Value: filter.ToHeight, Op: "<="})
+	}
+	if filter.Tag != "" {
+		where = append(where, database.Where{Name: "tag", Value: filter.Tag})
+	}
+
+	orderT, err := database.MakeOrder(order, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	var result []database.V3RawTx
+	err = m.rdb.SelectRows(database.TableV3RawTxs, where, orderT, paging, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,96 @@
+package datamanager
+
+import (
+	"github.com/toolglobal/api/database"
+)
+
+// v3HeightTables 按区块高度写入的表（不含v3_ledgers），回滚和重建区块时一起删除
+var v3HeightTables = []string{
+	database.TableV3Payments,
+	database.TableV3NFTTransfers,
+	database.TableV3Approvals,
+	database.TableV3BalanceHistory,
+	database.TableV3Contracts,
+	database.TableV3Logs,
+	database.TableV3RawTxs,
+	database.TableV3Transactions,
+}
+
+// RollbackV3 删除高度大于height的区块数据，撤销其对余额的影响、把其中的充值标记为已回滚并回退同步进度，在同一个数据库事务中完成
+func (m *DataManager) RollbackV3(height int64) (err error) {
+	if m.qNeedLock {
+		m.qLock.Lock()
+		defer m.qLock.Unlock()
+	}
+
+	if err = m.wdb.Begin(); err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			m.wdb.Rollback()
+		}
+	}()
+
+	where := []database.Where{
+		database.Where{Name: "height", Value: height, Op: ">"},
+	}
+	payments, err := m.tokenPayments(where[0])
+	if err != nil {
+		return err
+	}
+	for _, table := range append(v3HeightTables, database.TableV3Ledgers) {
+		if _, err = m.wdb.Delete(table, where); err != nil {
+			return err
+		}
+	}
+	if err = m.revertV3Balances(payments); err != nil {
+		return err
+	}
+	if err = m.rollbackSyncState(height); err != nil {
+		return err
+	}
+	if err = m.invalidateV3Deposits(height); err != nil {
+		return err
+	}
+	// 回滚的区块尚未推送的webhook不再推送，推送进度随sync_state回退后按新的区块重新匹配
+	pending := []database.Where{
+		where[0],
+		database.Where{Name: "status", Value: database.WebhookPending},
+	}
+	if _, err = m.wdb.Delete(database.TableV3WebhookDeliveries, pending); err != nil {
+		return err
+	}
+
+	return m.wdb.Commit()
+}
+
+// DeleteV3Height 删除某一高度除ledger外的数据并撤销其对余额的影响，在wdb当前事务中执行
+func (m *DataManager) DeleteV3Height(height int64) error {
+	if m.qNeedLock {
+		m.qLock.Lock()
+		defer m.qLock.Unlock()
+	}
+
+	where := []database.Where{
+		database.Where{Name: "height", Value: height},
+	}
+	payments, err := m.tokenPayments(where[0])
+	if err != nil {
+		return err
+	}
+	for _, table := range v3HeightTables {
+		if _, err := m.wdb.Delete(table, where); err != nil {
+			return err
+		}
+	}
+	// 重建的是同一个区块，充值随payment重新写入；已回滚的保留
+	deposits := []database.Where{
+		where[0],
+		database.Where{Name: "invalidated", Value: false},
+	}
+	if _, err := m.wdb.Delete(database.TableV3Deposits, deposits); err != nil {
+		return err
+	}
+	return m.revertV3Balances(payments)
+}
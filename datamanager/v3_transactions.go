@@ -1,7 +1,11 @@
 package datamanager
 
 import (
+	"bytes"
 	"database/sql"
+	"errors"
+	"fmt"
+
 	"github.com/toolglobal/api/database"
 )
 
@@ -28,6 +32,8 @@ func (m *DataManager) PrepareV3Transaction() (*sql.Stmt, error) {
 		database.Feild{Name: "codei"},
 		database.Feild{Name: "codes"},
 		database.Feild{Name: "createdAt"},
+		database.Feild{Name: "txIdx"},
+		database.Feild{Name: "rawTx"},
 	}
 
 	return m.wdb.Prepare(database.TableV3Transactions, fields)
@@ -56,7 +62,9 @@ func (m *DataManager) AddV3TransactionStmt(stmt *sql.Stmt, data *database.V3Tran
 		database.Feild{Name: "events", Value: data.Events},
 		database.Feild{Name: "codei", Value: data.Codei},
 		database.Feild{Name: "codes", Value: data.Codes},
-		database.Feild{Name: "createdAt", Value: data.CreatedAt.Unix()},
+		database.Feild{Name: "createdAt", Value: data.CreatedAt},
+		database.Feild{Name: "txIdx", Value: data.TxIdx},
+		database.Feild{Name: "rawTx", Value: data.RawTx},
 	}
 	_, err = m.wdb.Excute(stmt, fields)
 	return err
@@ -85,7 +93,9 @@ func (m *DataManager) AddV3Transaction(data *database.V3Transaction) (uint64, er
 		database.Feild{Name: "events", Value: data.Events},
 		database.Feild{Name: "codei", Value: data.Codei},
 		database.Feild{Name: "codes", Value: data.Codes},
-		database.Feild{Name: "createdAt", Value: data.CreatedAt.Unix()},
+		database.Feild{Name: "createdAt", Value: data.CreatedAt},
+		database.Feild{Name: "txIdx", Value: data.TxIdx},
+		database.Feild{Name: "rawTx", Value: data.RawTx},
 	}
 
 	sqlRes, err := m.wdb.Insert(database.TableV3Transactions, fields)
@@ -124,33 +134,120 @@ func (m *DataManager) QueryV3SingleTx(hash string) (*database.V3Transaction, err
 	return &result[0], nil
 }
 
-func (m *DataManager) QueryV3AccountTxs(address string, begin, end uint64, cursor, limit uint64, order string) ([]database.V3Transaction, error) {
+// QueryV3TxsByHashes 批量查询多个hash的交易，按id升序
+func (m *DataManager) QueryV3TxsByHashes(hashes []string) ([]database.V3Transaction, error) {
 	if m.qNeedLock {
 		m.qLock.Lock()
 		defer m.qLock.Unlock()
 	}
 
 	where := []database.Where{
-		database.Where{Name: "1", Value: 1},
+		database.Where{Name: "hash", Value: hashes, Op: database.OpIn},
 	}
-	if address != "" {
-		where = append(where, database.Where{Name: "sender", Value: address})
+
+	orderT, err := database.MakeOrder("ASC", "id")
+	if err != nil {
+		return nil, err
 	}
-	if begin != 0 {
-		where = append(where, database.Where{Name: "createdAt", Value: begin, Op: ">="})
+
+	var result []database.V3Transaction
+	err = m.rdb.SelectRows(database.TableV3Transactions, where, orderT, nil, &result)
+	if err != nil {
+		return nil, err
 	}
-	if end != 0 {
-		where = append(where, database.Where{Name: "createdAt", Value: end, Op: "<"})
+
+	return result, nil
+}
+
+// QueryV3TxsByHeights 批量查询多个区块的全部交易，按id升序
+func (m *DataManager) QueryV3TxsByHeights(heights []int64) ([]database.V3Transaction, error) {
+	if m.qNeedLock {
+		m.qLock.Lock()
+		defer m.qLock.Unlock()
+	}
+
+	where := []database.Where{
+		database.Where{Name: "height", Value: heights, Op: database.OpIn},
+	}
+
+	orderT, err := database.MakeOrder("ASC", "id")
+	if err != nil {
+		return nil, err
+	}
+
+	var result []database.V3Transaction
+	err = m.rdb.SelectRows(database.TableV3Transactions, where, orderT, nil, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// 账户交易的方向
+const (
+	DirectionIn  = "in"  // 转入：交易接收方，或批量交易中的收款方
+	DirectionOut = "out" // 转出：交易发起方
+	DirectionAll = "all"
+)
+
+// QueryV3AccountTxs 查询账户相关的交易，direction为in/out/all，空值等同all
+func (m *DataManager) QueryV3AccountTxs(address, direction string, begin, end uint64, paging *database.Paging, order string) ([]database.V3Transaction, error) {
+	if m.qNeedLock {
+		m.qLock.Lock()
+		defer m.qLock.Unlock()
+	}
+
+	if direction == "" {
+		direction = DirectionAll
+	}
+	if direction != DirectionIn && direction != DirectionOut && direction != DirectionAll {
+		return nil, errors.New("invalid direction :" + direction)
 	}
 
 	orderT, err := database.MakeOrder(order, "id")
 	if err != nil {
 		return nil, err
 	}
-	paging := database.MakePaging("id", cursor, limit)
+
+	var conds []string
+	if direction != DirectionIn {
+		conds = append(conds, "sender = ?")
+	}
+	if direction != DirectionOut {
+		conds = append(conds,
+			"receiver = ?",
+			// 批量交易的receiver为空，收款方记录在payments中
+			fmt.Sprintf("hash IN (SELECT hash FROM %s WHERE receiver = ? AND evName = '')", database.TableV3Payments))
+	}
+
+	var (
+		sqlBuff bytes.Buffer
+		values  []interface{}
+	)
+	for i, cond := range conds {
+		if i > 0 {
+			sqlBuff.WriteString(" union ")
+		}
+		sqlBuff.WriteString(fmt.Sprintf("select * from %s where %s", database.TableV3Transactions, cond))
+		values = append(values, address)
+		if begin != 0 {
+			sqlBuff.WriteString(" and createdAt >= ?")
+			values = append(values, unixTime(begin))
+		}
+		if end != 0 {
+			sqlBuff.WriteString(" and createdAt < ?")
+			values = append(values, unixTime(end))
+		}
+		if w := paging.KeysetWhere(orderT); w != nil {
+			sqlBuff.WriteString(fmt.Sprintf(" and %s %s ?", w.Name, w.GetOp()))
+			values = append(values, w.Value)
+		}
+	}
+	sqlBuff.WriteString(fmt.Sprintf(" order by %s %s limit %d offset %d", orderT.Feilds[0], orderT.Type, paging.Limit, paging.Offset()))
 
 	var result []database.V3Transaction
-	err = m.rdb.SelectRows(database.TableV3Transactions, where, orderT, paging, &result)
+	err = m.rdb.SelectRawSQL(database.TableV3Transactions, sqlBuff.String(), values, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -158,7 +255,7 @@ func (m *DataManager) QueryV3AccountTxs(address string, begin, end uint64, curso
 	return result, nil
 }
 
-func (m *DataManager) QueryV3BlockTxs(height int64, begin, end uint64, cursor, limit uint64, order string) ([]database.V3Transaction, error) {
+func (m *DataManager) QueryV3BlockTxs(height int64, begin, end uint64, paging *database.Paging, order string) ([]database.V3Transaction, error) {
 	if m.qNeedLock {
 		m.qLock.Lock()
 		defer m.qLock.Unlock()
@@ -171,17 +268,16 @@ func (m *DataManager) QueryV3BlockTxs(height int64, begin, end uint64, cursor, l
 		where = append(where, database.Where{Name: "height", Value: height})
 	}
 	if begin != 0 {
-		where = append(where, database.Where{Name: "createdAt", Value: begin, Op: ">="})
+		where = append(where, database.Where{Name: "createdAt", Value: unixTime(begin), Op: ">="})
 	}
 	if end != 0 {
-		where = append(where, database.Where{Name: "createdAt", Value: end, Op: "<"})
+		where = append(where, database.Where{Name: "createdAt", Value: unixTime(end), Op: "<"})
 	}
 
 	orderT, err := database.MakeOrder(order, "id")
 	if err != nil {
 		return nil, err
 	}
-	paging := database.MakePaging("id", cursor, limit)
 
 	var result []database.V3Transaction
 	err = m.rdb.SelectRows(database.TableV3Transactions, where, orderT, paging, &result)
@@ -192,7 +288,7 @@ func (m *DataManager) QueryV3BlockTxs(height int64, begin, end uint64, cursor, l
 	return result, nil
 }
 
-func (m *DataManager) QueryV3AllTxs(begin, end uint64, cursor, limit uint64, order string) ([]database.V3Transaction, error) {
+func (m *DataManager) QueryV3AllTxs(begin, end uint64, paging *database.Paging, order string) ([]database.V3Transaction, error) {
 	if m.qNeedLock {
 		m.qLock.Lock()
 		defer m.qLock.Unlock()
@@ -202,10 +298,10 @@ func (m *DataManager) QueryV3AllTxs(begin, end uint64, cursor, limit uint64, ord
 		database.Where{Name: "1", Value: 1},
 	}
 	if begin != 0 {
-		where = append(where, database.Where{Name: "createdAt", Value: begin, Op: ">="})
+		where = append(where, database.Where{Name: "createdAt", Value: unixTime(begin), Op: ">="})
 	}
 	if end != 0 {
-		where = append(where, database.Where{Name: "createdAt", Value: end, Op: "<"})
+		where = append(where, database.Where{Name: "createdAt", Value: unixTime(end), Op: "<"})
 	}
 	//if order == "ASC" || order == "asc" {
 	//	where = append(where, database.Where{Name: "id", Value: cursor * limit, Op: ">"})
@@ -216,7 +312,6 @@ func (m *DataManager) QueryV3AllTxs(begin, end uint64, cursor, limit uint64, ord
 	if err != nil {
 		return nil, err
 	}
-	paging := database.MakePaging("id", cursor, limit)
 
 	var result []database.V3Transaction
 	err = m.rdb.SelectRows(database.TableV3Transactions, where, orderT, paging, &result)
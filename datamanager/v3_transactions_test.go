@@ -0,0 +1,178 @@
+package datamanager
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/toolglobal/api/database"
+	"github.com/toolglobal/api/database/basesql"
+	"github.com/toolglobal/api/libs/log"
+)
+
+func newTestDataManager(t *testing.T) *DataManager {
+	dir := t.TempDir()
+	m, err := NewDataManager("mondo_query.db", func(dbname string) database.Database {
+		dbi := &basesql.Basesql{}
+		if err := dbi.Init(dbname, dir, log.Logger); err != nil {
+			t.Fatal(err)
+		}
+		return dbi
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(m.Close)
+	return m
+}
+
+// saveTestBlock 在一个batch中写入height的交易和payment，createdAt同时作为ledger和数据的时间。
+// replace为true时按重建区块的方式先删除该高度的数据，保留原有ledger
+func saveTestBlock(t *testing.T, m *DataManager, height int64, replace bool, createdAt time.Time, txs []database.V3Transaction, payments []database.V3Payment) {
+	batch, err := m.BeginV3Batch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replace {
+		if err := batch.DeleteHeight(height); err != nil {
+			t.Fatal(err)
+		}
+	} else if err := batch.AddLedger(&database.V3Ledger{Height: height, BlockHash: "AAA", CreatedAt: createdAt}); err != nil {
+		t.Fatal(err)
+	}
+	for i := range txs {
+		tx := txs[i]
+		tx.CreatedAt = createdAt
+		if err := batch.AddTransaction(&tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := range payments {
+		payment := payments[i]
+		payment.CreatedAt = createdAt
+		if err := batch.AddPayment(&payment); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := batch.Commit(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDataManager_QueryV3AccountTxs(t *testing.T) {
+	m := newTestDataManager(t)
+
+	const (
+		alice = "0x00000000000000000000000000000000000A11cE"
+		bob   = "0x0000000000000000000000000000000000000B0b"
+		carol = "0x000000000000000000000000000000000000CA01"
+	)
+	now := time.Unix(1600000000, 0)
+	txs := []database.V3Transaction{
+		{Hash: "0x01", Height: 1, Types: "TxTagAppEvm", Sender: alice, Receiver: bob, CreatedAt: now},
+		{Hash: "0x02", Height: 2, Types: "TxTagEthereumTx", Sender: bob, Receiver: alice, CreatedAt: now},
+		{Hash: "0x03", Height: 3, Types: "TxTagAppEvmMultisig", Sender: carol, Receiver: alice, CreatedAt: now},
+		{Hash: "0x04", Height: 4, Types: "TxTagAppBatch", Sender: carol, Receiver: "", CreatedAt: now},
+		{Hash: "0x05", Height: 5, Types: "TxTagAppBatch", Sender: carol, Receiver: "", CreatedAt: now},
+	}
+	for i := range txs {
+		if _, err := m.AddV3Transaction(&txs[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	payments := []database.V3Payment{
+		{Hash: "0x04", Height: 4, Idx: 0, Sender: carol, Receiver: bob, CreatedAt: now},
+		{Hash: "0x04", Height: 4, Idx: 1, Sender: carol, Receiver: alice, CreatedAt: now},
+		{Hash: "0x05", Height: 5, Idx: 0, Sender: carol, Receiver: bob, CreatedAt: now},
+	}
+	for i := range payments {
+		if _, err := m.AddV3Payment(&payments[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		direction string
+		want      []string
+	}{
+		{DirectionOut, []string{"0x01"}},
+		{DirectionIn, []string{"0x04", "0x03", "0x02"}},
+		{DirectionAll, []string{"0x04", "0x03", "0x02", "0x01"}},
+		{"", []string{"0x04", "0x03", "0x02", "0x01"}},
+	}
+	for _, c := range cases {
+		result, err := m.QueryV3AccountTxs(alice, c.direction, 0, 0, database.MakePaging("id", 0, 10), "DESC")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, tx := range result {
+			got = append(got, tx.Hash)
+		}
+		if len(got) != len(c.want) {
+			t.Fatalf("direction %q got %v, want %v", c.direction, got, c.want)
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Fatalf("direction %q got %v, want %v", c.direction, got, c.want)
+			}
+		}
+	}
+
+	if _, err := m.QueryV3AccountTxs(alice, "sideways", 0, 0, database.MakePaging("id", 0, 10), "DESC"); err == nil {
+		t.Fatal("invalid direction should fail")
+	}
+}
+
+func TestDataManager_KeysetPaging(t *testing.T) {
+	const alice = "0x00000000000000000000000000000000000A11cE"
+	now := time.Unix(1600000000, 0)
+
+	queries := map[string]func(m *DataManager, paging *database.Paging) ([]database.V3Transaction, error){
+		"all": func(m *DataManager, paging *database.Paging) ([]database.V3Transaction, error) {
+			return m.QueryV3AllTxs(0, 0, paging, "DESC")
+		},
+		"account": func(m *DataManager, paging *database.Paging) ([]database.V3Transaction, error) {
+			return m.QueryV3AccountTxs(alice, DirectionAll, 0, 0, paging, "DESC")
+		},
+	}
+	for name, query := range queries {
+		m := newTestDataManager(t)
+		add := func(height int64) {
+			tx := database.V3Transaction{Hash: fmt.Sprintf("0x%02x", height), Height: height, Sender: alice, CreatedAt: now}
+			if _, err := m.AddV3Transaction(&tx); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for h := int64(1); h <= 5; h++ {
+			add(h)
+		}
+
+		first, err := query(m, database.MakeKeysetPaging("id", 0, 2))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(first) != 2 || first[0].Height != 5 || first[1].Height != 4 {
+			t.Fatalf("%s first page %+v", name, first)
+		}
+
+		// 翻页期间有新交易入库，下一页不应重复或遗漏
+		add(6)
+
+		second, err := query(m, database.MakeKeysetPaging("id", first[1].Id, 2))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(second) != 2 || second[0].Height != 3 || second[1].Height != 2 {
+			t.Fatalf("%s second page %+v", name, second)
+		}
+
+		asc, err := m.QueryV3AllTxs(0, 0, database.MakeKeysetPaging("id", 2, 2), "ASC")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(asc) != 2 || asc[0].Height != 3 || asc[1].Height != 4 {
+			t.Fatalf("asc page %+v", asc)
+		}
+	}
+}
@@ -0,0 +1,300 @@
+package datamanager
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/toolglobal/api/database"
+)
+
+// AddV3Webhook 注册webhook，返回自增id
+func (m *DataManager) AddV3Webhook(data *database.V3Webhook) (uint64, error) {
+	m.aLock.Lock()
+	defer m.aLock.Unlock()
+
+	fields := []database.Feild{
+		database.Feild{Name: "url", Value: data.URL},
+		database.Feild{Name: "secret", Value: data.Secret},
+		database.Feild{Name: "address", Value: data.Address},
+		database.Feild{Name: "contract", Value: data.Contract},
+		database.Feild{Name: "event", Value: data.Event},
+		database.Feild{Name: "createdAt", Value: data.CreatedAt},
+	}
+	res, err := m.awdb.Insert(database.TableV3Webhooks, fields)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return uint64(id), nil
+}
+
+// DeleteV3Webhook 删除webhook及其推送记录，返回是否存在
+func (m *DataManager) DeleteV3Webhook(id uint64) (ok bool, err error) {
+	m.aLock.Lock()
+	defer m.aLock.Unlock()
+
+	if err = m.awdb.Begin(); err != nil {
+		return false, err
+	}
+	defer func() {
+		if err != nil {
+			m.awdb.Rollback()
+		}
+	}()
+
+	where := []database.Where{
+		database.Where{Name: "webhookId", Value: id},
+	}
+	if _, err = m.awdb.Delete(database.TableV3WebhookDeliveries, where); err != nil {
+		return false, err
+	}
+	where = []database.Where{
+		database.Where{Name: "id", Value: id},
+	}
+	res, err := m.awdb.Delete(database.TableV3Webhooks, where)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, m.awdb.Commit()
+}
+
+// QueryV3Webhook 根据id查询webhook，不存在时返回nil
+func (m *DataManager) QueryV3Webhook(id uint64) (*database.V3Webhook, error) {
+	if m.qNeedLock {
+		m.qLock.Lock()
+		defer m.qLock.Unlock()
+	}
+
+	where := []database.Where{
+		database.Where{Name: "id", Value: id},
+	}
+
+	var result []database.V3Webhook
+	err := m.rdb.SelectRows(database.TableV3Webhooks, where, nil, nil, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result) == 0 {
+		return nil, nil
+	}
+
+	return &result[0], nil
+}
+
+// QueryV3Webhooks 查询webhook，paging为nil时返回全部
+func (m *DataManager) QueryV3Webhooks(paging *database.Paging, order string) ([]database.V3Webhook, error) {
+	if m.qNeedLock {
+		m.qLock.Lock()
+		defer m.qLock.Unlock()
+	}
+
+	where := []database.Where{
+		database.Where{Name: "1", Value: 1},
+	}
+
+	orderT, err := database.MakeOrder(order, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	var result []database.V3Webhook
+	err = m.rdb.SelectRows(database.TableV3Webhooks, where, orderT, paging, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// AddV3WebhookDeliveries 写入匹配到的推送并把推送进度name更新到height，在同一事务中完成。
+// height的区块hash与blockHash不一致时（匹配期间发生了回滚）放弃写入
+func (m *DataManager) AddV3WebhookDeliveries(name string, height int64, blockHash string, deliveries []database.V3WebhookDelivery) (err error) {
+	m.aLock.Lock()
+	defer m.aLock.Unlock()
+
+	if err = m.awdb.Begin(); err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			m.awdb.Rollback()
+		}
+	}()
+
+	var ledgers []database.V3Ledger
+	where := []database.Where{
+		database.Where{Name: "height", Value: height},
+	}
+	if err = m.awdb.SelectRows(database.TableV3Ledgers, where, nil, nil, &ledgers); err != nil {
+		return err
+	}
+	if len(ledgers) == 0 || ledgers[0].BlockHash != blockHash {
+		return fmt.Errorf("block %d changed during matching", height)
+	}
+
+	// 匹配期间被删除的webhook不再写入推送记录
+	var hooks []database.V3Webhook
+	where = []database.Where{
+		database.Where{Name: "1", Value: 1},
+	}
+	if err = m.awdb.SelectRows(database.TableV3Webhooks, where, nil, nil, &hooks); err != nil {
+		return err
+	}
+	live := make(map[uint64]bool, len(hooks))
+	for i := range hooks {
+		live[hooks[i].Id] = true
+	}
+	for i := range deliveries {
+		if !live[deliveries[i].WebhookId] {
+			continue
+		}
+		if _, err = m.awdb.Insert(database.TableV3WebhookDeliveries, v3WebhookDeliveryFields(&deliveries[i])); err != nil {
+			return err
+		}
+	}
+	if err = saveSyncState(m.awdb, name, height, blockHash, 0); err != nil {
+		return err
+	}
+
+	return m.awdb.Commit()
+}
+
+func v3WebhookDeliveryFields(data *database.V3WebhookDelivery) []database.Feild {
+	return []database.Feild{
+		database.Feild{Name: "webhookId", Value: data.WebhookId},
+		database.Feild{Name: "kind", Value: data.Kind},
+		database.Feild{Name: "hash", Value: data.Hash},
+		database.Feild{Name: "height", Value: data.Height},
+		database.Feild{Name: "payload", Value: data.Payload},
+		database.Feild{Name: "status", Value: data.Status},
+		database.Feild{Name: "attempts", Value: data.Attempts},
+		database.Feild{Name: "responseCode", Value: data.ResponseCode},
+		database.Feild{Name: "lastError", Value: data.LastError},
+		database.Feild{Name: "nextAttemptAt", Value: data.NextAttemptAt},
+		database.Feild{Name: "createdAt", Value: data.CreatedAt},
+		database.Feild{Name: "updatedAt", Value: data.UpdatedAt},
+	}
+}
+
+// UpdateV3WebhookDelivery 记录一次推送的结果
+func (m *DataManager) UpdateV3WebhookDelivery(data *database.V3WebhookDelivery) error {
+	m.aLock.Lock()
+	defer m.aLock.Unlock()
+
+	fields := []database.Feild{
+		database.Feild{Name: "status", Value: data.Status},
+		database.Feild{Name: "attempts", Value: data.Attempts},
+		database.Feild{Name: "responseCode", Value: data.ResponseCode},
+		database.Feild{Name: "lastError", Value: data.LastError},
+		database.Feild{Name: "nextAttemptAt", Value: data.NextAttemptAt},
+		database.Feild{Name: "updatedAt", Value: data.UpdatedAt},
+	}
+	where := []database.Where{
+		database.Where{Name: "id", Value: data.Id},
+	}
+	_, err := m.awdb.Update(database.TableV3WebhookDeliveries, fields, where)
+	return err
+}
+
+// DeleteV3WebhookDeliveries 删除webhook的全部推送记录，用于清理webhook已删除的记录
+func (m *DataManager) DeleteV3WebhookDeliveries(webhookId uint64) error {
+	m.aLock.Lock()
+	defer m.aLock.Unlock()
+
+	where := []database.Where{
+		database.Where{Name: "webhookId", Value: webhookId},
+	}
+	_, err := m.awdb.Delete(database.TableV3WebhookDeliveries, where)
+	return err
+}
+
+// RetryV3WebhookDelivery 把死信重新放回推送队列并清零推送次数，返回是否存在该死信
+func (m *DataManager) RetryV3WebhookDelivery(webhookId, id uint64) (bool, error) {
+	m.aLock.Lock()
+	defer m.aLock.Unlock()
+
+	now := time.Now()
+	fields := []database.Feild{
+		database.Feild{Name: "status", Value: database.WebhookPending},
+		database.Feild{Name: "attempts", Value: 0},
+		database.Feild{Name: "nextAttemptAt", Value: now},
+		database.Feild{Name: "updatedAt", Value: now},
+	}
+	where := []database.Where{
+		database.Where{Name: "id", Value: id},
+		database.Where{Name: "webhookId", Value: webhookId},
+		database.Where{Name: "status", Value: database.WebhookDead},
+	}
+	res, err := m.awdb.Update(database.TableV3WebhookDeliveries, fields, where)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// QueryDueV3WebhookDeliveries 查询到期需要推送的记录，按id升序
+func (m *DataManager) QueryDueV3WebhookDeliveries(now time.Time, limit uint64) ([]database.V3WebhookDelivery, error) {
+	if m.qNeedLock {
+		m.qLock.Lock()
+		defer m.qLock.Unlock()
+	}
+
+	where := []database.Where{
+		database.Where{Name: "status", Value: database.WebhookPending},
+		database.Where{Name: "nextAttemptAt", Value: now, Op: "<="},
+	}
+
+	orderT, err := database.MakeOrder("ASC", "id")
+	if err != nil {
+		return nil, err
+	}
+
+	var result []database.V3WebhookDelivery
+	err = m.rdb.SelectRows(database.TableV3WebhookDeliveries, where, orderT, database.MakeKeysetPaging("id", 0, limit), &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// QueryV3WebhookDeliveries 查询webhook的推送记录，status不为空时只查询该状态，例如dead查询死信
+func (m *DataManager) QueryV3WebhookDeliveries(webhookId uint64, status string, paging *database.Paging, order string) ([]database.V3WebhookDelivery, error) {
+	if m.qNeedLock {
+		m.qLock.Lock()
+		defer m.qLock.Unlock()
+	}
+
+	where := []database.Where{
+		database.Where{Name: "webhookId", Value: webhookId},
+	}
+	if status != "" {
+		where = append(where, database.Where{Name: "status", Value: status})
+	}
+
+	orderT, err := database.MakeOrder(order, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	var result []database.V3WebhookDelivery
+	err = m.rdb.SelectRows(database.TableV3WebhookDeliveries, where, orderT, paging, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
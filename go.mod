@@ -10,11 +10,15 @@ require (
 	github.com/ethereum/go-ethereum v1.10.3
 	github.com/gin-contrib/pprof v1.3.0
 	github.com/gin-gonic/gin v1.6.2
+	github.com/go-sql-driver/mysql v1.5.0
+	github.com/gorilla/websocket v1.4.2
+	github.com/graph-gophers/graphql-go v1.3.0
 	github.com/huzhongqing/ginprom v0.1.1
 	github.com/jmoiron/sqlx v1.3.1
 	github.com/juju/ratelimit v1.0.1
 	github.com/mattn/go-sqlite3 v1.14.6
 	github.com/pkg/errors v0.9.1
+	github.com/prometheus/client_golang v1.8.0
 	github.com/recallsong/httpc v0.0.0-20180810070359-a9326ce32aa8 // indirect
 	github.com/shopspring/decimal v0.0.0-20191009025716-f1972eb1d1f5
 	github.com/stretchr/objx v0.2.0 // indirect
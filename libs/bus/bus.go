@@ -0,0 +1,82 @@
+package bus
+
+import (
+	"sync"
+)
+
+/*
+进程内的消息总线
+1. 同步任务入库后发布，推送接口订阅
+2. 发布不阻塞：订阅者的缓冲区满时取消该订阅并关闭其通道，订阅者需要重新订阅并从断开的高度补齐
+*/
+
+type Bus struct {
+	mu   sync.Mutex
+	subs map[*Subscription]struct{}
+}
+
+type Subscription struct {
+	bus    *Bus
+	ch     chan interface{}
+	lagged bool // 因缓冲区满被取消
+}
+
+func New() *Bus {
+	return &Bus{
+		subs: make(map[*Subscription]struct{}),
+	}
+}
+
+// Subscribe 订阅之后发布的消息，size为缓冲区大小
+func (b *Bus) Subscribe(size int) *Subscription {
+	sub := &Subscription{
+		bus: b,
+		ch:  make(chan interface{}, size),
+	}
+
+	b.mu.Lock()
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+	return sub
+}
+
+// Publish 把消息发给全部订阅者，b为nil时忽略
+func (b *Bus) Publish(msg interface{}) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subs {
+		select {
+		case sub.ch <- msg:
+		default:
+			sub.lagged = true
+			delete(b.subs, sub)
+			close(sub.ch)
+		}
+	}
+}
+
+// C 消息通道，订阅被取消后关闭
+func (s *Subscription) C() <-chan interface{} {
+	return s.ch
+}
+
+// Lagged 通道关闭后判断是否因处理太慢被取消
+func (s *Subscription) Lagged() bool {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	return s.lagged
+}
+
+// Unsubscribe 取消订阅，可以重复调用
+func (s *Subscription) Unsubscribe() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	if _, ok := s.bus.subs[s]; ok {
+		delete(s.bus.subs, s)
+		close(s.ch)
+	}
+}
@@ -0,0 +1,41 @@
+package bus
+
+import (
+	"testing"
+)
+
+func TestBus(t *testing.T) {
+	b := New()
+	fast := b.Subscribe(4)
+	slow := b.Subscribe(1)
+
+	b.Publish(1)
+	b.Publish(2)
+
+	for want := 1; want <= 2; want++ {
+		if got := <-fast.C(); got != want {
+			t.Fatalf("fast got %v, want %d", got, want)
+		}
+	}
+
+	// 缓冲区满的订阅被取消
+	if got := <-slow.C(); got != 1 {
+		t.Fatalf("slow got %v", got)
+	}
+	if _, ok := <-slow.C(); ok || !slow.Lagged() {
+		t.Fatal("slow subscription should be closed as lagged")
+	}
+	if fast.Lagged() {
+		t.Fatal("fast subscription lagged")
+	}
+
+	fast.Unsubscribe()
+	fast.Unsubscribe()
+	if _, ok := <-fast.C(); ok || fast.Lagged() {
+		t.Fatal("unsubscribed channel should be closed")
+	}
+	b.Publish(3)
+
+	var nilBus *Bus
+	nilBus.Publish(4)
+}
@@ -30,7 +30,7 @@ func NewTxTag(tag []byte) TxTag {
 func (t TxTag) Bytes() []byte { return t[:] }
 
 var (
-	//TxTagAppInit        = TxTag{0, 0}     // 账户迁移，采用与batch相同的交易结构，但是不收取手续费 v4废弃
+	TxTagAppInit = TxTag{0, 0} // 账户迁移，采用与batch相同的交易结构，但是不收取手续费 v4废弃
 	//TxTagTinInit        = TxTag{0, 1}     // v3初始化TIN用户 v4废弃
 	//TxTagAppOLO         = TxTag{1, 1}     // 原生交易 v1 - v3废弃
 	TxTagAppEvm = TxTag{1, 2} // 合约交易
@@ -0,0 +1,26 @@
+package bean
+
+import (
+	"github.com/pkg/errors"
+	"github.com/toolglobal/api/mondo/types"
+)
+
+// V3DepositAddressReq 登记充值地址
+type V3DepositAddressReq struct {
+	Address       string `json:"address"`       // 充值地址
+	Confirmations int64  `json:"confirmations"` // 需要的确认数，包含转账所在区块，为0时使用配置的默认值
+	Label         string `json:"label"`         // 备注，可选
+}
+
+func (req *V3DepositAddressReq) Check() error {
+	if !types.ValidAddress(req.Address) {
+		return errors.New("invalid address")
+	}
+	if req.Confirmations < 0 {
+		return errors.New("invalid confirmations")
+	}
+	if len(req.Label) > 128 {
+		return errors.New("label too long")
+	}
+	return nil
+}
@@ -1,6 +1,10 @@
 package bean
 
-import "github.com/ethereum/go-ethereum/common"
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/toolglobal/api/database"
+	"time"
+)
 
 type PublicResp struct {
 	IsSuccess bool        `json:"isSuccess"` // 是否成功
@@ -46,3 +50,29 @@ type V2EvmLog struct {
 	Index       uint           `json:"logIndex"`         // 日志索引
 	Removed     bool           `json:"removed"`          // 是否已移除
 }
+
+type V3StatusResult struct {
+	SyncState       *database.SyncState `json:"syncState"`           // 同步进度，尚未同步时为空
+	LastBlockHeight int64               `json:"lastBlockHeight"`     // 节点最新高度
+	Lag             int64               `json:"lag"`                 // 落后节点的区块数
+	NodeError       string              `json:"nodeError,omitempty"` // 查询节点失败的原因
+}
+
+// V3BalanceCheck 索引余额与节点balanceOf的比对结果
+type V3BalanceCheck struct {
+	database.V3Balance
+	IndexedHeight int64  `json:"indexedHeight"`   // 比对的高度：同步高度，余额在其后变化过时为最后一次变化的高度
+	ChainBalance  string `json:"chainBalance"`    // 节点上balanceOf的结果，查询失败时为空
+	ChainHeight   int64  `json:"chainHeight"`     // 节点应答的高度，与indexedHeight不同时节点未按高度查询，不一致可能是索引落后
+	Match         bool   `json:"match"`           // 索引余额与节点一致
+	Error         string `json:"error,omitempty"` // 节点查询错误
+}
+
+// V3NativeBalanceResult 某一高度的原生币余额
+type V3NativeBalanceResult struct {
+	Address       string     `json:"address"`             // 账户地址
+	Height        int64      `json:"height"`              // 查询的高度
+	Balance       string     `json:"balance"`             // 该高度之后的余额
+	ChangedHeight int64      `json:"changedHeight"`       // 余额最后一次变化的高度，没有变化时为0
+	ChangedAt     *time.Time `json:"changedAt,omitempty"` // 余额最后一次变化的区块时间
+}
@@ -0,0 +1,40 @@
+package bean
+
+import (
+	"net/url"
+
+	"github.com/pkg/errors"
+	"github.com/toolglobal/api/mondo/types"
+)
+
+// V3WebhookReq 注册webhook
+type V3WebhookReq struct {
+	URL      string `json:"url"`      // 推送地址，http或https
+	Secret   string `json:"secret"`   // HMAC-SHA256签名密钥，为空时自动生成
+	Address  string `json:"address"`  // 交易、转账的发起方或接收方，可选
+	Contract string `json:"contract"` // 转账的合约地址或交易的接收方，可选
+	Event    string `json:"event"`    // 转账的事件名称，如Transfer；指定时只推送转账，可选
+}
+
+func (req *V3WebhookReq) Check() error {
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("invalid url")
+	}
+	if len(req.URL) > 512 {
+		return errors.New("url too long")
+	}
+	if len(req.Secret) > 128 {
+		return errors.New("secret too long")
+	}
+	if req.Address != "" && !types.ValidAddress(req.Address) {
+		return errors.New("invalid address")
+	}
+	if req.Contract != "" && !types.ValidAddress(req.Contract) {
+		return errors.New("invalid contract")
+	}
+	if len(req.Event) > 32 {
+		return errors.New("event too long")
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package dbo
+
+import (
+	"github.com/toolglobal/api/database"
+)
+
+func (app *DBO) SaveV3DepositAddress(data *database.V3DepositAddress) error {
+	return app.dataM.SaveV3DepositAddress(data)
+}
+
+func (app *DBO) DeleteV3DepositAddress(address string) (bool, error) {
+	return app.dataM.DeleteV3DepositAddress(address)
+}
+
+func (app *DBO) QueryV3DepositAddress(address string) (*database.V3DepositAddress, error) {
+	return app.dataM.QueryV3DepositAddress(address)
+}
+
+func (app *DBO) QueryV3DepositAddresses(paging *database.Paging, order string) ([]database.V3DepositAddress, error) {
+	return app.dataM.QueryV3DepositAddresses(paging, order)
+}
+
+func (app *DBO) QueryV3Deposits(filter *database.V3DepositFilter, indexed int64, paging *database.Paging, order string) ([]database.V3Deposit, error) {
+	return app.dataM.QueryV3Deposits(filter, indexed, paging, order)
+}
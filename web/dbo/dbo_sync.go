@@ -0,0 +1,9 @@
+package dbo
+
+import (
+	"github.com/toolglobal/api/database"
+)
+
+func (app *DBO) QuerySyncState(name string) (*database.SyncState, error) {
+	return app.dataM.QuerySyncState(name)
+}
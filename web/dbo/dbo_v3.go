@@ -4,8 +4,8 @@ import (
 	"github.com/toolglobal/api/database"
 )
 
-func (app *DBO) QueryV3Ledgers(begin, end uint64, cursor, limit uint64, order string) ([]database.V3Ledger, error) {
-	return app.dataM.QueryV3AllLedger(begin, end, cursor, limit, order)
+func (app *DBO) QueryV3Ledgers(begin, end uint64, paging *database.Paging, order string) ([]database.V3Ledger, error) {
+	return app.dataM.QueryV3AllLedger(begin, end, paging, order)
 }
 
 func (app *DBO) QueryV3LedgerByHeight(height int64) ([]database.V3Ledger, error) {
@@ -19,8 +19,8 @@ func (app *DBO) QueryV3LedgerByHeight(height int64) ([]database.V3Ledger, error)
 	return []database.V3Ledger{*ledger}, nil
 }
 
-func (app *DBO) QueryV3Txs(begin, end uint64, cursor, limit uint64, order string) ([]database.V3Transaction, error) {
-	return app.dataM.QueryV3AllTxs(begin, end, cursor, limit, order)
+func (app *DBO) QueryV3Txs(begin, end uint64, paging *database.Paging, order string) ([]database.V3Transaction, error) {
+	return app.dataM.QueryV3AllTxs(begin, end, paging, order)
 }
 
 func (app *DBO) QueryV3SingleTx(txhash string) ([]database.V3Transaction, error) {
@@ -35,26 +35,86 @@ func (app *DBO) QueryV3SingleTx(txhash string) ([]database.V3Transaction, error)
 	return []database.V3Transaction{*tx}, nil
 }
 
-func (app *DBO) QueryV3AccountTxs(address string, begin, end uint64, cursor, limit uint64, order string) ([]database.V3Transaction, error) {
-	return app.dataM.QueryV3AccountTxs(address, begin, end, cursor, limit, order)
+func (app *DBO) QueryV3AccountTxs(address, direction string, begin, end uint64, paging *database.Paging, order string) ([]database.V3Transaction, error) {
+	return app.dataM.QueryV3AccountTxs(address, direction, begin, end, paging, order)
 }
 
-func (app *DBO) QueryV3BlockTxs(height int64, begin, end uint64, cursor, limit uint64, order string) ([]database.V3Transaction, error) {
-	return app.dataM.QueryV3BlockTxs(height, begin, end, cursor, limit, order)
+func (app *DBO) QueryV3BlockTxs(height int64, begin, end uint64, paging *database.Paging, order string) ([]database.V3Transaction, error) {
+	return app.dataM.QueryV3BlockTxs(height, begin, end, paging, order)
 }
 
-func (app *DBO) QueryV3Payments(symbol, contract string, begin, end uint64, cursor, limit uint64, order string) ([]database.V3Payment, error) {
-	return app.dataM.QueryV3AllPayments(symbol, contract, begin, end, cursor, limit, order)
+func (app *DBO) QueryV3Payments(symbol, contract string, begin, end uint64, paging *database.Paging, order string) ([]database.V3Payment, error) {
+	return app.dataM.QueryV3AllPayments(symbol, contract, begin, end, paging, order)
 }
 
-func (app *DBO) QueryV3TxPayments(txhash, symbol, contract string, begin, end uint64, cursor, limit uint64, order string) ([]database.V3Payment, error) {
-	return app.dataM.QueryV3PaymentsByHash(txhash, symbol, contract, begin, end, cursor, limit, order)
+func (app *DBO) QueryV3TxPayments(txhash, symbol, contract string, begin, end uint64, paging *database.Paging, order string) ([]database.V3Payment, error) {
+	return app.dataM.QueryV3PaymentsByHash(txhash, symbol, contract, begin, end, paging, order)
 }
 
-func (app *DBO) QueryV3AccountPayments(address, symbol, contract string, begin, end uint64, cursor, limit uint64, order string) ([]database.V3Payment, error) {
-	return app.dataM.QueryV3PaymentsByAddress(address, symbol, contract, begin, end, cursor, limit, order)
+func (app *DBO) QueryV3AccountPayments(address, symbol, contract string, begin, end uint64, paging *database.Paging, order string) ([]database.V3Payment, error) {
+	return app.dataM.QueryV3PaymentsByAddress(address, symbol, contract, begin, end, paging, order)
 }
 
-func (app *DBO) QueryV3BlockPayments(height int64, symbol, contract string, begin, end uint64, cursor, limit uint64, order string) ([]database.V3Payment, error) {
-	return app.dataM.QueryV3PaymentsByHeight(height, symbol, contract, begin, end, cursor, limit, order)
+func (app *DBO) QueryV3BlockPayments(height int64, symbol, contract string, begin, end uint64, paging *database.Paging, order string) ([]database.V3Payment, error) {
+	return app.dataM.QueryV3PaymentsByHeight(height, symbol, contract, begin, end, paging, order)
+}
+
+func (app *DBO) QueryV3NFTTransfers(contract, tokenId string, begin, end uint64, paging *database.Paging, order string) ([]database.V3NFTTransfer, error) {
+	return app.dataM.QueryV3NFTTransfers("", contract, tokenId, begin, end, paging, order)
+}
+
+func (app *DBO) QueryV3AccountNFTTransfers(address, contract, tokenId string, begin, end uint64, paging *database.Paging, order string) ([]database.V3NFTTransfer, error) {
+	return app.dataM.QueryV3NFTTransfers(address, contract, tokenId, begin, end, paging, order)
+}
+
+func (app *DBO) QueryV3AccountApprovals(address, contract string, begin, end uint64, paging *database.Paging, order string) ([]database.V3Approval, error) {
+	return app.dataM.QueryV3Approvals(address, contract, begin, end, paging, order)
+}
+
+func (app *DBO) QueryV3AccountBalances(address, contract string, paging *database.Paging, order string) ([]database.V3Balance, error) {
+	return app.dataM.QueryV3Balances(address, contract, paging, order)
+}
+
+func (app *DBO) QueryV3NativeBalance(address string, height int64) (*database.V3BalanceHistory, error) {
+	return app.dataM.QueryV3NativeBalance(address, height)
+}
+
+func (app *DBO) QueryV3Contract(address string) (*database.V3Contract, error) {
+	return app.dataM.QueryV3Contract(address)
+}
+
+func (app *DBO) QueryV3Contracts(creator string, begin, end uint64, paging *database.Paging, order string) ([]database.V3Contract, error) {
+	return app.dataM.QueryV3Contracts(creator, begin, end, paging, order)
+}
+
+func (app *DBO) QueryV3Logs(filter *database.V3LogFilter, paging *database.Paging, order string) ([]database.V3Log, error) {
+	return app.dataM.QueryV3Logs(filter, paging, order)
+}
+
+func (app *DBO) QueryV3RawTxs(filter *database.V3RawTxFilter, paging *database.Paging, order string) ([]database.V3RawTx, error) {
+	return app.dataM.QueryV3RawTxs(filter, paging, order)
+}
+
+func (app *DBO) QueryV3LedgerByHash(blockHash string) (*database.V3Ledger, error) {
+	return app.dataM.QueryV3LedgerByHash(blockHash)
+}
+
+func (app *DBO) QueryV3LedgersByHeights(heights []int64) ([]database.V3Ledger, error) {
+	return app.dataM.QueryV3LedgersByHeights(heights)
+}
+
+func (app *DBO) QueryV3TxsByHashes(hashes []string) ([]database.V3Transaction, error) {
+	return app.dataM.QueryV3TxsByHashes(hashes)
+}
+
+func (app *DBO) QueryV3TxsByHeights(heights []int64) ([]database.V3Transaction, error) {
+	return app.dataM.QueryV3TxsByHeights(heights)
+}
+
+func (app *DBO) QueryV3PaymentsByHashes(hashes []string) ([]database.V3Payment, error) {
+	return app.dataM.QueryV3PaymentsByHashes(hashes)
+}
+
+func (app *DBO) QueryV3PaymentsByHeights(heights []int64) ([]database.V3Payment, error) {
+	return app.dataM.QueryV3PaymentsByHeights(heights)
 }
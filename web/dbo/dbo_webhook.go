@@ -0,0 +1,29 @@
+package dbo
+
+import (
+	"github.com/toolglobal/api/database"
+)
+
+func (app *DBO) AddV3Webhook(data *database.V3Webhook) (uint64, error) {
+	return app.dataM.AddV3Webhook(data)
+}
+
+func (app *DBO) DeleteV3Webhook(id uint64) (bool, error) {
+	return app.dataM.DeleteV3Webhook(id)
+}
+
+func (app *DBO) QueryV3Webhook(id uint64) (*database.V3Webhook, error) {
+	return app.dataM.QueryV3Webhook(id)
+}
+
+func (app *DBO) QueryV3Webhooks(paging *database.Paging, order string) ([]database.V3Webhook, error) {
+	return app.dataM.QueryV3Webhooks(paging, order)
+}
+
+func (app *DBO) QueryV3WebhookDeliveries(webhookId uint64, status string, paging *database.Paging, order string) ([]database.V3WebhookDelivery, error) {
+	return app.dataM.QueryV3WebhookDeliveries(webhookId, status, paging, order)
+}
+
+func (app *DBO) RetryV3WebhookDelivery(webhookId, id uint64) (bool, error) {
+	return app.dataM.RetryV3WebhookDelivery(webhookId, id)
+}
@@ -0,0 +1,507 @@
+package ethrpc
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/toolglobal/api/database"
+	"github.com/toolglobal/api/utils"
+	"github.com/toolglobal/api/web/dbo"
+)
+
+const (
+	pageSize = 200   // 分页读取一个区块的交易、日志时每页条数
+	maxLogs  = 10000 // eth_getLogs单次最多返回的日志条数
+)
+
+// EthAPI eth命名空间，区块、交易、收据、日志从v3表查询
+type EthAPI struct {
+	dbo3 *dbo.DBO
+	node *rpc.Client
+}
+
+// rpcTransaction 与go-ethereum的RPCTransaction字段一致
+type rpcTransaction struct {
+	BlockHash        *common.Hash    `json:"blockHash"`
+	BlockNumber      *hexutil.Big    `json:"blockNumber"`
+	From             common.Address  `json:"from"`
+	Gas              hexutil.Uint64  `json:"gas"`
+	GasPrice         *hexutil.Big    `json:"gasPrice"`
+	Hash             common.Hash     `json:"hash"`
+	Input            hexutil.Bytes   `json:"input"`
+	Nonce            hexutil.Uint64  `json:"nonce"`
+	To               *common.Address `json:"to"`
+	TransactionIndex *hexutil.Uint64 `json:"transactionIndex"`
+	Value            *hexutil.Big    `json:"value"`
+	Type             hexutil.Uint64  `json:"type"`
+	V                *hexutil.Big    `json:"v"`
+	R                *hexutil.Big    `json:"r"`
+	S                *hexutil.Big    `json:"s"`
+}
+
+// BlockNumber eth_blockNumber，返回已同步的高度
+func (api *EthAPI) BlockNumber(ctx context.Context) (hexutil.Uint64, error) {
+	height, err := api.latestHeight()
+	return hexutil.Uint64(height), err
+}
+
+// GetBlockByNumber eth_getBlockByNumber
+func (api *EthAPI) GetBlockByNumber(ctx context.Context, number rpc.BlockNumber, fullTx bool) (map[string]interface{}, error) {
+	height, err := api.resolveHeight(number)
+	if err != nil {
+		return nil, err
+	}
+	ledger, err := api.ledger(height)
+	if err != nil || ledger == nil {
+		return nil, err
+	}
+	return api.marshalBlock(ledger, fullTx)
+}
+
+// GetBlockByHash eth_getBlockByHash
+func (api *EthAPI) GetBlockByHash(ctx context.Context, hash common.Hash, fullTx bool) (map[string]interface{}, error) {
+	ledger, err := api.dbo3.QueryV3LedgerByHash(blockHashKey(hash))
+	if err != nil || ledger == nil {
+		return nil, err
+	}
+	return api.marshalBlock(ledger, fullTx)
+}
+
+// GetTransactionByHash eth_getTransactionByHash
+func (api *EthAPI) GetTransactionByHash(ctx context.Context, hash common.Hash) (*rpcTransaction, error) {
+	tx, err := api.transaction(hash)
+	if err != nil || tx == nil {
+		return nil, err
+	}
+	ledger, err := api.ledger(tx.Height)
+	if err != nil {
+		return nil, err
+	}
+	return newRPCTransaction(tx, ledger)
+}
+
+// GetTransactionReceipt eth_getTransactionReceipt
+func (api *EthAPI) GetTransactionReceipt(ctx context.Context, hash common.Hash) (map[string]interface{}, error) {
+	tx, err := api.transaction(hash)
+	if err != nil || tx == nil {
+		return nil, err
+	}
+	ledger, err := api.ledger(tx.Height)
+	if err != nil {
+		return nil, err
+	}
+
+	// 区块内该交易及之前交易的gas之和
+	txs, err := api.blockTxs(tx.Height)
+	if err != nil {
+		return nil, err
+	}
+	var cumulativeGasUsed int64
+	for _, v := range txs {
+		if v.TxIdx <= tx.TxIdx {
+			cumulativeGasUsed += v.GasUsed
+		}
+	}
+
+	rows, err := api.queryLogs(&database.V3LogFilter{Hash: tx.Hash})
+	if err != nil {
+		return nil, err
+	}
+	logs := make([]*ethtypes.Log, 0, len(rows))
+	for i := range rows {
+		logs = append(logs, newLog(&rows[i], ledger))
+	}
+
+	var status uint64
+	if tx.Codei == 0 {
+		status = ethtypes.ReceiptStatusSuccessful
+	}
+	fields := map[string]interface{}{
+		"blockHash":         ledgerHash(ledger),
+		"blockNumber":       hexutil.Uint64(tx.Height),
+		"transactionHash":   common.HexToHash(tx.Hash),
+		"transactionIndex":  hexutil.Uint64(tx.TxIdx),
+		"from":              common.HexToAddress(tx.Sender),
+		"to":                txTo(tx),
+		"gasUsed":           hexutil.Uint64(tx.GasUsed),
+		"cumulativeGasUsed": hexutil.Uint64(cumulativeGasUsed),
+		"contractAddress":   nil,
+		"logs":              logs,
+		"logsBloom":         logsBloom(logs),
+		"type":              hexutil.Uint(ethtypes.LegacyTxType),
+		"status":            hexutil.Uint(status),
+	}
+	if isCreation(tx) && tx.Codei == 0 {
+		fields["contractAddress"] = crypto.CreateAddress(common.HexToAddress(tx.Sender), uint64(tx.Nonce))
+	}
+	return fields, nil
+}
+
+// GetLogs eth_getLogs
+func (api *EthAPI) GetLogs(ctx context.Context, crit FilterCriteria) ([]*ethtypes.Log, error) {
+	if len(crit.Topics) > 4 {
+		return nil, fmt.Errorf("too many topics: %d", len(crit.Topics))
+	}
+
+	filter := &database.V3LogFilter{}
+	if crit.BlockHash != nil {
+		ledger, err := api.dbo3.QueryV3LedgerByHash(blockHashKey(*crit.BlockHash))
+		if err != nil {
+			return nil, err
+		}
+		if ledger == nil {
+			return nil, fmt.Errorf("unknown block")
+		}
+		filter.FromHeight, filter.ToHeight = ledger.Height, ledger.Height
+	} else {
+		latest, err := api.latestHeight()
+		if err != nil {
+			return nil, err
+		}
+		// 未指定或为latest、pending时取已同步的高度
+		filter.FromHeight, filter.ToHeight = latest, latest
+		if crit.FromBlock != nil && crit.FromBlock.Sign() >= 0 {
+			filter.FromHeight = crit.FromBlock.Int64()
+		}
+		if crit.ToBlock != nil && crit.ToBlock.Sign() >= 0 {
+			filter.ToHeight = crit.ToBlock.Int64()
+		}
+		if filter.FromHeight > filter.ToHeight {
+			return []*ethtypes.Log{}, nil
+		}
+	}
+	for _, addr := range crit.Addresses {
+		filter.Addresses = append(filter.Addresses, addr.Hex())
+	}
+	for _, topics := range crit.Topics {
+		var values []string
+		for _, topic := range topics {
+			values = append(values, topic.Hex())
+		}
+		filter.Topics = append(filter.Topics, values)
+	}
+
+	rows, err := api.queryLogs(filter)
+	if err != nil {
+		return nil, err
+	}
+	ledgers := make(map[int64]*database.V3Ledger)
+	logs := make([]*ethtypes.Log, 0, len(rows))
+	for i := range rows {
+		ledger, ok := ledgers[rows[i].Height]
+		if !ok {
+			if ledger, err = api.ledger(rows[i].Height); err != nil {
+				return nil, err
+			}
+			ledgers[rows[i].Height] = ledger
+		}
+		logs = append(logs, newLog(&rows[i], ledger))
+	}
+	return logs, nil
+}
+
+func (api *EthAPI) latestHeight() (int64, error) {
+	state, err := api.dbo3.QuerySyncState(database.SyncStateV3)
+	if err != nil || state == nil {
+		return 0, err
+	}
+	return state.Height, nil
+}
+
+func (api *EthAPI) resolveHeight(number rpc.BlockNumber) (int64, error) {
+	if number == rpc.LatestBlockNumber || number == rpc.PendingBlockNumber {
+		return api.latestHeight()
+	}
+	return number.Int64(), nil
+}
+
+func (api *EthAPI) ledger(height int64) (*database.V3Ledger, error) {
+	ledgers, err := api.dbo3.QueryV3LedgerByHeight(height)
+	if err != nil || len(ledgers) == 0 {
+		return nil, err
+	}
+	return &ledgers[0], nil
+}
+
+func (api *EthAPI) transaction(hash common.Hash) (*database.V3Transaction, error) {
+	txs, err := api.dbo3.QueryV3SingleTx(hash.Hex())
+	if err != nil || len(txs) == 0 {
+		return nil, err
+	}
+	return &txs[0], nil
+}
+
+// blockTxs 区块内全部交易，按区块内顺序
+func (api *EthAPI) blockTxs(height int64) ([]database.V3Transaction, error) {
+	var result []database.V3Transaction
+	var after uint64
+	for {
+		txs, err := api.dbo3.QueryV3BlockTxs(height, 0, 0, database.MakeKeysetPaging("id", after, pageSize), "ASC")
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, txs...)
+		if len(txs) < pageSize {
+			return result, nil
+		}
+		after = txs[len(txs)-1].Id
+	}
+}
+
+// queryLogs 按filter查询全部日志，超过maxLogs条时返回错误
+func (api *EthAPI) queryLogs(filter *database.V3LogFilter) ([]database.V3Log, error) {
+	var result []database.V3Log
+	var after uint64
+	for {
+		logs, err := api.dbo3.QueryV3Logs(filter, database.MakeKeysetPaging("id", after, pageSize), "ASC")
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, logs...)
+		if len(result) > maxLogs {
+			return nil, fmt.Errorf("query returned more than %d results", maxLogs)
+		}
+		if len(logs) < pageSize {
+			return result, nil
+		}
+		after = logs[len(logs)-1].Id
+	}
+}
+
+// marshalBlock 与go-ethereum的RPCMarshalBlock字段一致，链上不存在的字段取零值。
+// transactionsRoot、receiptsRoot按返回的交易、收据计算；stateRoot为零，hash为tendermint区块hash，
+// 与客户端按区块头计算的hash不同
+func (api *EthAPI) marshalBlock(ledger *database.V3Ledger, fullTx bool) (map[string]interface{}, error) {
+	var parentHash common.Hash
+	if ledger.Height > 1 {
+		parent, err := api.ledger(ledger.Height - 1)
+		if err != nil {
+			return nil, err
+		}
+		if parent != nil {
+			parentHash = ledgerHash(parent)
+		}
+	}
+
+	rows, err := api.queryLogs(&database.V3LogFilter{FromHeight: ledger.Height, ToHeight: ledger.Height})
+	if err != nil {
+		return nil, err
+	}
+	logs := make([]*ethtypes.Log, 0, len(rows))
+	txLogs := make(map[int][]*ethtypes.Log)
+	for i := range rows {
+		log := newLog(&rows[i], ledger)
+		logs = append(logs, log)
+		txLogs[rows[i].TxIdx] = append(txLogs[rows[i].TxIdx], log)
+	}
+
+	txs, err := api.blockTxs(ledger.Height)
+	if err != nil {
+		return nil, err
+	}
+	transactions := make([]interface{}, 0, len(txs))
+	etxs := make(ethtypes.Transactions, 0, len(txs))
+	receipts := make(ethtypes.Receipts, 0, len(txs))
+	var cumulativeGasUsed uint64
+	for i := range txs {
+		etx, err := ethTransaction(&txs[i])
+		if err != nil {
+			return nil, err
+		}
+		etxs = append(etxs, etx)
+		cumulativeGasUsed += uint64(txs[i].GasUsed)
+		receipts = append(receipts, newReceipt(&txs[i], cumulativeGasUsed, txLogs[txs[i].TxIdx]))
+
+		if !fullTx {
+			transactions = append(transactions, common.HexToHash(txs[i].Hash))
+			continue
+		}
+		tx, err := newRPCTransaction(&txs[i], ledger)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, tx)
+	}
+
+	return map[string]interface{}{
+		"number":           (*hexutil.Big)(big.NewInt(ledger.Height)),
+		"hash":             ledgerHash(ledger),
+		"parentHash":       parentHash,
+		"nonce":            ethtypes.BlockNonce{},
+		"mixHash":          common.Hash{},
+		"sha3Uncles":       ethtypes.EmptyUncleHash,
+		"logsBloom":        logsBloom(logs),
+		"stateRoot":        common.Hash{},
+		"miner":            common.HexToAddress(ledger.Validator),
+		"difficulty":       (*hexutil.Big)(new(big.Int)),
+		"totalDifficulty":  (*hexutil.Big)(new(big.Int)),
+		"extraData":        hexutil.Bytes{},
+		"size":             hexutil.Uint64(ledger.BlockSize),
+		"gasLimit":         hexutil.Uint64(ledger.GasLimit),
+		"gasUsed":          hexutil.Uint64(ledger.GasUsed),
+		"timestamp":        hexutil.Uint64(ledger.CreatedAt.Unix()),
+		"transactionsRoot": ethtypes.DeriveSha(etxs, trie.NewStackTrie(nil)),
+		"receiptsRoot":     ethtypes.DeriveSha(receipts, trie.NewStackTrie(nil)),
+		"transactions":     transactions,
+		"uncles":           []common.Hash{},
+	}, nil
+}
+
+// newRPCTransaction ledger为nil时区块字段为null。以太坊交易从签名数据还原，其他交易没有签名，v、r、s为0
+func newRPCTransaction(tx *database.V3Transaction, ledger *database.V3Ledger) (*rpcTransaction, error) {
+	etx, err := ethTransaction(tx)
+	if err != nil {
+		return nil, err
+	}
+	v, r, s := etx.RawSignatureValues()
+	result := &rpcTransaction{
+		From:     common.HexToAddress(tx.Sender),
+		Gas:      hexutil.Uint64(etx.Gas()),
+		GasPrice: (*hexutil.Big)(etx.GasPrice()),
+		Hash:     common.HexToHash(tx.Hash),
+		Input:    etx.Data(),
+		Nonce:    hexutil.Uint64(etx.Nonce()),
+		To:       etx.To(),
+		Value:    (*hexutil.Big)(etx.Value()),
+		Type:     hexutil.Uint64(etx.Type()),
+		V:        (*hexutil.Big)(v),
+		R:        (*hexutil.Big)(r),
+		S:        (*hexutil.Big)(s),
+	}
+	if ledger != nil {
+		blockHash := ledgerHash(ledger)
+		index := hexutil.Uint64(tx.TxIdx)
+		result.BlockHash = &blockHash
+		result.BlockNumber = (*hexutil.Big)(big.NewInt(tx.Height))
+		result.TransactionIndex = &index
+	}
+	return result, nil
+}
+
+// ethTransaction 以太坊交易解析库中保存的签名数据，hash与库中一致；
+// 其他交易按库中字段构造未签名的交易，金额、gas价格由库中单位转换为wei
+func ethTransaction(tx *database.V3Transaction) (*ethtypes.Transaction, error) {
+	if tx.RawTx != "" {
+		raw, err := hexutil.Decode(tx.RawTx)
+		if err != nil {
+			return nil, err
+		}
+		etx := new(ethtypes.Transaction)
+		if err := etx.UnmarshalBinary(raw); err != nil {
+			return nil, err
+		}
+		return etx, nil
+	}
+
+	value, err := parseAmount(tx.Value)
+	if err != nil {
+		return nil, err
+	}
+	gasPrice, err := parseAmount(tx.GasPrice)
+	if err != nil {
+		return nil, err
+	}
+	input, err := hex.DecodeString(tx.Payload)
+	if err != nil {
+		return nil, err
+	}
+	return ethtypes.NewTx(&ethtypes.LegacyTx{
+		Nonce:    uint64(tx.Nonce),
+		GasPrice: utils.ToWei(gasPrice),
+		Gas:      uint64(tx.GasLimit),
+		To:       txTo(tx),
+		Value:    utils.ToWei(value),
+		Data:     input,
+	}), nil
+}
+
+// newReceipt 参与receiptsRoot计算的收据字段
+func newReceipt(tx *database.V3Transaction, cumulativeGasUsed uint64, logs []*ethtypes.Log) *ethtypes.Receipt {
+	receipt := &ethtypes.Receipt{
+		Type:              ethtypes.LegacyTxType,
+		CumulativeGasUsed: cumulativeGasUsed,
+		Logs:              logs,
+		Bloom:             logsBloom(logs),
+	}
+	if tx.Codei == 0 {
+		receipt.Status = ethtypes.ReceiptStatusSuccessful
+	}
+	return receipt
+}
+
+func newLog(row *database.V3Log, ledger *database.V3Ledger) *ethtypes.Log {
+	log := &ethtypes.Log{
+		Address:     common.HexToAddress(row.Address),
+		Topics:      []common.Hash{},
+		Data:        common.FromHex(row.Data),
+		BlockNumber: uint64(row.Height),
+		TxHash:      common.HexToHash(row.Hash),
+		TxIndex:     uint(row.TxIdx),
+		Index:       row.LogIdx,
+	}
+	if ledger != nil {
+		log.BlockHash = ledgerHash(ledger)
+	}
+	for _, topic := range []string{row.Topic0, row.Topic1, row.Topic2, row.Topic3} {
+		if topic == "" {
+			break
+		}
+		log.Topics = append(log.Topics, common.HexToHash(topic))
+	}
+	return log
+}
+
+func logsBloom(logs []*ethtypes.Log) ethtypes.Bloom {
+	var bloom ethtypes.Bloom
+	for _, log := range logs {
+		bloom.Add(log.Address.Bytes())
+		for _, topic := range log.Topics {
+			bloom.Add(topic.Bytes())
+		}
+	}
+	return bloom
+}
+
+// parseAmount 库中的整数金额，批量交易等没有金额时为空
+func parseAmount(s string) (*big.Int, error) {
+	if s == "" {
+		return new(big.Int), nil
+	}
+	v, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid amount %q", s)
+	}
+	return v, nil
+}
+
+// isCreation 创建合约的交易接收方记为全零地址
+func isCreation(tx *database.V3Transaction) bool {
+	return tx.Receiver == (common.Address{}).Hex()
+}
+
+// txTo 创建合约及没有单一接收方的交易为nil
+func txTo(tx *database.V3Transaction) *common.Address {
+	if tx.Receiver == "" || isCreation(tx) {
+		return nil
+	}
+	to := common.HexToAddress(tx.Receiver)
+	return &to
+}
+
+// ledgerHash 库中区块hash为tendermint格式的大写hex
+func ledgerHash(ledger *database.V3Ledger) common.Hash {
+	return common.HexToHash(ledger.BlockHash)
+}
+
+func blockHashKey(hash common.Hash) string {
+	return strings.ToUpper(hex.EncodeToString(hash[:]))
+}
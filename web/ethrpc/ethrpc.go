@@ -0,0 +1,37 @@
+package ethrpc
+
+import (
+	"errors"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/toolglobal/api/web/dbo"
+)
+
+/*
+以太坊JSON-RPC兼容层
+1. 区块、交易、收据、日志从v3表查询，返回格式与go-ethereum一致
+2. 账户状态、合约调用等转发给节点的web3 JSON-RPC
+*/
+
+var errNoNode = errors.New("state queries are not available: web3RPC is not configured")
+
+// NewServer 创建/rpc的JSON-RPC服务，nodeURL为节点web3 JSON-RPC地址，为空时不支持状态查询
+func NewServer(dbo3 *dbo.DBO, nodeURL string) (*rpc.Server, error) {
+	api := &EthAPI{dbo3: dbo3}
+	if nodeURL != "" {
+		node, err := rpc.DialHTTP(nodeURL)
+		if err != nil {
+			return nil, err
+		}
+		api.node = node
+	}
+
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", api); err != nil {
+		return nil, err
+	}
+	if err := server.RegisterName("net", &NetAPI{node: api.node}); err != nil {
+		return nil, err
+	}
+	return server, nil
+}
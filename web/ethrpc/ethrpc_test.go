@@ -0,0 +1,366 @@
+package ethrpc
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/toolglobal/api/client"
+	"github.com/toolglobal/api/database"
+	"github.com/toolglobal/api/datamanager/datamanagertest"
+	"github.com/toolglobal/api/web/dbo"
+)
+
+const (
+	blockHash1 = "1111111111111111111111111111111111111111111111111111111111111111"
+	blockHash2 = "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"
+	transfer   = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+)
+
+var (
+	alice    = common.HexToAddress("0x00000000000000000000000000000000000A11cE")
+	bob      = common.HexToAddress("0x0000000000000000000000000000000000000B0b")
+	token    = common.HexToAddress("0x1000000000000000000000000000000000000001")
+	callHash = common.HexToHash("0x01")
+	newHash  = common.HexToHash("0x02")
+
+	// ethTx 以太坊交易，金额、gas价格不是1e10的整数倍，库中换算后有精度损失
+	ethTx = signEthTx()
+)
+
+func signEthTx() *ethtypes.Transaction {
+	key, err := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	if err != nil {
+		panic(err)
+	}
+	tx := ethtypes.NewTx(&ethtypes.LegacyTx{Nonce: 3, GasPrice: big.NewInt(1000000001), Gas: 21000, To: &bob, Value: big.NewInt(123456789012345678)})
+	tx, err = ethtypes.SignTx(tx, ethtypes.NewEIP155Signer(big.NewInt(0x41)), key)
+	if err != nil {
+		panic(err)
+	}
+	return tx
+}
+
+// fakeNode 模拟节点的web3 JSON-RPC
+type fakeNode struct{}
+
+func (fakeNode) ChainId() hexutil.Uint64 { return 0x41 }
+
+func (fakeNode) GetBalance(address common.Address, block *rpc.BlockNumberOrHash) *hexutil.Big {
+	if address != alice || block == nil {
+		return (*hexutil.Big)(new(big.Int))
+	}
+	return (*hexutil.Big)(big.NewInt(42))
+}
+
+func newTestEthClient(t *testing.T) (*ethclient.Client, *rpc.Client) {
+	dataM := datamanagertest.New(t)
+
+	now := time.Unix(1600000000, 0)
+	batch, err := dataM.BeginV3Batch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ledgers := []database.V3Ledger{
+		{Height: 1, BlockHash: blockHash1, BlockSize: 100, Validator: "0A0B0C0D0E0F0A0B0C0D0E0F0A0B0C0D0E0F0A0B", GasPrice: "0", CreatedAt: now},
+		{Height: 2, BlockHash: blockHash2, BlockSize: 300, Validator: "0A0B0C0D0E0F0A0B0C0D0E0F0A0B0C0D0E0F0A0B", TxCount: 3,
+			GasLimit: 221000, GasUsed: 101000, GasPrice: "100", CreatedAt: now.Add(time.Second)},
+	}
+	txs := []database.V3Transaction{
+		{Hash: callHash.Hex(), Height: 2, TxIdx: 0, Typei: 1, Types: "TxTagAppEvm", Sender: alice.Hex(), Nonce: 6, Receiver: token.Hex(),
+			Value: "5", GasLimit: 100000, GasUsed: 30000, GasPrice: "100", Payload: "a9059cbb", CreatedAt: now.Add(time.Second)},
+		{Hash: newHash.Hex(), Height: 2, TxIdx: 1, Typei: 1, Types: "TxTagAppEvm", Sender: alice.Hex(), Nonce: 7, Receiver: (common.Address{}).Hex(),
+			Value: "0", GasLimit: 100000, GasUsed: 50000, GasPrice: "100", Payload: "6080", CreatedAt: now.Add(time.Second)},
+		{Hash: ethTx.Hash().Hex(), Height: 2, TxIdx: 2, Typei: 5, Types: "TxTagEthereumTx", Sender: ethSender().Hex(), Nonce: 3, Receiver: bob.Hex(),
+			Value: "12345678", GasLimit: 21000, GasUsed: 21000, GasPrice: "0", CreatedAt: now.Add(time.Second), RawTx: ethRawTx()},
+	}
+	for i := range ledgers {
+		if err := batch.AddLedger(&ledgers[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := range txs {
+		if err := batch.AddTransaction(&txs[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := batch.AddLog(&database.V3Log{Hash: callHash.Hex(), Height: 2, TxIdx: 0, LogIdx: 0, Address: token.Hex(), Topic0: transfer,
+		Topic1: alice.Hash().Hex(), Topic2: bob.Hash().Hex(), Data: "0x05", CreatedAt: now.Add(time.Second)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := batch.SetSyncState(database.SyncStateV3, 2, blockHash2, client.IndexerVersion); err != nil {
+		t.Fatal(err)
+	}
+	if err := batch.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	node := rpc.NewServer()
+	if err := node.RegisterName("eth", fakeNode{}); err != nil {
+		t.Fatal(err)
+	}
+	nodeHTTP := httptest.NewServer(node)
+	t.Cleanup(nodeHTTP.Close)
+
+	server, err := NewServer(dbo.New(dataM), nodeHTTP.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+
+	rc, err := rpc.Dial(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(rc.Close)
+	return ethclient.NewClient(rc), rc
+}
+
+func TestEthAPI_Blocks(t *testing.T) {
+	ec, _ := newTestEthClient(t)
+	ctx := context.Background()
+
+	number, err := ec.BlockNumber(ctx)
+	if err != nil || number != 2 {
+		t.Fatalf("blockNumber %d %v", number, err)
+	}
+
+	// go-ethereum客户端能解析并校验返回的区块
+	block, err := ec.BlockByNumber(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if block.NumberU64() != 2 || block.Time() != 1600000001 || block.GasUsed() != 101000 || len(block.Transactions()) != 3 {
+		t.Fatalf("block %+v", block.Header())
+	}
+	if block.ParentHash() != common.HexToHash(blockHash1) || block.Coinbase() != common.HexToAddress("0A0B0C0D0E0F0A0B0C0D0E0F0A0B0C0D0E0F0A0B") {
+		t.Fatalf("block header %+v", block.Header())
+	}
+	if !block.Bloom().Test(token.Bytes()) || !block.Bloom().Test(common.HexToHash(transfer).Bytes()) {
+		t.Fatal("logsBloom does not contain the log")
+	}
+	tx := block.Transactions()[0]
+	if tx.Value().Cmp(big.NewInt(5e10)) != 0 || tx.GasPrice().Cmp(big.NewInt(100e10)) != 0 || *tx.To() != token || tx.Nonce() != 6 {
+		t.Fatalf("tx value %s gasPrice %s to %s nonce %d", tx.Value(), tx.GasPrice(), tx.To().Hex(), tx.Nonce())
+	}
+	if block.Transactions()[1].To() != nil {
+		t.Fatal("contract creation has a receiver")
+	}
+
+	// transactionsRoot、receiptsRoot与客户端按交易、收据计算的结果一致
+	receipts := make(ethtypes.Receipts, 0, len(block.Transactions()))
+	for _, hash := range []common.Hash{callHash, newHash, ethTx.Hash()} {
+		receipt, err := ec.TransactionReceipt(ctx, hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		receipts = append(receipts, receipt)
+	}
+	if block.TxHash() != ethtypes.DeriveSha(block.Transactions(), trie.NewStackTrie(nil)) ||
+		block.ReceiptHash() != ethtypes.DeriveSha(receipts, trie.NewStackTrie(nil)) {
+		t.Fatalf("transactionsRoot %s receiptsRoot %s", block.TxHash().Hex(), block.ReceiptHash().Hex())
+	}
+	// 与以太坊不同之处：stateRoot为零；区块hash是tendermint区块hash，与客户端按区块头计算的不同；
+	// 非以太坊交易没有签名，客户端计算的交易hash与库中不同
+	if block.Root() != (common.Hash{}) || block.Hash() == common.HexToHash(blockHash2) {
+		t.Fatalf("stateRoot %s hash %s", block.Root().Hex(), block.Hash().Hex())
+	}
+	if block.Transactions()[0].Hash() == callHash || block.Transactions()[2].Hash() != ethTx.Hash() {
+		t.Fatal("unexpected tx hashes")
+	}
+
+	// 空区块
+	header, err := ec.HeaderByHash(ctx, common.HexToHash(blockHash1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Number.Int64() != 1 || header.ParentHash != (common.Hash{}) {
+		t.Fatalf("header %+v", header)
+	}
+	if header.TxHash != ethtypes.EmptyRootHash || header.ReceiptHash != ethtypes.EmptyRootHash {
+		t.Fatalf("empty block roots %s %s", header.TxHash.Hex(), header.ReceiptHash.Hex())
+	}
+	if _, err := ec.BlockByNumber(ctx, big.NewInt(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ec.HeaderByNumber(ctx, big.NewInt(3)); err != ethereum.NotFound {
+		t.Fatalf("unknown block %v", err)
+	}
+}
+
+func TestEthAPI_Transactions(t *testing.T) {
+	ec, rc := newTestEthClient(t)
+	ctx := context.Background()
+
+	tx, pending, err := ec.TransactionByHash(ctx, callHash)
+	if err != nil || pending {
+		t.Fatal(err)
+	}
+	if common.Bytes2Hex(tx.Data()) != "a9059cbb" || tx.Gas() != 100000 {
+		t.Fatalf("tx %+v", tx)
+	}
+	// 以太坊交易返回签名，客户端计算的hash与库中一致，并能恢复出发送方
+	tx, _, err = ec.TransactionByHash(ctx, ethTx.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, r, s := tx.RawSignatureValues()
+	wantV, wantR, wantS := ethTx.RawSignatureValues()
+	if tx.Hash() != ethTx.Hash() || v.Cmp(wantV) != 0 || r.Cmp(wantR) != 0 || s.Cmp(wantS) != 0 {
+		t.Fatalf("eth tx hash %s v %s r %s s %s", tx.Hash().Hex(), v, r, s)
+	}
+	if tx.Value().Cmp(ethTx.Value()) != 0 || tx.GasPrice().Cmp(ethTx.GasPrice()) != 0 {
+		t.Fatalf("eth tx value %s gasPrice %s", tx.Value(), tx.GasPrice())
+	}
+	if sender, err := ethtypes.Sender(ethtypes.NewEIP155Signer(big.NewInt(0x41)), tx); err != nil || sender != ethSender() {
+		t.Fatalf("eth tx sender %s %v", sender.Hex(), err)
+	}
+	if _, _, err := ec.TransactionByHash(ctx, common.HexToHash("0x03")); err != ethereum.NotFound {
+		t.Fatalf("unknown tx %v", err)
+	}
+
+	receipt, err := ec.TransactionReceipt(ctx, callHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt.Status != ethtypes.ReceiptStatusSuccessful || receipt.GasUsed != 30000 || receipt.CumulativeGasUsed != 30000 ||
+		receipt.BlockHash != common.HexToHash(blockHash2) || len(receipt.Logs) != 1 {
+		t.Fatalf("receipt %+v", receipt)
+	}
+	l := receipt.Logs[0]
+	if l.Address != token || len(l.Topics) != 3 || l.Topics[2] != bob.Hash() || common.Bytes2Hex(l.Data) != "05" {
+		t.Fatalf("log %+v", l)
+	}
+
+	receipt, err = ec.TransactionReceipt(ctx, newHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt.CumulativeGasUsed != 80000 || receipt.TransactionIndex != 1 || receipt.ContractAddress != crypto.CreateAddress(alice, 7) {
+		t.Fatalf("creation receipt %+v", receipt)
+	}
+
+	// 没有日志时为空数组
+	var raw map[string]json.RawMessage
+	if err := rc.CallContext(ctx, &raw, "eth_getTransactionReceipt", newHash); err != nil {
+		t.Fatal(err)
+	}
+	if string(raw["logs"]) != "[]" || string(raw["to"]) != "null" || string(raw["status"]) != `"0x1"` {
+		t.Fatalf("raw receipt logs %s to %s status %s", raw["logs"], raw["to"], raw["status"])
+	}
+}
+
+func TestEthAPI_GetLogs(t *testing.T) {
+	ec, _ := newTestEthClient(t)
+	ctx := context.Background()
+
+	cases := []struct {
+		name  string
+		query ethereum.FilterQuery
+		want  int
+	}{
+		{"latest", ethereum.FilterQuery{}, 1},
+		{"range", ethereum.FilterQuery{FromBlock: big.NewInt(1), ToBlock: big.NewInt(1)}, 0},
+		{"block hash", ethereum.FilterQuery{BlockHash: hashPtr(common.HexToHash(blockHash2))}, 1},
+		{"address", ethereum.FilterQuery{FromBlock: big.NewInt(1), Addresses: []common.Address{bob, token}}, 1},
+		{"other address", ethereum.FilterQuery{Addresses: []common.Address{bob}}, 0},
+		{"topic", ethereum.FilterQuery{Topics: [][]common.Hash{{common.HexToHash(transfer)}, nil, {bob.Hash()}}}, 1},
+		{"other topic", ethereum.FilterQuery{Topics: [][]common.Hash{nil, {bob.Hash()}}}, 0},
+	}
+	for _, c := range cases {
+		logs, err := ec.FilterLogs(ctx, c.query)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if len(logs) != c.want {
+			t.Fatalf("%s: got %+v", c.name, logs)
+		}
+		for _, l := range logs {
+			if l.TxHash != callHash || l.BlockHash != common.HexToHash(blockHash2) || l.BlockNumber != 2 {
+				t.Fatalf("%s: log %+v", c.name, l)
+			}
+		}
+	}
+
+	if _, err := ec.FilterLogs(ctx, ethereum.FilterQuery{BlockHash: hashPtr(common.HexToHash("0x03"))}); err == nil {
+		t.Fatal("want error for unknown block hash")
+	}
+}
+
+func TestEthAPI_Proxy(t *testing.T) {
+	ec, _ := newTestEthClient(t)
+	ctx := context.Background()
+
+	chainID, err := ec.ChainID(ctx)
+	if err != nil || chainID.Int64() != 0x41 {
+		t.Fatalf("chainId %v %v", chainID, err)
+	}
+	balance, err := ec.BalanceAt(ctx, alice, nil)
+	if err != nil || balance.Int64() != 42 {
+		t.Fatalf("balance %v %v", balance, err)
+	}
+	// 节点未实现的方法返回节点的错误
+	if _, err := ec.CodeAt(ctx, alice, nil); err == nil {
+		t.Fatal("want error from node")
+	}
+}
+
+func TestFilterCriteria_UnmarshalJSON(t *testing.T) {
+	var crit FilterCriteria
+	input := `{"fromBlock":"0x1","toBlock":"latest","address":"0x1000000000000000000000000000000000000001",
+		"topics":[null,"0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef",["0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef",null]]}`
+	if err := json.Unmarshal([]byte(input), &crit); err != nil {
+		t.Fatal(err)
+	}
+	if crit.FromBlock.Int64() != 1 || crit.ToBlock.Int64() != int64(rpc.LatestBlockNumber) || len(crit.Addresses) != 1 || crit.Addresses[0] != token {
+		t.Fatalf("crit %+v", crit)
+	}
+	if len(crit.Topics) != 3 || crit.Topics[0] != nil || len(crit.Topics[1]) != 1 || crit.Topics[2] != nil {
+		t.Fatalf("topics %+v", crit.Topics)
+	}
+
+	for _, input := range []string{
+		`{"blockHash":"0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa","fromBlock":"0x1"}`,
+		`{"address":"0x01"}`,
+		`{"address":[1]}`,
+		`{"topics":["0x01"]}`,
+		`{"topics":[[1]]}`,
+	} {
+		if err := json.Unmarshal([]byte(input), &crit); err == nil {
+			t.Fatalf("want error for %s", input)
+		}
+	}
+}
+
+func ethSender() common.Address {
+	sender, err := ethtypes.Sender(ethtypes.NewEIP155Signer(big.NewInt(0x41)), ethTx)
+	if err != nil {
+		panic(err)
+	}
+	return sender
+}
+
+func ethRawTx() string {
+	raw, err := ethTx.MarshalBinary()
+	if err != nil {
+		panic(err)
+	}
+	return hexutil.Encode(raw)
+}
+
+func hashPtr(h common.Hash) *common.Hash {
+	return &h
+}
@@ -0,0 +1,127 @@
+package ethrpc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// FilterCriteria eth_getLogs的查询条件，解析规则与go-ethereum的eth/filters一致
+type FilterCriteria struct {
+	BlockHash *common.Hash     // 指定区块hash时不能再指定高度范围
+	FromBlock *big.Int         // 为nil或负数(latest、pending)时取已同步的高度
+	ToBlock   *big.Int         // 同FromBlock
+	Addresses []common.Address // 合约地址，满足任一即可
+	Topics    [][]common.Hash  // 每个位置满足任一即可，nil表示不限
+}
+
+func (args *FilterCriteria) UnmarshalJSON(data []byte) error {
+	type input struct {
+		BlockHash *common.Hash     `json:"blockHash"`
+		FromBlock *rpc.BlockNumber `json:"fromBlock"`
+		ToBlock   *rpc.BlockNumber `json:"toBlock"`
+		Addresses interface{}      `json:"address"`
+		Topics    []interface{}    `json:"topics"`
+	}
+
+	var raw input
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	if raw.BlockHash != nil {
+		if raw.FromBlock != nil || raw.ToBlock != nil {
+			return errors.New("cannot specify both BlockHash and FromBlock/ToBlock, choose one or the other")
+		}
+		args.BlockHash = raw.BlockHash
+	} else {
+		if raw.FromBlock != nil {
+			args.FromBlock = big.NewInt(raw.FromBlock.Int64())
+		}
+		if raw.ToBlock != nil {
+			args.ToBlock = big.NewInt(raw.ToBlock.Int64())
+		}
+	}
+
+	// address可以是单个地址或地址数组
+	switch addrs := raw.Addresses.(type) {
+	case nil:
+	case string:
+		addr, err := decodeAddress(addrs)
+		if err != nil {
+			return fmt.Errorf("invalid address: %v", err)
+		}
+		args.Addresses = []common.Address{addr}
+	case []interface{}:
+		for i, v := range addrs {
+			s, ok := v.(string)
+			if !ok {
+				return fmt.Errorf("non-string address at index %d", i)
+			}
+			addr, err := decodeAddress(s)
+			if err != nil {
+				return fmt.Errorf("invalid address at index %d: %v", i, err)
+			}
+			args.Addresses = append(args.Addresses, addr)
+		}
+	default:
+		return errors.New("invalid addresses in query")
+	}
+
+	// topics每个位置可以是null、单个topic或topic数组，数组中含null时该位置不限
+	if len(raw.Topics) > 0 {
+		args.Topics = make([][]common.Hash, len(raw.Topics))
+		for i, t := range raw.Topics {
+			switch topic := t.(type) {
+			case nil:
+			case string:
+				parsed, err := decodeTopic(topic)
+				if err != nil {
+					return err
+				}
+				args.Topics[i] = []common.Hash{parsed}
+			case []interface{}:
+				for _, v := range topic {
+					if v == nil {
+						args.Topics[i] = nil
+						break
+					}
+					s, ok := v.(string)
+					if !ok {
+						return errors.New("invalid topic(s)")
+					}
+					parsed, err := decodeTopic(s)
+					if err != nil {
+						return err
+					}
+					args.Topics[i] = append(args.Topics[i], parsed)
+				}
+			default:
+				return errors.New("invalid topic(s)")
+			}
+		}
+	}
+
+	return nil
+}
+
+func decodeAddress(s string) (common.Address, error) {
+	b, err := hexutil.Decode(s)
+	if err == nil && len(b) != common.AddressLength {
+		err = fmt.Errorf("hex has invalid length %d after decoding; expected %d for address", len(b), common.AddressLength)
+	}
+	return common.BytesToAddress(b), err
+}
+
+func decodeTopic(s string) (common.Hash, error) {
+	b, err := hexutil.Decode(s)
+	if err == nil && len(b) != common.HashLength {
+		err = fmt.Errorf("hex has invalid length %d after decoding; expected %d for topic", len(b), common.HashLength)
+	}
+	return common.BytesToHash(b), err
+}
@@ -0,0 +1,70 @@
+package ethrpc
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// 以下状态查询原样转发给节点，可选参数未传时不转发
+
+func (api *EthAPI) ChainId(ctx context.Context) (json.RawMessage, error) {
+	return forward(ctx, api.node, "eth_chainId")
+}
+
+func (api *EthAPI) GasPrice(ctx context.Context) (json.RawMessage, error) {
+	return forward(ctx, api.node, "eth_gasPrice")
+}
+
+func (api *EthAPI) GetBalance(ctx context.Context, address json.RawMessage, block *json.RawMessage) (json.RawMessage, error) {
+	return forward(ctx, api.node, "eth_getBalance", &address, block)
+}
+
+func (api *EthAPI) GetCode(ctx context.Context, address json.RawMessage, block *json.RawMessage) (json.RawMessage, error) {
+	return forward(ctx, api.node, "eth_getCode", &address, block)
+}
+
+func (api *EthAPI) GetStorageAt(ctx context.Context, address, key json.RawMessage, block *json.RawMessage) (json.RawMessage, error) {
+	return forward(ctx, api.node, "eth_getStorageAt", &address, &key, block)
+}
+
+func (api *EthAPI) GetTransactionCount(ctx context.Context, address json.RawMessage, block *json.RawMessage) (json.RawMessage, error) {
+	return forward(ctx, api.node, "eth_getTransactionCount", &address, block)
+}
+
+func (api *EthAPI) Call(ctx context.Context, args json.RawMessage, block *json.RawMessage) (json.RawMessage, error) {
+	return forward(ctx, api.node, "eth_call", &args, block)
+}
+
+func (api *EthAPI) EstimateGas(ctx context.Context, args json.RawMessage, block *json.RawMessage) (json.RawMessage, error) {
+	return forward(ctx, api.node, "eth_estimateGas", &args, block)
+}
+
+// NetAPI net命名空间，转发给节点
+type NetAPI struct {
+	node *rpc.Client
+}
+
+func (api *NetAPI) Version(ctx context.Context) (json.RawMessage, error) {
+	return forward(ctx, api.node, "net_version")
+}
+
+func forward(ctx context.Context, node *rpc.Client, method string, args ...*json.RawMessage) (json.RawMessage, error) {
+	if node == nil {
+		return nil, errNoNode
+	}
+	for len(args) > 0 && args[len(args)-1] == nil {
+		args = args[:len(args)-1]
+	}
+	params := make([]interface{}, len(args))
+	for i, arg := range args {
+		params[i] = arg
+	}
+
+	var result json.RawMessage
+	if err := node.CallContext(ctx, &result, method, params...); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
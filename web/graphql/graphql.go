@@ -0,0 +1,59 @@
+package graphql
+
+import (
+	"encoding/json"
+	"net/http"
+
+	graphqlgo "github.com/graph-gophers/graphql-go"
+	"github.com/toolglobal/api/web/dbo"
+)
+
+/*
+GraphQL查询接口
+1. 区块、交易、转账及其嵌套关系(区块 -> 交易 -> 转账)一次请求返回
+2. 嵌套字段通过请求内的批量加载器查询，每层只需一次SQL
+*/
+
+// maxDepth 查询的最大嵌套深度，避免区块、交易、转账之间循环嵌套
+const maxDepth = 8
+
+type Handler struct {
+	schema *graphqlgo.Schema
+	db     store
+}
+
+// NewHandler 创建/graphql的http处理器
+func NewHandler(dbo3 *dbo.DBO) (*Handler, error) {
+	return newHandler(dbo3)
+}
+
+func newHandler(db store) (*Handler, error) {
+	s, err := graphqlgo.ParseSchema(schema, &resolver{db: db}, graphqlgo.MaxDepth(maxDepth))
+	if err != nil {
+		return nil, err
+	}
+	return &Handler{schema: s, db: db}, nil
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var params struct {
+		Query         string                 `json:"query"`
+		OperationName string                 `json:"operationName"`
+		Variables     map[string]interface{} `json:"variables"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := withLoaders(r.Context(), newLoaders(h.db))
+	response := h.schema.Exec(ctx, params.Query, params.OperationName, params.Variables)
+	bz, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(bz)
+}
@@ -0,0 +1,248 @@
+package graphql
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/toolglobal/api/database"
+	"github.com/toolglobal/api/datamanager/datamanagertest"
+	"github.com/toolglobal/api/web/dbo"
+)
+
+// countingStore 记录批量查询的次数
+type countingStore struct {
+	*dbo.DBO
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func (s *countingStore) count(name string) {
+	s.mu.Lock()
+	s.calls[name]++
+	s.mu.Unlock()
+}
+
+func (s *countingStore) QueryV3LedgersByHeights(heights []int64) ([]database.V3Ledger, error) {
+	s.count("ledgers")
+	return s.DBO.QueryV3LedgersByHeights(heights)
+}
+
+func (s *countingStore) QueryV3TxsByHeights(heights []int64) ([]database.V3Transaction, error) {
+	s.count("blockTxs")
+	return s.DBO.QueryV3TxsByHeights(heights)
+}
+
+func (s *countingStore) QueryV3TxsByHashes(hashes []string) ([]database.V3Transaction, error) {
+	s.count("txs")
+	return s.DBO.QueryV3TxsByHashes(hashes)
+}
+
+func (s *countingStore) QueryV3PaymentsByHashes(hashes []string) ([]database.V3Payment, error) {
+	s.count("payments")
+	return s.DBO.QueryV3PaymentsByHashes(hashes)
+}
+
+// newTestServer 3个区块，每个区块2笔交易，每笔交易2笔转账
+func newTestServer(t *testing.T) (*httptest.Server, *countingStore) {
+	dataM := datamanagertest.New(t)
+
+	now := time.Unix(1600000000, 0)
+	batch, err := dataM.BeginV3Batch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for h := int64(1); h <= 3; h++ {
+		if err := batch.AddLedger(&database.V3Ledger{Height: h, BlockHash: fmt.Sprintf("%064X", h), TxCount: 2, GasPrice: "1", CreatedAt: now}); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 2; i++ {
+			hash := fmt.Sprintf("0x%02d%02d", h, i)
+			tx := &database.V3Transaction{Hash: hash, Height: h, TxIdx: i, Types: "TxTagAppEvm", Sender: "0xA11cE", Receiver: "0xB0b",
+				Value: "1", GasPrice: "1", CreatedAt: now}
+			if err := batch.AddTransaction(tx); err != nil {
+				t.Fatal(err)
+			}
+			for j := 0; j < 2; j++ {
+				p := &database.V3Payment{Hash: hash, Height: h, Idx: uint(j), Sender: "0xA11cE", Receiver: "0xB0b", Symbol: "OLO",
+					Value: fmt.Sprint(j + 1), CreatedAt: now}
+				if err := batch.AddPayment(p); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+	if err := batch.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	db := &countingStore{DBO: dbo.New(dataM), calls: make(map[string]int)}
+	h, err := newHandler(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(h)
+	t.Cleanup(ts.Close)
+	return ts, db
+}
+
+func exec(t *testing.T, ts *httptest.Server, query string, variables map[string]interface{}, data interface{}) []string {
+	bz, _ := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	resp, err := http.Post(ts.URL, "application/json", bytes.NewReader(bz))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Data   json.RawMessage
+		Errors []struct{ Message string }
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	var errs []string
+	for _, e := range result.Errors {
+		errs = append(errs, e.Message)
+	}
+	if data != nil && len(errs) == 0 {
+		if err := json.Unmarshal(result.Data, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return errs
+}
+
+func TestGraphQL_NestedBatching(t *testing.T) {
+	ts, db := newTestServer(t)
+
+	query := `{
+		blocks(first: 3, order: "ASC") {
+			nodes {
+				height
+				transactions {
+					hash
+					block { height }
+					payments { value transaction { hash } }
+				}
+			}
+			nextCursor
+		}
+	}`
+	var data struct {
+		Blocks struct {
+			Nodes []struct {
+				Height       int64
+				Transactions []struct {
+					Hash     string
+					Block    struct{ Height int64 }
+					Payments []struct {
+						Value       string
+						Transaction struct{ Hash string }
+					}
+				}
+			}
+			NextCursor *string
+		}
+	}
+	if errs := exec(t, ts, query, nil, &data); errs != nil {
+		t.Fatal(errs)
+	}
+
+	if len(data.Blocks.Nodes) != 3 || data.Blocks.NextCursor == nil {
+		t.Fatalf("blocks %+v", data.Blocks)
+	}
+	for i, b := range data.Blocks.Nodes {
+		if b.Height != int64(i+1) || len(b.Transactions) != 2 {
+			t.Fatalf("block %+v", b)
+		}
+		for j, tx := range b.Transactions {
+			if tx.Hash != fmt.Sprintf("0x%02d%02d", b.Height, j) || tx.Block.Height != b.Height || len(tx.Payments) != 2 {
+				t.Fatalf("tx %+v", tx)
+			}
+			if tx.Payments[1].Value != "2" || tx.Payments[1].Transaction.Hash != tx.Hash {
+				t.Fatalf("payments %+v", tx.Payments)
+			}
+		}
+	}
+
+	// 每层一次查询
+	want := map[string]int{"ledgers": 1, "blockTxs": 1, "txs": 1, "payments": 1}
+	for name, n := range want {
+		if db.calls[name] != n {
+			t.Fatalf("%s queried %d times, calls %v", name, db.calls[name], db.calls)
+		}
+	}
+}
+
+func TestGraphQL_Paging(t *testing.T) {
+	ts, _ := newTestServer(t)
+
+	query := `query($after: String) {
+		transactions(first: 4, order: "ASC", after: $after) { nodes { hash } nextCursor }
+	}`
+	type page struct {
+		Transactions struct {
+			Nodes      []struct{ Hash string }
+			NextCursor *string
+		}
+	}
+	var hashes []string
+	variables := map[string]interface{}{}
+	for i := 0; i < 3; i++ {
+		var data page
+		if errs := exec(t, ts, query, variables, &data); errs != nil {
+			t.Fatal(errs)
+		}
+		for _, n := range data.Transactions.Nodes {
+			hashes = append(hashes, n.Hash)
+		}
+		if data.Transactions.NextCursor == nil {
+			break
+		}
+		variables["after"] = *data.Transactions.NextCursor
+	}
+	if len(hashes) != 6 || hashes[0] != "0x0100" || hashes[5] != "0x0301" {
+		t.Fatalf("hashes %v", hashes)
+	}
+
+	// 过滤条件，Long可以用字符串传入
+	var data struct {
+		Payments struct{ Nodes []struct{ Hash string } }
+		Block    struct{ BlockHash string }
+	}
+	errs := exec(t, ts, `query($h: Long!) { payments(height: $h) { nodes { hash } } block(height: $h) { blockHash } }`,
+		map[string]interface{}{"h": "2"}, &data)
+	if errs != nil {
+		t.Fatal(errs)
+	}
+	if len(data.Payments.Nodes) != 4 || data.Payments.Nodes[0].Hash[:4] != "0x02" || data.Block.BlockHash != fmt.Sprintf("%064X", 2) {
+		t.Fatalf("filtered %+v", data)
+	}
+
+	for _, query := range []string{
+		`{ transactions(height: 1, address: "0xA11cE") { nodes { hash } } }`,
+		`{ payments(hash: "0x0100", address: "0xA11cE") { nodes { hash } } }`,
+		`{ blocks(after: "bad") { nodes { height } } }`,
+	} {
+		if errs := exec(t, ts, query, nil, nil); errs == nil {
+			t.Fatalf("want error for %s", query)
+		}
+	}
+
+	var missing struct {
+		Block       *struct{ Height int64 }
+		Transaction *struct{ Hash string }
+	}
+	if errs := exec(t, ts, `{ block(height: 9) { height } transaction(hash: "0x09") { hash } }`, nil, &missing); errs != nil {
+		t.Fatal(errs)
+	}
+	if missing.Block != nil || missing.Transaction != nil {
+		t.Fatalf("missing %+v", missing)
+	}
+}
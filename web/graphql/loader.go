@@ -0,0 +1,238 @@
+package graphql
+
+import (
+	"context"
+	"sync"
+
+	"github.com/toolglobal/api/database"
+)
+
+// maxBatch 单次in查询的key数量上限
+const maxBatch = 500
+
+/*
+请求内的批量加载器
+1. 解析列表时把下一层要用到的key登记到pending(prime)
+2. 第一次load时把全部登记的key分批一次查出并缓存，同级的其余load直接命中缓存
+3. 加载一层数据后立即登记下一层的key，嵌套查询每层只需一次SQL，避免N+1
+*/
+type loader struct {
+	fetch func(keys []interface{}) (map[interface{}]interface{}, error)
+
+	mu      sync.Mutex // 保护results，查询期间持有，同一时刻只有一次查询
+	results map[interface{}]interface{}
+	errs    map[interface{}]error
+
+	pmu     sync.Mutex // 只保护pending，持有期间不获取其它锁
+	pending []interface{}
+}
+
+func newLoader(fetch func(keys []interface{}) (map[interface{}]interface{}, error)) *loader {
+	return &loader{
+		fetch:   fetch,
+		results: make(map[interface{}]interface{}),
+		errs:    make(map[interface{}]error),
+	}
+}
+
+// prime 登记稍后需要加载的key
+func (l *loader) prime(keys ...interface{}) {
+	l.pmu.Lock()
+	l.pending = append(l.pending, keys...)
+	l.pmu.Unlock()
+}
+
+// load 返回key对应的值，不存在时为nil
+func (l *loader) load(key interface{}) (interface{}, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if v, ok := l.results[key]; ok {
+		return v, nil
+	}
+	if err, ok := l.errs[key]; ok {
+		return nil, err
+	}
+
+	l.pmu.Lock()
+	pending := l.pending
+	l.pending = nil
+	l.pmu.Unlock()
+
+	seen := make(map[interface{}]bool)
+	var keys []interface{}
+	for _, k := range append(pending, key) {
+		if _, ok := l.results[k]; ok || seen[k] {
+			continue
+		}
+		seen[k] = true
+		keys = append(keys, k)
+	}
+
+	for len(keys) > 0 {
+		n := len(keys)
+		if n > maxBatch {
+			n = maxBatch
+		}
+		values, err := l.fetch(keys[:n])
+		for _, k := range keys[:n] {
+			if err != nil {
+				l.errs[k] = err
+			} else {
+				l.results[k] = values[k]
+			}
+		}
+		keys = keys[n:]
+	}
+
+	if err, ok := l.errs[key]; ok {
+		return nil, err
+	}
+	return l.results[key], nil
+}
+
+// store 解析器用到的查询，由dbo.DBO实现
+type store interface {
+	QueryV3LedgerByHeight(height int64) ([]database.V3Ledger, error)
+	QueryV3Ledgers(begin, end uint64, paging *database.Paging, order string) ([]database.V3Ledger, error)
+	QueryV3LedgersByHeights(heights []int64) ([]database.V3Ledger, error)
+	QueryV3SingleTx(txhash string) ([]database.V3Transaction, error)
+	QueryV3Txs(begin, end uint64, paging *database.Paging, order string) ([]database.V3Transaction, error)
+	QueryV3AccountTxs(address, direction string, begin, end uint64, paging *database.Paging, order string) ([]database.V3Transaction, error)
+	QueryV3BlockTxs(height int64, begin, end uint64, paging *database.Paging, order string) ([]database.V3Transaction, error)
+	QueryV3TxsByHashes(hashes []string) ([]database.V3Transaction, error)
+	QueryV3TxsByHeights(heights []int64) ([]database.V3Transaction, error)
+	QueryV3Payments(symbol, contract string, begin, end uint64, paging *database.Paging, order string) ([]database.V3Payment, error)
+	QueryV3TxPayments(txhash, symbol, contract string, begin, end uint64, paging *database.Paging, order string) ([]database.V3Payment, error)
+	QueryV3AccountPayments(address, symbol, contract string, begin, end uint64, paging *database.Paging, order string) ([]database.V3Payment, error)
+	QueryV3BlockPayments(height int64, symbol, contract string, begin, end uint64, paging *database.Paging, order string) ([]database.V3Payment, error)
+	QueryV3PaymentsByHashes(hashes []string) ([]database.V3Payment, error)
+}
+
+// loaders 一次请求的全部加载器
+type loaders struct {
+	ledgers  *loader // height -> *database.V3Ledger
+	blockTxs *loader // height -> []database.V3Transaction
+	txs      *loader // hash -> *database.V3Transaction
+	payments *loader // hash -> []database.V3Payment
+}
+
+func newLoaders(db store) *loaders {
+	ls := &loaders{}
+
+	ls.ledgers = newLoader(func(keys []interface{}) (map[interface{}]interface{}, error) {
+		rows, err := db.QueryV3LedgersByHeights(int64Keys(keys))
+		if err != nil {
+			return nil, err
+		}
+		ls.primeLedgers(rows)
+		result := make(map[interface{}]interface{}, len(rows))
+		for i := range rows {
+			result[rows[i].Height] = &rows[i]
+		}
+		return result, nil
+	})
+
+	ls.blockTxs = newLoader(func(keys []interface{}) (map[interface{}]interface{}, error) {
+		rows, err := db.QueryV3TxsByHeights(int64Keys(keys))
+		if err != nil {
+			return nil, err
+		}
+		ls.primeTxs(rows)
+		result := make(map[interface{}]interface{}, len(keys))
+		for _, k := range keys {
+			result[k] = []database.V3Transaction{}
+		}
+		for _, row := range rows {
+			result[row.Height] = append(result[row.Height].([]database.V3Transaction), row)
+		}
+		return result, nil
+	})
+
+	ls.txs = newLoader(func(keys []interface{}) (map[interface{}]interface{}, error) {
+		rows, err := db.QueryV3TxsByHashes(stringKeys(keys))
+		if err != nil {
+			return nil, err
+		}
+		ls.primeTxs(rows)
+		result := make(map[interface{}]interface{}, len(rows))
+		for i := range rows {
+			result[rows[i].Hash] = &rows[i]
+		}
+		return result, nil
+	})
+
+	ls.payments = newLoader(func(keys []interface{}) (map[interface{}]interface{}, error) {
+		rows, err := db.QueryV3PaymentsByHashes(stringKeys(keys))
+		if err != nil {
+			return nil, err
+		}
+		ls.primePayments(rows)
+		result := make(map[interface{}]interface{}, len(keys))
+		for _, k := range keys {
+			result[k] = []database.V3Payment{}
+		}
+		for _, row := range rows {
+			result[row.Hash] = append(result[row.Hash].([]database.V3Payment), row)
+		}
+		return result, nil
+	})
+
+	return ls
+}
+
+// primeLedgers 登记区块的交易
+func (ls *loaders) primeLedgers(rows []database.V3Ledger) {
+	keys := make([]interface{}, 0, len(rows))
+	for _, row := range rows {
+		keys = append(keys, row.Height)
+	}
+	ls.blockTxs.prime(keys...)
+}
+
+// primeTxs 登记交易所在的区块和交易的转账
+func (ls *loaders) primeTxs(rows []database.V3Transaction) {
+	heights := make([]interface{}, 0, len(rows))
+	hashes := make([]interface{}, 0, len(rows))
+	for _, row := range rows {
+		heights = append(heights, row.Height)
+		hashes = append(hashes, row.Hash)
+	}
+	ls.ledgers.prime(heights...)
+	ls.payments.prime(hashes...)
+}
+
+// primePayments 登记转账所属的交易
+func (ls *loaders) primePayments(rows []database.V3Payment) {
+	keys := make([]interface{}, 0, len(rows))
+	for _, row := range rows {
+		keys = append(keys, row.Hash)
+	}
+	ls.txs.prime(keys...)
+}
+
+type loadersKey struct{}
+
+func withLoaders(ctx context.Context, ls *loaders) context.Context {
+	return context.WithValue(ctx, loadersKey{}, ls)
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
+
+func int64Keys(keys []interface{}) []int64 {
+	result := make([]int64, len(keys))
+	for i, k := range keys {
+		result[i] = k.(int64)
+	}
+	return result
+}
+
+func stringKeys(keys []interface{}) []string {
+	result := make([]string, len(keys))
+	for i, k := range keys {
+		result[i] = k.(string)
+	}
+	return result
+}
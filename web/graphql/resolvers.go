@@ -0,0 +1,364 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/toolglobal/api/database"
+)
+
+// Long 64位整数，输入可以是数字或十进制字符串
+type Long int64
+
+func (Long) ImplementsGraphQLType(name string) bool {
+	return name == "Long"
+}
+
+func (l *Long) UnmarshalGraphQL(input interface{}) error {
+	switch v := input.(type) {
+	case int32:
+		*l = Long(v)
+	case int64:
+		*l = Long(v)
+	case float64:
+		*l = Long(v)
+	case string:
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return err
+		}
+		*l = Long(n)
+	default:
+		return fmt.Errorf("wrong type for Long: %T", input)
+	}
+	return nil
+}
+
+type resolver struct {
+	db store
+}
+
+type pageArgs struct {
+	First *int32
+	After *string
+	Order *string
+	Begin *Long
+	End   *Long
+}
+
+// paging 按id游标分页，first默认10、最多200
+func (args *pageArgs) paging() (*database.Paging, error) {
+	var limit uint64
+	if args.First != nil {
+		if *args.First < 0 {
+			return nil, errors.New("first must not be negative")
+		}
+		limit = uint64(*args.First)
+	}
+	var after uint64
+	if args.After != nil && *args.After != "" {
+		var err error
+		if after, err = database.DecodeCursor(*args.After); err != nil {
+			return nil, err
+		}
+	}
+	return database.MakeKeysetPaging("id", after, limit), nil
+}
+
+func (args *pageArgs) order() string {
+	if args.Order == nil {
+		return ""
+	}
+	return *args.Order
+}
+
+func (args *pageArgs) timeRange() (uint64, uint64) {
+	var begin, end uint64
+	if args.Begin != nil {
+		begin = uint64(*args.Begin)
+	}
+	if args.End != nil {
+		end = uint64(*args.End)
+	}
+	return begin, end
+}
+
+// nextCursor 本页已满时返回最后一条记录id生成的游标
+func nextCursor(paging *database.Paging, count int, lastId func() uint64) *string {
+	if count == 0 || uint64(count) < paging.Limit {
+		return nil
+	}
+	cursor := database.EncodeCursor(lastId())
+	return &cursor
+}
+
+func (r *resolver) Block(ctx context.Context, args struct{ Height Long }) (*blockResolver, error) {
+	ledgers, err := r.db.QueryV3LedgerByHeight(int64(args.Height))
+	if err != nil || len(ledgers) == 0 {
+		return nil, err
+	}
+	return newBlocks(ctx, ledgers)[0], nil
+}
+
+func (r *resolver) Blocks(ctx context.Context, args pageArgs) (*blockConnection, error) {
+	paging, err := args.paging()
+	if err != nil {
+		return nil, err
+	}
+	begin, end := args.timeRange()
+	ledgers, err := r.db.QueryV3Ledgers(begin, end, paging, args.order())
+	if err != nil {
+		return nil, err
+	}
+	return &blockConnection{
+		nodes:      newBlocks(ctx, ledgers),
+		nextCursor: nextCursor(paging, len(ledgers), func() uint64 { return ledgers[len(ledgers)-1].Id }),
+	}, nil
+}
+
+func (r *resolver) Transaction(ctx context.Context, args struct{ Hash string }) (*txResolver, error) {
+	txs, err := r.db.QueryV3SingleTx(args.Hash)
+	if err != nil || len(txs) == 0 {
+		return nil, err
+	}
+	return newTxs(ctx, txs)[0], nil
+}
+
+func (r *resolver) Transactions(ctx context.Context, args struct {
+	pageArgs
+	Height    *Long
+	Address   *string
+	Direction *string
+}) (*txConnection, error) {
+	if args.Height != nil && args.Address != nil {
+		return nil, errors.New("height and address can not be used together")
+	}
+	paging, err := args.paging()
+	if err != nil {
+		return nil, err
+	}
+	begin, end := args.timeRange()
+
+	var txs []database.V3Transaction
+	switch {
+	case args.Address != nil:
+		direction := ""
+		if args.Direction != nil {
+			direction = *args.Direction
+		}
+		txs, err = r.db.QueryV3AccountTxs(*args.Address, direction, begin, end, paging, args.order())
+	case args.Height != nil:
+		txs, err = r.db.QueryV3BlockTxs(int64(*args.Height), begin, end, paging, args.order())
+	default:
+		txs, err = r.db.QueryV3Txs(begin, end, paging, args.order())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &txConnection{
+		nodes:      newTxs(ctx, txs),
+		nextCursor: nextCursor(paging, len(txs), func() uint64 { return txs[len(txs)-1].Id }),
+	}, nil
+}
+
+func (r *resolver) Payments(ctx context.Context, args struct {
+	pageArgs
+	Height   *Long
+	Hash     *string
+	Address  *string
+	Symbol   *string
+	Contract *string
+}) (*paymentConnection, error) {
+	n := 0
+	for _, set := range []bool{args.Height != nil, args.Hash != nil, args.Address != nil} {
+		if set {
+			n++
+		}
+	}
+	if n > 1 {
+		return nil, errors.New("only one of height, hash and address can be used")
+	}
+	paging, err := args.paging()
+	if err != nil {
+		return nil, err
+	}
+	begin, end := args.timeRange()
+	var symbol, contract string
+	if args.Symbol != nil {
+		symbol = *args.Symbol
+	}
+	if args.Contract != nil {
+		contract = *args.Contract
+	}
+
+	var payments []database.V3Payment
+	switch {
+	case args.Address != nil:
+		payments, err = r.db.QueryV3AccountPayments(*args.Address, symbol, contract, begin, end, paging, args.order())
+	case args.Hash != nil:
+		payments, err = r.db.QueryV3TxPayments(*args.Hash, symbol, contract, begin, end, paging, args.order())
+	case args.Height != nil:
+		payments, err = r.db.QueryV3BlockPayments(int64(*args.Height), symbol, contract, begin, end, paging, args.order())
+	default:
+		payments, err = r.db.QueryV3Payments(symbol, contract, begin, end, paging, args.order())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &paymentConnection{
+		nodes:      newPayments(ctx, payments),
+		nextCursor: nextCursor(paging, len(payments), func() uint64 { return payments[len(payments)-1].Id }),
+	}, nil
+}
+
+type blockConnection struct {
+	nodes      []*blockResolver
+	nextCursor *string
+}
+
+func (c *blockConnection) Nodes() []*blockResolver { return c.nodes }
+func (c *blockConnection) NextCursor() *string     { return c.nextCursor }
+
+type txConnection struct {
+	nodes      []*txResolver
+	nextCursor *string
+}
+
+func (c *txConnection) Nodes() []*txResolver { return c.nodes }
+func (c *txConnection) NextCursor() *string  { return c.nextCursor }
+
+type paymentConnection struct {
+	nodes      []*paymentResolver
+	nextCursor *string
+}
+
+func (c *paymentConnection) Nodes() []*paymentResolver { return c.nodes }
+func (c *paymentConnection) NextCursor() *string       { return c.nextCursor }
+
+type blockResolver struct {
+	ls *loaders
+	l  database.V3Ledger
+}
+
+// newBlocks 同时登记这些区块的交易，供嵌套查询批量加载
+func newBlocks(ctx context.Context, rows []database.V3Ledger) []*blockResolver {
+	ls := loadersFrom(ctx)
+	ls.primeLedgers(rows)
+	result := make([]*blockResolver, len(rows))
+	for i := range rows {
+		result[i] = &blockResolver{ls: ls, l: rows[i]}
+	}
+	return result
+}
+
+func (b *blockResolver) Height() Long      { return Long(b.l.Height) }
+func (b *blockResolver) BlockHash() string { return b.l.BlockHash }
+func (b *blockResolver) BlockSize() int32  { return int32(b.l.BlockSize) }
+func (b *blockResolver) Validator() string { return b.l.Validator }
+func (b *blockResolver) TxCount() Long     { return Long(b.l.TxCount) }
+func (b *blockResolver) GasLimit() Long    { return Long(b.l.GasLimit) }
+func (b *blockResolver) GasUsed() Long     { return Long(b.l.GasUsed) }
+func (b *blockResolver) GasPrice() string  { return b.l.GasPrice }
+func (b *blockResolver) CreatedAt() string { return formatTime(b.l.CreatedAt) }
+
+func (b *blockResolver) Transactions(ctx context.Context) ([]*txResolver, error) {
+	v, err := b.ls.blockTxs.load(b.l.Height)
+	if err != nil {
+		return nil, err
+	}
+	return newTxs(ctx, v.([]database.V3Transaction)), nil
+}
+
+type txResolver struct {
+	ls *loaders
+	tx database.V3Transaction
+}
+
+// newTxs 同时登记交易所在的区块和交易的转账
+func newTxs(ctx context.Context, rows []database.V3Transaction) []*txResolver {
+	ls := loadersFrom(ctx)
+	ls.primeTxs(rows)
+	result := make([]*txResolver, len(rows))
+	for i := range rows {
+		result[i] = &txResolver{ls: ls, tx: rows[i]}
+	}
+	return result
+}
+
+func (t *txResolver) Hash() string      { return t.tx.Hash }
+func (t *txResolver) Height() Long      { return Long(t.tx.Height) }
+func (t *txResolver) Typei() int32      { return int32(t.tx.Typei) }
+func (t *txResolver) Types() string     { return t.tx.Types }
+func (t *txResolver) Sender() string    { return t.tx.Sender }
+func (t *txResolver) Nonce() Long       { return Long(t.tx.Nonce) }
+func (t *txResolver) Receiver() string  { return t.tx.Receiver }
+func (t *txResolver) Value() string     { return t.tx.Value }
+func (t *txResolver) GasLimit() Long    { return Long(t.tx.GasLimit) }
+func (t *txResolver) GasUsed() Long     { return Long(t.tx.GasUsed) }
+func (t *txResolver) GasPrice() string  { return t.tx.GasPrice }
+func (t *txResolver) Memo() string      { return t.tx.Memo }
+func (t *txResolver) Payload() string   { return t.tx.Payload }
+func (t *txResolver) Events() string    { return t.tx.Events }
+func (t *txResolver) Codei() Long       { return Long(t.tx.Codei) }
+func (t *txResolver) Codes() string     { return t.tx.Codes }
+func (t *txResolver) CreatedAt() string { return formatTime(t.tx.CreatedAt) }
+func (t *txResolver) TxIdx() int32      { return int32(t.tx.TxIdx) }
+
+func (t *txResolver) Block(ctx context.Context) (*blockResolver, error) {
+	v, err := t.ls.ledgers.load(t.tx.Height)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	return newBlocks(ctx, []database.V3Ledger{*v.(*database.V3Ledger)})[0], nil
+}
+
+func (t *txResolver) Payments(ctx context.Context) ([]*paymentResolver, error) {
+	v, err := t.ls.payments.load(t.tx.Hash)
+	if err != nil {
+		return nil, err
+	}
+	return newPayments(ctx, v.([]database.V3Payment)), nil
+}
+
+type paymentResolver struct {
+	ls *loaders
+	p  database.V3Payment
+}
+
+// newPayments 同时登记转账所属的交易
+func newPayments(ctx context.Context, rows []database.V3Payment) []*paymentResolver {
+	ls := loadersFrom(ctx)
+	ls.primePayments(rows)
+	result := make([]*paymentResolver, len(rows))
+	for i := range rows {
+		result[i] = &paymentResolver{ls: ls, p: rows[i]}
+	}
+	return result
+}
+
+func (p *paymentResolver) Hash() string      { return p.p.Hash }
+func (p *paymentResolver) Height() Long      { return Long(p.p.Height) }
+func (p *paymentResolver) EvName() string    { return p.p.EvName }
+func (p *paymentResolver) Idx() int32        { return int32(p.p.Idx) }
+func (p *paymentResolver) Sender() string    { return p.p.Sender }
+func (p *paymentResolver) Receiver() string  { return p.p.Receiver }
+func (p *paymentResolver) Symbol() string    { return p.p.Symbol }
+func (p *paymentResolver) Contract() string  { return p.p.Contract }
+func (p *paymentResolver) Value() string     { return p.p.Value }
+func (p *paymentResolver) CreatedAt() string { return formatTime(p.p.CreatedAt) }
+
+func (p *paymentResolver) Transaction(ctx context.Context) (*txResolver, error) {
+	v, err := p.ls.txs.load(p.p.Hash)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	return newTxs(ctx, []database.V3Transaction{*v.(*database.V3Transaction)})[0], nil
+}
+
+// formatTime 与REST接口的时间格式一致
+func formatTime(t time.Time) string {
+	return t.Format(time.RFC3339Nano)
+}
@@ -0,0 +1,94 @@
+package graphql
+
+// schema 字段与REST接口返回的V3Ledger、V3Transaction、V3Payment一致
+const schema = `
+# 64位整数
+scalar Long
+
+schema {
+	query: Query
+}
+
+type Query {
+	# 根据高度查询区块
+	block(height: Long!): Block
+	# 区块列表，begin、end为开始、结束时间戳
+	blocks(first: Int, after: String, order: String, begin: Long, end: Long): BlockConnection!
+	# 根据hash查询交易
+	transaction(hash: String!): Transaction
+	# 交易列表，address、height最多指定一个；direction为in/out/all，仅在指定address时有效
+	transactions(first: Int, after: String, order: String, begin: Long, end: Long,
+		height: Long, address: String, direction: String): TransactionConnection!
+	# 转账列表，address、hash、height最多指定一个
+	payments(first: Int, after: String, order: String, begin: Long, end: Long,
+		height: Long, hash: String, address: String, symbol: String, contract: String): PaymentConnection!
+}
+
+type Block {
+	height: Long!
+	blockHash: String!
+	blockSize: Int!
+	validator: String!
+	txCount: Long!
+	gasLimit: Long!
+	gasUsed: Long!
+	gasPrice: String!
+	createdAt: String!
+	# 区块内全部交易
+	transactions: [Transaction!]!
+}
+
+type Transaction {
+	hash: String!
+	height: Long!
+	typei: Int!
+	types: String!
+	sender: String!
+	nonce: Long!
+	receiver: String!
+	value: String!
+	gasLimit: Long!
+	gasUsed: Long!
+	gasPrice: String!
+	memo: String!
+	payload: String!
+	events: String!
+	codei: Long!
+	codes: String!
+	createdAt: String!
+	txIdx: Int!
+	block: Block
+	# 交易的全部转账
+	payments: [Payment!]!
+}
+
+type Payment {
+	hash: String!
+	height: Long!
+	evName: String!
+	idx: Int!
+	sender: String!
+	receiver: String!
+	symbol: String!
+	contract: String!
+	value: String!
+	createdAt: String!
+	transaction: Transaction
+}
+
+# nextCursor为下一页的游标，没有更多数据时为null
+type BlockConnection {
+	nodes: [Block!]!
+	nextCursor: String
+}
+
+type TransactionConnection {
+	nodes: [Transaction!]!
+	nextCursor: String
+}
+
+type PaymentConnection {
+	nodes: [Payment!]!
+	nextCursor: String
+}
+`
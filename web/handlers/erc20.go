@@ -1,23 +1,40 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	ethcmn "github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/gin-gonic/gin"
+	rpcclient "github.com/tendermint/tendermint/rpc/client"
 	"github.com/toolglobal/api/mondo/types"
 	"github.com/toolglobal/api/utils"
 	"github.com/toolglobal/api/web/bean"
+	"github.com/toolglobal/api/web/node"
 	"math/big"
 	"strings"
 )
 
+var erc20ABI, _ = abi.JSON(strings.NewReader(`[{"constant":true,"inputs":[{"name":"_owner","type":"address"}],"name":"balanceOf","outputs":[{"name":"","type":"uint256"}],"payable":false,"stateMutability":"view","type":"function"}]`))
+
 func (hd *Handler) BalanceOf(ctx *gin.Context) {
 	token := ctx.Param("token")
 	to := ctx.Param("to")
 
+	balance, _, err := hd.erc20BalanceOf(ctx, token, to, 0)
+	if err != nil {
+		hd.responseWrite(ctx, false, node.Message(err))
+		return
+	}
+	hd.responseWrite(ctx, true, balance.String())
+}
+
+// erc20BalanceOf 在节点上执行合约balanceOf查询，height为0时查询节点最新状态。
+// 同时返回节点应答的高度，节点未返回时为0
+func (hd *Handler) erc20BalanceOf(ctx context.Context, token, owner string, height int64) (*big.Int, int64, error) {
 	tx := types.NewTxEvm()
 	tx.CreatedAt = 0
 	tx.GasLimit = 100000
@@ -31,42 +48,38 @@ func (hd *Handler) BalanceOf(ctx *gin.Context) {
 	tx.Sender.SetBytes(crypto.PubkeyToAddress(privkey.PublicKey).Bytes())
 	tx.Body.To.SetBytes(ethcmn.HexToAddress(token).Bytes())
 	tx.Body.Value = big.NewInt(0)
-	abiIns, _ := abi.JSON(strings.NewReader(`[{"constant":true,"inputs":[{"name":"_owner","type":"address"}],"name":"balanceOf","outputs":[{"name":"","type":"uint256"}],"payable":false,"stateMutability":"view","type":"function"}]`))
-	tx.Body.Load, _ = abiIns.Pack("balanceOf", ethcmn.HexToAddress(to))
+	tx.Body.Load, _ = erc20ABI.Pack("balanceOf", ethcmn.HexToAddress(owner))
 	tx.Signature, _ = tx.Sign(ethcmn.Bytes2Hex(buff))
 
-	result, err := hd.client.ABCIQuery(ctx, types.API_V2_CONTRACT_CALL, tx.ToBytes())
+	result, err := hd.nodes.ABCIQueryWithOptions(ctx, types.API_V2_CONTRACT_CALL, tx.ToBytes(), rpcclient.ABCIQueryOptions{Height: height})
 	if err != nil {
-		hd.responseWrite(ctx, false, err.Error())
-		return
+		return nil, 0, err
 	}
 
 	var resp types.Result
 	err = rlp.DecodeBytes(result.Response.Value, &resp)
 	if err != nil {
-		hd.responseWrite(ctx, false, err.Error())
-		return
+		return nil, 0, err
 	}
 
 	if resp.Code != types.CodeType_OK {
-		hd.responseWrite(ctx, false, resp.Log)
-		return
+		return nil, 0, errors.New(resp.Log)
 	}
 	var evmResult bean.EvmCallResult
 	if err = json.Unmarshal(resp.Data, &evmResult); err != nil {
-		hd.responseWrite(ctx, false, err.Error())
-		return
+		return nil, 0, err
 	}
-	results, err := abiIns.Unpack("balanceOf", utils.HexToBytes(evmResult.Ret))
+	results, err := erc20ABI.Unpack("balanceOf", utils.HexToBytes(evmResult.Ret))
 	if err != nil {
-		hd.responseWrite(ctx, false, err.Error())
-		return
+		return nil, 0, err
 	}
 
 	if len(results) != 1 {
-		hd.responseWrite(ctx, false, "Wrong contract execute result")
-		return
+		return nil, 0, errors.New("Wrong contract execute result")
 	}
-	balance := results[0].(*big.Int)
-	hd.responseWrite(ctx, true, balance.String())
+	balance, ok := results[0].(*big.Int)
+	if !ok {
+		return nil, 0, errors.New("Wrong contract execute result")
+	}
+	return balance, result.Response.Height, nil
 }
@@ -1,29 +1,37 @@
 package handlers
 
 import (
+	"errors"
 	"github.com/gin-gonic/gin"
-	"github.com/tendermint/tendermint/rpc/client/http"
 	"github.com/toolglobal/api/config"
+	"github.com/toolglobal/api/database"
 	"github.com/toolglobal/api/web/dbo"
+	"github.com/toolglobal/api/web/node"
 	"go.uber.org/zap"
+	"strconv"
 	"sync"
 )
 
 type Handler struct {
-	client *http.HTTP
+	nodes  *node.Pool
 	logger *zap.Logger
 	mu     sync.Mutex
 	cfg    *config.Config
 	dbo3   *dbo.DBO
 }
 
-func NewHandler(logger *zap.Logger, cfg *config.Config, dbo3 *dbo.DBO) *Handler {
+func NewHandler(logger *zap.Logger, cfg *config.Config, dbo3 *dbo.DBO) (*Handler, error) {
+	nodes, err := node.New(cfg.RPCRemotes(), cfg.Node)
+	if err != nil {
+		return nil, err
+	}
+
 	var h Handler
-	h.client, _ = http.New("http://"+cfg.RPC, "/websocket")
+	h.nodes = nodes
 	h.logger = logger
 	h.cfg = cfg
 	h.dbo3 = dbo3
-	return &h
+	return &h, nil
 }
 
 func (hd *Handler) responseWrite(ctx *gin.Context, isSuccess bool, result interface{}) {
@@ -52,3 +60,43 @@ func (hd *Handler) responseWriteV2(ctx *gin.Context, isSuccess bool, result inte
 	}
 	ctx.JSON(200, ret)
 }
+
+// responseWritePage 列表接口的返回，nextCursor为下一页的游标，没有更多数据时为空
+func (hd *Handler) responseWritePage(ctx *gin.Context, result interface{}, nextCursor string) {
+	ctx.JSON(200, gin.H{
+		"isSuccess":  true,
+		"result":     result,
+		"nextCursor": nextCursor,
+	})
+}
+
+// paging 解析列表接口的cursor、limit参数。
+// cursor为空或为上一页返回的nextCursor时按id游标分页；开启legacyPaging时数字cursor作为页码
+func (hd *Handler) paging(ctx *gin.Context) (*database.Paging, error) {
+	limit, _ := strconv.ParseUint(ctx.Query("limit"), 10, 64)
+	cursor := ctx.Query("cursor")
+	if cursor == "" {
+		return database.MakeKeysetPaging("id", 0, limit), nil
+	}
+
+	if page, err := strconv.ParseUint(cursor, 10, 64); err == nil {
+		if !hd.cfg.LegacyPaging {
+			return nil, errors.New("page number cursor is disabled, use nextCursor")
+		}
+		return database.MakePaging("id", page, limit), nil
+	}
+
+	after, err := database.DecodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+	return database.MakeKeysetPaging("id", after, limit), nil
+}
+
+// nextCursor 本页已满时返回最后一条记录id生成的游标
+func nextCursor(paging *database.Paging, count int, lastId func() uint64) string {
+	if count == 0 || uint64(count) < paging.Limit {
+		return ""
+	}
+	return database.EncodeCursor(lastId())
+}
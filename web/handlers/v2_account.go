@@ -10,6 +10,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/toolglobal/api/mondo/types"
 	"github.com/toolglobal/api/web/bean"
+	"github.com/toolglobal/api/web/node"
 )
 
 // @Summary 随机生成mondo账户
@@ -59,7 +60,7 @@ func (hd *Handler) V2QueryAccount(ctx *gin.Context) {
 
 	act, err := hd.v2QueryAccount(addressHex)
 	if err != nil {
-		hd.responseWrite(ctx, false, err.Error())
+		hd.responseWrite(ctx, false, node.Message(err))
 		return
 	}
 
@@ -67,7 +68,7 @@ func (hd *Handler) V2QueryAccount(ctx *gin.Context) {
 }
 
 func (hd *Handler) v2QueryAccount(address string) (*bean.V2AccountResult, error) {
-	result, err := hd.client.ABCIQuery(context.Background(), types.API_V2_QUERY_ACCOUNT, ethcmn.HexToAddress(address).Bytes())
+	result, err := hd.nodes.ABCIQuery(context.Background(), types.API_V2_QUERY_ACCOUNT, ethcmn.HexToAddress(address).Bytes())
 	if err != nil {
 		return nil, err
 	}
@@ -6,6 +6,7 @@ import (
 	"github.com/toolglobal/api/mondo/types"
 	"github.com/toolglobal/api/utils"
 	"github.com/toolglobal/api/web/bean"
+	"github.com/toolglobal/api/web/node"
 	"go.uber.org/zap"
 	"math/big"
 )
@@ -87,10 +88,10 @@ func (hd *Handler) signedBatchSendToCommitTx(ctx *gin.Context, sigTx *types.TxBa
 		response = make(map[string]interface{})
 	)
 	response["tx"] = sigTx.Hash().Hex()
-	result, err := hd.client.BroadcastTxCommit(ctx, append(types.TxTagAppBatch[:], txBytes...))
+	result, err := hd.nodes.BroadcastTxCommit(ctx, append(types.TxTagAppBatch[:], txBytes...))
 	if err != nil {
 		hd.logger.Error("BroadcastTxCommit", zap.Error(err))
-		hd.responseWriteV2(ctx, false, response, err.Error())
+		hd.responseWriteV2(ctx, false, response, node.Message(err))
 		return
 	}
 	if result.CheckTx.Code != types.CodeType_OK {
@@ -117,10 +118,10 @@ func (hd *Handler) signedBatchSendToSyncTx(ctx *gin.Context, sigTx *types.TxBatc
 		response = make(map[string]interface{})
 	)
 	response["tx"] = sigTx.Hash().Hex()
-	result, err := hd.client.BroadcastTxSync(ctx, append(types.TxTagAppBatch[:], txBytes...))
+	result, err := hd.nodes.BroadcastTxSync(ctx, append(types.TxTagAppBatch[:], txBytes...))
 	if err != nil {
 		hd.logger.Error("BroadcastTxSync", zap.Error(err))
-		hd.responseWriteV2(ctx, false, response, err.Error())
+		hd.responseWriteV2(ctx, false, response, node.Message(err))
 		return
 	}
 	if result.Code != types.CodeType_OK {
@@ -138,10 +139,10 @@ func (hd *Handler) signedBatchSendToAsyncTx(ctx *gin.Context, sigTx *types.TxBat
 		response = make(map[string]interface{})
 	)
 	response["tx"] = sigTx.Hash().Hex()
-	result, err := hd.client.BroadcastTxAsync(ctx, append(types.TxTagAppBatch[:], txBytes...))
+	result, err := hd.nodes.BroadcastTxAsync(ctx, append(types.TxTagAppBatch[:], txBytes...))
 	if err != nil {
 		hd.logger.Error("BroadcastTxAsync", zap.Error(err))
-		hd.responseWriteV2(ctx, false, response, err.Error())
+		hd.responseWriteV2(ctx, false, response, node.Message(err))
 		return
 	}
 	if result.Code != types.CodeType_OK {
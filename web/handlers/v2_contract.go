@@ -10,6 +10,7 @@ import (
 	"github.com/toolglobal/api/mondo/types"
 	"github.com/toolglobal/api/utils"
 	"github.com/toolglobal/api/web/bean"
+	"github.com/toolglobal/api/web/node"
 	"go.uber.org/zap"
 	"math/big"
 	"time"
@@ -94,10 +95,10 @@ func (hd *Handler) signedEvmSendToCommitTx(ctx *gin.Context, tag []byte, sigTx I
 	)
 	response["tx"] = sigTx.Hash().Hex()
 
-	result, err := hd.client.BroadcastTxCommit(ctx, append(tag, txBytes...))
+	result, err := hd.nodes.BroadcastTxCommit(ctx, append(tag, txBytes...))
 	if err != nil {
 		hd.logger.Error("BroadcastTxCommit", zap.Error(err))
-		hd.responseWriteV2(ctx, false, response, err.Error())
+		hd.responseWriteV2(ctx, false, response, node.Message(err))
 		return
 	}
 	if result.CheckTx.Code != types.CodeType_OK {
@@ -125,10 +126,10 @@ func (hd *Handler) signedEvmSendToSyncTx(ctx *gin.Context, tag []byte, sigTx ITx
 		response = make(map[string]interface{})
 	)
 	response["tx"] = sigTx.Hash().Hex()
-	result, err := hd.client.BroadcastTxSync(ctx, append(tag, txBytes...))
+	result, err := hd.nodes.BroadcastTxSync(ctx, append(tag, txBytes...))
 	if err != nil {
 		hd.logger.Error("BroadcastTxSync", zap.Error(err))
-		hd.responseWriteV2(ctx, false, response, err.Error())
+		hd.responseWriteV2(ctx, false, response, node.Message(err))
 		return
 	}
 	if result.Code != types.CodeType_OK {
@@ -147,10 +148,10 @@ func (hd *Handler) signedEvmSendToAsyncTx(ctx *gin.Context, tag []byte, sigTx IT
 	)
 	response["tx"] = sigTx.Hash().Hex()
 
-	result, err := hd.client.BroadcastTxAsync(ctx, append(tag, txBytes...))
+	result, err := hd.nodes.BroadcastTxAsync(ctx, append(tag, txBytes...))
 	if err != nil {
 		hd.logger.Error("BroadcastTxAsync", zap.Error(err))
-		hd.responseWriteV2(ctx, false, response, err.Error())
+		hd.responseWriteV2(ctx, false, response, node.Message(err))
 		return
 	}
 	if result.Code != types.CodeType_OK {
@@ -196,7 +197,7 @@ func (hd *Handler) ContractDeployTx(ctx *gin.Context) {
 	}
 	act, err := hd.v2QueryAccount(tx.Sender.ToAddress().Address.Hex())
 	if err != nil {
-		hd.responseWrite(ctx, false, "get nonce:"+err.Error())
+		hd.responseWrite(ctx, false, "get nonce:"+node.Message(err))
 		return
 	}
 	tx.Nonce = act.Nonce
@@ -219,10 +220,10 @@ func (hd *Handler) contractDeployCommitTx(ctx *gin.Context, sigTx *types.TxEvm)
 	response["tx"] = sigTx.Hash().Hex()
 	response["address"] = crypto.CreateAddress(sigTx.Sender.ToAddress().Address, sigTx.Nonce).Hex()
 
-	result, err := hd.client.BroadcastTxCommit(ctx, append(types.TxTagAppEvm[:], txBytes...))
+	result, err := hd.nodes.BroadcastTxCommit(ctx, append(types.TxTagAppEvm[:], txBytes...))
 	if err != nil {
 		hd.logger.Error("BroadcastTxCommit", zap.Error(err))
-		hd.responseWriteV2(ctx, false, response, err.Error())
+		hd.responseWriteV2(ctx, false, response, node.Message(err))
 		return
 	}
 	if result.CheckTx.Code != types.CodeType_OK {
@@ -279,7 +280,7 @@ func (hd *Handler) ContractInvokeTx(ctx *gin.Context) {
 	}
 	act, err := hd.v2QueryAccount(tx.Sender.ToAddress().Address.Hex())
 	if err != nil {
-		hd.responseWrite(ctx, false, "get nonce:"+err.Error())
+		hd.responseWrite(ctx, false, "get nonce:"+node.Message(err))
 		return
 	}
 	tx.Nonce = act.Nonce
@@ -341,7 +342,7 @@ func (hd *Handler) ContractCallTx(ctx *gin.Context) {
 	}
 	act, err := hd.v2QueryAccount(tx.Sender.ToAddress().Address.Hex())
 	if err != nil {
-		hd.responseWrite(ctx, false, "get nonce:"+err.Error())
+		hd.responseWrite(ctx, false, "get nonce:"+node.Message(err))
 		return
 	}
 	tx.Nonce = act.Nonce
@@ -427,9 +428,9 @@ func (hd *Handler) ContractSignedCallTx(ctx *gin.Context) {
 }
 
 func (hd *Handler) callContract(ctx *gin.Context, tx *types.TxEvm) {
-	result, err := hd.client.ABCIQuery(ctx, types.API_V2_CONTRACT_CALL, tx.ToBytes())
+	result, err := hd.nodes.ABCIQuery(ctx, types.API_V2_CONTRACT_CALL, tx.ToBytes())
 	if err != nil {
-		hd.responseWrite(ctx, false, err.Error())
+		hd.responseWrite(ctx, false, node.Message(err))
 		return
 	}
 
@@ -459,10 +460,10 @@ func (hd *Handler) contractInvokeCommitTx(ctx *gin.Context, sigTx *types.TxEvm)
 	)
 	response["tx"] = sigTx.Hash().Hex()
 
-	result, err := hd.client.BroadcastTxCommit(ctx, append(types.TxTagAppEvm[:], txBytes...))
+	result, err := hd.nodes.BroadcastTxCommit(ctx, append(types.TxTagAppEvm[:], txBytes...))
 	if err != nil {
 		hd.logger.Error("BroadcastTxCommit", zap.Error(err))
-		hd.responseWriteV2(ctx, false, response, err.Error())
+		hd.responseWriteV2(ctx, false, response, node.Message(err))
 		return
 	}
 	if result.CheckTx.Code != types.CodeType_OK {
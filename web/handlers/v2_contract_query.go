@@ -7,6 +7,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/toolglobal/api/mondo/types"
 	"github.com/toolglobal/api/web/bean"
+	"github.com/toolglobal/api/web/node"
 )
 
 // @Summary 查询合约账户信息
@@ -29,9 +30,9 @@ func (hd *Handler) QueryContract(ctx *gin.Context) {
 		addressHex = pubkey.ToAddress().Address.Hex()
 	}
 
-	result, err := hd.client.ABCIQuery(ctx, types.API_V2_CONTRACT_QUERY_ACCOUNT, ethcmn.HexToAddress(addressHex).Bytes())
+	result, err := hd.nodes.ABCIQuery(ctx, types.API_V2_CONTRACT_QUERY_ACCOUNT, ethcmn.HexToAddress(addressHex).Bytes())
 	if err != nil {
-		hd.responseWrite(ctx, false, err.Error())
+		hd.responseWrite(ctx, false, node.Message(err))
 		return
 	}
 
@@ -64,9 +65,9 @@ func (hd *Handler) QueryContract(ctx *gin.Context) {
 func (hd *Handler) QueryTxEvents(ctx *gin.Context) {
 	hash := ctx.Param("txhash")
 
-	result, err := hd.client.ABCIQuery(ctx, types.API_V2_CONTRACT_QUERY_LOGS, ethcmn.HexToHash(hash).Bytes())
+	result, err := hd.nodes.ABCIQuery(ctx, types.API_V2_CONTRACT_QUERY_LOGS, ethcmn.HexToHash(hash).Bytes())
 	if err != nil {
-		hd.responseWrite(ctx, false, err.Error())
+		hd.responseWrite(ctx, false, node.Message(err))
 		return
 	}
 
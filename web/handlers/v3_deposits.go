@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+	"github.com/toolglobal/api/database"
+	"github.com/toolglobal/api/mondo/types"
+	"github.com/toolglobal/api/web/bean"
+)
+
+// defaultDepositConfirmations 未配置deposit.confirmations时的默认确认数
+const defaultDepositConfirmations = 12
+
+// CheckDepositAPIKey 充值接口校验X-API-KEY，未配置apiKey时不开放
+func (hd *Handler) CheckDepositAPIKey(ctx *gin.Context) {
+	hd.checkAPIKey(ctx, hd.cfg.Deposit.APIKey)
+}
+
+// @Summary 登记充值地址
+// @Description 登记后同步到的转入记为充值，已登记时更新确认数和备注
+// @Tags v3-deposit
+// @Accept json
+// @Produce json
+// @Param X-API-KEY header string true "管理接口key"
+// @Param Request body bean.V3DepositAddressReq true "请求参数"
+// @Success 200 {object} database.V3DepositAddress "成功"
+// @Router /v3/deposit-addresses [post]
+func (hd *Handler) SaveV3DepositAddress(ctx *gin.Context) {
+	var req bean.V3DepositAddressReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		hd.responseWrite(ctx, false, err.Error())
+		return
+	}
+	if err := req.Check(); err != nil {
+		hd.responseWrite(ctx, false, err.Error())
+		return
+	}
+	if req.Confirmations == 0 {
+		req.Confirmations = hd.cfg.Deposit.Confirmations
+	}
+	if req.Confirmations == 0 {
+		req.Confirmations = defaultDepositConfirmations
+	}
+
+	data := &database.V3DepositAddress{
+		Address:       common.HexToAddress(req.Address).Hex(),
+		Confirmations: req.Confirmations,
+		Label:         req.Label,
+		CreatedAt:     time.Now(),
+	}
+	if err := hd.dbo3.SaveV3DepositAddress(data); err != nil {
+		hd.responseWrite(ctx, false, err.Error())
+		return
+	}
+
+	result, err := hd.dbo3.QueryV3DepositAddress(data.Address)
+	if err != nil {
+		hd.responseWrite(ctx, false, err.Error())
+	} else {
+		hd.responseWrite(ctx, true, result)
+	}
+}
+
+// @Summary 查询充值地址
+// @Description 查询已登记的充值地址
+// @Tags v3-deposit
+// @Accept json
+// @Produce json
+// @Param X-API-KEY header string true "管理接口key"
+// @Param cursor query string false "游标，上一页返回的nextCursor"
+// @Param limit query int false "限制"
+// @Param order query string false "排序(ASC/DESC)"
+// @Success 200 {array} database.V3DepositAddress "成功"
+// @Router /v3/deposit-addresses [get]
+func (hd *Handler) QueryV3DepositAddresses(ctx *gin.Context) {
+	paging, err := hd.paging(ctx)
+	if err != nil {
+		hd.responseWrite(ctx, false, err.Error())
+		return
+	}
+
+	result, err := hd.dbo3.QueryV3DepositAddresses(paging, ctx.Query("order"))
+	if err != nil {
+		hd.responseWrite(ctx, false, err.Error())
+	} else {
+		hd.responseWritePage(ctx, result, nextCursor(paging, len(result), func() uint64 { return result[len(result)-1].Id }))
+	}
+}
+
+// @Summary 取消登记充值地址
+// @Description 之后的转入不再记为充值，已记录的充值保留
+// @Tags v3-deposit
+// @Accept json
+// @Produce json
+// @Param X-API-KEY header string true "管理接口key"
+// @Param address path string true "充值地址"
+// @Success 200 {object} bean.PublicResp "成功"
+// @Router /v3/deposit-addresses/{address} [delete]
+func (hd *Handler) DeleteV3DepositAddress(ctx *gin.Context) {
+	address := ctx.Param("address")
+	if !types.ValidAddress(address) {
+		hd.responseWrite(ctx, false, "invalid address :"+address)
+		return
+	}
+
+	ok, err := hd.dbo3.DeleteV3DepositAddress(common.HexToAddress(address).Hex())
+	if err != nil {
+		hd.responseWrite(ctx, false, err.Error())
+	} else if !ok {
+		hd.responseWrite(ctx, false, "deposit address not found")
+	} else {
+		hd.responseWrite(ctx, true, nil)
+	}
+}
+
+// @Summary 查询充值
+// @Description 查询登记地址收到的充值，确认数按已同步高度计算；同步回滚时高度之上的充值标记为invalidated
+// @Tags v3-deposit
+// @Accept json
+// @Produce json
+// @Param X-API-KEY header string true "管理接口key"
+// @Param address query string false "充值地址"
+// @Param contract query string false "币种合约地址"
+// @Param symbol query string false "币种"
+// @Param status query string false "状态(pending/confirmed/invalidated)"
+// @Param cursor query string false "游标，上一页返回的nextCursor"
+// @Param limit query int false "限制"
+// @Param order query string false "排序(ASC/DESC)"
+// @Success 200 {array} database.V3Deposit "成功"
+// @Router /v3/deposits [get]
+func (hd *Handler) QueryV3Deposits(ctx *gin.Context) {
+	filter := database.V3DepositFilter{
+		Address:  ctx.Query("address"),
+		Contract: ctx.Query("contract"),
+		Symbol:   ctx.Query("symbol"),
+		Status:   ctx.Query("status"),
+	}
+	if filter.Address != "" {
+		if !types.ValidAddress(filter.Address) {
+			hd.responseWrite(ctx, false, "invalid address :"+filter.Address)
+			return
+		}
+		filter.Address = common.HexToAddress(filter.Address).Hex()
+	}
+	switch filter.Status {
+	case "", database.DepositPending, database.DepositConfirmed, database.DepositInvalidated:
+	default:
+		hd.responseWrite(ctx, false, "invalid status :"+filter.Status)
+		return
+	}
+
+	paging, err := hd.paging(ctx)
+	if err != nil {
+		hd.responseWrite(ctx, false, err.Error())
+		return
+	}
+
+	var indexed int64
+	state, err := hd.dbo3.QuerySyncState(database.SyncStateV3)
+	if err != nil {
+		hd.responseWrite(ctx, false, err.Error())
+		return
+	}
+	if state != nil {
+		indexed = state.Height
+	}
+
+	result, err := hd.dbo3.QueryV3Deposits(&filter, indexed, paging, ctx.Query("order"))
+	if err != nil {
+		hd.responseWrite(ctx, false, err.Error())
+	} else {
+		hd.responseWritePage(ctx, result, nextCursor(paging, len(result), func() uint64 { return result[len(result)-1].Id }))
+	}
+}
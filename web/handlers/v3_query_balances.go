@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/toolglobal/api/database"
+	"github.com/toolglobal/api/web/bean"
+	"github.com/toolglobal/api/web/node"
+)
+
+// maxVerifyBalances verify时每页最多比对的余额数，每条都需要在节点上执行一次合约调用
+const maxVerifyBalances = 20
+
+// @Summary 根据用户地址查询代币余额
+// @Description 根据用户地址查询索引的代币余额。verify=true时逐条在索引的高度上与节点balanceOf比对，返回比对的高度和节点应答的高度
+// @Tags v3-query
+// @Accept json
+// @Produce json
+// @Param address path string true "账户地址"
+// @Param contract query string false "代币合约地址"
+// @Param verify query bool false "与节点balanceOf比对，每页最多20条；按页码翻页时limit不能超过20"
+// @Param cursor query string false "游标，上一页返回的nextCursor"
+// @Param limit query int false "限制"
+// @Param order query string false "排序(ASC/DESC)"
+// @Success 200 {array}  database.V3Balance "成功"
+// @Router /v3/accounts/{address}/balances [get]
+func (hd *Handler) QueryV3AccBalances(ctx *gin.Context) {
+	address := ctx.Param("address")
+	contract := ctx.Query("contract")
+	order := ctx.Query("order")
+	verify := ctx.Query("verify") == "true"
+
+	if address == "" {
+		hd.responseWrite(ctx, false, "param address is required")
+		return
+	}
+
+	paging, err := hd.paging(ctx)
+	if err != nil {
+		hd.responseWrite(ctx, false, err.Error())
+		return
+	}
+	if verify && paging.Limit > maxVerifyBalances {
+		// 按页码翻页时每页的起点由limit决定，缩小limit会跳过数据
+		if !paging.Keyset {
+			hd.responseWrite(ctx, false, fmt.Sprintf("verify=true with a page number cursor requires limit <= %d", maxVerifyBalances))
+			return
+		}
+		paging.Limit = maxVerifyBalances
+	}
+
+	// 先读取同步高度再查询余额，查询期间入库的区块只会让余额的height大于同步高度
+	var state *database.SyncState
+	if verify {
+		if state, err = hd.dbo3.QuerySyncState(database.SyncStateV3); err != nil {
+			hd.responseWrite(ctx, false, err.Error())
+			return
+		}
+		if state == nil {
+			hd.responseWrite(ctx, false, "not synced yet")
+			return
+		}
+	}
+
+	result, err := hd.dbo3.QueryV3AccountBalances(address, contract, paging, order)
+	if err != nil {
+		hd.responseWrite(ctx, false, err.Error())
+		return
+	}
+	cursor := nextCursor(paging, len(result), func() uint64 { return result[len(result)-1].Id })
+	if !verify {
+		hd.responseWritePage(ctx, result, cursor)
+		return
+	}
+
+	// 在索引的高度比对，索引落后于节点不会造成不一致。
+	// 余额在同步高度之后发生过变化时，在最后一次变化的高度比对
+	checks := make([]bean.V3BalanceCheck, len(result))
+	for i, balance := range result {
+		checks[i].V3Balance = balance
+		checks[i].IndexedHeight = state.Height
+		if balance.Height > state.Height {
+			checks[i].IndexedHeight = balance.Height
+		}
+		chain, height, err := hd.erc20BalanceOf(ctx, balance.Contract, balance.Address, checks[i].IndexedHeight)
+		if err != nil {
+			checks[i].Error = node.Message(err)
+			continue
+		}
+		checks[i].ChainBalance = chain.String()
+		checks[i].ChainHeight = height
+		checks[i].Match = checks[i].ChainBalance == balance.Balance
+	}
+	hd.responseWritePage(ctx, checks, cursor)
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"strconv"
+)
+
+// @Summary 查询创建的合约
+// @Description 查询交易创建的合约，不含合约内部创建的合约
+// @Tags v3-query
+// @Accept json
+// @Produce json
+// @Param creator query string false "创建者地址"
+// @Param begin query int false "开始时间"
+// @Param end query int false "结束时间"
+// @Param cursor query string false "游标，上一页返回的nextCursor"
+// @Param limit query int false "限制"
+// @Param order query string false "排序(ASC/DESC)"
+// @Success 200 {array}  database.V3Contract "成功"
+// @Router /v3/contracts [get]
+func (hd *Handler) QueryV3Contracts(ctx *gin.Context) {
+	creator := ctx.Query("creator")
+	order := ctx.Query("order")
+	begins := ctx.Query("begin")
+	ends := ctx.Query("end")
+	begin, _ := strconv.ParseUint(begins, 10, 64)
+	end, _ := strconv.ParseUint(ends, 10, 64)
+
+	paging, err := hd.paging(ctx)
+	if err != nil {
+		hd.responseWrite(ctx, false, err.Error())
+		return
+	}
+
+	result, err := hd.dbo3.QueryV3Contracts(creator, begin, end, paging, order)
+	if err != nil {
+		hd.responseWrite(ctx, false, err.Error())
+	} else {
+		hd.responseWritePage(ctx, result, nextCursor(paging, len(result), func() uint64 { return result[len(result)-1].Id }))
+	}
+}
+
+// @Summary 根据合约地址查询创建信息
+// @Description 根据合约地址查询创建者、创建交易和高度，未索引到时返回null
+// @Tags v3-query
+// @Accept json
+// @Produce json
+// @Param address path string true "合约地址"
+// @Success 200 {object} database.V3Contract "成功"
+// @Router /v3/contracts/{address} [get]
+func (hd *Handler) QueryV3Contract(ctx *gin.Context) {
+	address := ctx.Param("address")
+	if address == "" {
+		hd.responseWrite(ctx, false, "param address is required")
+		return
+	}
+
+	result, err := hd.dbo3.QueryV3Contract(address)
+	if err != nil {
+		hd.responseWrite(ctx, false, err.Error())
+	} else {
+		hd.responseWrite(ctx, true, result)
+	}
+}
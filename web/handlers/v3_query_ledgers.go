@@ -12,28 +12,30 @@ import (
 // @Produce json
 // @Param begin query int false "开始时间戳"
 // @Param end query int false "结束时间戳"
-// @Param cursor query int false "游标"
+// @Param cursor query string false "游标，上一页返回的nextCursor"
 // @Param limit query int false "限制"
 // @Param order query string false "排序(ASC/DESC)"
 // @Success 200 {array}  database.V3Ledger "成功"
 // @Router /v3/ledgers [get]
 func (hd *Handler) QueryV3Ledgers(ctx *gin.Context) {
 	order := ctx.Query("order")
-	limit := ctx.Query("limit")
-	cursor := ctx.Query("cursor")
 	begin := ctx.Query("begin")
 	end := ctx.Query("end")
 
-	iLimit, _ := strconv.ParseUint(limit, 10, 64)
-	iCursor, _ := strconv.ParseUint(cursor, 10, 64)
 	iBegin, _ := strconv.ParseUint(begin, 10, 64)
 	iEnd, _ := strconv.ParseUint(end, 10, 64)
 
-	result, err := hd.dbo3.QueryV3Ledgers(iBegin, iEnd, iCursor, iLimit, order)
+	paging, err := hd.paging(ctx)
+	if err != nil {
+		hd.responseWrite(ctx, false, err.Error())
+		return
+	}
+
+	result, err := hd.dbo3.QueryV3Ledgers(iBegin, iEnd, paging, order)
 	if err != nil {
 		hd.responseWrite(ctx, false, err.Error())
 	} else {
-		hd.responseWrite(ctx, true, result)
+		hd.responseWritePage(ctx, result, nextCursor(paging, len(result), func() uint64 { return result[len(result)-1].Id }))
 	}
 }
 
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"fmt"
+	ethcmn "github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+	"github.com/toolglobal/api/database"
+	"strconv"
+	"strings"
+)
+
+// maxLogFilterValues address和每个topic最多指定的值
+const maxLogFilterValues = 20
+
+// @Summary 查询合约日志
+// @Description 与eth_getLogs相同的过滤条件：address、topic0-3可以用逗号分隔多个值，同一参数内为或，不同参数之间为且，不传表示任意
+// @Tags v3-query
+// @Accept json
+// @Produce json
+// @Param address query string false "合约地址，逗号分隔"
+// @Param topic0 query string false "事件签名，逗号分隔"
+// @Param topic1 query string false "topic1，逗号分隔"
+// @Param topic2 query string false "topic2，逗号分隔"
+// @Param topic3 query string false "topic3，逗号分隔"
+// @Param fromHeight query int false "起始高度（含）"
+// @Param toHeight query int false "结束高度（含）"
+// @Param cursor query string false "游标，上一页返回的nextCursor"
+// @Param limit query int false "限制"
+// @Param order query string false "排序(ASC/DESC)"
+// @Success 200 {array}  database.V3Log "成功"
+// @Router /v3/logs [get]
+func (hd *Handler) QueryV3Logs(ctx *gin.Context) {
+	order := ctx.Query("order")
+
+	filter, err := logFilter(ctx)
+	if err != nil {
+		hd.responseWrite(ctx, false, err.Error())
+		return
+	}
+
+	paging, err := hd.paging(ctx)
+	if err != nil {
+		hd.responseWrite(ctx, false, err.Error())
+		return
+	}
+
+	result, err := hd.dbo3.QueryV3Logs(filter, paging, order)
+	if err != nil {
+		hd.responseWrite(ctx, false, err.Error())
+	} else {
+		hd.responseWritePage(ctx, result, nextCursor(paging, len(result), func() uint64 { return result[len(result)-1].Id }))
+	}
+}
+
+// @Summary 根据txhash查询合约日志
+// @Description 根据txhash查询索引的合约日志，不需要访问节点
+// @Tags v3-query
+// @Accept json
+// @Produce json
+// @Param txhash path string true "交易hash"
+// @Param cursor query string false "游标，上一页返回的nextCursor"
+// @Param limit query int false "限制"
+// @Param order query string false "排序(ASC/DESC)"
+// @Success 200 {array}  database.V3Log "成功"
+// @Router /v3/transactions/{txhash}/logs [get]
+func (hd *Handler) QueryV3TxLogs(ctx *gin.Context) {
+	txhash := ctx.Param("txhash")
+	order := ctx.Query("order")
+
+	if txhash == "" {
+		hd.responseWrite(ctx, false, "param txhash is required")
+		return
+	}
+
+	paging, err := hd.paging(ctx)
+	if err != nil {
+		hd.responseWrite(ctx, false, err.Error())
+		return
+	}
+
+	result, err := hd.dbo3.QueryV3Logs(&database.V3LogFilter{Hash: txhash}, paging, order)
+	if err != nil {
+		hd.responseWrite(ctx, false, err.Error())
+	} else {
+		hd.responseWritePage(ctx, result, nextCursor(paging, len(result), func() uint64 { return result[len(result)-1].Id }))
+	}
+}
+
+// logFilter 解析/v3/logs的过滤条件，地址、topic转换为与索引一致的格式
+func logFilter(ctx *gin.Context) (*database.V3LogFilter, error) {
+	var (
+		filter database.V3LogFilter
+		err    error
+	)
+	if s := ctx.Query("fromHeight"); s != "" {
+		if filter.FromHeight, err = strconv.ParseInt(s, 10, 64); err != nil || filter.FromHeight < 0 {
+			return nil, fmt.Errorf("invalid fromHeight %q", s)
+		}
+	}
+	if s := ctx.Query("toHeight"); s != "" {
+		if filter.ToHeight, err = strconv.ParseInt(s, 10, 64); err != nil || filter.ToHeight < 0 {
+			return nil, fmt.Errorf("invalid toHeight %q", s)
+		}
+	}
+	if filter.ToHeight != 0 && filter.FromHeight > filter.ToHeight {
+		return nil, fmt.Errorf("fromHeight %d is greater than toHeight %d", filter.FromHeight, filter.ToHeight)
+	}
+
+	addresses, err := splitFilterValues(ctx.Query("address"), "address")
+	if err != nil {
+		return nil, err
+	}
+	for _, address := range addresses {
+		if !ethcmn.IsHexAddress(address) {
+			return nil, fmt.Errorf("invalid address %q", address)
+		}
+		filter.Addresses = append(filter.Addresses, ethcmn.HexToAddress(address).Hex())
+	}
+
+	for i := 0; i < 4; i++ {
+		name := fmt.Sprintf("topic%d", i)
+		values, err := splitFilterValues(ctx.Query(name), name)
+		if err != nil {
+			return nil, err
+		}
+		var topics []string
+		for _, topic := range values {
+			if !strings.HasPrefix(topic, "0x") || len(topic) != 2+2*ethcmn.HashLength {
+				return nil, fmt.Errorf("invalid %s %q", name, topic)
+			}
+			topics = append(topics, ethcmn.HexToHash(topic).Hex())
+		}
+		filter.Topics = append(filter.Topics, topics)
+	}
+	return &filter, nil
+}
+
+func splitFilterValues(s, name string) ([]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	values := strings.Split(s, ",")
+	if len(values) > maxLogFilterValues {
+		return nil, fmt.Errorf("too many %s values, max %d", name, maxLogFilterValues)
+	}
+	for i := range values {
+		values[i] = strings.TrimSpace(values[i])
+	}
+	return values, nil
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/toolglobal/api/database"
+	"github.com/toolglobal/api/web/bean"
+	"strconv"
+)
+
+// @Summary 查询账户某一高度的OLO余额
+// @Description 按索引的原生币payment和手续费计算的余额历史查询，不含创世分配和合约内部转账。height、time都不传时为最新同步高度，传time时取该时间（含）之前的最后一个区块
+// @Tags v3-query
+// @Accept json
+// @Produce json
+// @Param address path string true "账户地址"
+// @Param height query int false "区块高度"
+// @Param time query int false "时间戳（秒）"
+// @Success 200 {object} bean.V3NativeBalanceResult "成功"
+// @Router /v3/accounts/{address}/balance [get]
+func (hd *Handler) QueryV3AccBalance(ctx *gin.Context) {
+	address := ctx.Param("address")
+	heights := ctx.Query("height")
+	times := ctx.Query("time")
+
+	if address == "" {
+		hd.responseWrite(ctx, false, "param address is required")
+		return
+	}
+
+	state, err := hd.dbo3.QuerySyncState(database.SyncStateV3)
+	if err != nil {
+		hd.responseWrite(ctx, false, err.Error())
+		return
+	}
+	if state == nil {
+		hd.responseWrite(ctx, false, "not synced yet")
+		return
+	}
+
+	height := state.Height
+	switch {
+	case heights != "":
+		if height, err = strconv.ParseInt(heights, 10, 64); err != nil || height <= 0 {
+			hd.responseWrite(ctx, false, "invalid height "+heights)
+			return
+		}
+		if height > state.Height {
+			hd.responseWrite(ctx, false, fmt.Sprintf("height %d not indexed yet, indexed height %d", height, state.Height))
+			return
+		}
+	case times != "":
+		ts, err := strconv.ParseUint(times, 10, 64)
+		if err != nil {
+			hd.responseWrite(ctx, false, "invalid time "+times)
+			return
+		}
+		ledgers, err := hd.dbo3.QueryV3Ledgers(0, ts+1, database.MakePaging("id", 0, 1), "DESC")
+		if err != nil {
+			hd.responseWrite(ctx, false, err.Error())
+			return
+		}
+		if len(ledgers) == 0 {
+			hd.responseWrite(ctx, false, "no block before time "+times)
+			return
+		}
+		height = ledgers[0].Height
+	}
+
+	history, err := hd.dbo3.QueryV3NativeBalance(address, height)
+	if err != nil {
+		hd.responseWrite(ctx, false, err.Error())
+		return
+	}
+	result := bean.V3NativeBalanceResult{
+		Address: address,
+		Height:  height,
+		Balance: "0",
+	}
+	if history != nil {
+		result.Balance = history.Balance
+		result.ChangedHeight = history.Height
+		result.ChangedAt = &history.CreatedAt
+	}
+	hd.responseWrite(ctx, true, result)
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"strconv"
+)
+
+// @Summary 查询NFT转账
+// @Description 查询ERC721、ERC1155转账，指定tokenId时需要同时指定contract
+// @Tags v3-query
+// @Accept json
+// @Produce json
+// @Param contract query string false "NFT合约地址"
+// @Param tokenId query string false "tokenId"
+// @Param begin query int false "开始时间"
+// @Param end query int false "结束时间"
+// @Param cursor query string false "游标，上一页返回的nextCursor"
+// @Param limit query int false "限制"
+// @Param order query string false "排序(ASC/DESC)"
+// @Success 200 {array}  database.V3NFTTransfer "成功"
+// @Router /v3/nft-transfers [get]
+func (hd *Handler) QueryV3NFTTransfers(ctx *gin.Context) {
+	contract := ctx.Query("contract")
+	tokenId := ctx.Query("tokenId")
+	order := ctx.Query("order")
+	begins := ctx.Query("begin")
+	ends := ctx.Query("end")
+	begin, _ := strconv.ParseUint(begins, 10, 64)
+	end, _ := strconv.ParseUint(ends, 10, 64)
+
+	if tokenId != "" && contract == "" {
+		hd.responseWrite(ctx, false, "param contract is required with tokenId")
+		return
+	}
+
+	paging, err := hd.paging(ctx)
+	if err != nil {
+		hd.responseWrite(ctx, false, err.Error())
+		return
+	}
+
+	result, err := hd.dbo3.QueryV3NFTTransfers(contract, tokenId, begin, end, paging, order)
+	if err != nil {
+		hd.responseWrite(ctx, false, err.Error())
+	} else {
+		hd.responseWritePage(ctx, result, nextCursor(paging, len(result), func() uint64 { return result[len(result)-1].Id }))
+	}
+}
+
+// @Summary 根据用户地址查询NFT转账
+// @Description 根据用户地址查询转出或转入的ERC721、ERC1155转账
+// @Tags v3-query
+// @Accept json
+// @Produce json
+// @Param address path string true "账户地址"
+// @Param contract query string false "NFT合约地址"
+// @Param tokenId query string false "tokenId"
+// @Param begin query int false "开始时间"
+// @Param end query int false "结束时间"
+// @Param cursor query string false "游标，上一页返回的nextCursor"
+// @Param limit query int false "限制"
+// @Param order query string false "排序(ASC/DESC)"
+// @Success 200 {array}  database.V3NFTTransfer "成功"
+// @Router /v3/accounts/{address}/nft-transfers [get]
+func (hd *Handler) QueryV3AccNFTTransfers(ctx *gin.Context) {
+	address := ctx.Param("address")
+	contract := ctx.Query("contract")
+	tokenId := ctx.Query("tokenId")
+	order := ctx.Query("order")
+	begins := ctx.Query("begin")
+	ends := ctx.Query("end")
+	begin, _ := strconv.ParseUint(begins, 10, 64)
+	end, _ := strconv.ParseUint(ends, 10, 64)
+
+	if address == "" {
+		hd.responseWrite(ctx, false, "param address is required")
+		return
+	}
+
+	paging, err := hd.paging(ctx)
+	if err != nil {
+		hd.responseWrite(ctx, false, err.Error())
+		return
+	}
+
+	result, err := hd.dbo3.QueryV3AccountNFTTransfers(address, contract, tokenId, begin, end, paging, order)
+	if err != nil {
+		hd.responseWrite(ctx, false, err.Error())
+	} else {
+		hd.responseWritePage(ctx, result, nextCursor(paging, len(result), func() uint64 { return result[len(result)-1].Id }))
+	}
+}
+
+// @Summary 根据用户地址查询授权
+// @Description 根据用户地址查询作为授权方或被授权方的Approval、ApprovalForAll
+// @Tags v3-query
+// @Accept json
+// @Produce json
+// @Param address path string true "账户地址"
+// @Param contract query string false "合约地址"
+// @Param begin query int false "开始时间"
+// @Param end query int false "结束时间"
+// @Param cursor query string false "游标，上一页返回的nextCursor"
+// @Param limit query int false "限制"
+// @Param order query string false "排序(ASC/DESC)"
+// @Success 200 {array}  database.V3Approval "成功"
+// @Router /v3/accounts/{address}/approvals [get]
+func (hd *Handler) QueryV3AccApprovals(ctx *gin.Context) {
+	address := ctx.Param("address")
+	contract := ctx.Query("contract")
+	order := ctx.Query("order")
+	begins := ctx.Query("begin")
+	ends := ctx.Query("end")
+	begin, _ := strconv.ParseUint(begins, 10, 64)
+	end, _ := strconv.ParseUint(ends, 10, 64)
+
+	if address == "" {
+		hd.responseWrite(ctx, false, "param address is required")
+		return
+	}
+
+	paging, err := hd.paging(ctx)
+	if err != nil {
+		hd.responseWrite(ctx, false, err.Error())
+		return
+	}
+
+	result, err := hd.dbo3.QueryV3AccountApprovals(address, contract, begin, end, paging, order)
+	if err != nil {
+		hd.responseWrite(ctx, false, err.Error())
+	} else {
+		hd.responseWritePage(ctx, result, nextCursor(paging, len(result), func() uint64 { return result[len(result)-1].Id }))
+	}
+}
@@ -14,28 +14,30 @@ import (
 // @Param contract query string false "币种合约地址"
 // @Param begin query int false "开始时间"
 // @Param end query int false "结束时间"
-// @Param cursor query int false "游标"
+// @Param cursor query string false "游标，上一页返回的nextCursor"
 // @Param limit query int false "限制"
 // @Param order query string false "排序(ASC/DESC)"
 // @Success 200 {array}  database.V3Payment "成功"
 // @Router /v3/payments [get]
 func (hd *Handler) QueryV3Payments(ctx *gin.Context) {
 	order := ctx.Query("order")
-	limits := ctx.Query("limit")
-	cursors := ctx.Query("cursor")
 	begins := ctx.Query("begin")
 	ends := ctx.Query("end")
 	symbol := ctx.Query("symbol")
 	contract := ctx.Query("contract")
-	limit, _ := strconv.ParseUint(limits, 10, 64)
-	cursor, _ := strconv.ParseUint(cursors, 10, 64)
 	begin, _ := strconv.ParseUint(begins, 10, 64)
 	end, _ := strconv.ParseUint(ends, 10, 64)
-	result, err := hd.dbo3.QueryV3Payments(symbol, contract, begin, end, cursor, limit, order)
+	paging, err := hd.paging(ctx)
+	if err != nil {
+		hd.responseWrite(ctx, false, err.Error())
+		return
+	}
+
+	result, err := hd.dbo3.QueryV3Payments(symbol, contract, begin, end, paging, order)
 	if err != nil {
 		hd.responseWrite(ctx, false, err.Error())
 	} else {
-		hd.responseWrite(ctx, true, result)
+		hd.responseWritePage(ctx, result, nextCursor(paging, len(result), func() uint64 { return result[len(result)-1].Id }))
 	}
 }
 
@@ -49,7 +51,7 @@ func (hd *Handler) QueryV3Payments(ctx *gin.Context) {
 // @Param contract query string false "币种合约地址"
 // @Param begin query int false "开始时间"
 // @Param end query int false "结束时间"
-// @Param cursor query int false "游标"
+// @Param cursor query string false "游标，上一页返回的nextCursor"
 // @Param limit query int false "限制"
 // @Param order query string false "排序(ASC/DESC)"
 // @Success 200 {array}  database.V3Payment "成功"
@@ -59,12 +61,8 @@ func (hd *Handler) QueryV3AccPayments(ctx *gin.Context) {
 	symbol := ctx.Query("symbol")
 	contract := ctx.Query("contract")
 	order := ctx.Query("order")
-	limits := ctx.Query("limit")
-	cursors := ctx.Query("cursor")
 	begins := ctx.Query("begin")
 	ends := ctx.Query("end")
-	limit, _ := strconv.ParseUint(limits, 10, 64)
-	cursor, _ := strconv.ParseUint(cursors, 10, 64)
 	begin, _ := strconv.ParseUint(begins, 10, 64)
 	end, _ := strconv.ParseUint(ends, 10, 64)
 
@@ -73,11 +71,17 @@ func (hd *Handler) QueryV3AccPayments(ctx *gin.Context) {
 		return
 	}
 
-	result, err := hd.dbo3.QueryV3AccountPayments(address, symbol, contract, begin, end, cursor, limit, order)
+	paging, err := hd.paging(ctx)
+	if err != nil {
+		hd.responseWrite(ctx, false, err.Error())
+		return
+	}
+
+	result, err := hd.dbo3.QueryV3AccountPayments(address, symbol, contract, begin, end, paging, order)
 	if err != nil {
 		hd.responseWrite(ctx, false, err.Error())
 	} else {
-		hd.responseWrite(ctx, true, result)
+		hd.responseWritePage(ctx, result, nextCursor(paging, len(result), func() uint64 { return result[len(result)-1].Id }))
 	}
 }
 
@@ -91,7 +95,7 @@ func (hd *Handler) QueryV3AccPayments(ctx *gin.Context) {
 // @Param contract query string false "币种合约地址"
 // @Param begin query int false "开始时间"
 // @Param end query int false "结束时间"
-// @Param cursor query int false "游标"
+// @Param cursor query string false "游标，上一页返回的nextCursor"
 // @Param limit query int false "限制"
 // @Param order query string false "排序(ASC/DESC)"
 // @Success 200 {array}  database.V3Payment "成功"
@@ -101,12 +105,8 @@ func (hd *Handler) QueryV3TxPayments(ctx *gin.Context) {
 	symbol := ctx.Query("symbol")
 	contract := ctx.Query("contract")
 	order := ctx.Query("order")
-	limits := ctx.Query("limit")
-	cursors := ctx.Query("cursor")
 	begins := ctx.Query("begin")
 	ends := ctx.Query("end")
-	limit, _ := strconv.ParseUint(limits, 10, 64)
-	cursor, _ := strconv.ParseUint(cursors, 10, 64)
 	begin, _ := strconv.ParseUint(begins, 10, 64)
 	end, _ := strconv.ParseUint(ends, 10, 64)
 
@@ -115,11 +115,17 @@ func (hd *Handler) QueryV3TxPayments(ctx *gin.Context) {
 		return
 	}
 
-	result, err := hd.dbo3.QueryV3TxPayments(txhash, symbol, contract, begin, end, cursor, limit, order)
+	paging, err := hd.paging(ctx)
+	if err != nil {
+		hd.responseWrite(ctx, false, err.Error())
+		return
+	}
+
+	result, err := hd.dbo3.QueryV3TxPayments(txhash, symbol, contract, begin, end, paging, order)
 	if err != nil {
 		hd.responseWrite(ctx, false, err.Error())
 	} else {
-		hd.responseWrite(ctx, true, result)
+		hd.responseWritePage(ctx, result, nextCursor(paging, len(result), func() uint64 { return result[len(result)-1].Id }))
 	}
 }
 
@@ -133,7 +139,7 @@ func (hd *Handler) QueryV3TxPayments(ctx *gin.Context) {
 // @Param contract query string false "币种合约地址"
 // @Param begin query int false "开始时间"
 // @Param end query int false "结束时间"
-// @Param cursor query int false "游标"
+// @Param cursor query string false "游标，上一页返回的nextCursor"
 // @Param limit query int false "限制"
 // @Param order query string false "排序(ASC/DESC)"
 // @Success 200 {array}  database.V3Payment "成功"
@@ -143,13 +149,9 @@ func (hd *Handler) QueryV3LedgerPayments(ctx *gin.Context) {
 	symbol := ctx.Query("symbol")
 	contract := ctx.Query("contract")
 	order := ctx.Query("order")
-	limits := ctx.Query("limit")
-	cursors := ctx.Query("cursor")
 	begins := ctx.Query("begin")
 	ends := ctx.Query("end")
 	height, _ := strconv.ParseUint(heights, 10, 64)
-	limit, _ := strconv.ParseUint(limits, 10, 64)
-	cursor, _ := strconv.ParseUint(cursors, 10, 64)
 	begin, _ := strconv.ParseUint(begins, 10, 64)
 	end, _ := strconv.ParseUint(ends, 10, 64)
 
@@ -158,10 +160,16 @@ func (hd *Handler) QueryV3LedgerPayments(ctx *gin.Context) {
 		return
 	}
 
-	result, err := hd.dbo3.QueryV3BlockPayments(int64(height), symbol, contract, begin, end, cursor, limit, order)
+	paging, err := hd.paging(ctx)
+	if err != nil {
+		hd.responseWrite(ctx, false, err.Error())
+		return
+	}
+
+	result, err := hd.dbo3.QueryV3BlockPayments(int64(height), symbol, contract, begin, end, paging, order)
 	if err != nil {
 		hd.responseWrite(ctx, false, err.Error())
 	} else {
-		hd.responseWrite(ctx, true, result)
+		hd.responseWritePage(ctx, result, nextCursor(paging, len(result), func() uint64 { return result[len(result)-1].Id }))
 	}
 }
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/toolglobal/api/database"
+)
+
+// rawTxTagRe 交易类型，前两个字节的十六进制
+var rawTxTagRe = regexp.MustCompile(`^0x[0-9a-f]{0,4}$`)
+
+// @Summary 查询无法解析的交易
+// @Description 同步时格式错误或类型未知的交易原样记录，不影响同步；解析器升级后执行api retry-raw-txs重新解析
+// @Tags v3-query
+// @Accept json
+// @Produce json
+// @Param fromHeight query int false "起始高度（含）"
+// @Param toHeight query int false "结束高度（含）"
+// @Param tag query string false "交易类型，例如0x0102"
+// @Param cursor query string false "游标，上一页返回的nextCursor"
+// @Param limit query int false "限制"
+// @Param order query string false "排序(ASC/DESC)"
+// @Success 200 {array}  database.V3RawTx "成功"
+// @Router /v3/raw-txs [get]
+func (hd *Handler) QueryV3RawTxs(ctx *gin.Context) {
+	order := ctx.Query("order")
+
+	var (
+		filter database.V3RawTxFilter
+		err    error
+	)
+	if s := ctx.Query("fromHeight"); s != "" {
+		if filter.FromHeight, err = strconv.ParseInt(s, 10, 64); err != nil || filter.FromHeight < 0 {
+			hd.responseWrite(ctx, false, fmt.Sprintf("invalid fromHeight %q", s))
+			return
+		}
+	}
+	if s := ctx.Query("toHeight"); s != "" {
+		if filter.ToHeight, err = strconv.ParseInt(s, 10, 64); err != nil || filter.ToHeight < 0 {
+			hd.responseWrite(ctx, false, fmt.Sprintf("invalid toHeight %q", s))
+			return
+		}
+	}
+	if s := ctx.Query("tag"); s != "" {
+		filter.Tag = strings.ToLower(s)
+		if !rawTxTagRe.MatchString(filter.Tag) {
+			hd.responseWrite(ctx, false, fmt.Sprintf("invalid tag %q", s))
+			return
+		}
+	}
+
+	paging, err := hd.paging(ctx)
+	if err != nil {
+		hd.responseWrite(ctx, false, err.Error())
+		return
+	}
+
+	result, err := hd.dbo3.QueryV3RawTxs(&filter, paging, order)
+	if err != nil {
+		hd.responseWrite(ctx, false, err.Error())
+	} else {
+		hd.responseWritePage(ctx, result, nextCursor(paging, len(result), func() uint64 { return result[len(result)-1].Id }))
+	}
+}
@@ -35,26 +35,28 @@ func (hd *Handler) QueryV3SingleTx(ctx *gin.Context) {
 // @Produce json
 // @Param begin query int false "开始时间戳"
 // @Param end query int false "结束时间戳"
-// @Param cursor query int false "游标"
+// @Param cursor query string false "游标，上一页返回的nextCursor"
 // @Param limit query int false "限制"
 // @Param order query string false "排序(ASC/DESC)"
 // @Success 200 {array}  database.V3Transaction "成功"
 // @Router /v3/transactions [get]
 func (hd *Handler) QueryV3Txs(ctx *gin.Context) {
 	order := ctx.Query("order")
-	limits := ctx.Query("limit")
-	cursors := ctx.Query("cursor")
 	begins := ctx.Query("begin")
 	ends := ctx.Query("end")
-	limit, _ := strconv.ParseUint(limits, 10, 64)
-	cursor, _ := strconv.ParseUint(cursors, 10, 64)
 	begin, _ := strconv.ParseUint(begins, 10, 64)
 	end, _ := strconv.ParseUint(ends, 10, 64)
-	result, err := hd.dbo3.QueryV3Txs(begin, end, cursor, limit, order)
+	paging, err := hd.paging(ctx)
+	if err != nil {
+		hd.responseWrite(ctx, false, err.Error())
+		return
+	}
+
+	result, err := hd.dbo3.QueryV3Txs(begin, end, paging, order)
 	if err != nil {
 		hd.responseWrite(ctx, false, err.Error())
 	} else {
-		hd.responseWrite(ctx, true, result)
+		hd.responseWritePage(ctx, result, nextCursor(paging, len(result), func() uint64 { return result[len(result)-1].Id }))
 	}
 }
 
@@ -64,35 +66,39 @@ func (hd *Handler) QueryV3Txs(ctx *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param address path string true "账户地址"
+// @Param direction query string false "方向(in/out/all，默认all)"
 // @Param begin query int false "开始时间戳"
 // @Param end query int false "结束时间戳"
-// @Param cursor query int false "游标"
+// @Param cursor query string false "游标，上一页返回的nextCursor"
 // @Param limit query int false "限制"
 // @Param order query string false "排序(ASC/DESC)"
 // @Success 200 {array}  database.V3Transaction "成功"
 // @Router /v3/accounts/{address}/transactions [get]
 func (hd *Handler) QueryV3AccTxs(ctx *gin.Context) {
 	order := ctx.Query("order")
-	limits := ctx.Query("limit")
-	cursors := ctx.Query("cursor")
 	begins := ctx.Query("begin")
 	ends := ctx.Query("end")
-	limit, _ := strconv.ParseUint(limits, 10, 64)
-	cursor, _ := strconv.ParseUint(cursors, 10, 64)
 	begin, _ := strconv.ParseUint(begins, 10, 64)
 	end, _ := strconv.ParseUint(ends, 10, 64)
 	address := ctx.Param("address")
+	direction := ctx.Query("direction")
 
 	if address == "" {
 		hd.responseWrite(ctx, false, "param address is required")
 		return
 	}
 
-	result, err := hd.dbo3.QueryV3AccountTxs(address, begin, end, cursor, limit, order)
+	paging, err := hd.paging(ctx)
+	if err != nil {
+		hd.responseWrite(ctx, false, err.Error())
+		return
+	}
+
+	result, err := hd.dbo3.QueryV3AccountTxs(address, direction, begin, end, paging, order)
 	if err != nil {
 		hd.responseWrite(ctx, false, err.Error())
 	} else {
-		hd.responseWrite(ctx, true, result)
+		hd.responseWritePage(ctx, result, nextCursor(paging, len(result), func() uint64 { return result[len(result)-1].Id }))
 	}
 }
 
@@ -104,7 +110,7 @@ func (hd *Handler) QueryV3AccTxs(ctx *gin.Context) {
 // @Param height path string true "区块高度"
 // @Param begin query int false "开始时间戳"
 // @Param end query int false "结束时间戳"
-// @Param cursor query int false "游标"
+// @Param cursor query string false "游标，上一页返回的nextCursor"
 // @Param limit query int false "限制"
 // @Param order query string false "排序(ASC/DESC)"
 // @Success 200 {array}  database.V3Transaction "成功"
@@ -112,13 +118,9 @@ func (hd *Handler) QueryV3AccTxs(ctx *gin.Context) {
 func (hd *Handler) QueryV3LedgerTxs(ctx *gin.Context) {
 	heights := ctx.Param("height")
 	order := ctx.Query("order")
-	limits := ctx.Query("limit")
-	cursors := ctx.Query("cursor")
 	begins := ctx.Query("begin")
 	ends := ctx.Query("end")
 	height, _ := strconv.ParseUint(heights, 10, 64)
-	limit, _ := strconv.ParseUint(limits, 10, 64)
-	cursor, _ := strconv.ParseUint(cursors, 10, 64)
 	begin, _ := strconv.ParseUint(begins, 10, 64)
 	end, _ := strconv.ParseUint(ends, 10, 64)
 
@@ -127,10 +129,16 @@ func (hd *Handler) QueryV3LedgerTxs(ctx *gin.Context) {
 		return
 	}
 
-	result, err := hd.dbo3.QueryV3BlockTxs(int64(height), begin, end, cursor, limit, order)
+	paging, err := hd.paging(ctx)
+	if err != nil {
+		hd.responseWrite(ctx, false, err.Error())
+		return
+	}
+
+	result, err := hd.dbo3.QueryV3BlockTxs(int64(height), begin, end, paging, order)
 	if err != nil {
 		hd.responseWrite(ctx, false, err.Error())
 	} else {
-		hd.responseWrite(ctx, true, result)
+		hd.responseWritePage(ctx, result, nextCursor(paging, len(result), func() uint64 { return result[len(result)-1].Id }))
 	}
 }
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/toolglobal/api/database"
+	"github.com/toolglobal/api/web/bean"
+	"github.com/toolglobal/api/web/node"
+)
+
+// @Summary 查询同步状态
+// @Description 查询索引的同步进度和节点最新高度
+// @Tags v3-query
+// @Accept json
+// @Produce json
+// @Success 200 {object}  bean.V3StatusResult "成功"
+// @Router /v3/status [get]
+func (hd *Handler) QueryV3Status(ctx *gin.Context) {
+	state, err := hd.dbo3.QuerySyncState(database.SyncStateV3)
+	if err != nil {
+		hd.responseWrite(ctx, false, err.Error())
+		return
+	}
+
+	result := bean.V3StatusResult{
+		SyncState: state,
+	}
+
+	info, err := hd.nodes.ABCIInfo(ctx)
+	if err != nil {
+		result.NodeError = node.Message(err)
+	} else {
+		result.LastBlockHeight = info.Response.LastBlockHeight
+		if state != nil && result.LastBlockHeight > state.Height {
+			result.Lag = result.LastBlockHeight - state.Height
+		}
+	}
+
+	hd.responseWrite(ctx, true, result)
+}
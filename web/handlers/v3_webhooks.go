@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/toolglobal/api/database"
+	"github.com/toolglobal/api/web/bean"
+)
+
+// CheckWebhookAPIKey webhook管理接口校验X-API-KEY，未配置apiKey时不开放
+func (hd *Handler) CheckWebhookAPIKey(ctx *gin.Context) {
+	hd.checkAPIKey(ctx, hd.cfg.Webhook.APIKey)
+}
+
+// checkAPIKey 校验请求头X-API-KEY，key为空时拒绝全部请求
+func (hd *Handler) checkAPIKey(ctx *gin.Context, key string) {
+	if key == "" || subtle.ConstantTimeCompare([]byte(ctx.GetHeader("X-API-KEY")), []byte(key)) != 1 {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"isSuccess": false,
+			"message":   "invalid api key",
+		})
+		return
+	}
+	ctx.Next()
+}
+
+// @Summary 注册webhook
+// @Description 注册推送地址及过滤条件，返回的secret用于校验X-Mondo-Signature，只在注册时返回
+// @Tags v3-webhook
+// @Accept json
+// @Produce json
+// @Param X-API-KEY header string true "管理接口key"
+// @Param Request body bean.V3WebhookReq true "请求参数"
+// @Success 200 {object} database.V3Webhook "成功"
+// @Router /v3/webhooks [post]
+func (hd *Handler) AddV3Webhook(ctx *gin.Context) {
+	var req bean.V3WebhookReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		hd.responseWrite(ctx, false, err.Error())
+		return
+	}
+	if err := req.Check(); err != nil {
+		hd.responseWrite(ctx, false, err.Error())
+		return
+	}
+	if req.Secret == "" {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			hd.responseWrite(ctx, false, err.Error())
+			return
+		}
+		req.Secret = hex.EncodeToString(b)
+	}
+
+	hook := &database.V3Webhook{
+		URL:       req.URL,
+		Secret:    req.Secret,
+		Address:   req.Address,
+		Contract:  req.Contract,
+		Event:     req.Event,
+		CreatedAt: time.Now(),
+	}
+	id, err := hd.dbo3.AddV3Webhook(hook)
+	if err != nil {
+		hd.responseWrite(ctx, false, err.Error())
+		return
+	}
+	hook.Id = id
+	hd.responseWrite(ctx, true, hook)
+}
+
+// @Summary 查询webhook
+// @Description 查询已注册的webhook，不返回secret
+// @Tags v3-webhook
+// @Accept json
+// @Produce json
+// @Param X-API-KEY header string true "管理接口key"
+// @Param cursor query string false "游标，上一页返回的nextCursor"
+// @Param limit query int false "限制"
+// @Param order query string false "排序(ASC/DESC)"
+// @Success 200 {array} database.V3Webhook "成功"
+// @Router /v3/webhooks [get]
+func (hd *Handler) QueryV3Webhooks(ctx *gin.Context) {
+	paging, err := hd.paging(ctx)
+	if err != nil {
+		hd.responseWrite(ctx, false, err.Error())
+		return
+	}
+
+	result, err := hd.dbo3.QueryV3Webhooks(paging, ctx.Query("order"))
+	if err != nil {
+		hd.responseWrite(ctx, false, err.Error())
+		return
+	}
+	for i := range result {
+		result[i].Secret = ""
+	}
+	hd.responseWritePage(ctx, result, nextCursor(paging, len(result), func() uint64 { return result[len(result)-1].Id }))
+}
+
+// @Summary 根据id查询webhook
+// @Description 根据id查询webhook，不返回secret，不存在时返回null
+// @Tags v3-webhook
+// @Accept json
+// @Produce json
+// @Param X-API-KEY header string true "管理接口key"
+// @Param id path int true "webhook id"
+// @Success 200 {object} database.V3Webhook "成功"
+// @Router /v3/webhooks/{id} [get]
+func (hd *Handler) QueryV3Webhook(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		hd.responseWrite(ctx, false, "invalid id :"+ctx.Param("id"))
+		return
+	}
+
+	result, err := hd.dbo3.QueryV3Webhook(id)
+	if err != nil {
+		hd.responseWrite(ctx, false, err.Error())
+		return
+	}
+	if result != nil {
+		result.Secret = ""
+	}
+	hd.responseWrite(ctx, true, result)
+}
+
+// @Summary 删除webhook
+// @Description 删除webhook及其推送记录，尚未推送的不再推送
+// @Tags v3-webhook
+// @Accept json
+// @Produce json
+// @Param X-API-KEY header string true "管理接口key"
+// @Param id path int true "webhook id"
+// @Success 200 {object} bean.PublicResp "成功"
+// @Router /v3/webhooks/{id} [delete]
+func (hd *Handler) DeleteV3Webhook(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		hd.responseWrite(ctx, false, "invalid id :"+ctx.Param("id"))
+		return
+	}
+
+	ok, err := hd.dbo3.DeleteV3Webhook(id)
+	if err != nil {
+		hd.responseWrite(ctx, false, err.Error())
+	} else if !ok {
+		hd.responseWrite(ctx, false, "webhook not found")
+	} else {
+		hd.responseWrite(ctx, true, nil)
+	}
+}
+
+// @Summary 查询推送记录
+// @Description 查询webhook的推送记录，status=dead查询死信
+// @Tags v3-webhook
+// @Accept json
+// @Produce json
+// @Param X-API-KEY header string true "管理接口key"
+// @Param id path int true "webhook id"
+// @Param status query string false "状态(pending/delivered/dead)"
+// @Param cursor query string false "游标，上一页返回的nextCursor"
+// @Param limit query int false "限制"
+// @Param order query string false "排序(ASC/DESC)"
+// @Success 200 {array} database.V3WebhookDelivery "成功"
+// @Router /v3/webhooks/{id}/deliveries [get]
+func (hd *Handler) QueryV3WebhookDeliveries(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		hd.responseWrite(ctx, false, "invalid id :"+ctx.Param("id"))
+		return
+	}
+	status := ctx.Query("status")
+	switch status {
+	case "", database.WebhookPending, database.WebhookDelivered, database.WebhookDead:
+	default:
+		hd.responseWrite(ctx, false, "invalid status :"+status)
+		return
+	}
+
+	paging, err := hd.paging(ctx)
+	if err != nil {
+		hd.responseWrite(ctx, false, err.Error())
+		return
+	}
+
+	result, err := hd.dbo3.QueryV3WebhookDeliveries(id, status, paging, ctx.Query("order"))
+	if err != nil {
+		hd.responseWrite(ctx, false, err.Error())
+	} else {
+		hd.responseWritePage(ctx, result, nextCursor(paging, len(result), func() uint64 { return result[len(result)-1].Id }))
+	}
+}
+
+// @Summary 重新推送死信
+// @Description 把死信放回推送队列，推送次数清零
+// @Tags v3-webhook
+// @Accept json
+// @Produce json
+// @Param X-API-KEY header string true "管理接口key"
+// @Param id path int true "webhook id"
+// @Param deliveryId path int true "推送记录id"
+// @Success 200 {object} bean.PublicResp "成功"
+// @Router /v3/webhooks/{id}/deliveries/{deliveryId}/retry [post]
+func (hd *Handler) RetryV3WebhookDelivery(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		hd.responseWrite(ctx, false, "invalid id :"+ctx.Param("id"))
+		return
+	}
+	deliveryId, err := strconv.ParseUint(ctx.Param("deliveryId"), 10, 64)
+	if err != nil {
+		hd.responseWrite(ctx, false, "invalid deliveryId :"+ctx.Param("deliveryId"))
+		return
+	}
+
+	ok, err := hd.dbo3.RetryV3WebhookDelivery(id, deliveryId)
+	if err != nil {
+		hd.responseWrite(ctx, false, err.Error())
+	} else if !ok {
+		hd.responseWrite(ctx, false, "dead delivery not found")
+	} else {
+		hd.responseWrite(ctx, true, nil)
+	}
+}
@@ -0,0 +1,69 @@
+package node
+
+import (
+	"sync"
+	"time"
+)
+
+// breaker 熔断器：连续失败threshold次后熔断cooldown时间，之后只放行一个请求试探，成功则恢复，失败继续熔断
+type breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openUntil time.Time
+	probing   bool // 正在试探
+	now       func() time.Time
+}
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+// allow 是否可以发送请求，熔断结束后第一次调用返回true并进入试探
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return true
+	}
+	if b.probing || b.now().Before(b.openUntil) {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// success 记录请求成功，返回是否从熔断中恢复
+func (b *breaker) success() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	recovered := b.failures >= b.threshold
+	b.failures = 0
+	b.probing = false
+	return recovered
+}
+
+// failure 记录请求失败，返回是否因此开始熔断
+func (b *breaker) failure() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.probing = false
+	if b.failures < b.threshold {
+		return false
+	}
+	b.openUntil = b.now().Add(b.cooldown)
+	return b.failures == b.threshold
+}
+
+// release 请求被调用方取消，不记录结果，结束试探
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
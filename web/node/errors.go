@@ -0,0 +1,85 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	rpctypes "github.com/tendermint/tendermint/rpc/jsonrpc/types"
+)
+
+// Kind 节点请求失败的类型
+type Kind string
+
+const (
+	KindUnavailable Kind = "unavailable" // 无法连接节点，或所有节点都已熔断
+	KindTimeout     Kind = "timeout"     // 超过该操作的超时时间
+	KindRejected    Kind = "rejected"    // 节点返回了JSON-RPC错误，例如交易已在内存池中
+)
+
+// Error 节点请求失败。Error()包含节点地址和原始错误，用于日志；返回给接口调用方使用Message()
+type Error struct {
+	Kind   Kind
+	Op     string // RPC方法，例如broadcast_tx_sync
+	Remote string // 最后一次请求的节点，没有可用节点时为空
+	Reason string // 节点返回的错误信息，只在KindRejected时有值
+	Err    error
+}
+
+func (e *Error) Error() string {
+	if e.Remote == "" {
+		return fmt.Sprintf("%s: node %s: %v", e.Op, e.Kind, e.Err)
+	}
+	return fmt.Sprintf("%s %s: node %s: %v", e.Op, e.Remote, e.Kind, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Message 不含节点地址的错误信息
+func (e *Error) Message() string {
+	switch e.Kind {
+	case KindRejected:
+		return "node rejected request: " + e.Reason
+	case KindTimeout:
+		return "node timeout"
+	default:
+		return "node unavailable"
+	}
+}
+
+// Message 返回给接口调用方的错误信息，节点错误不暴露节点地址和连接细节
+func Message(err error) string {
+	var nerr *Error
+	if errors.As(err, &nerr) {
+		return nerr.Message()
+	}
+	return err.Error()
+}
+
+// IsKind 判断err是否为kind类型的节点错误
+func IsKind(err error, kind Kind) bool {
+	var nerr *Error
+	return errors.As(err, &nerr) && nerr.Kind == kind
+}
+
+// classify 根据tendermint rpc客户端返回的错误判断失败类型
+func classify(op, remote string, err error) *Error {
+	e := &Error{Kind: KindUnavailable, Op: op, Remote: remote, Err: err}
+
+	var rpcErr *rpctypes.RPCError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &rpcErr):
+		e.Kind = KindRejected
+		e.Reason = rpcErr.Message
+		if rpcErr.Data != "" {
+			e.Reason = rpcErr.Data
+		}
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		e.Kind = KindTimeout
+	}
+	return e
+}
@@ -0,0 +1,296 @@
+// Package node /v2接口访问节点的客户端：请求在多个节点之间轮流分配，按操作设置超时，
+// 连续失败的节点熔断一段时间；广播交易同时发送到多个节点
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	rpcclient "github.com/tendermint/tendermint/rpc/client"
+	"github.com/tendermint/tendermint/rpc/client/http"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+	tmtypes "github.com/tendermint/tendermint/types"
+	"github.com/toolglobal/api/config"
+	"github.com/toolglobal/api/libs/log"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultQueryTimeout     = 5 * time.Second
+	defaultBroadcastTimeout = 10 * time.Second
+	defaultCommitTimeout    = 30 * time.Second
+	defaultRetries          = 1
+	defaultFanout           = 3
+	defaultFailureThreshold = 5
+	defaultCooldown         = 30 * time.Second
+)
+
+var errAllOpen = errors.New("all nodes are circuit broken")
+
+type node struct {
+	*breaker
+	remote string
+	cli    *http.HTTP
+}
+
+// Pool 节点客户端
+type Pool struct {
+	nodes            []*node
+	queryTimeout     time.Duration
+	broadcastTimeout time.Duration
+	commitTimeout    time.Duration
+	retries          int
+	fanout           int
+
+	mu   sync.Mutex
+	next int // 轮询位置
+}
+
+// New remotes为节点RPC地址，例如http://127.0.0.1:26657
+func New(remotes []string, cfg config.Node) (*Pool, error) {
+	if len(remotes) == 0 {
+		return nil, errors.New("no rpc endpoint configured")
+	}
+
+	p := &Pool{
+		queryTimeout:     orDefault(cfg.QueryTimeout.Duration, defaultQueryTimeout),
+		broadcastTimeout: orDefault(cfg.BroadcastTimeout.Duration, defaultBroadcastTimeout),
+		commitTimeout:    orDefault(cfg.CommitTimeout.Duration, defaultCommitTimeout),
+		retries:          cfg.Retries,
+		fanout:           cfg.Fanout,
+	}
+	if p.retries <= 0 {
+		p.retries = defaultRetries
+	}
+	if p.fanout <= 0 {
+		p.fanout = defaultFanout
+	}
+	threshold := cfg.FailureThreshold
+	if threshold <= 0 {
+		threshold = defaultFailureThreshold
+	}
+	cooldown := orDefault(cfg.Cooldown.Duration, defaultCooldown)
+
+	for _, remote := range remotes {
+		cli, err := http.New(remote, "/websocket")
+		if err != nil {
+			return nil, fmt.Errorf("rpc %s: %v", remote, err)
+		}
+		p.nodes = append(p.nodes, &node{breaker: newBreaker(threshold, cooldown), remote: remote, cli: cli})
+	}
+	return p, nil
+}
+
+func (p *Pool) ABCIQuery(ctx context.Context, path string, data []byte) (*ctypes.ResultABCIQuery, error) {
+	var result *ctypes.ResultABCIQuery
+	err := p.query(ctx, "abci_query", func(ctx context.Context, cli *http.HTTP) (err error) {
+		result, err = cli.ABCIQuery(ctx, path, data)
+		return err
+	})
+	return result, err
+}
+
+// ABCIQueryWithOptions 按opts查询，opts.Height不为0时查询该高度的状态
+func (p *Pool) ABCIQueryWithOptions(ctx context.Context, path string, data []byte, opts rpcclient.ABCIQueryOptions) (*ctypes.ResultABCIQuery, error) {
+	var result *ctypes.ResultABCIQuery
+	err := p.query(ctx, "abci_query", func(ctx context.Context, cli *http.HTTP) (err error) {
+		result, err = cli.ABCIQueryWithOptions(ctx, path, data, opts)
+		return err
+	})
+	return result, err
+}
+
+func (p *Pool) ABCIInfo(ctx context.Context) (*ctypes.ResultABCIInfo, error) {
+	var result *ctypes.ResultABCIInfo
+	err := p.query(ctx, "abci_info", func(ctx context.Context, cli *http.HTTP) (err error) {
+		result, err = cli.ABCIInfo(ctx)
+		return err
+	})
+	return result, err
+}
+
+func (p *Pool) BroadcastTxSync(ctx context.Context, tx tmtypes.Tx) (*ctypes.ResultBroadcastTx, error) {
+	return p.broadcast(ctx, "broadcast_tx_sync", func(ctx context.Context, cli *http.HTTP) (*ctypes.ResultBroadcastTx, error) {
+		return cli.BroadcastTxSync(ctx, tx)
+	})
+}
+
+func (p *Pool) BroadcastTxAsync(ctx context.Context, tx tmtypes.Tx) (*ctypes.ResultBroadcastTx, error) {
+	return p.broadcast(ctx, "broadcast_tx_async", func(ctx context.Context, cli *http.HTTP) (*ctypes.ResultBroadcastTx, error) {
+		return cli.BroadcastTxAsync(ctx, tx)
+	})
+}
+
+// BroadcastTxCommit 只发送到一个节点且不重试：请求失败时交易可能已经进入内存池，由调用方按交易hash查询结果
+func (p *Pool) BroadcastTxCommit(ctx context.Context, tx tmtypes.Tx) (*ctypes.ResultBroadcastTxCommit, error) {
+	const op = "broadcast_tx_commit"
+	n := p.pick(nil)
+	if n == nil {
+		return nil, &Error{Kind: KindUnavailable, Op: op, Err: errAllOpen}
+	}
+
+	var result *ctypes.ResultBroadcastTxCommit
+	if err := p.try(ctx, n, op, p.commitTimeout, func(ctx context.Context, cli *http.HTTP) (err error) {
+		result, err = cli.BroadcastTxCommit(ctx, tx)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// query 查询失败时换节点重试，节点返回的错误不重试
+func (p *Pool) query(ctx context.Context, op string, call func(ctx context.Context, cli *http.HTTP) error) error {
+	tried := make(map[*node]bool)
+	var last *Error
+	for attempt := 0; attempt <= p.retries; attempt++ {
+		n := p.pick(tried)
+		if n == nil {
+			break
+		}
+		tried[n] = true
+
+		err := p.try(ctx, n, op, p.queryTimeout, call)
+		if err == nil {
+			return nil
+		}
+		last = err
+		if err.Kind == KindRejected || ctx.Err() != nil {
+			break
+		}
+	}
+	if last == nil {
+		return &Error{Kind: KindUnavailable, Op: op, Err: errAllOpen}
+	}
+	return last
+}
+
+// broadcast 同时发送到fanout个节点，返回第一个CheckTx通过的结果；都未通过时返回节点的CheckTx结果或错误。
+// 其余节点的请求在返回后继续完成，不受调用方ctx取消的影响
+func (p *Pool) broadcast(ctx context.Context, op string, call func(ctx context.Context, cli *http.HTTP) (*ctypes.ResultBroadcastTx, error)) (*ctypes.ResultBroadcastTx, error) {
+	nodes := p.pickN(p.fanout)
+	if len(nodes) == 0 {
+		return nil, &Error{Kind: KindUnavailable, Op: op, Err: errAllOpen}
+	}
+
+	type response struct {
+		result *ctypes.ResultBroadcastTx
+		err    *Error
+	}
+	responses := make(chan response, len(nodes))
+	for _, n := range nodes {
+		go func(n *node) {
+			var result *ctypes.ResultBroadcastTx
+			err := p.try(context.Background(), n, op, p.broadcastTimeout, func(ctx context.Context, cli *http.HTTP) (err error) {
+				result, err = call(ctx, cli)
+				return err
+			})
+			responses <- response{result, err}
+		}(n)
+	}
+
+	var (
+		checked *ctypes.ResultBroadcastTx
+		errs    []*Error
+	)
+	for range nodes {
+		select {
+		case <-ctx.Done():
+			return nil, &Error{Kind: KindTimeout, Op: op, Err: ctx.Err()}
+		case resp := <-responses:
+			switch {
+			case resp.err != nil:
+				errs = append(errs, resp.err)
+			case resp.result.Code == 0:
+				return resp.result, nil
+			case checked == nil:
+				checked = resp.result
+			}
+		}
+	}
+	if checked != nil {
+		return checked, nil
+	}
+	return nil, worst(errs)
+}
+
+// try 在节点n上执行一次请求并更新熔断状态。调用方取消ctx不计为节点失败，节点返回的错误说明节点正常
+func (p *Pool) try(ctx context.Context, n *node, op string, timeout time.Duration, call func(ctx context.Context, cli *http.HTTP) error) *Error {
+	cctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := call(cctx, n.cli)
+	if err == nil {
+		if n.success() {
+			log.Logger.Info("node recovered", zap.String("remote", n.remote))
+		}
+		return nil
+	}
+
+	e := classify(op, n.remote, err)
+	switch {
+	case e.Kind == KindRejected:
+		n.success()
+	case ctx.Err() != nil:
+		e.Kind = KindTimeout
+		n.release()
+	default:
+		log.Logger.Warn("node request failed", zap.Error(e))
+		if n.failure() {
+			log.Logger.Error("node circuit open", zap.String("remote", n.remote))
+		}
+	}
+	return e
+}
+
+// pick 轮流选择一个未熔断且不在exclude中的节点，没有时返回nil
+func (p *Pool) pick(exclude map[*node]bool) *node {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i := range p.nodes {
+		idx := (p.next + i) % len(p.nodes)
+		if n := p.nodes[idx]; !exclude[n] && n.allow() {
+			p.next = idx + 1
+			return n
+		}
+	}
+	return nil
+}
+
+// pickN 轮流选择最多count个未熔断的节点
+func (p *Pool) pickN(count int) []*node {
+	picked := make(map[*node]bool)
+	var nodes []*node
+	for len(nodes) < count {
+		n := p.pick(picked)
+		if n == nil {
+			break
+		}
+		picked[n] = true
+		nodes = append(nodes, n)
+	}
+	return nodes
+}
+
+// worst 多个节点都失败时返回最能说明原因的错误：节点拒绝优先于超时，超时优先于无法连接
+func worst(errs []*Error) *Error {
+	rank := map[Kind]int{KindRejected: 2, KindTimeout: 1, KindUnavailable: 0}
+	e := errs[0]
+	for _, err := range errs[1:] {
+		if rank[err.Kind] > rank[e.Kind] {
+			e = err
+		}
+	}
+	return e
+}
+
+func orDefault(d, def time.Duration) time.Duration {
+	if d <= 0 {
+		return def
+	}
+	return d
+}
@@ -0,0 +1,249 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	abcitypes "github.com/tendermint/tendermint/abci/types"
+	rpcclient "github.com/tendermint/tendermint/rpc/client"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+	rpctypes "github.com/tendermint/tendermint/rpc/jsonrpc/types"
+	"github.com/toolglobal/api/config"
+)
+
+// fakeNode 模拟节点的tendermint RPC
+type fakeNode struct {
+	*httptest.Server
+	mu     sync.Mutex
+	down   bool          // 返回503
+	delay  time.Duration // 响应前等待
+	code   uint32        // CheckTx结果
+	reject string        // 返回JSON-RPC错误
+	calls  map[string]int
+}
+
+func newFakeNode(t *testing.T) *fakeNode {
+	n := &fakeNode{calls: make(map[string]int)}
+	n.Server = httptest.NewServer(http.HandlerFunc(n.serve))
+	t.Cleanup(n.Close)
+	return n
+}
+
+func (n *fakeNode) set(fn func(n *fakeNode)) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	fn(n)
+}
+
+func (n *fakeNode) count(method string) int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.calls[method]
+}
+
+func (n *fakeNode) serve(w http.ResponseWriter, r *http.Request) {
+	var req rpctypes.RPCRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	n.mu.Lock()
+	n.calls[req.Method]++
+	down, delay, code, reject := n.down, n.delay, n.code, n.reject
+	n.mu.Unlock()
+	time.Sleep(delay)
+	if down {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	if reject != "" {
+		json.NewEncoder(w).Encode(rpctypes.RPCInternalError(req.ID, errors.New(reject)))
+		return
+	}
+
+	var result interface{}
+	switch req.Method {
+	case "abci_info":
+		result = &ctypes.ResultABCIInfo{Response: abcitypes.ResponseInfo{LastBlockHeight: 10}}
+	case "abci_query":
+		var params struct {
+			Height int64 `json:"height,string"`
+		}
+		json.Unmarshal(req.Params, &params)
+		result = &ctypes.ResultABCIQuery{Response: abcitypes.ResponseQuery{Value: []byte(n.URL), Height: params.Height}}
+	case "broadcast_tx_sync", "broadcast_tx_async":
+		result = &ctypes.ResultBroadcastTx{Code: code, Log: n.URL}
+	case "broadcast_tx_commit":
+		result = &ctypes.ResultBroadcastTxCommit{CheckTx: abcitypes.ResponseCheckTx{Code: code}, Height: 11}
+	default:
+		json.NewEncoder(w).Encode(rpctypes.RPCMethodNotFoundError(req.ID))
+		return
+	}
+	json.NewEncoder(w).Encode(rpctypes.NewRPCSuccessResponse(req.ID, result))
+}
+
+func newTestPool(t *testing.T, cfg config.Node, nodes ...*fakeNode) *Pool {
+	remotes := make([]string, len(nodes))
+	for i, n := range nodes {
+		remotes[i] = n.URL
+	}
+	p, err := New(remotes, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestPool_Query(t *testing.T) {
+	a, b := newFakeNode(t), newFakeNode(t)
+	p := newTestPool(t, config.Node{FailureThreshold: 2}, a, b)
+
+	for i := 0; i < 3; i++ {
+		if _, err := p.ABCIQuery(context.Background(), "/query", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 按高度查询
+	result, err := p.ABCIQueryWithOptions(context.Background(), "/query", nil, rpcclient.ABCIQueryOptions{Height: 5})
+	if err != nil || result.Response.Height != 5 {
+		t.Fatalf("query at height 5: %+v %v", result, err)
+	}
+	if a.count("abci_query") != 2 || b.count("abci_query") != 2 {
+		t.Fatalf("queries a=%d b=%d", a.count("abci_query"), b.count("abci_query"))
+	}
+
+	// a故障时换到b，连续失败两次后熔断，不再请求a
+	a.set(func(n *fakeNode) { n.down = true })
+	for i := 0; i < 6; i++ {
+		result, err := p.ABCIQuery(context.Background(), "/query", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(result.Response.Value) != b.URL {
+			t.Fatalf("answered by %s", result.Response.Value)
+		}
+	}
+	if calls := a.count("abci_query"); calls != 4 {
+		t.Fatalf("circuit not open, a queried %d times", calls)
+	}
+
+	// 全部熔断
+	b.set(func(n *fakeNode) { n.down = true })
+	p.ABCIInfo(context.Background())
+	p.ABCIInfo(context.Background())
+	_, err = p.ABCIInfo(context.Background())
+	if !IsKind(err, KindUnavailable) || Message(err) != "node unavailable" {
+		t.Fatalf("err %v", err)
+	}
+}
+
+func TestPool_Timeout(t *testing.T) {
+	a := newFakeNode(t)
+	a.set(func(n *fakeNode) { n.delay = 200 * time.Millisecond })
+	p := newTestPool(t, config.Node{}, a)
+	p.queryTimeout = 20 * time.Millisecond
+
+	_, err := p.ABCIInfo(context.Background())
+	if !IsKind(err, KindTimeout) || Message(err) != "node timeout" {
+		t.Fatalf("err %v", err)
+	}
+	if !strings.Contains(err.Error(), a.URL) || strings.Contains(Message(err), a.URL) {
+		t.Fatalf("remote in error: %q / %q", err.Error(), Message(err))
+	}
+}
+
+func TestPool_Broadcast(t *testing.T) {
+	a, b, c := newFakeNode(t), newFakeNode(t), newFakeNode(t)
+	p := newTestPool(t, config.Node{Fanout: 3}, a, b, c)
+
+	// 同时发送到所有节点，其中一个节点故障不影响结果
+	b.set(func(n *fakeNode) { n.down = true })
+	result, err := p.BroadcastTxSync(context.Background(), []byte("tx"))
+	if err != nil || result.Code != 0 {
+		t.Fatalf("broadcast %+v %v", result, err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for a.count("broadcast_tx_sync")+b.count("broadcast_tx_sync")+c.count("broadcast_tx_sync") != 3 {
+		if time.Now().After(deadline) {
+			t.Fatal("not sent to every node")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// 优先返回CheckTx通过的结果
+	b.set(func(n *fakeNode) { n.down = false })
+	a.set(func(n *fakeNode) { n.code = 1 })
+	b.set(func(n *fakeNode) { n.code = 1 })
+	if result, err := p.BroadcastTxAsync(context.Background(), []byte("tx")); err != nil || result.Code != 0 || result.Log != c.URL {
+		t.Fatalf("broadcast %+v %v", result, err)
+	}
+	// 都未通过时返回CheckTx结果
+	c.set(func(n *fakeNode) { n.code = 1 })
+	if result, err := p.BroadcastTxSync(context.Background(), []byte("tx")); err != nil || result.Code != 1 {
+		t.Fatalf("broadcast %+v %v", result, err)
+	}
+
+	// 节点返回的错误原样说明原因
+	for _, n := range []*fakeNode{a, b, c} {
+		n.set(func(n *fakeNode) { n.reject = "tx already exists in cache" })
+	}
+	_, err = p.BroadcastTxSync(context.Background(), []byte("tx"))
+	if !IsKind(err, KindRejected) || Message(err) != "node rejected request: tx already exists in cache" {
+		t.Fatalf("err %v", err)
+	}
+}
+
+func TestPool_BroadcastTxCommit(t *testing.T) {
+	a, b := newFakeNode(t), newFakeNode(t)
+	p := newTestPool(t, config.Node{}, a, b)
+
+	result, err := p.BroadcastTxCommit(context.Background(), []byte("tx"))
+	if err != nil || result.Height != 11 {
+		t.Fatalf("commit %+v %v", result, err)
+	}
+	// 不重试，也不发送到其它节点
+	a.set(func(n *fakeNode) { n.down = true })
+	b.set(func(n *fakeNode) { n.down = true })
+	if _, err := p.BroadcastTxCommit(context.Background(), []byte("tx")); !IsKind(err, KindUnavailable) {
+		t.Fatalf("err %v", err)
+	}
+	if calls := a.count("broadcast_tx_commit") + b.count("broadcast_tx_commit"); calls != 2 {
+		t.Fatalf("commit sent %d times", calls)
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	b := newBreaker(2, time.Minute)
+	b.now = func() time.Time { return now }
+
+	if b.failure() || !b.allow() {
+		t.Fatal("opened after one failure")
+	}
+	if !b.failure() || b.allow() {
+		t.Fatal("not opened after threshold")
+	}
+
+	// 熔断结束后只放行一个试探请求
+	now = now.Add(time.Minute)
+	if !b.allow() || b.allow() {
+		t.Fatal("half open should allow exactly one probe")
+	}
+	b.failure()
+	if b.allow() {
+		t.Fatal("failed probe should reopen")
+	}
+
+	now = now.Add(time.Minute)
+	if !b.allow() || !b.success() || !b.allow() || !b.allow() {
+		t.Fatal("successful probe should close")
+	}
+}
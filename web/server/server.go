@@ -12,12 +12,17 @@ import (
 	"github.com/toolglobal/api/config"
 	_ "github.com/toolglobal/api/docs"
 	"github.com/toolglobal/api/libs"
+	"github.com/toolglobal/api/libs/bus"
 	"github.com/toolglobal/api/libs/ginlimiter"
 	"github.com/toolglobal/api/web/dbo"
+	"github.com/toolglobal/api/web/ethrpc"
+	"github.com/toolglobal/api/web/graphql"
 	"github.com/toolglobal/api/web/handlers"
 	"github.com/toolglobal/api/web/proxy"
+	"github.com/toolglobal/api/web/stream"
 	"github.com/zsais/go-gin-prometheus"
 	"go.uber.org/zap"
+	"net/http"
 	"os"
 	"time"
 )
@@ -25,12 +30,28 @@ import (
 type Server struct {
 	cfg     *config.Config
 	handler *handlers.Handler
+	ethrpc  http.Handler
+	graphql http.Handler
+	stream  http.Handler
 	proxy   *proxy.ReverseProxy
 	metrics *ginprom.GinPrometheus
 }
 
-func NewServer(logger *zap.Logger, cfg *config.Config, dbo3 *dbo.DBO) *Server {
-	handler := handlers.NewHandler(logger, cfg, dbo3)
+// NewServer events为同步任务发布新区块的总线，供/v3/stream推送
+func NewServer(logger *zap.Logger, cfg *config.Config, dbo3 *dbo.DBO, events *bus.Bus) *Server {
+	handler, err := handlers.NewHandler(logger, cfg, dbo3)
+	if err != nil {
+		panic(err)
+	}
+
+	rpcServer, err := ethrpc.NewServer(dbo3, cfg.Web3RPC)
+	if err != nil {
+		panic(err)
+	}
+	gqlHandler, err := graphql.NewHandler(dbo3)
+	if err != nil {
+		panic(err)
+	}
 
 	p := proxy.NewReverseProxy()
 	p.AddToSetUpstream(cfg.RPC)
@@ -40,6 +61,9 @@ func NewServer(logger *zap.Logger, cfg *config.Config, dbo3 *dbo.DBO) *Server {
 	return &Server{
 		cfg:     cfg,
 		handler: handler,
+		ethrpc:  rpcServer,
+		graphql: gqlHandler,
+		stream:  stream.NewHandler(dbo3, events),
 		proxy:   p,
 	}
 }
@@ -131,12 +155,52 @@ func (s *Server) Start() {
 		v3.GET("/accounts/:address/payments", s.handler.QueryV3AccPayments)
 		v3.GET("/transactions/:txhash/payments", s.handler.QueryV3TxPayments)
 
+		v3.GET("/nft-transfers", s.handler.QueryV3NFTTransfers)
+		v3.GET("/accounts/:address/nft-transfers", s.handler.QueryV3AccNFTTransfers)
+		v3.GET("/accounts/:address/approvals", s.handler.QueryV3AccApprovals)
+		v3.GET("/accounts/:address/balance", s.handler.QueryV3AccBalance)
+		v3.GET("/accounts/:address/balances", s.handler.QueryV3AccBalances)
+
+		v3.GET("/contracts", s.handler.QueryV3Contracts)
+		v3.GET("/contracts/:address", s.handler.QueryV3Contract)
+
+		v3.GET("/logs", s.handler.QueryV3Logs)
+		v3.GET("/transactions/:txhash/logs", s.handler.QueryV3TxLogs)
+		v3.GET("/raw-txs", s.handler.QueryV3RawTxs)
+
+		v3.GET("/status", s.handler.QueryV3Status)
+		v3.GET("/stream", gin.WrapH(s.stream))
+
+		webhooks := v3.Group("/webhooks", s.handler.CheckWebhookAPIKey)
+		{
+			webhooks.POST("", s.handler.AddV3Webhook)
+			webhooks.GET("", s.handler.QueryV3Webhooks)
+			webhooks.GET("/:id", s.handler.QueryV3Webhook)
+			webhooks.DELETE("/:id", s.handler.DeleteV3Webhook)
+			webhooks.GET("/:id/deliveries", s.handler.QueryV3WebhookDeliveries)
+			webhooks.POST("/:id/deliveries/:deliveryId/retry", s.handler.RetryV3WebhookDelivery)
+		}
+
+		depositAddresses := v3.Group("/deposit-addresses", s.handler.CheckDepositAPIKey)
+		{
+			depositAddresses.POST("", s.handler.SaveV3DepositAddress)
+			depositAddresses.GET("", s.handler.QueryV3DepositAddresses)
+			depositAddresses.DELETE("/:address", s.handler.DeleteV3DepositAddress)
+		}
+		v3.GET("/deposits", s.handler.CheckDepositAPIKey, s.handler.QueryV3Deposits)
+
 		v3.GET("/config/tokens", s.handler.V3QueryConfigTokens)
 		//v3.GET("/config/nodes", s.handler.V3QueryConfigNodes)
 		v3.GET("/ext/price/:symbol", cache.CachePageAtomic(store, time.Minute, s.handler.V3QueryPrice))
 		//v3.GET("/ext/price/:symbol", s.handler.V3QueryPrice)
 	}
 
+	// 以太坊JSON-RPC
+	router.POST("/rpc", gin.WrapH(s.ethrpc))
+
+	// GraphQL
+	router.POST("/graphql", gin.WrapH(s.graphql))
+
 	// reverse proxy
 	//s.proxy.SetPrefixPath("/v2/proxy")
 	//router.GET("/v2/proxy/*proxypath", gin.WrapH(s.proxy.Proxy()))
@@ -0,0 +1,80 @@
+package stream
+
+import (
+	"strings"
+
+	"github.com/toolglobal/api/database"
+)
+
+// filter 订阅条件，均为空时推送全部区块
+type filter struct {
+	Address  string // 交易或转账的发起方、接收方
+	Contract string // 转账的合约地址
+	Symbol   string // 转账的币种
+	TxType   string // 交易类型，例如TxTagAppEvm
+}
+
+func (f *filter) empty() bool {
+	return f.Address == "" && f.Contract == "" && f.Symbol == "" && f.TxType == ""
+}
+
+// apply 返回只包含匹配的交易和转账的区块，没有匹配时返回nil
+func (f *filter) apply(ev *database.BlockEvent) *database.BlockEvent {
+	if f.empty() {
+		return ev
+	}
+
+	txTypes := make(map[string]string, len(ev.Transactions))
+	for _, tx := range ev.Transactions {
+		txTypes[tx.Hash] = tx.Types
+	}
+
+	result := &database.BlockEvent{
+		Ledger:       ev.Ledger,
+		Transactions: []database.V3Transaction{},
+		Payments:     []database.V3Payment{},
+	}
+	matched := make(map[string]bool)
+	for _, p := range ev.Payments {
+		if f.matchPayment(&p, txTypes[p.Hash]) {
+			result.Payments = append(result.Payments, p)
+			matched[p.Hash] = true
+		}
+	}
+	for _, tx := range ev.Transactions {
+		if f.matchTx(&tx, matched[tx.Hash]) {
+			result.Transactions = append(result.Transactions, tx)
+		}
+	}
+
+	if len(result.Transactions) == 0 && len(result.Payments) == 0 {
+		return nil
+	}
+	return result
+}
+
+func (f *filter) matchPayment(p *database.V3Payment, txType string) bool {
+	return (f.Address == "" || equal(p.Sender, f.Address) || equal(p.Receiver, f.Address)) &&
+		(f.Contract == "" || equal(p.Contract, f.Contract)) &&
+		(f.Symbol == "" || equal(p.Symbol, f.Symbol)) &&
+		(f.TxType == "" || txType == f.TxType)
+}
+
+// matchTx 指定contract、symbol时交易需要有匹配的转账；只指定address时发起方、接收方或转账匹配即可
+func (f *filter) matchTx(tx *database.V3Transaction, hasPayment bool) bool {
+	if f.TxType != "" && tx.Types != f.TxType {
+		return false
+	}
+	if hasPayment {
+		return true
+	}
+	if f.Contract != "" || f.Symbol != "" {
+		return false
+	}
+	return f.Address == "" || equal(tx.Sender, f.Address) || equal(tx.Receiver, f.Address)
+}
+
+// equal 地址大小写不敏感
+func equal(a, b string) bool {
+	return strings.EqualFold(a, b)
+}
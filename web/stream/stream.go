@@ -0,0 +1,326 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/toolglobal/api/database"
+	"github.com/toolglobal/api/libs/bus"
+	"github.com/toolglobal/api/web/dbo"
+)
+
+/*
+新区块推送，同一地址支持WebSocket和Server-Sent Events
+1. 订阅同步任务入库后发布的区块，按address、contract、symbol、txType过滤
+2. 指定fromHeight(SSE重连时为Last-Event-ID+1)时先从库中补齐该高度之后的区块，再推送新区块
+3. 处理太慢被总线取消订阅时断开连接，客户端按最后收到的高度重连补齐
+*/
+
+const (
+	bufferSize   = 256              // 每个连接缓冲的区块数
+	resumeBatch  = 100              // 补齐时每次查询的区块数
+	maxResume    = 10000            // 最多补齐的区块数，更早的数据请使用查询接口
+	pingInterval = 30 * time.Second // 心跳间隔
+	writeTimeout = 10 * time.Second
+)
+
+var errLagged = errors.New("subscriber is too slow, reconnect with fromHeight")
+
+// store 补齐区块用到的查询，由dbo.DBO实现
+type store interface {
+	QuerySyncState(name string) (*database.SyncState, error)
+	QueryV3LedgersByHeights(heights []int64) ([]database.V3Ledger, error)
+	QueryV3TxsByHeights(heights []int64) ([]database.V3Transaction, error)
+	QueryV3PaymentsByHeights(heights []int64) ([]database.V3Payment, error)
+}
+
+// sender 推送方式
+type sender interface {
+	send(event string, id int64, v interface{}) error
+	ping() error
+}
+
+type Handler struct {
+	db       store
+	bus      *bus.Bus
+	upgrader websocket.Upgrader
+}
+
+// NewHandler 创建/v3/stream的http处理器
+func NewHandler(dbo3 *dbo.DBO, b *bus.Bus) *Handler {
+	return newHandler(dbo3, b)
+}
+
+func newHandler(db store, b *bus.Bus) *Handler {
+	return &Handler{
+		db:  db,
+		bus: b,
+		upgrader: websocket.Upgrader{
+			// 与REST接口一样允许跨域
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+	}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := &filter{
+		Address:  q.Get("address"),
+		Contract: q.Get("contract"),
+		Symbol:   q.Get("symbol"),
+		TxType:   q.Get("txType"),
+	}
+	var from int64
+	if s := q.Get("fromHeight"); s != "" {
+		var err error
+		if from, err = strconv.ParseInt(s, 10, 64); err != nil || from < 0 {
+			http.Error(w, "invalid fromHeight :"+s, http.StatusBadRequest)
+			return
+		}
+	}
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		if last, err := strconv.ParseInt(id, 10, 64); err == nil {
+			from = last + 1
+		}
+	}
+
+	if websocket.IsWebSocketUpgrade(r) {
+		h.serveWebSocket(w, r, f, from)
+	} else {
+		h.serveSSE(w, r, f, from)
+	}
+}
+
+func (h *Handler) serveSSE(w http.ResponseWriter, r *http.Request, f *filter, from int64) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	s := &sseSender{w: w, flusher: flusher}
+	if err := h.run(r.Context(), f, from, s); err != nil && r.Context().Err() == nil {
+		s.send("error", 0, map[string]string{"message": err.Error()})
+	}
+}
+
+func (h *Handler) serveWebSocket(w http.ResponseWriter, r *http.Request, f *filter, from int64) {
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	// 读取并丢弃客户端消息，连接断开时结束推送
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	s := &wsSender{conn: conn}
+	if err := h.run(ctx, f, from, s); err != nil && ctx.Err() == nil {
+		code := websocket.CloseInternalServerErr
+		if err == errLagged {
+			code = websocket.CloseTryAgainLater
+		}
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, err.Error()), time.Now().Add(writeTimeout))
+	}
+}
+
+// run 补齐fromHeight之后的区块，然后推送新区块直到连接断开
+func (h *Handler) run(ctx context.Context, f *filter, from int64, s sender) error {
+	sub := h.bus.Subscribe(bufferSize)
+	defer sub.Unsubscribe()
+
+	// 已推送的高度，补齐期间总线上缓冲的区块不重复推送
+	var last int64
+	if from > 0 {
+		var err error
+		if last, err = h.resume(ctx, f, from, s); err != nil {
+			return err
+		}
+	}
+
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := s.ping(); err != nil {
+				return err
+			}
+		case msg, ok := <-sub.C():
+			if !ok {
+				if sub.Lagged() {
+					return errLagged
+				}
+				return nil
+			}
+			switch ev := msg.(type) {
+			case *database.BlockEvent:
+				if ev.Ledger.Height <= last {
+					continue
+				}
+				last = ev.Ledger.Height
+				if err := sendBlock(s, f, ev); err != nil {
+					return err
+				}
+			case *database.RollbackEvent:
+				last = ev.Height
+				if err := s.send("rollback", ev.Height, ev); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// resume 从库中补齐from到已同步高度的区块，返回最后补齐的高度
+func (h *Handler) resume(ctx context.Context, f *filter, from int64, s sender) (int64, error) {
+	state, err := h.db.QuerySyncState(database.SyncStateV3)
+	if err != nil {
+		return 0, err
+	}
+	if state == nil || state.Height < from {
+		return from - 1, nil
+	}
+	if state.Height-from+1 > maxResume {
+		return 0, fmt.Errorf("fromHeight is more than %d blocks behind, use the query API", maxResume)
+	}
+
+	for begin := from; begin <= state.Height; begin += resumeBatch {
+		if ctx.Err() != nil {
+			return 0, nil
+		}
+		end := begin + resumeBatch - 1
+		if end > state.Height {
+			end = state.Height
+		}
+		events, err := h.loadBlocks(begin, end)
+		if err != nil {
+			return 0, err
+		}
+		for _, ev := range events {
+			if err := sendBlock(s, f, ev); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return state.Height, nil
+}
+
+// loadBlocks 从库中读取begin到end高度的区块，按高度升序
+func (h *Handler) loadBlocks(begin, end int64) ([]*database.BlockEvent, error) {
+	var heights []int64
+	for height := begin; height <= end; height++ {
+		heights = append(heights, height)
+	}
+
+	ledgers, err := h.db.QueryV3LedgersByHeights(heights)
+	if err != nil {
+		return nil, err
+	}
+	txs, err := h.db.QueryV3TxsByHeights(heights)
+	if err != nil {
+		return nil, err
+	}
+	payments, err := h.db.QueryV3PaymentsByHeights(heights)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]*database.BlockEvent, 0, len(ledgers))
+	byHeight := make(map[int64]*database.BlockEvent, len(ledgers))
+	for _, ledger := range ledgers {
+		ev := &database.BlockEvent{Ledger: ledger, Transactions: []database.V3Transaction{}, Payments: []database.V3Payment{}}
+		events = append(events, ev)
+		byHeight[ledger.Height] = ev
+	}
+	for _, tx := range txs {
+		if ev, ok := byHeight[tx.Height]; ok {
+			ev.Transactions = append(ev.Transactions, tx)
+		}
+	}
+	for _, p := range payments {
+		if ev, ok := byHeight[p.Height]; ok {
+			ev.Payments = append(ev.Payments, p)
+		}
+	}
+	return events, nil
+}
+
+func sendBlock(s sender, f *filter, ev *database.BlockEvent) error {
+	if ev = f.apply(ev); ev == nil {
+		return nil
+	}
+	return s.send("block", ev.Ledger.Height, ev)
+}
+
+// sseSender 每个事件的id为高度，断线重连时浏览器通过Last-Event-ID带回
+type sseSender struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+func (s *sseSender) send(event string, id int64, v interface{}) error {
+	bz, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if id > 0 {
+		if _, err := fmt.Fprintf(s.w, "id: %d\n", id); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, bz); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+func (s *sseSender) ping() error {
+	if _, err := fmt.Fprint(s.w, ": ping\n\n"); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+// wsSender 每条消息为{"event":"block","height":高度,"data":区块}
+type wsSender struct {
+	conn *websocket.Conn
+}
+
+func (s *wsSender) send(event string, id int64, v interface{}) error {
+	s.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	return s.conn.WriteJSON(struct {
+		Event  string      `json:"event"`
+		Height int64       `json:"height"`
+		Data   interface{} `json:"data"`
+	}{event, id, v})
+}
+
+func (s *wsSender) ping() error {
+	return s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeTimeout))
+}
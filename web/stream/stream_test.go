@@ -0,0 +1,183 @@
+package stream
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/toolglobal/api/client"
+	"github.com/toolglobal/api/database"
+	"github.com/toolglobal/api/datamanager/datamanagertest"
+	"github.com/toolglobal/api/libs/bus"
+	"github.com/toolglobal/api/web/dbo"
+)
+
+const (
+	alice = datamanagertest.Alice
+	bob   = datamanagertest.Bob
+	usdt  = datamanagertest.USDT
+)
+
+// newTestServer 库中已有高度1-3
+func newTestServer(t *testing.T) (*httptest.Server, *bus.Bus) {
+	dataM := datamanagertest.New(t)
+	datamanagertest.SaveBlocks(t, dataM, 1, 3, client.IndexerVersion)
+
+	b := bus.New()
+	ts := httptest.NewServer(newHandler(dbo.New(dataM), b))
+	t.Cleanup(ts.Close)
+	return ts, b
+}
+
+type sseEvent struct {
+	id, event string
+	data      database.BlockEvent
+}
+
+func readSSE(t *testing.T, r *bufio.Reader) sseEvent {
+	var ev sseEvent
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			if ev.event != "" {
+				return ev
+			}
+		case strings.HasPrefix(line, "id: "):
+			ev.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			ev.event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &ev.data); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestStream_SSE(t *testing.T) {
+	ts, b := newTestServer(t)
+
+	// 断线重连：Last-Event-ID为1，从高度2开始补齐
+	req, _ := http.NewRequest("GET", ts.URL+"?address="+strings.ToLower(alice), nil)
+	req.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("content type %s", ct)
+	}
+	r := bufio.NewReader(resp.Body)
+
+	for _, h := range []int64{2, 3} {
+		ev := readSSE(t, r)
+		if ev.event != "block" || ev.id != fmt.Sprint(h) || ev.data.Ledger.Height != h ||
+			len(ev.data.Transactions) != 2 || len(ev.data.Payments) != 2 {
+			t.Fatalf("resumed %+v", ev)
+		}
+	}
+
+	// 补齐过的高度不重复推送
+	b.Publish(datamanagertest.Block(3))
+	b.Publish(datamanagertest.Block(4))
+	b.Publish(&database.RollbackEvent{Height: 3})
+	if ev := readSSE(t, r); ev.event != "block" || ev.id != "4" || ev.data.Ledger.Height != 4 {
+		t.Fatalf("live %+v", ev)
+	}
+	if ev := readSSE(t, r); ev.event != "rollback" || ev.id != "3" {
+		t.Fatalf("rollback %+v", ev)
+	}
+}
+
+func TestStream_WebSocket(t *testing.T) {
+	ts, b := newTestServer(t)
+
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "?symbol=USDT&fromHeight=3"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	var msg struct {
+		Event  string
+		Height int64
+		Data   database.BlockEvent
+	}
+	read := func() {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	read()
+	if msg.Event != "block" || msg.Height != 3 || len(msg.Data.Payments) != 1 || msg.Data.Payments[0].Value != "3" ||
+		len(msg.Data.Transactions) != 1 || msg.Data.Transactions[0].Types != "TxTagEthereumTx" {
+		t.Fatalf("resumed %+v", msg)
+	}
+
+	// 没有匹配的区块不推送
+	empty := datamanagertest.Block(4)
+	empty.Payments = empty.Payments[:1]
+	b.Publish(empty)
+	b.Publish(datamanagertest.Block(5))
+	read()
+	if msg.Height != 5 || len(msg.Data.Payments) != 1 || msg.Data.Payments[0].Symbol != "USDT" {
+		t.Fatalf("live %+v", msg)
+	}
+}
+
+func TestStream_InvalidFromHeight(t *testing.T) {
+	ts, _ := newTestServer(t)
+
+	resp, err := http.Get(ts.URL + "?fromHeight=x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("status %d", resp.StatusCode)
+	}
+}
+
+func TestFilter_Apply(t *testing.T) {
+	cases := []struct {
+		name     string
+		filter   filter
+		txs, pms int
+	}{
+		{"empty", filter{}, 2, 2},
+		{"address", filter{Address: strings.ToLower(alice)}, 2, 2},
+		{"other address", filter{Address: usdt[:len(usdt)-1] + "2"}, 0, 0},
+		{"address receiver", filter{Address: usdt}, 1, 0},
+		{"contract", filter{Contract: usdt}, 1, 1},
+		{"symbol and address", filter{Symbol: "OLO", Address: alice}, 1, 1},
+		{"tx type", filter{TxType: "TxTagAppEvm"}, 1, 1},
+		{"tx type and contract", filter{TxType: "TxTagAppEvm", Contract: usdt}, 0, 0},
+	}
+	for _, c := range cases {
+		ev := c.filter.apply(datamanagertest.Block(1))
+		if c.txs == 0 && c.pms == 0 {
+			if ev != nil {
+				t.Fatalf("%s: got %+v", c.name, ev)
+			}
+			continue
+		}
+		if ev == nil || len(ev.Transactions) != c.txs || len(ev.Payments) != c.pms {
+			t.Fatalf("%s: got %+v", c.name, ev)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package webhook
+
+import (
+	"strings"
+
+	"github.com/toolglobal/api/database"
+)
+
+// matchTx 交易的发起方或接收方匹配address、接收方匹配contract；指定event时只推送转账
+func matchTx(hook *database.V3Webhook, tx *database.V3Transaction) bool {
+	return hook.Event == "" &&
+		(hook.Address == "" || equal(tx.Sender, hook.Address) || equal(tx.Receiver, hook.Address)) &&
+		(hook.Contract == "" || equal(tx.Receiver, hook.Contract))
+}
+
+// matchPayment 转账的发起方或接收方匹配address、合约地址匹配contract、事件名称匹配event
+func matchPayment(hook *database.V3Webhook, p *database.V3Payment) bool {
+	return (hook.Address == "" || equal(p.Sender, hook.Address) || equal(p.Receiver, hook.Address)) &&
+		(hook.Contract == "" || equal(p.Contract, hook.Contract)) &&
+		(hook.Event == "" || p.EvName == hook.Event)
+}
+
+// equal 地址大小写不敏感
+func equal(a, b string) bool {
+	return strings.EqualFold(a, b)
+}
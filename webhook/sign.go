@@ -0,0 +1,17 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Sign 推送签名：hex(HMAC-SHA256(secret, timestamp + "." + body))。
+// 接收方用注册时返回的secret重新计算并比较X-Mondo-Signature，同时拒绝X-Mondo-Timestamp相差过大的请求以防重放
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,401 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/toolglobal/api/config"
+	"github.com/toolglobal/api/database"
+	"github.com/toolglobal/api/datamanager"
+	"github.com/toolglobal/api/libs/bus"
+	"github.com/toolglobal/api/libs/log"
+	"go.uber.org/zap"
+)
+
+/*
+webhook推送
+1. 按推送进度（sync_state中的webhook）读取已入库的区块，匹配已注册的webhook后写入v3_webhook_deliveries，与推送进度在同一事务中提交
+2. 到期的记录POST到注册的地址，带HMAC-SHA256签名；失败时按指数退避重试，超过最大次数的进入死信，可以通过接口重新推送
+3. 回滚时推送进度随sync_state回退，尚未推送的记录被删除；已推送的不会撤回，接收方按交易hash去重
+*/
+
+const (
+	// SyncStateName sync_state中推送进度的名称
+	SyncStateName = "webhook"
+
+	HeaderWebhook   = "X-Mondo-Webhook"   // webhook id
+	HeaderDelivery  = "X-Mondo-Delivery"  // 推送记录id，重试时不变
+	HeaderTimestamp = "X-Mondo-Timestamp" // 推送时的unix时间戳，参与签名
+	HeaderSignature = "X-Mondo-Signature" // sha256=签名
+
+	bufferSize   = 256       // 缓冲的新区块通知数
+	matchBatch   = 100       // 每次匹配的区块数
+	sendBatch    = 100       // 每次取出的到期记录数
+	maxErrorLen  = 512       // lastError的最大长度
+	maxRespBody  = 64 << 10  // 读取并丢弃的响应大小
+	retryMaximum = time.Hour // 重试间隔上限
+	retryInitial = 10 * time.Second
+)
+
+// Notification 推送的内容
+type Notification struct {
+	WebhookId uint64      `json:"webhookId"` // webhook id
+	Kind      string      `json:"kind"`      // transaction、payment
+	Height    int64       `json:"height"`    // 区块高度
+	Hash      string      `json:"hash"`      // 交易hash
+	Data      interface{} `json:"data"`      // database.V3Transaction或database.V3Payment
+}
+
+type Service struct {
+	ctx          context.Context
+	dataMgr      *datamanager.DataManager
+	bus          *bus.Bus
+	client       *http.Client
+	workers      int           // 并发推送数
+	maxAttempts  int           // 最多推送次数
+	retryBase    time.Duration // 第一次重试的间隔，之后每次翻倍
+	pollInterval time.Duration // 没有新区块通知时的检查间隔
+}
+
+func New(ctx context.Context, mgr *datamanager.DataManager, b *bus.Bus, cfg config.Webhook) *Service {
+	s := &Service{
+		ctx:          ctx,
+		dataMgr:      mgr,
+		bus:          b,
+		client:       &http.Client{Timeout: cfg.Timeout.Duration},
+		workers:      cfg.Workers,
+		maxAttempts:  cfg.MaxAttempts,
+		retryBase:    retryInitial,
+		pollInterval: time.Second,
+	}
+	if s.client.Timeout <= 0 {
+		s.client.Timeout = 10 * time.Second
+	}
+	if s.workers <= 0 {
+		s.workers = 4
+	}
+	if s.maxAttempts <= 0 {
+		s.maxAttempts = 8
+	}
+	return s
+}
+
+// Start 匹配新区块并推送，直到ctx结束
+func (s *Service) Start() {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.sendLoop()
+	}()
+	s.matchLoop()
+	wg.Wait()
+}
+
+func (s *Service) matchLoop() {
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+	sub := s.bus.Subscribe(bufferSize)
+	defer func() { sub.Unsubscribe() }()
+
+	for {
+		if err := s.match(); err != nil {
+			log.Logger.Error("webhook match", zap.Error(err))
+		}
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+		case _, ok := <-sub.C():
+			// 通知只用于唤醒，处理慢被取消时重新订阅，数据从库中读取不会遗漏
+			if !ok {
+				sub = s.bus.Subscribe(bufferSize)
+			}
+		}
+	}
+}
+
+// match 匹配推送进度之后已入库的区块
+func (s *Service) match() error {
+	state, err := s.dataMgr.QuerySyncState(database.SyncStateV3)
+	if err != nil || state == nil {
+		return err
+	}
+	cursor, err := s.dataMgr.QuerySyncState(SyncStateName)
+	if err != nil {
+		return err
+	}
+	if cursor == nil {
+		// 首次开启时从当前高度开始，不推送历史区块
+		ledger, err := s.dataMgr.QueryV3Ledger(state.Height)
+		if err != nil || ledger == nil {
+			return err
+		}
+		return s.dataMgr.AddV3WebhookDeliveries(SyncStateName, ledger.Height, ledger.BlockHash, nil)
+	}
+	if cursor.Height >= state.Height {
+		return nil
+	}
+
+	hooks, err := s.dataMgr.QueryV3Webhooks(nil, "ASC")
+	if err != nil {
+		return err
+	}
+	for begin := cursor.Height + 1; begin <= state.Height; begin += matchBatch {
+		if s.ctx.Err() != nil {
+			return nil
+		}
+		end := begin + matchBatch - 1
+		if end > state.Height {
+			end = state.Height
+		}
+		if err := s.matchRange(hooks, begin, end); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchRange 匹配begin到end高度的交易和转账，按高度、交易、转账的顺序写入
+func (s *Service) matchRange(hooks []database.V3Webhook, begin, end int64) error {
+	var heights []int64
+	for height := begin; height <= end; height++ {
+		heights = append(heights, height)
+	}
+	ledgers, err := s.dataMgr.QueryV3LedgersByHeights(heights)
+	if err != nil {
+		return err
+	}
+	var blockHash string
+	for _, ledger := range ledgers {
+		if ledger.Height == end {
+			blockHash = ledger.BlockHash
+		}
+	}
+	if blockHash == "" {
+		return fmt.Errorf("ledger %d not found", end)
+	}
+
+	var deliveries []database.V3WebhookDelivery
+	if len(hooks) > 0 {
+		txs, err := s.dataMgr.QueryV3TxsByHeights(heights)
+		if err != nil {
+			return err
+		}
+		payments, err := s.dataMgr.QueryV3PaymentsByHeights(heights)
+		if err != nil {
+			return err
+		}
+		if deliveries, err = matchBlocks(hooks, heights, txs, payments, time.Now()); err != nil {
+			return err
+		}
+	}
+	return s.dataMgr.AddV3WebhookDeliveries(SyncStateName, end, blockHash, deliveries)
+}
+
+func matchBlocks(hooks []database.V3Webhook, heights []int64, txs []database.V3Transaction, payments []database.V3Payment, now time.Time) ([]database.V3WebhookDelivery, error) {
+	txsByHeight := make(map[int64][]*database.V3Transaction)
+	for i := range txs {
+		txsByHeight[txs[i].Height] = append(txsByHeight[txs[i].Height], &txs[i])
+	}
+	paymentsByHeight := make(map[int64][]*database.V3Payment)
+	for i := range payments {
+		paymentsByHeight[payments[i].Height] = append(paymentsByHeight[payments[i].Height], &payments[i])
+	}
+
+	var deliveries []database.V3WebhookDelivery
+	add := func(hook *database.V3Webhook, kind, hash string, height int64, data interface{}) error {
+		bz, err := json.Marshal(&Notification{WebhookId: hook.Id, Kind: kind, Height: height, Hash: hash, Data: data})
+		if err != nil {
+			return err
+		}
+		deliveries = append(deliveries, database.V3WebhookDelivery{
+			WebhookId:     hook.Id,
+			Kind:          kind,
+			Hash:          hash,
+			Height:        height,
+			Payload:       string(bz),
+			Status:        database.WebhookPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		})
+		return nil
+	}
+
+	for _, height := range heights {
+		for _, tx := range txsByHeight[height] {
+			for i := range hooks {
+				if matchTx(&hooks[i], tx) {
+					if err := add(&hooks[i], database.WebhookKindTransaction, tx.Hash, height, tx); err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+		for _, p := range paymentsByHeight[height] {
+			for i := range hooks {
+				if matchPayment(&hooks[i], p) {
+					if err := add(&hooks[i], database.WebhookKindPayment, p.Hash, height, p); err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+	}
+	return deliveries, nil
+}
+
+func (s *Service) sendLoop() {
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		n, err := s.send()
+		if err != nil {
+			log.Logger.Error("webhook send", zap.Error(err))
+		}
+		if n == sendBatch && s.ctx.Err() == nil {
+			continue
+		}
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// send 推送到期的记录，返回推送成功的记录数
+func (s *Service) send() (int, error) {
+	due, err := s.dataMgr.QueryDueV3WebhookDeliveries(time.Now(), sendBatch)
+	if err != nil || len(due) == 0 {
+		return 0, err
+	}
+	hooks, err := s.dataMgr.QueryV3Webhooks(nil, "ASC")
+	if err != nil {
+		return 0, err
+	}
+	byId := make(map[uint64]*database.V3Webhook, len(hooks))
+	for i := range hooks {
+		byId[hooks[i].Id] = &hooks[i]
+	}
+
+	var (
+		wg        sync.WaitGroup
+		delivered int32
+		orphaned  = make(map[uint64]bool)
+	)
+	sem := make(chan struct{}, s.workers)
+	for i := range due {
+		hook, ok := byId[due[i].WebhookId]
+		if !ok {
+			orphaned[due[i].WebhookId] = true
+			continue
+		}
+		d := &due[i]
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			s.deliver(hook, d)
+			if s.ctx.Err() != nil {
+				return
+			}
+			if err := s.dataMgr.UpdateV3WebhookDelivery(d); err != nil {
+				log.Logger.Error("webhook update delivery", zap.Uint64("id", d.Id), zap.Error(err))
+				return
+			}
+			if d.Status == database.WebhookDelivered {
+				atomic.AddInt32(&delivered, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// webhook已删除的记录不再推送，删除后不会占满下一批
+	for id := range orphaned {
+		if err := s.dataMgr.DeleteV3WebhookDeliveries(id); err != nil {
+			return int(delivered), err
+		}
+		log.Logger.Warn("webhook deleted, drop deliveries", zap.Uint64("webhook", id))
+	}
+	return int(delivered), nil
+}
+
+// deliver 推送一次并更新记录的状态
+func (s *Service) deliver(hook *database.V3Webhook, d *database.V3WebhookDelivery) {
+	d.Attempts++
+	now := time.Now()
+	d.UpdatedAt = now
+
+	code, err := s.post(hook, d)
+	d.ResponseCode = code
+	if err == nil {
+		d.Status = database.WebhookDelivered
+		d.LastError = ""
+		return
+	}
+
+	d.LastError = err.Error()
+	if len(d.LastError) > maxErrorLen {
+		d.LastError = d.LastError[:maxErrorLen]
+	}
+	if d.Attempts >= s.maxAttempts {
+		d.Status = database.WebhookDead
+		log.Logger.Warn("webhook delivery dead", zap.Uint64("webhook", hook.Id), zap.Uint64("id", d.Id), zap.String("error", d.LastError))
+		return
+	}
+	d.NextAttemptAt = now.Add(s.backoff(d.Attempts))
+}
+
+func (s *Service) post(hook *database.V3Webhook, d *database.V3WebhookDelivery) (int, error) {
+	body := []byte(d.Payload)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodPost, hook.URL, strings.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderWebhook, strconv.FormatUint(hook.Id, 10))
+	req.Header.Set(HeaderDelivery, strconv.FormatUint(d.Id, 10))
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, "sha256="+Sign(hook.Secret, timestamp, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, maxRespBody))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff 第attempts次失败后的重试间隔
+func (s *Service) backoff(attempts int) time.Duration {
+	delay := s.retryBase
+	for i := 1; i < attempts && delay < retryMaximum; i++ {
+		delay *= 2
+	}
+	if delay > retryMaximum {
+		delay = retryMaximum
+	}
+	return delay
+}
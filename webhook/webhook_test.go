@@ -0,0 +1,285 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/toolglobal/api/client"
+	"github.com/toolglobal/api/config"
+	"github.com/toolglobal/api/database"
+	"github.com/toolglobal/api/datamanager"
+	"github.com/toolglobal/api/datamanager/datamanagertest"
+	"github.com/toolglobal/api/libs/bus"
+)
+
+const (
+	alice = datamanagertest.Alice
+	bob   = datamanagertest.Bob
+	usdt  = datamanagertest.USDT
+)
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func deliveries(t *testing.T, dataM *datamanager.DataManager, webhookId uint64, status string) []database.V3WebhookDelivery {
+	result, err := dataM.QueryV3WebhookDeliveries(webhookId, status, nil, "ASC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestService(t *testing.T) {
+	dataM := datamanagertest.New(t)
+	datamanagertest.SaveBlocks(t, dataM, 1, 2, client.IndexerVersion)
+
+	// /ok第一次返回500，之后成功；/dead在recovered之前一直返回500
+	var (
+		mu        sync.Mutex
+		received  []Notification
+		okCalls   int32
+		recovered int32
+	)
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Header.Get(HeaderSignature) != "sha256="+Sign("secret-"+r.URL.Path, r.Header.Get(HeaderTimestamp), body) {
+			t.Errorf("%s: bad signature", r.URL.Path)
+		}
+		switch r.URL.Path {
+		case "/ok":
+			if atomic.AddInt32(&okCalls, 1) == 1 {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			var n Notification
+			if err := json.Unmarshal(body, &n); err != nil {
+				t.Error(err)
+			}
+			mu.Lock()
+			received = append(received, n)
+			mu.Unlock()
+		case "/dead":
+			if atomic.LoadInt32(&recovered) == 0 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}
+	}))
+	defer stub.Close()
+
+	ok := &database.V3Webhook{URL: stub.URL + "/ok", Secret: "secret-/ok", Address: alice, CreatedAt: time.Now()}
+	dead := &database.V3Webhook{URL: stub.URL + "/dead", Secret: "secret-/dead", Contract: usdt, Event: "Transfer", CreatedAt: time.Now()}
+	for _, hook := range []*database.V3Webhook{ok, dead} {
+		id, err := dataM.AddV3Webhook(hook)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hook.Id = id
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	b := bus.New()
+	s := New(ctx, dataM, b, config.Webhook{MaxAttempts: 2})
+	s.retryBase = 10 * time.Millisecond
+	s.pollInterval = 20 * time.Millisecond
+	done := make(chan struct{})
+	go func() {
+		s.Start()
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// 首次开启从已同步高度开始，不推送历史区块
+	waitFor(t, "cursor", func() bool {
+		state, _ := dataM.QuerySyncState(SyncStateName)
+		return state != nil && state.Height == 2
+	})
+
+	datamanagertest.SaveBlocks(t, dataM, 3, 3, client.IndexerVersion)
+	b.Publish(datamanagertest.Block(3))
+
+	// alice的交易和两笔转账，第一次推送失败后重试成功
+	waitFor(t, "ok delivered", func() bool { return len(deliveries(t, dataM, ok.Id, database.WebhookDelivered)) == 3 })
+	delivered := deliveries(t, dataM, ok.Id, "")
+	var attempts int
+	for _, d := range delivered {
+		attempts += d.Attempts
+		if d.ResponseCode != http.StatusOK || d.LastError != "" {
+			t.Fatalf("ok delivery %+v", d)
+		}
+	}
+	if len(delivered) != 3 || delivered[0].Kind != database.WebhookKindTransaction || delivered[1].Kind != database.WebhookKindPayment || attempts != 4 {
+		t.Fatalf("ok deliveries %+v", delivered)
+	}
+	// 并发推送，到达顺序不固定
+	mu.Lock()
+	kinds := make(map[string]int)
+	for _, n := range received {
+		if n.WebhookId != ok.Id || n.Height != 3 {
+			t.Fatalf("received %+v", n)
+		}
+		kinds[n.Kind+"/"+n.Hash]++
+	}
+	mu.Unlock()
+	if len(kinds) != 3 || kinds["transaction/0x0301"] != 1 || kinds["payment/0x0301"] != 1 || kinds["payment/0x0302"] != 1 {
+		t.Fatalf("received %v", kinds)
+	}
+
+	// USDT Transfer推送两次失败后进入死信
+	waitFor(t, "dead letter", func() bool { return len(deliveries(t, dataM, dead.Id, database.WebhookDead)) == 1 })
+	dl := deliveries(t, dataM, dead.Id, "")
+	if len(dl) != 1 || dl[0].Hash != "0x0302" || dl[0].Attempts != 2 || dl[0].ResponseCode != http.StatusServiceUnavailable || dl[0].LastError == "" {
+		t.Fatalf("dead deliveries %+v", dl)
+	}
+
+	// 重新推送死信
+	atomic.StoreInt32(&recovered, 1)
+	if retried, err := dataM.RetryV3WebhookDelivery(dead.Id, dl[0].Id); err != nil || !retried {
+		t.Fatalf("retry %v %v", retried, err)
+	}
+	waitFor(t, "retried", func() bool { return len(deliveries(t, dataM, dead.Id, database.WebhookDelivered)) == 1 })
+}
+
+func TestService_Rollback(t *testing.T) {
+	dataM := datamanagertest.New(t)
+	datamanagertest.SaveBlocks(t, dataM, 1, 3, client.IndexerVersion)
+
+	hook := &database.V3Webhook{URL: "http://127.0.0.1:0/hook", Secret: "s", Address: alice, CreatedAt: time.Now()}
+	id, err := dataM.AddV3Webhook(hook)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dataM.AddV3WebhookDeliveries(SyncStateName, 1, fmt.Sprintf("%064X", 1), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	s := New(context.Background(), dataM, bus.New(), config.Webhook{})
+	if err := s.match(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(deliveries(t, dataM, id, database.WebhookPending)); n != 6 {
+		t.Fatalf("pending %d", n)
+	}
+
+	// 回滚删除未推送的记录并回退推送进度
+	if err := dataM.RollbackV3(2); err != nil {
+		t.Fatal(err)
+	}
+	pending := deliveries(t, dataM, id, database.WebhookPending)
+	if len(pending) != 3 || pending[2].Height != 2 {
+		t.Fatalf("pending %+v", pending)
+	}
+	if state, _ := dataM.QuerySyncState(SyncStateName); state == nil || state.Height != 2 {
+		t.Fatalf("cursor %+v", state)
+	}
+
+	// 区块hash不一致时不写入
+	if err := dataM.AddV3WebhookDeliveries(SyncStateName, 2, "stale", pending); err == nil {
+		t.Fatal("stale block accepted")
+	}
+}
+
+func TestService_DeletedWebhook(t *testing.T) {
+	dataM := datamanagertest.New(t)
+	datamanagertest.SaveBlocks(t, dataM, 1, 2, client.IndexerVersion)
+
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer stub.Close()
+	hook := database.V3Webhook{URL: stub.URL, Secret: "s", Address: alice, CreatedAt: time.Now()}
+	id, err := dataM.AddV3Webhook(&hook)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook.Id = id
+
+	// 匹配期间删除的webhook不写入推送记录
+	deleted := hook
+	deleted.Id = id + 1
+	heights := []int64{2}
+	txs, err := dataM.QueryV3TxsByHeights(heights)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payments, err := dataM.QueryV3PaymentsByHeights(heights)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds, err := matchBlocks([]database.V3Webhook{hook, deleted}, heights, txs, payments, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dataM.AddV3WebhookDeliveries(SyncStateName, 2, fmt.Sprintf("%064X", 2), ds); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(deliveries(t, dataM, deleted.Id, "")); n != 0 {
+		t.Fatalf("deleted webhook got %d deliveries", n)
+	}
+
+	// 返回推送成功的记录数
+	s := New(context.Background(), dataM, bus.New(), config.Webhook{})
+	n, err := s.send()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 || len(deliveries(t, dataM, id, database.WebhookDelivered)) != 3 {
+		t.Fatalf("sent %d", n)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	ev := datamanagertest.Block(1)
+	cases := []struct {
+		name     string
+		hook     database.V3Webhook
+		txs, pms int
+	}{
+		{"all", database.V3Webhook{}, 2, 2},
+		{"address", database.V3Webhook{Address: "0x00000000000000000000000000000000000a11ce"}, 1, 2},
+		{"contract", database.V3Webhook{Contract: usdt}, 1, 1},
+		{"event", database.V3Webhook{Event: "Transfer"}, 0, 1},
+		{"address and contract", database.V3Webhook{Address: alice, Contract: usdt}, 0, 1},
+		{"other", database.V3Webhook{Address: usdt[:len(usdt)-1] + "2"}, 0, 0},
+	}
+	for _, c := range cases {
+		var txs, pms int
+		for i := range ev.Transactions {
+			if matchTx(&c.hook, &ev.Transactions[i]) {
+				txs++
+			}
+		}
+		for i := range ev.Payments {
+			if matchPayment(&c.hook, &ev.Payments[i]) {
+				pms++
+			}
+		}
+		if txs != c.txs || pms != c.pms {
+			t.Fatalf("%s: got %d txs %d payments", c.name, txs, pms)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	s := New(context.Background(), nil, nil, config.Webhook{})
+	for attempts, want := range map[int]time.Duration{1: 10 * time.Second, 2: 20 * time.Second, 4: 80 * time.Second, 20: time.Hour} {
+		if got := s.backoff(attempts); got != want {
+			t.Fatalf("backoff(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}